@@ -0,0 +1,376 @@
+// Package harness runs a tiny stand-in for sqld so the examples can run
+// without a database account. It speaks enough of Hrana over HTTP for the
+// examples, backed by a SQLite file in a temporary directory. It is not a
+// replacement for sqld.
+package harness
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+// Server is a running harness.
+type Server struct {
+	// URL is the URL to pass to sql.Open("libsql", ...).
+	URL string
+
+	http *httptest.Server
+	dir  string
+	db   *sql.DB
+
+	mu        sync.Mutex
+	streams   map[string]*sql.Conn
+	nextBaton int
+}
+
+// URL returns the database URL from the LIBSQL_URL environment variable if it
+// is set, so the examples can run against a real server. Otherwise it starts a
+// harness and returns its URL. The returned function stops the harness.
+func URL() (string, func(), error) {
+	if url := os.Getenv("LIBSQL_URL"); url != "" {
+		return url, func() {}, nil
+	}
+	s, err := Start()
+	if err != nil {
+		return "", nil, err
+	}
+	return s.URL, s.Close, nil
+}
+
+// Start starts a harness with an empty database.
+func Start() (*Server, error) {
+	dir, err := os.MkdirTemp("", "libsql-harness")
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", filepath.Join(dir, "db.sqlite"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	s := &Server{dir: dir, db: db, streams: map[string]*sql.Conn{}}
+	s.http = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.http.URL
+	return s, nil
+}
+
+// Close stops the harness and deletes its database.
+func (s *Server) Close() {
+	s.http.Close()
+	s.mu.Lock()
+	for _, c := range s.streams {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.db.Close()
+	os.RemoveAll(s.dir)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v2":
+	case r.Method == "GET" && r.URL.Path == "/version":
+		fmt.Fprintln(w, "harness")
+	case r.Method == "POST" && r.URL.Path == "/v2/pipeline":
+		s.servePipeline(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+type value struct {
+	Type   string          `json:"type"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Base64 string          `json:"base64,omitempty"`
+}
+
+type stmt struct {
+	Sql       string  `json:"sql"`
+	Args      []value `json:"args"`
+	NamedArgs []struct {
+		Name  string `json:"name"`
+		Value value  `json:"value"`
+	} `json:"named_args"`
+	WantRows bool `json:"want_rows"`
+}
+
+type condition struct {
+	Type  string      `json:"type"`
+	Step  int         `json:"step"`
+	Cond  *condition  `json:"cond"`
+	Conds []condition `json:"conds"`
+}
+
+type request struct {
+	Type  string `json:"type"`
+	Stmt  *stmt  `json:"stmt"`
+	Batch *struct {
+		Steps []struct {
+			Condition *condition `json:"condition"`
+			Stmt      stmt       `json:"stmt"`
+		} `json:"steps"`
+	} `json:"batch"`
+}
+
+type column struct {
+	Name     string `json:"name"`
+	DeclType string `json:"decltype"`
+}
+
+type stmtResult struct {
+	Cols             []column  `json:"cols"`
+	Rows             [][]value `json:"rows"`
+	AffectedRowCount int64     `json:"affected_row_count"`
+	LastInsertRowId  *string   `json:"last_insert_rowid"`
+}
+
+type hranaError struct {
+	Message string `json:"message"`
+}
+
+type batchResult struct {
+	StepResults []*stmtResult `json:"step_results"`
+	StepErrors  []*hranaError `json:"step_errors"`
+}
+
+func (s *Server) servePipeline(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Baton    string    `json:"baton"`
+		Requests []request `json:"requests"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	conn, err := s.stream(ctx, req.Baton)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": err.Error(), "code": "STREAM_EXPIRED"})
+		return
+	}
+
+	closed := false
+	results := []map[string]any{}
+	for _, request := range req.Requests {
+		var response any
+		var err error
+		switch request.Type {
+		case "execute":
+			var res *stmtResult
+			if res, err = execute(ctx, conn, request.Stmt); err == nil {
+				response = map[string]any{"type": "execute", "result": res}
+			}
+		case "batch":
+			response = map[string]any{"type": "batch", "result": executeBatch(ctx, conn, &request)}
+		case "close":
+			closed = true
+			response = map[string]any{"type": "close"}
+		default:
+			err = fmt.Errorf("request type %s is not supported by the harness", request.Type)
+		}
+		if err != nil {
+			results = append(results, map[string]any{"type": "error", "error": hranaError{Message: err.Error()}})
+		} else {
+			results = append(results, map[string]any{"type": "ok", "response": response})
+		}
+	}
+
+	var baton *string
+	if closed {
+		conn.Close()
+	} else {
+		b := s.keep(conn)
+		baton = &b
+	}
+	json.NewEncoder(w).Encode(map[string]any{"baton": baton, "base_url": nil, "results": results})
+}
+
+// stream returns the connection of the stream identified by baton, or a new
+// connection if baton is empty.
+func (s *Server) stream(ctx context.Context, baton string) (*sql.Conn, error) {
+	if baton == "" {
+		return s.db.Conn(ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn, ok := s.streams[baton]
+	if !ok {
+		return nil, fmt.Errorf("stream %s expired", baton)
+	}
+	delete(s.streams, baton)
+	return conn, nil
+}
+
+func (s *Server) keep(conn *sql.Conn) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextBaton++
+	baton := strconv.Itoa(s.nextBaton)
+	s.streams[baton] = conn
+	return baton
+}
+
+func executeBatch(ctx context.Context, conn *sql.Conn, request *request) *batchResult {
+	steps := request.Batch.Steps
+	res := &batchResult{StepResults: make([]*stmtResult, len(steps)), StepErrors: make([]*hranaError, len(steps))}
+	executed := make([]bool, len(steps))
+	var eval func(c *condition) bool
+	eval = func(c *condition) bool {
+		switch c.Type {
+		case "ok":
+			return executed[c.Step] && res.StepErrors[c.Step] == nil
+		case "error":
+			return executed[c.Step] && res.StepErrors[c.Step] != nil
+		case "not":
+			return !eval(c.Cond)
+		case "and":
+			for idx := range c.Conds {
+				if !eval(&c.Conds[idx]) {
+					return false
+				}
+			}
+			return true
+		case "or":
+			for idx := range c.Conds {
+				if eval(&c.Conds[idx]) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for idx, step := range steps {
+		if step.Condition != nil && !eval(step.Condition) {
+			continue
+		}
+		executed[idx] = true
+		stmt := step.Stmt
+		result, err := execute(ctx, conn, &stmt)
+		if err != nil {
+			res.StepErrors[idx] = &hranaError{Message: err.Error()}
+			continue
+		}
+		res.StepResults[idx] = result
+	}
+	return res
+}
+
+func execute(ctx context.Context, conn *sql.Conn, stmt *stmt) (*stmtResult, error) {
+	var args []any
+	for _, v := range stmt.Args {
+		arg, err := decode(v)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	for _, named := range stmt.NamedArgs {
+		arg, err := decode(named.Value)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, sql.Named(strings.TrimLeft(named.Name, ":@$"), arg))
+	}
+
+	var changesBefore int64
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes()").Scan(&changesBefore); err != nil {
+		return nil, err
+	}
+	rows, err := conn.QueryContext(ctx, stmt.Sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	res := &stmtResult{Cols: []column{}, Rows: [][]value{}}
+	for _, t := range types {
+		res.Cols = append(res.Cols, column{Name: t.Name(), DeclType: t.DatabaseTypeName()})
+	}
+	for rows.Next() {
+		dest := make([]any, len(types))
+		ptrs := make([]any, len(types))
+		for idx := range dest {
+			ptrs[idx] = &dest[idx]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make([]value, len(dest))
+		for idx, v := range dest {
+			row[idx] = encode(v)
+		}
+		if stmt.WantRows {
+			res.Rows = append(res.Rows, row)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	var changesAfter, lastInsertRowId int64
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes(), last_insert_rowid()").Scan(&changesAfter, &lastInsertRowId); err != nil {
+		return nil, err
+	}
+	res.AffectedRowCount = changesAfter - changesBefore
+	id := strconv.FormatInt(lastInsertRowId, 10)
+	res.LastInsertRowId = &id
+	return res, nil
+}
+
+func decode(v value) (any, error) {
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "integer":
+		var s string
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case "float":
+		var f float64
+		err := json.Unmarshal(v.Value, &f)
+		return f, err
+	case "text":
+		var s string
+		err := json.Unmarshal(v.Value, &s)
+		return s, err
+	case "blob":
+		return base64.RawStdEncoding.DecodeString(v.Base64)
+	}
+	return nil, fmt.Errorf("unknown value type %s", v.Type)
+}
+
+func encode(v any) value {
+	switch v := v.(type) {
+	case int64:
+		raw, _ := json.Marshal(strconv.FormatInt(v, 10))
+		return value{Type: "integer", Value: raw}
+	case float64:
+		raw, _ := json.Marshal(v)
+		return value{Type: "float", Value: raw}
+	case string:
+		raw, _ := json.Marshal(v)
+		return value{Type: "text", Value: raw}
+	case []byte:
+		return value{Type: "blob", Base64: base64.RawStdEncoding.EncodeToString(v)}
+	}
+	return value{Type: "null"}
+}
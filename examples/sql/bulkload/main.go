@@ -0,0 +1,51 @@
+// Bulk load loads rows with libsql.BulkInsert, which groups them into
+// multi-row INSERT statements instead of sending one request per row.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/libsql/libsql-client-go/examples/internal/harness"
+	"github.com/libsql/libsql-client-go/libsql"
+)
+
+func main() {
+	dbUrl, stop, err := harness.URL()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start harness: %s", err)
+		os.Exit(1)
+	}
+	defer stop()
+
+	db, err := sql.Open("libsql", dbUrl)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open db %s: %s", dbUrl, err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS measurements(sensor TEXT, value REAL)"); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create table: %s", err)
+		os.Exit(1)
+	}
+
+	rows := make([][]any, 10000)
+	for i := range rows {
+		rows[i] = []any{fmt.Sprintf("sensor-%d", i%10), float64(i) / 10}
+	}
+	n, err := libsql.BulkInsert(ctx, db, "measurements", []string{"sensor", "value"}, libsql.SliceRows(rows), nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to insert rows: %s", err)
+		os.Exit(1)
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT count(*) FROM measurements").Scan(&count); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to count rows: %s", err)
+		os.Exit(1)
+	}
+	fmt.Printf("inserted %d rows, table has %d rows\n", n, count)
+}
@@ -0,0 +1,70 @@
+// Metrics records per-statement metrics and reports slow transactions through
+// a diagnostics hook, then prints the metrics in the Prometheus text format.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/libsql/libsql-client-go/examples/internal/harness"
+	"github.com/libsql/libsql-client-go/libsql"
+)
+
+type logDiagnostics struct{}
+
+func (logDiagnostics) Report(d libsql.Diagnostic) {
+	fmt.Printf("diagnostic: %s\n", d.Message)
+}
+
+func main() {
+	dbUrl, stop, err := harness.URL()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start harness: %s", err)
+		os.Exit(1)
+	}
+	defer stop()
+
+	connector, err := libsql.NewConnector(dbUrl,
+		libsql.WithMetrics(),
+		libsql.WithDiagnostics(logDiagnostics{}),
+		libsql.WithLongTransactionThreshold(10*time.Millisecond),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create connector: %s", err)
+		os.Exit(1)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS events(name TEXT)"); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create table: %s", err)
+		os.Exit(1)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := db.ExecContext(ctx, "INSERT INTO events VALUES(?)", fmt.Sprintf("event-%d", i)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to insert event: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start a transaction: %s", err)
+		os.Exit(1)
+	}
+	// Holding the transaction open triggers the long transaction diagnostic.
+	time.Sleep(20 * time.Millisecond)
+	if err := tx.Commit(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to commit: %s", err)
+		os.Exit(1)
+	}
+
+	if err := libsql.WriteStatementMetrics(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write metrics: %s", err)
+		os.Exit(1)
+	}
+}
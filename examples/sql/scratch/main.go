@@ -0,0 +1,69 @@
+// Scratch stages data in an in-memory database attached to a single remote
+// connection, the way temporary tables are used with a local database.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/libsql/libsql-client-go/examples/internal/harness"
+	"github.com/libsql/libsql-client-go/libsql"
+)
+
+func main() {
+	dbUrl, stop, err := harness.URL()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start harness: %s", err)
+		os.Exit(1)
+	}
+	defer stop()
+
+	db, err := sql.Open("libsql", dbUrl)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open db %s: %s", dbUrl, err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	for _, stmt := range []string{
+		"DROP TABLE IF EXISTS users",
+		"CREATE TABLE users(id INT PRIMARY KEY, name TEXT, active INT)",
+		"INSERT INTO users VALUES(1, 'alice', 1), (2, 'bob', 0), (3, 'carol', 1)",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to execute statement %s: %s", stmt, err)
+			os.Exit(1)
+		}
+	}
+
+	scratch, err := libsql.OpenScratch(ctx, db, "scratch")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open scratch database: %s", err)
+		os.Exit(1)
+	}
+	defer scratch.Close()
+	if _, err := scratch.ExecContext(ctx, "CREATE TABLE scratch.active AS SELECT id, name FROM users WHERE active"); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to stage users: %s", err)
+		os.Exit(1)
+	}
+	rows, err := scratch.QueryContext(ctx, "SELECT name FROM scratch.active ORDER BY id")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to query staged users: %s", err)
+		os.Exit(1)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to scan row: %s", err)
+			os.Exit(1)
+		}
+		fmt.Println(name)
+	}
+	if err := rows.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "errors from query: %s", err)
+		os.Exit(1)
+	}
+}
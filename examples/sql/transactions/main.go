@@ -0,0 +1,104 @@
+// Transactions moves money between accounts with an interactive transaction,
+// then records an audit entry with a buffered transaction which is sent as a
+// single atomic batch on Commit.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/libsql/libsql-client-go/examples/internal/harness"
+	"github.com/libsql/libsql-client-go/libsql"
+)
+
+func transfer(ctx context.Context, db *sql.DB, from, to string, amount int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Roll back if anything below fails, after Commit this is a no-op.
+	defer tx.Rollback()
+
+	var balance int
+	if err := tx.QueryRowContext(ctx, "SELECT balance FROM accounts WHERE name = ?", from).Scan(&balance); err != nil {
+		return err
+	}
+	if balance < amount {
+		return fmt.Errorf("%s has %d, cannot transfer %d", from, balance, amount)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE accounts SET balance = balance - ? WHERE name = ?", amount, from); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE accounts SET balance = balance + ? WHERE name = ?", amount, to); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func audit(ctx context.Context, db *sql.DB, message string) error {
+	tx, err := db.BeginTx(libsql.WithBufferedTransaction(ctx), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "INSERT INTO audit(message) VALUES(?)", message); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE stats SET transfers = transfers + 1"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func main() {
+	dbUrl, stop, err := harness.URL()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start harness: %s", err)
+		os.Exit(1)
+	}
+	defer stop()
+
+	db, err := sql.Open("libsql", dbUrl)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open db %s: %s", dbUrl, err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	setup := []string{
+		"DROP TABLE IF EXISTS accounts",
+		"DROP TABLE IF EXISTS audit",
+		"DROP TABLE IF EXISTS stats",
+		"CREATE TABLE accounts(name TEXT PRIMARY KEY, balance INT)",
+		"CREATE TABLE audit(message TEXT)",
+		"CREATE TABLE stats(transfers INT)",
+		"INSERT INTO accounts VALUES('alice', 100), ('bob', 0)",
+		"INSERT INTO stats VALUES(0)",
+	}
+	for _, stmt := range setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to execute statement %s: %s", stmt, err)
+			os.Exit(1)
+		}
+	}
+
+	for _, amount := range []int{60, 60} {
+		if err := transfer(ctx, db, "alice", "bob", amount); err != nil {
+			fmt.Printf("transfer of %d failed: %s\n", amount, err)
+			continue
+		}
+		if err := audit(ctx, db, fmt.Sprintf("alice sent %d to bob", amount)); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to record transfer: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	var transfers int
+	if err := db.QueryRowContext(ctx, "SELECT transfers FROM stats").Scan(&transfers); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read stats: %s", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d transfers recorded\n", transfers)
+}
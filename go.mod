@@ -1,6 +1,6 @@
 module github.com/libsql/libsql-client-go
 
-go 1.19
+go 1.21
 
 require (
 	github.com/antlr/antlr4/runtime/Go/antlr/v4 v4.0.0-20230512164433-5d1fd1a340c9
@@ -0,0 +1,10 @@
+package libsql
+
+import "github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+
+// ErrArgs is wrapped by the errors of queries run with arguments that do not
+// match their placeholders: too few or too many positional arguments, named
+// arguments without a placeholder of that name, or named placeholders without
+// an argument. The mismatch is reported before the query is sent, with the
+// placeholders involved, instead of failing on the server.
+var ErrArgs = shared.ErrArgs
@@ -0,0 +1,51 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestArgsMismatch(t *testing.T) {
+	url, received := newNullServer(t)
+	db, err := sql.Open("libsql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	tests := []struct {
+		query   string
+		args    []any
+		wantErr string
+	}{
+		{query: "SELECT ?, ?", args: []any{1}, wantErr: "needs 2 arguments, got 1"},
+		{query: "SELECT ?; SELECT ?", args: []any{1, 2, 3}, wantErr: "needs 2 arguments, got 3"},
+		{query: "SELECT :id", args: []any{sql.Named("ID", 1)}, wantErr: "no parameters named ID"},
+		{query: "SELECT :a, :b", args: []any{sql.Named("a", 1)}, wantErr: "missing arguments for the parameters b"},
+	}
+	for _, tt := range tests {
+		_, err := db.ExecContext(ctx, tt.query, tt.args...)
+		if !errors.Is(err, ErrArgs) || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), tt.query) {
+			t.Errorf("%s: got error %v, want %#v", tt.query, err, tt.wantErr)
+		}
+	}
+
+	stmt, err := db.PrepareContext(ctx, "SELECT :a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.ExecContext(ctx, sql.Named("b", 1)); !errors.Is(err, ErrArgs) {
+		t.Errorf("got error %v from a prepared statement, want ErrArgs", err)
+	}
+	if got := received(); len(got) != 0 {
+		t.Errorf("got %d statements sent to the server, want none", len(got))
+	}
+	if _, err := stmt.ExecContext(ctx, sql.Named("a", 1)); err != nil {
+		t.Fatal(err)
+	}
+}
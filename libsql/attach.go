@@ -0,0 +1,125 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// statePinner is implemented by transports that can keep the session state of
+// a connection, like attached databases and temporary tables. Once pinned, a
+// connection fails with driver.ErrBadConn instead of silently reconnecting and
+// losing that state.
+type statePinner interface {
+	PinState(pinned bool)
+}
+
+var errStateless = errors.New("the server does not keep connection state between requests")
+
+// attachment is a database attached to every connection when it is opened.
+type attachment struct {
+	alias    string
+	database string
+}
+
+// WithAttachment attaches database as schema alias to every connection when it
+// is opened, before any transaction. With sqld, database names another database
+// of the deployment, on deployments that allow attaching it.
+func WithAttachment(alias, database string) Option {
+	return option(func(c *Connector) error {
+		if !schemaNameRegexp.MatchString(alias) {
+			return fmt.Errorf("invalid attachment alias %#v", alias)
+		}
+		c.attachments = append(c.attachments, attachment{alias: alias, database: database})
+		return nil
+	})
+}
+
+// attach runs the ATTACH statements of the attachments of the connector on c.
+func (c *Connector) attach(ctx context.Context, dc driver.Conn) error {
+	for _, a := range c.attachments {
+		query := fmt.Sprintf("ATTACH DATABASE '%s' AS %s", strings.ReplaceAll(a.database, "'", "''"), quoteIdentifier(a.alias))
+		e, ok := dc.(driver.ExecerContext)
+		if !ok {
+			return fmt.Errorf("failed to attach %s: the connection cannot execute statements", a.alias)
+		}
+		if _, err := e.ExecContext(ctx, query, nil); err != nil {
+			return fmt.Errorf("failed to attach %s: %w", a.alias, err)
+		}
+	}
+	return nil
+}
+
+// attachDelta returns 1 for an ATTACH statement, -1 for a DETACH statement and
+// 0 for any other query.
+func attachDelta(query string) int {
+	switch strings.ToUpper(leadingKeyword(query)) {
+	case "ATTACH":
+		return 1
+	case "DETACH":
+		return -1
+	}
+	return 0
+}
+
+// leadingKeyword returns the first word of query, after whitespace and
+// comments.
+func leadingKeyword(query string) string {
+	query = trimLeadingComments(query)
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end < 0 {
+		return query
+	}
+	return query[:end]
+}
+
+// trimLeadingComments removes the whitespace and comments query starts with.
+func trimLeadingComments(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n\f")
+		switch {
+		case strings.HasPrefix(query, "--"):
+			end := strings.IndexByte(query, '\n')
+			if end < 0 {
+				return ""
+			}
+			query = query[end+1:]
+		case strings.HasPrefix(query, "/*"):
+			end := strings.Index(query[2:], "*/")
+			if end < 0 {
+				return ""
+			}
+			query = query[end+4:]
+		default:
+			return query
+		}
+	}
+}
+
+// execAttach runs an ATTACH or DETACH statement with exec. The transport is
+// pinned while databases are attached, so that the attachments are not lost
+// to a reconnect.
+func (c *Conn) execAttach(delta int, exec func() (driver.Result, error)) (driver.Result, error) {
+	p, ok := c.transport.(statePinner)
+	if !ok {
+		return nil, errStateless
+	}
+	if delta > 0 {
+		p.PinState(true)
+	}
+	res, err := exec()
+	if err == nil {
+		c.attached += delta
+		if c.attached < 0 {
+			c.attached = 0
+		}
+	}
+	if c.attached == 0 {
+		p.PinState(false)
+	}
+	return res, err
+}
@@ -0,0 +1,90 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestAttachDelta(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{query: "ATTACH DATABASE ? AS aux", want: 1},
+		{query: "  -- aux\n/* analytics */ attach 'analytics' AS aux", want: 1},
+		{query: "DETACH aux", want: -1},
+		{query: "SELECT 'ATTACH'", want: 0},
+		{query: "/* unterminated", want: 0},
+		{query: "", want: 0},
+	}
+	for _, tt := range tests {
+		if got := attachDelta(tt.query); got != tt.want {
+			t.Errorf("attachDelta(%#v) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAttachPinsConnection(t *testing.T) {
+	transport := &pinnableConn{}
+	db := sql.OpenDB(transportConnector{transport})
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for _, query := range []string{"ATTACH DATABASE ? AS aux", "ATTACH DATABASE ? AS other"} {
+		if _, err := db.ExecContext(ctx, query, "analytics"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, "DETACH aux"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(transport.pins, []bool{true, true}) {
+		t.Errorf("got pins %v, want the connection pinned while a database is attached", transport.pins)
+	}
+	if _, err := db.ExecContext(ctx, "DETACH other"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(transport.pins, []bool{true, true, false}) {
+		t.Errorf("got pins %v, want the connection unpinned once every database is detached", transport.pins)
+	}
+
+	tx, err := db.BeginTx(WithBufferedTransaction(ctx), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "ATTACH DATABASE 'analytics' AS aux"); err == nil {
+		t.Error("expected ATTACH to be rejected in a buffered transaction")
+	}
+}
+
+func TestAttachStateless(t *testing.T) {
+	db := sql.OpenDB(transportConnector{&statelessConn{}})
+	defer db.Close()
+	if _, err := db.Exec("ATTACH DATABASE 'analytics' AS aux"); err == nil {
+		t.Error("expected error for a transport that does not keep state")
+	}
+}
+
+func TestWithAttachment(t *testing.T) {
+	if _, err := NewConnector("libsql://db.turso.io", WithAttachment("aux; DROP TABLE t", "analytics")); err == nil {
+		t.Error("expected error for an invalid alias")
+	}
+	connector, err := NewConnector("libsql://db.turso.io", WithAttachment("aux", "it's"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &pinnableConn{}
+	if err := connector.attach(context.Background(), newConn(transport, connector)); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`ATTACH DATABASE 'it''s' AS "aux"`}; !reflect.DeepEqual(transport.queries, want) {
+		t.Errorf("got queries %#v, want %#v", transport.queries, want)
+	}
+	if !reflect.DeepEqual(transport.pins, []bool{true}) {
+		t.Errorf("got pins %v, want the connection pinned", transport.pins)
+	}
+}
@@ -0,0 +1,22 @@
+package libsql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+)
+
+// WithAuthTokenProvider fetches auth tokens from provider instead of taking one
+// from the URL. A token is reused until it is a minute away from the expiry in
+// its exp claim, or until the server rejects it, so tokens that expire do not
+// require restarting the application.
+func WithAuthTokenProvider(provider func(ctx context.Context) (string, error)) Option {
+	return option(func(c *Connector) error {
+		if token, _ := c.token.Get(context.Background()); token != "" {
+			return fmt.Errorf("an auth token provider cannot be used with an auth token in the URL")
+		}
+		c.token = auth.FromProvider(provider)
+		return nil
+	})
+}
@@ -0,0 +1,86 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type BlobOptions struct {
+	// ChunkSize is the number of bytes sent by each statement. It defaults to
+	// 1 MiB, which keeps requests well below the payload limits of sqld.
+	ChunkSize int
+}
+
+const defaultBlobChunkSize = 1 << 20
+
+// WriteBlob stores the bytes read from r in column of the row of table with
+// the given rowid. Hrana cannot stream parameters, and a blob of several
+// megabytes bound to a single statement makes a request that proxies and
+// servers may refuse, so the blob is sent in chunks: the first one replaces the
+// value of the column and the others are appended to it, each by its own
+// UPDATE statement. All statements run in a single transaction, so the column
+// holds either the whole blob or its previous value. The table name is quoted
+// like by BulkInsert. It returns the number of bytes written, and fails with
+// sql.ErrNoRows if table has no row with that rowid.
+//
+// A new row is written by inserting it with any value in the column first:
+//
+//	res, err := db.ExecContext(ctx, "INSERT INTO files (name, data) VALUES (?, NULL)", name)
+//	// ...
+//	id, err := res.LastInsertId()
+//	// ...
+//	n, err := libsql.WriteBlob(ctx, db, "files", "data", id, f, nil)
+func WriteBlob(ctx context.Context, db *sql.DB, table, column string, rowid int64, r io.Reader, opts *BlobOptions) (int64, error) {
+	if opts == nil {
+		opts = &BlobOptions{}
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultBlobChunkSize
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin blob write: %w", err)
+	}
+	defer tx.Rollback()
+
+	quotedTable, quotedColumn := quoteTable(table), quoteIdentifier(column)
+	set := fmt.Sprintf("UPDATE %s SET %s = ? WHERE rowid = ?", quotedTable, quotedColumn)
+	// || makes a text of the bytes of its blob operands, which the cast turns
+	// back into a blob without conversion.
+	appendChunk := fmt.Sprintf("UPDATE %s SET %s = CAST(%s || ? AS BLOB) WHERE rowid = ?", quotedTable, quotedColumn, quotedColumn)
+	var written int64
+	for query := set; ; query = appendChunk {
+		// Every chunk gets its own buffer, the arguments of buffered
+		// transactions are kept until they are committed.
+		chunk := make([]byte, chunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, fmt.Errorf("failed to read blob: %w", err)
+		}
+		if n == 0 && query == appendChunk {
+			break
+		}
+		res, execErr := tx.ExecContext(ctx, query, chunk[:n], rowid)
+		if execErr != nil {
+			return 0, fmt.Errorf("failed to write bytes %d to %d of blob: %w", written, written+int64(n), execErr)
+		}
+		if query == set {
+			if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+				return 0, fmt.Errorf("no row with rowid %d in %s: %w", rowid, table, sql.ErrNoRows)
+			}
+		}
+		written += int64(n)
+		if n < chunkSize {
+			break
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit blob write: %w", err)
+	}
+	return written, nil
+}
@@ -0,0 +1,90 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+const (
+	setBlob    = `UPDATE "main"."files" SET "data" = ? WHERE rowid = ?`
+	appendBlob = `UPDATE "main"."files" SET "data" = CAST("data" || ? AS BLOB) WHERE rowid = ?`
+)
+
+// newBlobServer returns a database recording the statements it receives,
+// answering updates with affected rows and failing those whose record
+// contains fail.
+func newBlobServer(t *testing.T, affected int64, fail string) (*sql.DB, func() []string) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		if fail != "" && strings.Contains(stmt, fail) {
+			return nil, errors.New("too big")
+		}
+		return &libsqltest.Result{Affected: affected}, nil
+	})
+	db, _ := libsqltest.Open(t, f)
+	return db, executed
+}
+
+func TestWriteBlob(t *testing.T) {
+	tests := []struct {
+		name   string
+		blob   string
+		chunks []string
+	}{
+		{name: "empty", blob: "", chunks: []string{""}},
+		{name: "single chunk", blob: "abc", chunks: []string{"abc"}},
+		{name: "full chunks", blob: "abcdefgh", chunks: []string{"abcd", "efgh"}},
+		{name: "partial last chunk", blob: "abcdefghij", chunks: []string{"abcd", "efgh", "ij"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, executed := newBlobServer(t, 1, "")
+			want := []string{"BEGIN"}
+			for idx, chunk := range tt.chunks {
+				query := appendBlob
+				if idx == 0 {
+					query = setBlob
+				}
+				want = append(want, fmt.Sprintf("%s %v 7", query, []byte(chunk)))
+			}
+			want = append(want, "COMMIT")
+
+			n, err := WriteBlob(context.Background(), db, "main.files", "data", 7, strings.NewReader(tt.blob), &BlobOptions{ChunkSize: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(tt.blob)) {
+				t.Errorf("got %d bytes written, want %d", n, len(tt.blob))
+			}
+			if got := executed(); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteBlobErrors(t *testing.T) {
+	ctx := context.Background()
+	db, executed := newBlobServer(t, 0, "")
+	if _, err := WriteBlob(ctx, db, "main.files", "data", 7, strings.NewReader("ab"), nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("got %v for a missing row, want %v", err, sql.ErrNoRows)
+	}
+	if want := []string{"BEGIN", setBlob + " [97 98] 7", "ROLLBACK"}; !reflect.DeepEqual(executed(), want) {
+		t.Errorf("got %q, want %q", executed(), want)
+	}
+
+	db, executed = newBlobServer(t, 1, "[99 100]")
+	_, err := WriteBlob(ctx, db, "main.files", "data", 7, strings.NewReader("abcd"), &BlobOptions{ChunkSize: 2})
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to write bytes 2 to 4 of blob: ") || !strings.Contains(err.Error(), "too big") {
+		t.Errorf("got %v, want the error of the second chunk", err)
+	}
+	if want := []string{"BEGIN", setBlob + " [97 98] 7", appendBlob + " [99 100] 7", "ROLLBACK"}; !reflect.DeepEqual(executed(), want) {
+		t.Errorf("got %q, want %q", executed(), want)
+	}
+}
@@ -0,0 +1,55 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// closedServerURL returns the URL of a server that no longer accepts
+// connections.
+func closedServerURL() string {
+	server := libsqltest.NewServer(nil)
+	server.Close()
+	return server.URL
+}
+
+func TestErrUnreachable(t *testing.T) {
+	db, err := sql.Open("libsql", closedServerURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	if !errors.Is(err, ErrUnreachable) || !IsRetryable(err) {
+		t.Fatalf("got %v, want a retryable unreachable error", err)
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	if _, err := NewConnector(closedServerURL(), WithCircuitBreaker(0, time.Second)); err == nil {
+		t.Error("expected an error for a threshold of zero")
+	}
+	if _, err := NewConnector(closedServerURL(), WithCircuitBreaker(1, 0)); err == nil {
+		t.Error("expected an error for a zero cooldown")
+	}
+
+	connector, err := NewConnector(closedServerURL(), WithCircuitBreaker(1, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("got %v, want an unreachable error", err)
+	}
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	if !errors.Is(err, ErrUnreachable) || !strings.Contains(err.Error(), "next attempt in") {
+		t.Fatalf("got %v, want the open breaker to fail the request", err)
+	}
+}
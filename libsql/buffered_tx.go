@@ -0,0 +1,100 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+)
+
+// ErrBufferedResult is returned by the results of statements executed in a
+// buffered transaction, which are only sent by Commit.
+var ErrBufferedResult = errors.New("results are not available in a buffered transaction")
+
+// atomicBatchExecer is implemented by transports that can execute several
+// statements atomically in a single request.
+type atomicBatchExecer interface {
+	ExecAtomicBatch(ctx context.Context, queries []string, args [][]driver.NamedValue) error
+}
+
+type bufferedTx struct {
+	conn    *Conn
+	queries []string
+	args    [][]driver.NamedValue
+	// mode is the transaction mode asked for with the context of BeginTx.
+	mode string
+}
+
+func (t *bufferedTx) add(query string, args []driver.NamedValue) driver.Result {
+	t.queries = append(t.queries, query)
+	t.args = append(t.args, append([]driver.NamedValue(nil), args...))
+	return bufferedResult{}
+}
+
+func (t *bufferedTx) Commit() error {
+	t.conn.buffered = nil
+	if len(t.queries) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	if t.mode != "" {
+		ctx = ctxopt.WithTxMode(ctx, t.mode)
+	}
+	return t.exec(ctx)
+}
+
+// exec executes the statements atomically, in a single request if the
+// transport supports it.
+func (t *bufferedTx) exec(ctx context.Context) error {
+	var err error
+	if b, ok := t.conn.transport.(atomicBatchExecer); ok {
+		err = b.ExecAtomicBatch(ctx, t.queries, t.args)
+	} else {
+		err = t.execInteractive(ctx)
+	}
+	if err == nil {
+		t.conn.recordWrites(t.queries...)
+	}
+	return err
+}
+
+// execInteractive replays the statements in an interactive transaction for
+// transports that cannot send them in a single request.
+func (t *bufferedTx) execInteractive(ctx context.Context) error {
+	e, ok := t.conn.transport.(driver.ExecerContext)
+	if !ok {
+		return fmt.Errorf("buffered transactions are not supported by this connection")
+	}
+	tx, err := t.conn.beginTransportTx(ctx, driver.TxOptions{})
+	if err != nil {
+		return err
+	}
+	for idx, query := range t.queries {
+		if _, err := e.ExecContext(ctx, query, t.args[idx]); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				t.conn.connector.cfg.Log().WarnContext(ctx, "failed to roll back buffered transaction", "error", rollbackErr)
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (t *bufferedTx) Rollback() error {
+	t.conn.buffered = nil
+	return nil
+}
+
+type bufferedResult struct{}
+
+func (bufferedResult) LastInsertId() (int64, error) {
+	return 0, ErrBufferedResult
+}
+
+func (bufferedResult) RowsAffected() (int64, error) {
+	return 0, ErrBufferedResult
+}
+
+var errBufferedQuery = errors.New("queries are not supported in a buffered transaction")
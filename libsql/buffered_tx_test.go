@@ -0,0 +1,131 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+)
+
+// batchConn is a transport connection recording the atomic batches it runs.
+type batchConn struct {
+	driver.Conn
+	queries [][]string
+	args    [][][]driver.NamedValue
+	modes   []string
+}
+
+func (c *batchConn) ExecAtomicBatch(ctx context.Context, queries []string, args [][]driver.NamedValue) error {
+	c.queries = append(c.queries, queries)
+	c.args = append(c.args, args)
+	c.modes = append(c.modes, ctxopt.TxMode(ctx))
+	return nil
+}
+
+func (c *batchConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, errors.New("statement sent outside of the batch")
+}
+
+func (c *batchConn) Close() error {
+	return nil
+}
+
+type batchConnector struct {
+	conn *batchConn
+}
+
+func (c batchConnector) Connect(context.Context) (driver.Conn, error) {
+	return newConn(c.conn, &Connector{}), nil
+}
+
+func (c batchConnector) Driver() driver.Driver {
+	return libsqlDriver
+}
+
+func TestBufferedTransaction(t *testing.T) {
+	transport := &batchConn{}
+	db := sql.OpenDB(batchConnector{transport})
+	defer db.Close()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(WithBufferedTransaction(ctx), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (?)", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.RowsAffected(); !errors.Is(err, ErrBufferedResult) {
+		t.Errorf("got %v, want ErrBufferedResult", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE t SET a = :a", sql.Named("a", 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.QueryContext(ctx, "SELECT a FROM t"); err == nil {
+		t.Error("expected queries to be rejected")
+	}
+	if len(transport.queries) != 0 {
+		t.Fatal("statements sent before Commit")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"INSERT INTO t VALUES (?)", "UPDATE t SET a = :a"}
+	if len(transport.queries) != 1 || !reflect.DeepEqual(transport.queries[0], want) {
+		t.Fatalf("got batches %v, want %v", transport.queries, want)
+	}
+	if got := transport.args[0][1][0]; got.Name != "a" || got.Value != int64(2) {
+		t.Errorf("got args %#v", got)
+	}
+}
+
+func TestBufferedTransactionRollback(t *testing.T) {
+	transport := &batchConn{}
+	db := sql.OpenDB(batchConnector{transport})
+	defer db.Close()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(WithBufferedTransaction(ctx), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.queries) != 0 {
+		t.Errorf("rolled back statements were sent: %v", transport.queries)
+	}
+}
+
+func TestBufferedTransactionMode(t *testing.T) {
+	transport := &batchConn{}
+	db := sql.OpenDB(batchConnector{transport})
+	defer db.Close()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(WithTxMode(WithBufferedTransaction(ctx), TxImmediate), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"IMMEDIATE"}; !reflect.DeepEqual(transport.modes, want) {
+		t.Errorf("got modes %q, want %q", transport.modes, want)
+	}
+	if _, err := db.BeginTx(WithTxMode(WithBufferedTransaction(ctx), "EAGER"), nil); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+}
@@ -0,0 +1,173 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// BulkRows yields the rows inserted by BulkInsert. Next returns io.EOF once
+// every row was yielded.
+type BulkRows interface {
+	Next() ([]any, error)
+}
+
+type sliceRows struct {
+	rows [][]any
+}
+
+func (r *sliceRows) Next() ([]any, error) {
+	if len(r.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	return row, nil
+}
+
+// SliceRows returns BulkRows yielding rows.
+func SliceRows(rows [][]any) BulkRows {
+	return &sliceRows{rows: rows}
+}
+
+type BulkInsertOptions struct {
+	// MaxParameters is the largest number of parameters of a single INSERT
+	// statement. It defaults to 32766, the limit of sqld.
+	MaxParameters int
+	// MaxBytes is the approximate largest size of the values of a single INSERT
+	// statement. It defaults to 1 MiB which keeps requests well below the
+	// payload limits of sqld.
+	MaxBytes int
+}
+
+const (
+	defaultBulkMaxParameters = 32766
+	defaultBulkMaxBytes      = 1 << 20
+)
+
+// BulkInsert inserts rows into the columns of table using multi-row INSERT
+// statements as large as the limits of opts allow, which takes far fewer round
+// trips than an INSERT per row. All statements run in a single transaction, so
+// either every row is inserted or none is. The table name is quoted, with a
+// dot separating the schema name from the table name. It returns the number of
+// rows inserted.
+func BulkInsert(ctx context.Context, db *sql.DB, table string, columns []string, rows BulkRows, opts *BulkInsertOptions) (int64, error) {
+	if opts == nil {
+		opts = &BulkInsertOptions{}
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("bulk insert needs at least one column")
+	}
+	maxParameters := opts.MaxParameters
+	if maxParameters <= 0 {
+		maxParameters = defaultBulkMaxParameters
+	}
+	if maxParameters < len(columns) {
+		return 0, fmt.Errorf("%d parameters cannot hold a row of %d columns", maxParameters, len(columns))
+	}
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultBulkMaxBytes
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin bulk insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	insert := bulkInsertPrefix(table, columns)
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	var inserted int64
+	var args []any
+	size := 0
+	flush := func() error {
+		if len(args) == 0 {
+			return nil
+		}
+		n := len(args) / len(columns)
+		query := insert + strings.TrimSuffix(strings.Repeat(placeholders+", ", n), ", ")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert rows %d to %d: %w", inserted+1, inserted+int64(n), err)
+		}
+		inserted += int64(n)
+		args = args[:0]
+		size = 0
+		return nil
+	}
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(row) != len(columns) {
+			return 0, fmt.Errorf("row %d has %d values, want %d", inserted+int64(len(args)/len(columns))+1, len(row), len(columns))
+		}
+		rowSize := 0
+		for _, v := range row {
+			rowSize += bulkValueSize(v)
+		}
+		if len(args)+len(row) > maxParameters || (len(args) > 0 && size+rowSize > maxBytes) {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+		args = append(args, row...)
+		size += rowSize
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit bulk insert: %w", err)
+	}
+	return inserted, nil
+}
+
+func bulkInsertPrefix(table string, columns []string) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(quoteTable(table))
+	b.WriteString(" (")
+	for idx, column := range columns {
+		if idx > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(quoteIdentifier(column))
+	}
+	b.WriteString(") VALUES ")
+	return b.String()
+}
+
+// quoteTable quotes a table name, with a dot separating the schema name from
+// the table name.
+func quoteTable(table string) string {
+	parts := strings.SplitN(table, ".", 2)
+	for idx, part := range parts {
+		parts[idx] = quoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// bulkValueSize estimates the size of v in a request, including the JSON
+// encoding overhead. Blobs are base64 encoded.
+func bulkValueSize(v any) int {
+	const overhead = 32
+	switch v := v.(type) {
+	case string:
+		return len(v) + overhead
+	case []byte:
+		return len(v)*4/3 + overhead
+	}
+	return overhead
+}
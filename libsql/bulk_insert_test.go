@@ -0,0 +1,71 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newInsertServer returns a database recording the statements it receives
+// and failing those whose record contains fail.
+func newInsertServer(t *testing.T, fail string) (*sql.DB, func() []string) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		if fail != "" && strings.Contains(stmt, fail) {
+			return nil, errors.New("UNIQUE constraint failed")
+		}
+		return nil, nil
+	})
+	db, _ := libsqltest.Open(t, f)
+	return db, executed
+}
+
+func TestBulkInsert(t *testing.T) {
+	db, executed := newInsertServer(t, "")
+	rows := [][]any{{1, "x"}, {2, "y"}, {3, "z"}, {4, nil}, {5, []byte("w")}}
+	n, err := BulkInsert(context.Background(), db, "main.t", []string{"a", "b"}, SliceRows(rows), &BulkInsertOptions{MaxParameters: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("got %d rows inserted, want 5", n)
+	}
+	want := []string{
+		"BEGIN",
+		`INSERT INTO "main"."t" ("a", "b") VALUES (?, ?), (?, ?) 1 x 2 y`,
+		`INSERT INTO "main"."t" ("a", "b") VALUES (?, ?), (?, ?) 3 z 4 <nil>`,
+		`INSERT INTO "main"."t" ("a", "b") VALUES (?, ?) 5 [119]`,
+		"COMMIT",
+	}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBulkInsertMaxBytes(t *testing.T) {
+	db, executed := newInsertServer(t, "")
+	rows := SliceRows([][]any{{"aaaa"}, {"bbbb"}})
+	if _, err := BulkInsert(context.Background(), db, "t", []string{"a"}, rows, &BulkInsertOptions{MaxBytes: 40}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", `INSERT INTO "t" ("a") VALUES (?) aaaa`, `INSERT INTO "t" ("a") VALUES (?) bbbb`, "COMMIT"}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBulkInsertRollsBack(t *testing.T) {
+	db, executed := newInsertServer(t, "INSERT")
+	rows := SliceRows([][]any{{1}, {1}})
+	if _, err := BulkInsert(context.Background(), db, "t", []string{"a"}, rows, &BulkInsertOptions{MaxParameters: 1}); err == nil {
+		t.Fatal("expected error")
+	}
+	want := []string{"BEGIN", `INSERT INTO "t" ("a") VALUES (?) 1`, "ROLLBACK"}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
+
+// WithBusyTimeout retries the statements executed outside transactions that
+// fail with SQLITE_BUSY or SQLITE_LOCKED for up to d, like the busyTimeout
+// query parameter and the busy_timeout of SQLite: the waits between attempts
+// grow from 1ms to 100ms. Only single statements are retried, since a failed
+// statement of a query holding several may follow statements that ran. The
+// statements of transactions are not retried, the whole transaction has to be.
+func WithBusyTimeout(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		if d <= 0 {
+			return fmt.Errorf("busy timeout must be positive")
+		}
+		c.busyTimeout = d
+		return nil
+	})
+}
+
+// busyDelays are the waits of the busy handler of SQLite between attempts, the
+// last one repeating.
+var busyDelays = []time.Duration{1, 2, 5, 10, 15, 20, 25, 25, 25, 50, 50, 100}
+
+// retryBusy calls exec again while it fails with SQLITE_BUSY or SQLITE_LOCKED,
+// within the busy timeout and the deadline of ctx, if the statement query can
+// be retried.
+func (c *Conn) retryBusy(ctx context.Context, query string, exec func() (driver.Result, error)) (driver.Result, error) {
+	res, err := exec()
+	timeout := c.connector.busyTimeout
+	if err == nil || timeout <= 0 || c.inTx {
+		return res, err
+	}
+	if _, ok := shared.SingleStatement(query); !ok {
+		return res, err
+	}
+	deadline := time.Now().Add(timeout)
+	for attempt := 0; IsBusy(err) || IsLocked(err); attempt++ {
+		wait := busyDelays[min(attempt, len(busyDelays)-1)] * time.Millisecond
+		if time.Until(deadline) < wait {
+			break
+		}
+		c.connector.cfg.Log().DebugContext(ctx, "retrying busy statement", "attempt", attempt+1, "wait", wait, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return res, err
+		}
+		res, err = exec()
+	}
+	return res, err
+}
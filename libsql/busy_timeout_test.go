@@ -0,0 +1,95 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newBusyServer returns a server failing the first busy UPDATE statements
+// with SQLITE_BUSY, and a function counting the executions of the statements
+// updating column a.
+func newBusyServer(t *testing.T, busy int) (string, func() int) {
+	server := libsqltest.NewFuncServer(t, nil)
+	server.FailStatements(busy, "UPDATE", &libsqltest.Error{Code: "SQLITE_BUSY", Message: "database is locked"})
+	return server.URL, func() int {
+		return countStatements(server, "UPDATE t SET a")
+	}
+}
+
+func TestWithBusyTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		opts    []Option
+		query   string
+		busy    int
+		inTx    bool
+		wantErr bool
+		// executions is the number of executions expected, or zero for
+		// more than one.
+		executions int
+	}{
+		{name: "disabled", busy: 1, wantErr: true, executions: 1},
+		{name: "retried", opts: []Option{WithBusyTimeout(time.Second)}, busy: 3, executions: 4},
+		{name: "query parameter", url: "?busyTimeout=1000", busy: 3, executions: 4},
+		{name: "timed out", opts: []Option{WithBusyTimeout(20 * time.Millisecond)}, busy: 100, wantErr: true},
+		{name: "transaction", opts: []Option{WithBusyTimeout(time.Second)}, busy: 1, inTx: true, wantErr: true, executions: 1},
+		{name: "several statements", opts: []Option{WithBusyTimeout(time.Second)}, query: "UPDATE t SET a = 1; UPDATE t SET b = 2", busy: 1, wantErr: true, executions: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, executions := newBusyServer(t, tt.busy)
+			connector, err := NewConnector(url+tt.url, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			query, args := tt.query, []any(nil)
+			if query == "" {
+				query, args = "UPDATE t SET a = ?", []any{1}
+			}
+			exec := db.ExecContext
+			if tt.inTx {
+				tx, err := db.Begin()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer tx.Rollback()
+				exec = tx.ExecContext
+			}
+			_, err = exec(context.Background(), query, args...)
+			if (err != nil) != tt.wantErr || (err != nil && !IsBusy(err)) {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			got := executions()
+			if tt.executions == 0 && got > 1 {
+				return
+			}
+			if got != tt.executions {
+				t.Errorf("got %d executions, want %d", got, tt.executions)
+			}
+		})
+	}
+}
+
+func TestBusyTimeoutQueryParameter(t *testing.T) {
+	for _, value := range []string{"abc", "-5"} {
+		if _, err := NewConnector("http://localhost:8080?busyTimeout=" + value); err == nil {
+			t.Errorf("expected an error for busyTimeout=%s", value)
+		}
+	}
+	for value, want := range map[string]time.Duration{"250": 250 * time.Millisecond, "2s": 2 * time.Second} {
+		c, err := NewConnector("http://localhost:8080?busyTimeout=" + value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.busyTimeout != want {
+			t.Errorf("got busy timeout %v for %s, want %v", c.busyTimeout, value, want)
+		}
+	}
+}
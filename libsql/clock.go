@@ -0,0 +1,39 @@
+package libsql
+
+import "time"
+
+// ServerClock is the estimated clock of the server, derived from the Date
+// headers of its HTTP responses and websocket handshakes.
+type ServerClock struct {
+	// ServerTime is the time in the last Date header received, with a
+	// resolution of one second.
+	ServerTime time.Time
+	// ReceivedAt is the local time the last Date header was received at.
+	ReceivedAt time.Time
+	// Offset is the estimated time to add to the local clock to get the time
+	// of the server.
+	Offset time.Duration
+	// Samples is the number of responses the estimate is based on.
+	Samples int
+}
+
+// Now returns the estimated current time of the server.
+func (s ServerClock) Now() time.Time {
+	return time.Now().Add(s.Offset)
+}
+
+// ServerClock returns the clock estimate of the server built from the
+// responses received by the connections of c. ok is false until a response
+// with a Date header was received.
+func (c *Connector) ServerClock() (clock ServerClock, ok bool) {
+	estimate, ok := c.cfg.Clock.Estimate()
+	if !ok {
+		return ServerClock{}, false
+	}
+	return ServerClock{
+		ServerTime: estimate.ServerTime,
+		ReceivedAt: estimate.ReceivedAt,
+		Offset:     estimate.Offset,
+		Samples:    estimate.Samples,
+	}, true
+}
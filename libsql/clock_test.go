@@ -0,0 +1,30 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestServerClock(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+
+	connector, err := NewConnector(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := connector.ServerClock(); ok {
+		t.Fatal("expected no estimate before any request")
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	clock, ok := connector.ServerClock()
+	if !ok || clock.Samples != 1 || clock.ServerTime.IsZero() {
+		t.Errorf("got %#v, %v, want an estimate from the response", clock, ok)
+	}
+}
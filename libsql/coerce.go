@@ -0,0 +1,85 @@
+package libsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// affinity is the type affinity SQLite derives from a declared column type.
+type affinity int
+
+const (
+	// affinityNone is used for columns without a declared type, like
+	// expressions, whose values are never coerced.
+	affinityNone affinity = iota
+	affinityInteger
+	affinityText
+	affinityBlob
+	affinityReal
+	affinityNumeric
+)
+
+// columnAffinity applies the rules of https://www.sqlite.org/datatype3.html#determination_of_column_affinity.
+func columnAffinity(declType string) affinity {
+	t := strings.ToUpper(declType)
+	switch {
+	case t == "":
+		return affinityNone
+	case strings.Contains(t, "INT"):
+		return affinityInteger
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return affinityText
+	case strings.Contains(t, "BLOB"):
+		return affinityBlob
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return affinityReal
+	}
+	return affinityNumeric
+}
+
+// maxExactFloat is the largest integer magnitude every smaller integer of which
+// a float64 represents exactly.
+const maxExactFloat = 1 << 53
+
+// coerce converts v to the Go type matching the affinity of its column:
+//
+//	INTEGER  float64 -> int64    if the float is integral and in range
+//	REAL     int64   -> float64  if the integer is exactly representable
+//	TEXT     []byte  -> string   if the blob is valid UTF-8
+//	BLOB     string  -> []byte   always
+//
+// Values of other affinities and NULL are returned unchanged. A conversion that
+// would lose information returns an error.
+func coerce(a affinity, v driver.Value) (driver.Value, error) {
+	switch a {
+	case affinityInteger:
+		if f, ok := v.(float64); ok {
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return nil, fmt.Errorf("cannot convert %v to an integer without loss", f)
+			}
+			return int64(f), nil
+		}
+	case affinityReal:
+		if i, ok := v.(int64); ok {
+			if i > maxExactFloat || i < -maxExactFloat {
+				return nil, fmt.Errorf("cannot convert %d to a float without loss", i)
+			}
+			return float64(i), nil
+		}
+	case affinityText:
+		if b, ok := v.([]byte); ok {
+			if !utf8.Valid(b) {
+				return nil, fmt.Errorf("cannot convert a blob that is not valid UTF-8 to text")
+			}
+			return string(b), nil
+		}
+	case affinityBlob:
+		if s, ok := v.(string); ok {
+			return []byte(s), nil
+		}
+	}
+	return v, nil
+}
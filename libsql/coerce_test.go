@@ -0,0 +1,90 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestColumnAffinity(t *testing.T) {
+	tests := map[string]affinity{
+		"":                  affinityNone,
+		"INTEGER":           affinityInteger,
+		"bigint":            affinityInteger,
+		"VARCHAR(20)":       affinityText,
+		"CLOB":              affinityText,
+		"BLOB":              affinityBlob,
+		"DOUBLE PRECISION":  affinityReal,
+		"FLOAT":             affinityReal,
+		"DECIMAL(10,5)":     affinityNumeric,
+		"DATETIME":          affinityNumeric,
+		"CHARINT":           affinityInteger,
+		"FLOATING POINT":    affinityInteger,
+		"unsigned big text": affinityText,
+	}
+	for declType, want := range tests {
+		if got := columnAffinity(declType); got != want {
+			t.Errorf("%q: got affinity %d, want %d", declType, got, want)
+		}
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		name     string
+		affinity affinity
+		value    driver.Value
+		want     driver.Value
+		wantErr  bool
+	}{
+		{name: "IntegralFloatToInteger", affinity: affinityInteger, value: 42.0, want: int64(42)},
+		{name: "FractionalFloatToInteger", affinity: affinityInteger, value: 4.2, wantErr: true},
+		{name: "HugeFloatToInteger", affinity: affinityInteger, value: 1e19, wantErr: true},
+		{name: "IntegerUnchanged", affinity: affinityInteger, value: int64(7), want: int64(7)},
+		{name: "IntegerToReal", affinity: affinityReal, value: int64(3), want: 3.0},
+		{name: "HugeIntegerToReal", affinity: affinityReal, value: int64(1<<53 + 1), wantErr: true},
+		{name: "BlobToText", affinity: affinityText, value: []byte("hello"), want: "hello"},
+		{name: "InvalidUtf8ToText", affinity: affinityText, value: []byte{0xff}, wantErr: true},
+		{name: "TextToBlob", affinity: affinityBlob, value: "hello", want: []byte("hello")},
+		{name: "Null", affinity: affinityInteger, value: nil, want: nil},
+		{name: "NoAffinity", affinity: affinityNone, value: 4.2, want: 4.2},
+		{name: "Numeric", affinity: affinityNumeric, value: "2023-08-01", want: "2023-08-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coerce(tt.affinity, tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowsStrictTypes(t *testing.T) {
+	fake := &fakeRows{
+		columns:   []string{"a", "b"},
+		declTypes: []string{"INTEGER", "REAL"},
+		values:    [][]driver.Value{{1.0, int64(2)}, {1.5, int64(2)}},
+	}
+	r := wrapRows(context.Background(), fake, &Conn{connector: &Connector{strictTypes: true}}, "SELECT a, b FROM t")
+	dest := make([]driver.Value, 2)
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dest, []driver.Value{int64(1), 2.0}) {
+		t.Errorf("got %#v", dest)
+	}
+	if err := r.Next(dest); err == nil {
+		t.Error("expected error for a lossy conversion")
+	}
+}
@@ -0,0 +1,455 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr/v4"
+	"github.com/libsql/sqlite-antlr4-parser/sqliteparser"
+)
+
+// Hrana reports the name and the declared type of the columns of a result, so
+// the length of a column comes from its declared type and whether it is
+// nullable from the table it is selected from, which is found by parsing the
+// query and looked up with pragma_table_xinfo.
+
+var declTypeLength = regexp.MustCompile(`\(\s*(\d+)\s*(?:,\s*\d+\s*)?\)`)
+
+// columnTypeLength implements driver.RowsColumnTypeLength for a column of the
+// declared type: the size given in parentheses, like VARCHAR(255), or
+// math.MaxInt64 for other text and blob types, which are unbounded in SQLite.
+func columnTypeLength(declType string) (int64, bool) {
+	switch columnAffinity(declType) {
+	case affinityText, affinityBlob:
+	default:
+		return 0, false
+	}
+	if match := declTypeLength.FindStringSubmatch(declType); match != nil {
+		if length, err := strconv.ParseInt(match[1], 10, 64); err == nil {
+			return length, true
+		}
+	}
+	return math.MaxInt64, true
+}
+
+var declTypePrecision = regexp.MustCompile(`\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)`)
+
+// columnTypePrecisionScale implements driver.RowsColumnTypePrecisionScale for a
+// column of the declared type: the precision and scale given in parentheses to
+// a type of NUMERIC affinity, like DECIMAL(10,2). The scale defaults to 0.
+func columnTypePrecisionScale(declType string) (precision, scale int64, ok bool) {
+	if columnAffinity(declType) != affinityNumeric {
+		return 0, 0, false
+	}
+	match := declTypePrecision.FindStringSubmatch(declType)
+	if match == nil {
+		return 0, 0, false
+	}
+	precision, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if match[2] != "" {
+		if scale, err = strconv.ParseInt(match[2], 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return precision, scale, true
+}
+
+var (
+	scanTypeInt64   = reflect.TypeOf(int64(0))
+	scanTypeFloat64 = reflect.TypeOf(float64(0))
+	scanTypeString  = reflect.TypeOf("")
+	scanTypeBytes   = reflect.TypeOf([]byte(nil))
+	scanTypeBool    = reflect.TypeOf(false)
+	scanTypeTime    = reflect.TypeOf(time.Time{})
+	scanTypeAny     = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// columnTypeScanType implements driver.RowsColumnTypeScanType for a column of
+// the declared type, from its affinity. SQLite stores any type in any column,
+// so columns of NUMERIC affinity and expressions scan into any, except for
+// BOOLEAN columns, whose 0 and 1 scan into bool, and dates when parseTime is
+// set.
+func columnTypeScanType(declType string, parseTime bool) reflect.Type {
+	switch t := strings.ToUpper(declType); {
+	case parseTime && isTimeDeclType(t):
+		return scanTypeTime
+	case t == "BOOLEAN" || t == "BOOL":
+		return scanTypeBool
+	}
+	switch columnAffinity(declType) {
+	case affinityInteger:
+		return scanTypeInt64
+	case affinityReal:
+		return scanTypeFloat64
+	case affinityText:
+		return scanTypeString
+	case affinityBlob:
+		return scanTypeBytes
+	}
+	return scanTypeAny
+}
+
+type nullability int
+
+const (
+	nullabilityUnknown nullability = iota
+	nullabilityNullable
+	nullabilityNotNull
+)
+
+// sourceTable is a table of the FROM clause of a query.
+type sourceTable struct {
+	schema string
+	name   string
+	alias  string
+	// outer is set for the tables on the right of a LEFT JOIN, whose columns
+	// are NULL when no row matches.
+	outer bool
+}
+
+// resultSource is what a result column selects: a column of a source table,
+// every column of one of them with t.*, or of all of them with *.
+type resultSource struct {
+	star bool
+	// table qualifies the column or the star, empty if it is unqualified.
+	table string
+	// column is empty for expressions, whose nullability is unknown.
+	column string
+}
+
+type querySources struct {
+	tables  []sourceTable
+	columns []resultSource
+}
+
+// unquoteIdentifier returns the name of an identifier as SQLite reads it.
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 {
+		switch first, last := name[0], name[len(name)-1]; {
+		case first == '"' && last == '"':
+			return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		case first == '`' && last == '`':
+			return strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+		case first == '[' && last == ']':
+			return name[1 : len(name)-1]
+		}
+	}
+	return name
+}
+
+var joinKeywords = map[string]bool{"NATURAL": true, "LEFT": true, "RIGHT": true, "FULL": true, "INNER": true, "CROSS": true, "OUTER": true}
+
+type syntaxErrors struct {
+	*antlr.DefaultErrorListener
+	count int
+}
+
+func (e *syntaxErrors) SyntaxError(antlr.Recognizer, any, int, int, string, antlr.RecognitionException) {
+	e.count++
+}
+
+// parseQuerySources finds the source of every result column of query. It
+// returns false for queries it cannot map to tables: statements other than a
+// single simple SELECT, compound selects, common table expressions and
+// subqueries, as well as stars over joins merging columns with NATURAL or
+// USING.
+func parseQuerySources(query string) (*querySources, bool) {
+	errs := &syntaxErrors{DefaultErrorListener: antlr.NewDefaultErrorListener()}
+	lexer := sqliteparser.NewSQLiteLexer(antlr.NewInputStream(query))
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(errs)
+	parser := sqliteparser.NewSQLiteParser(antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(errs)
+	tree := parser.Parse()
+	if errs.count > 0 || len(tree.AllSql_stmt_list()) != 1 || len(tree.Sql_stmt_list(0).AllSql_stmt()) != 1 {
+		return nil, false
+	}
+	stmt := tree.Sql_stmt_list(0).Sql_stmt(0)
+	sel := stmt.Select_stmt()
+	if stmt.EXPLAIN_() != nil || sel == nil || sel.Common_table_stmt() != nil || len(sel.AllSelect_core()) != 1 {
+		return nil, false
+	}
+	core := sel.Select_core(0)
+	if core.Values_clause() != nil {
+		return nil, false
+	}
+
+	sources := &querySources{}
+	merged := false
+	addTable := func(t sqliteparser.ITable_or_subqueryContext, outer bool) bool {
+		if t.Table_name() == nil || t.OPEN_PAR() != nil {
+			return false
+		}
+		table := sourceTable{name: unquoteIdentifier(t.Table_name().GetText()), outer: outer}
+		if t.Schema_name() != nil {
+			table.schema = unquoteIdentifier(t.Schema_name().GetText())
+		}
+		if t.Table_alias() != nil {
+			// The grammar reads the join keyword of users NATURAL JOIN posts
+			// as an alias of users.
+			if joinKeywords[strings.ToUpper(t.Table_alias().GetText())] {
+				return false
+			}
+			table.alias = unquoteIdentifier(t.Table_alias().GetText())
+		}
+		sources.tables = append(sources.tables, table)
+		return true
+	}
+	for _, t := range core.AllTable_or_subquery() {
+		if !addTable(t, false) {
+			return nil, false
+		}
+	}
+	if join := core.Join_clause(); join != nil {
+		for idx, t := range join.AllTable_or_subquery() {
+			outer := false
+			if idx > 0 {
+				op := join.Join_operator(idx - 1)
+				outer = op.LEFT_() != nil
+				merged = merged || op.NATURAL_() != nil
+			}
+			if !addTable(t, outer) {
+				return nil, false
+			}
+		}
+		for _, constraint := range join.AllJoin_constraint() {
+			merged = merged || constraint.USING_() != nil
+		}
+	}
+
+	for _, col := range core.AllResult_column() {
+		var source resultSource
+		switch {
+		case col.STAR() != nil:
+			if merged {
+				return nil, false
+			}
+			source.star = true
+			if col.Table_name() != nil {
+				source.table = unquoteIdentifier(col.Table_name().GetText())
+			}
+		case col.Expr() != nil:
+			// Column references are the only expressions made of a
+			// column name and its optional qualifiers.
+			expr := col.Expr()
+			if expr.Column_name() != nil && expr.GetChildCount() == 2*qualifiers(expr)+1 {
+				source.column = unquoteIdentifier(expr.Column_name().GetText())
+				if expr.Table_name() != nil {
+					source.table = unquoteIdentifier(expr.Table_name().GetText())
+				}
+			}
+		}
+		sources.columns = append(sources.columns, source)
+	}
+	return sources, true
+}
+
+// qualifiers returns the number of schema and table names qualifying expr.
+func qualifiers(expr sqliteparser.IExprContext) int {
+	n := 0
+	if expr.Schema_name() != nil {
+		n++
+	}
+	if expr.Table_name() != nil {
+		n++
+	}
+	return n
+}
+
+// tableColumn is a column of a table as described by pragma_table_xinfo.
+type tableColumn struct {
+	name    string
+	notNull bool
+}
+
+// maxCachedQueries bounds the number of parsed queries kept by columnMetadata.
+const maxCachedQueries = 256
+
+// columnMetadata caches the parsed sources of queries and the columns of the
+// tables they select from, which are forgotten when a connection changes the
+// schema.
+type columnMetadata struct {
+	mu      sync.Mutex
+	queries map[string]*querySources
+	tables  map[string][]tableColumn
+}
+
+func (m *columnMetadata) invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tables = nil
+}
+
+func (m *columnMetadata) sources(query string) *querySources {
+	m.mu.Lock()
+	sources, ok := m.queries[query]
+	m.mu.Unlock()
+	if ok {
+		return sources
+	}
+	sources, _ = parseQuerySources(query)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.queries == nil || len(m.queries) >= maxCachedQueries {
+		m.queries = map[string]*querySources{}
+	}
+	m.queries[query] = sources
+	return sources
+}
+
+// tableColumns returns the columns of table, nil if it does not exist.
+func (m *columnMetadata) tableColumns(c *Connector, table sourceTable) ([]tableColumn, error) {
+	key := strings.ToLower(table.schema + "." + table.name)
+	m.mu.Lock()
+	columns, ok := m.tables[key]
+	m.mu.Unlock()
+	if ok {
+		return columns, nil
+	}
+	columns, err := queryTableColumns(c, table)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tables == nil {
+		m.tables = map[string][]tableColumn{}
+	}
+	m.tables[key] = columns
+	return columns, nil
+}
+
+// queryTableColumns runs pragma_table_xinfo on a connection of its own, since
+// the connection of the rows being described may still be reading them.
+func queryTableColumns(c *Connector, table sourceTable) ([]tableColumn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := c.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	query := `SELECT name, type, "notnull", pk, hidden FROM pragma_table_xinfo(?)`
+	args := []driver.NamedValue{{Ordinal: 1, Value: table.name}}
+	if table.schema != "" {
+		query = `SELECT name, type, "notnull", pk, hidden FROM pragma_table_xinfo(?, ?)`
+		args = append(args, driver.NamedValue{Ordinal: 2, Value: table.schema})
+	}
+	rows, err := conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []tableColumn
+	var rowidAlias []bool
+	pkColumns := 0
+	row := make([]driver.Value, 5)
+	for {
+		if err := rows.Next(row); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		name, _ := row[0].(string)
+		declType, _ := row[1].(string)
+		notNull, _ := row[2].(int64)
+		pk, _ := row[3].(int64)
+		hidden, _ := row[4].(int64)
+		if pk > 0 {
+			pkColumns++
+		}
+		// Hidden columns of virtual tables are not selected by a star.
+		if hidden == 1 {
+			continue
+		}
+		columns = append(columns, tableColumn{name: name, notNull: notNull != 0})
+		rowidAlias = append(rowidAlias, pk == 1 && strings.EqualFold(declType, "INTEGER"))
+	}
+	// An INTEGER PRIMARY KEY is an alias of the rowid, which is never NULL.
+	for idx := range columns {
+		if pkColumns == 1 && rowidAlias[idx] {
+			columns[idx].notNull = true
+		}
+	}
+	return columns, nil
+}
+
+// recordSchemaChange forgets the columns of tables after query changed the
+// schema.
+func (c *Conn) recordSchemaChange(query string) {
+	switch strings.ToUpper(leadingKeyword(query)) {
+	case "CREATE", "ALTER", "DROP":
+		c.connector.columns.invalidate()
+	}
+}
+
+// columnNullability returns the nullability of the count result columns of
+// query, or nil when its columns cannot be mapped to table columns.
+func (c *Connector) columnNullability(query string, count int) []nullability {
+	sources := c.columns.sources(query)
+	if sources == nil {
+		return nil
+	}
+	tables := make([][]tableColumn, len(sources.tables))
+	for idx, table := range sources.tables {
+		columns, err := c.columns.tableColumns(c, table)
+		if err != nil || columns == nil {
+			return nil
+		}
+		tables[idx] = columns
+	}
+	matches := func(t sourceTable, name string) bool {
+		return name == "" || strings.EqualFold(t.alias, name) || (t.alias == "" && strings.EqualFold(t.name, name))
+	}
+	of := func(t sourceTable, col tableColumn) nullability {
+		if col.notNull && !t.outer {
+			return nullabilityNotNull
+		}
+		return nullabilityNullable
+	}
+
+	var result []nullability
+	for _, source := range sources.columns {
+		switch {
+		case source.star:
+			for idx, t := range sources.tables {
+				if matches(t, source.table) {
+					for _, col := range tables[idx] {
+						result = append(result, of(t, col))
+					}
+				}
+			}
+		case source.column == "":
+			result = append(result, nullabilityUnknown)
+		default:
+			n := nullabilityUnknown
+			for idx, t := range sources.tables {
+				if !matches(t, source.table) {
+					continue
+				}
+				for _, col := range tables[idx] {
+					if strings.EqualFold(col.name, source.column) {
+						n = of(t, col)
+					}
+				}
+			}
+			result = append(result, n)
+		}
+	}
+	if len(result) != count {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestColumnTypeLength(t *testing.T) {
+	tests := []struct {
+		declType string
+		length   int64
+		ok       bool
+	}{
+		{declType: "VARCHAR(255)", length: 255, ok: true},
+		{declType: "character ( 20 )", length: 20, ok: true},
+		{declType: "TEXT", length: math.MaxInt64, ok: true},
+		{declType: "BLOB", length: math.MaxInt64, ok: true},
+		{declType: "", ok: false},
+		{declType: "INTEGER", ok: false},
+		{declType: "DECIMAL(10, 2)", ok: false},
+	}
+	for _, tt := range tests {
+		length, ok := columnTypeLength(tt.declType)
+		if length != tt.length || ok != tt.ok {
+			t.Errorf("columnTypeLength(%q) = %d, %t, want %d, %t", tt.declType, length, ok, tt.length, tt.ok)
+		}
+	}
+}
+
+func TestColumnTypePrecisionScale(t *testing.T) {
+	tests := []struct {
+		declType         string
+		precision, scale int64
+		ok               bool
+	}{
+		{declType: "DECIMAL(10, 2)", precision: 10, scale: 2, ok: true},
+		{declType: "numeric(5)", precision: 5, ok: true},
+		{declType: "NUMERIC", ok: false},
+		{declType: "VARCHAR(255)", ok: false},
+		{declType: "", ok: false},
+	}
+	for _, tt := range tests {
+		precision, scale, ok := columnTypePrecisionScale(tt.declType)
+		if precision != tt.precision || scale != tt.scale || ok != tt.ok {
+			t.Errorf("columnTypePrecisionScale(%q) = %d, %d, %t, want %d, %d, %t", tt.declType, precision, scale, ok, tt.precision, tt.scale, tt.ok)
+		}
+	}
+}
+
+func TestParseQuerySources(t *testing.T) {
+	tests := []struct {
+		query string
+		want  *querySources
+	}{
+		{
+			query: "SELECT * FROM users",
+			want:  &querySources{tables: []sourceTable{{name: "users"}}, columns: []resultSource{{star: true}}},
+		},
+		{
+			query: `SELECT u.id, "name", length(name) AS n FROM main.users AS u WHERE id > ?`,
+			want: &querySources{
+				tables:  []sourceTable{{schema: "main", name: "users", alias: "u"}},
+				columns: []resultSource{{table: "u", column: "id"}, {column: "name"}, {}},
+			},
+		},
+		{
+			query: "SELECT u.name, p.* FROM users u LEFT JOIN posts p ON p.user_id = u.id",
+			want: &querySources{
+				tables:  []sourceTable{{name: "users", alias: "u"}, {name: "posts", alias: "p", outer: true}},
+				columns: []resultSource{{table: "u", column: "name"}, {star: true, table: "p"}},
+			},
+		},
+		{query: "SELECT 1; SELECT 2"},
+		{query: "EXPLAIN SELECT * FROM users"},
+		{query: "WITH t AS (SELECT 1) SELECT * FROM t"},
+		{query: "SELECT id FROM users UNION SELECT id FROM posts"},
+		{query: "SELECT * FROM (SELECT 1)"},
+		{query: "VALUES (1)"},
+		{query: "SELECT * FROM users JOIN posts USING (id)"},
+		{query: "SELECT * FROM users NATURAL JOIN posts"},
+		{query: "INSERT INTO users VALUES (1)"},
+	}
+	for _, tt := range tests {
+		got, ok := parseQuerySources(tt.query)
+		if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseQuerySources(%q) = %#v, %t, want %#v", tt.query, got, ok, tt.want)
+		}
+	}
+}
+
+// newTableInfoServer returns a server answering pragma_table_xinfo for the
+// users and posts tables, and every other statement with an empty result of
+// cols columns.
+func newTableInfoServer(t *testing.T, cols int) *libsqltest.Server {
+	tables := map[string][][]driver.Value{
+		"users": {{"id", "INTEGER", int64(0), int64(1), int64(0)}, {"name", "TEXT", int64(1), int64(0), int64(0)}, {"email", "TEXT", int64(0), int64(0), int64(0)}},
+		"posts": {{"id", "INTEGER", int64(0), int64(1), int64(0)}, {"title", "VARCHAR(100)", int64(1), int64(0), int64(0)}},
+	}
+	return libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		if strings.Contains(query, "pragma_table_xinfo") {
+			table, _ := args[0].Value.(string)
+			return &libsqltest.Result{Cols: []string{"name", "type", "notnull", "pk", "hidden"}, Rows: tables[table]}, nil
+		}
+		res := &libsqltest.Result{Cols: make([]string, cols)}
+		for col := range res.Cols {
+			res.Cols[col] = fmt.Sprintf("c%d", col)
+		}
+		return res, nil
+	})
+}
+
+// nullable returns the nullability of the columns of query as "null",
+// "not null" or "unknown".
+func nullable(t *testing.T, db *sql.DB, query string) []string {
+	rows, err := db.QueryContext(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(types))
+	for idx, ct := range types {
+		switch nullable, ok := ct.Nullable(); {
+		case !ok:
+			got[idx] = "unknown"
+		case nullable:
+			got[idx] = "null"
+		default:
+			got[idx] = "not null"
+		}
+	}
+	return got
+}
+
+func TestColumnTypeNullable(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "SELECT * FROM users", want: []string{"not null", "not null", "null"}},
+		{query: "SELECT u.name, p.title FROM users u LEFT JOIN posts p ON p.id = u.id", want: []string{"not null", "null"}},
+		{query: "SELECT posts.*, email FROM posts, users", want: []string{"not null", "not null", "null"}},
+		{query: "SELECT count(*), Name FROM users", want: []string{"unknown", "not null"}},
+		{query: "SELECT * FROM missing", want: []string{"unknown", "unknown"}},
+		{query: "SELECT * FROM users UNION SELECT * FROM users", want: []string{"unknown", "unknown", "unknown"}},
+	}
+	for _, tt := range tests {
+		server := newTableInfoServer(t, len(tt.want))
+		db, err := sql.Open("libsql", server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := nullable(t, db, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got nullability %v of %q, want %v", got, tt.query, tt.want)
+		}
+		db.Close()
+	}
+}
+
+func TestColumnTypeNullableSchemaChange(t *testing.T) {
+	server := newTableInfoServer(t, 3)
+	db, err := sql.Open("libsql", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	nullable(t, db, "SELECT * FROM users")
+	nullable(t, db, "SELECT * FROM users")
+	if pragmas := countStatements(server, "pragma_table_xinfo"); pragmas != 1 {
+		t.Errorf("got %d table lookups, want the columns of the table to be cached", pragmas)
+	}
+	if _, err := db.ExecContext(context.Background(), "ALTER TABLE users ADD COLUMN age INTEGER"); err != nil {
+		t.Fatal(err)
+	}
+	nullable(t, db, "SELECT * FROM users")
+	if pragmas := countStatements(server, "pragma_table_xinfo"); pragmas != 2 {
+		t.Errorf("got %d table lookups, want the columns to be looked up again after the schema changed", pragmas)
+	}
+}
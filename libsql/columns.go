@@ -0,0 +1,68 @@
+package libsql
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ColumnInfo describes a column of a result set. Hrana column descriptors
+// carry the name and the declared type of columns, the table and column a
+// value originates from are not reported by the server.
+type ColumnInfo struct {
+	// Index is the position of the column in the result set.
+	Index int
+	Name  string
+	// UniqueName is Name for the first column with that name. Later columns
+	// with the same name, like two expressions selected with the same alias,
+	// get a suffix counting the occurrences: "text", "text:2", "text:3".
+	UniqueName string
+	// DeclType is the declared type of the column in upper case, empty for
+	// expressions.
+	DeclType string
+}
+
+// Columns returns the metadata of the columns of the current result set of
+// rows, with unique names so that columns can be told apart by name.
+func Columns(rows *sql.Rows) ([]ColumnInfo, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(types))
+	for idx, t := range types {
+		names[idx] = t.Name()
+	}
+	unique := uniqueColumnNames(names)
+	cols := make([]ColumnInfo, len(types))
+	for idx, t := range types {
+		cols[idx] = ColumnInfo{Index: idx, Name: names[idx], UniqueName: unique[idx], DeclType: t.DatabaseTypeName()}
+	}
+	return cols, nil
+}
+
+func uniqueColumnNames(names []string) []string {
+	taken := make(map[string]bool, len(names))
+	for _, name := range names {
+		taken[name] = true
+	}
+	seen := make(map[string]int, len(names))
+	unique := make([]string, len(names))
+	for idx, name := range names {
+		seen[name]++
+		if seen[name] == 1 {
+			unique[idx] = name
+			continue
+		}
+		// Skip suffixes that collide with the name of another column.
+		for n := seen[name]; ; n++ {
+			candidate := fmt.Sprintf("%s:%d", name, n)
+			if !taken[candidate] {
+				taken[candidate] = true
+				seen[name] = n
+				unique[idx] = candidate
+				break
+			}
+		}
+	}
+	return unique
+}
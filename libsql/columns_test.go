@@ -0,0 +1,54 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestUniqueColumnNames(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{names: []string{"a", "b"}, want: []string{"a", "b"}},
+		{names: []string{"text", "text", "integer", "text"}, want: []string{"text", "text:2", "integer", "text:3"}},
+		{names: []string{"a", "a", "a:2"}, want: []string{"a", "a:3", "a:2"}},
+	}
+	for _, tt := range tests {
+		if got := uniqueColumnNames(tt.names); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("uniqueColumnNames(%v) = %v, want %v", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		return &libsqltest.Result{Cols: []string{"text", "text"}, DeclTypes: []string{"TEXT", ""}}, nil
+	})
+	db, err := sql.Open("libsql", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(context.Background(), "SELECT a AS text, b AS text FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	cols, err := Columns(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ColumnInfo{
+		{Index: 0, Name: "text", UniqueName: "text", DeclType: "TEXT"},
+		{Index: 1, Name: "text", UniqueName: "text:2"},
+	}
+	if !reflect.DeepEqual(cols, want) {
+		t.Errorf("got %#v, want %#v", cols, want)
+	}
+}
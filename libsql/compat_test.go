@@ -0,0 +1,214 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// The tests of this file follow the way GORM, sqlx and ent use database/sql,
+// so that the behaviors they rely on do not regress.
+
+// newProductsServer returns a server answering inserts with rowid 42,
+// updates and deletes with 2 affected rows, and queries with a single product.
+// It returns a function listing the statements received so far.
+func newProductsServer(t *testing.T) (string, func() []string) {
+	product := &libsqltest.Result{
+		Cols:      []string{"id", "name", "price", "active", "created_at", "data"},
+		DeclTypes: []string{"INTEGER", "VARCHAR(64)", "DECIMAL(10,2)", "BOOLEAN", "DATETIME", "BLOB"},
+		Rows:      [][]driver.Value{{int64(1), "pen", 1.5, int64(1), "2024-01-02 03:04:05", nil}},
+	}
+	server := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		switch {
+		case strings.HasPrefix(query, "INSERT"):
+			return &libsqltest.Result{Affected: 1, LastInsertId: 42}, nil
+		case strings.HasPrefix(query, "UPDATE"), strings.HasPrefix(query, "DELETE"):
+			return &libsqltest.Result{Affected: 2}, nil
+		case strings.HasPrefix(query, "SELECT") && !strings.Contains(query, "pragma_table_xinfo"):
+			return product, nil
+		}
+		return nil, nil
+	})
+	return server.URL, server.Statements
+}
+
+// TestCompatColumnTypes checks the column types GORM migrators and map scans
+// read.
+func TestCompatColumnTypes(t *testing.T) {
+	url, _ := newProductsServer(t)
+	db, err := sql.Open("libsql", url+"?parseTime=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(context.Background(), "SELECT id, name, price, active, created_at, data FROM products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	type columnType struct {
+		name, databaseType string
+		scanType           reflect.Type
+		length             int64
+		precision, scale   int64
+	}
+	var got []columnType
+	for _, ct := range types {
+		c := columnType{name: ct.Name(), databaseType: ct.DatabaseTypeName(), scanType: ct.ScanType()}
+		c.length, _ = ct.Length()
+		c.precision, c.scale, _ = ct.DecimalSize()
+		got = append(got, c)
+	}
+	want := []columnType{
+		{name: "id", databaseType: "INTEGER", scanType: reflect.TypeOf(int64(0))},
+		{name: "name", databaseType: "VARCHAR(64)", scanType: reflect.TypeOf(""), length: 64},
+		{name: "price", databaseType: "DECIMAL(10,2)", scanType: reflect.TypeOf((*any)(nil)).Elem(), precision: 10, scale: 2},
+		{name: "active", databaseType: "BOOLEAN", scanType: reflect.TypeOf(false)},
+		{name: "created_at", databaseType: "DATETIME", scanType: reflect.TypeOf(time.Time{})},
+		{name: "data", databaseType: "BLOB", scanType: reflect.TypeOf([]byte(nil)), length: 1<<63 - 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got column types %+v, want %+v", got, want)
+	}
+
+	// GORM scans rows into maps through pointers to pointers of the scan types.
+	dest := make([]any, len(types))
+	for idx, ct := range types {
+		dest[idx] = reflect.New(reflect.PointerTo(ct.ScanType())).Interface()
+	}
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	if err := rows.Scan(dest...); err != nil {
+		t.Fatal(err)
+	}
+	if active := *dest[3].(**bool); active == nil || !*active {
+		t.Errorf("got active %v, want true", active)
+	}
+	if data := *dest[5].(**[]byte); data != nil {
+		t.Errorf("got data %v, want nil", *data)
+	}
+}
+
+// TestCompatExecResult checks the results ent and GORM read the ids of created
+// rows and the count of updated rows from.
+func TestCompatExecResult(t *testing.T) {
+	url, _ := newProductsServer(t)
+	db, err := sql.Open("libsql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, err := db.ExecContext(context.Background(), "INSERT INTO products (name) VALUES (?)", "pen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := res.LastInsertId(); err != nil || id != 42 {
+		t.Errorf("got last insert id %d, %v, want 42", id, err)
+	}
+	res, err = db.ExecContext(context.Background(), "UPDATE products SET active = @active WHERE price > @price",
+		sql.Named("active", false), sql.Named("price", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 2 {
+		t.Errorf("got %d rows affected, %v, want 2", n, err)
+	}
+}
+
+// TestCompatSavepoints checks the nested transactions of GORM and ent, which
+// run in savepoints of the outer transaction.
+func TestCompatSavepoints(t *testing.T) {
+	url, received := newProductsServer(t)
+	db, err := sql.Open("libsql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"SAVEPOINT sp1",
+		"INSERT INTO products (name) VALUES ('pen')",
+		"SAVEPOINT sp2",
+		"DELETE FROM products",
+		"ROLLBACK TO SAVEPOINT sp2",
+		"RELEASE SAVEPOINT sp1",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"BEGIN",
+		"SAVEPOINT sp1",
+		"INSERT INTO products (name) VALUES ('pen')",
+		"SAVEPOINT sp2",
+		"DELETE FROM products",
+		"ROLLBACK TO SAVEPOINT sp2",
+		"RELEASE SAVEPOINT sp1",
+		"COMMIT",
+	}
+	if got := received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got statements %q, want %q", got, want)
+	}
+}
+
+// TestCompatStructScan checks the column names sqlx matches struct fields
+// with, and scanning into the types of its structs.
+func TestCompatStructScan(t *testing.T) {
+	url, _ := newProductsServer(t)
+	db, err := sql.Open("libsql", url+"?parseTime=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM products WHERE id IN (?, ?)", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id", "name", "price", "active", "created_at", "data"}; !reflect.DeepEqual(columns, want) {
+		t.Fatalf("got columns %v, want %v", columns, want)
+	}
+	var p struct {
+		ID        int
+		Name      string
+		Price     float64
+		Active    bool
+		CreatedAt time.Time
+		Data      []byte
+	}
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Active, &p.CreatedAt, &p.Data); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != 1 || p.Name != "pen" || p.Price != 1.5 || !p.Active || !p.CreatedAt.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) || p.Data != nil {
+		t.Errorf("got %+v", p)
+	}
+	if rows.Next() {
+		t.Error("expected a single row")
+	}
+}
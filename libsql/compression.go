@@ -0,0 +1,27 @@
+package libsql
+
+import "fmt"
+
+// WithRequestCompression compresses the bodies of Hrana over HTTP requests of
+// at least minSize bytes with gzip, which suits large batches sent over slow
+// links. The server must accept gzip encoded requests, which is why it is not
+// enabled by default.
+func WithRequestCompression(minSize int) Option {
+	return option(func(c *Connector) error {
+		if minSize <= 0 {
+			return fmt.Errorf("request compression threshold must be positive")
+		}
+		c.cfg.RequestCompressionThreshold = minSize
+		return nil
+	})
+}
+
+// WithoutResponseCompression stops asking the server for gzip compressed
+// responses over HTTP. Responses are compressed by default when the server
+// supports it, saving bandwidth at the cost of some CPU to decompress them.
+func WithoutResponseCompression() Option {
+	return option(func(c *Connector) error {
+		c.cfg.DisableResponseCompression = true
+		return nil
+	})
+}
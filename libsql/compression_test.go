@@ -0,0 +1,106 @@
+package libsql
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+)
+
+type compressionRequest struct {
+	contentEncoding string
+	acceptEncoding  string
+	sql             string
+}
+
+// newCompressingServer returns a server decoding gzip encoded requests and
+// compressing its responses when the client accepts gzip.
+func newCompressingServer(t *testing.T, requests *[]compressionRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3" {
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			body = gz
+		}
+		var req hrana.PipelineRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		*requests = append(*requests, compressionRequest{
+			contentEncoding: r.Header.Get("Content-Encoding"),
+			acceptEncoding:  r.Header.Get("Accept-Encoding"),
+			sql:             *req.Requests[0].Stmt.Sql,
+		})
+		var out io.Writer = w
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+		_, err := io.WriteString(out, `{"baton":"b","base_url":null,"results":[{"type":"ok","response":{"type":"execute","result":`+
+			`{"cols":[{"name":"v"}],"rows":[[{"type":"text","value":"ok"}]],"affected_row_count":0,"last_insert_rowid":null}}}]}`)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+func TestCompression(t *testing.T) {
+	long := "SELECT '" + strings.Repeat("x", 1000) + "'"
+	tests := []struct {
+		name string
+		opts []Option
+		sql  string
+		want compressionRequest
+	}{
+		{name: "default", sql: long, want: compressionRequest{acceptEncoding: "gzip"}},
+		{name: "small request", opts: []Option{WithRequestCompression(1024)}, sql: "SELECT 1", want: compressionRequest{acceptEncoding: "gzip"}},
+		{name: "large request", opts: []Option{WithRequestCompression(1024)}, sql: long, want: compressionRequest{contentEncoding: "gzip", acceptEncoding: "gzip"}},
+		{name: "without response compression", opts: []Option{WithoutResponseCompression()}, sql: long, want: compressionRequest{acceptEncoding: "identity"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []compressionRequest
+			server := newCompressingServer(t, &requests)
+			defer server.Close()
+			connector, err := NewConnector(server.URL, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			var v string
+			if err := db.QueryRowContext(context.Background(), tt.sql).Scan(&v); err != nil {
+				t.Fatal(err)
+			}
+			if v != "ok" {
+				t.Errorf("got %#v, want the decompressed response", v)
+			}
+			tt.want.sql = tt.sql
+			if len(requests) != 1 || requests[0] != tt.want {
+				t.Errorf("got %#v, want %#v", requests, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRequestCompressionInvalid(t *testing.T) {
+	if _, err := NewConnector("https://db", WithRequestCompression(0)); err == nil {
+		t.Error("expected a zero threshold to be rejected")
+	}
+}
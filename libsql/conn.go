@@ -0,0 +1,395 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
+
+// Conn is the connection of the driver. It wraps the connection of a remote
+// transport and implements the parts of the database/sql driver interfaces that
+// behave the same for every transport.
+//
+// Besides the driver interfaces, Conn offers methods that database/sql cannot
+// express, reached through sql.Conn.Raw:
+//
+//	err := sqlConn.Raw(func(driverConn any) error {
+//		c := driverConn.(*libsql.Conn)
+//		log.Print(c.ReplicationIndex())
+//		return nil
+//	})
+//
+// A Conn must not be used after the function given to Raw returns.
+type Conn struct {
+	// transport is the connection of the transport, or of the database
+	// selected with WithRequestDatabase.
+	transport driver.Conn
+	connector *Connector
+	// buffered is the buffered transaction open on the connection, if any.
+	buffered *bufferedTx
+	// attached is the number of databases attached to the connection.
+	attached int
+	// inTx is set while a transaction of the transport is open.
+	inTx bool
+	// txTimedOut is the error of the statement of the open transaction that
+	// exceeded the transaction statement timeout, if any.
+	txTimedOut *StatementTimeoutError
+	// replica is the connection to the read replica, opened by the first
+	// read-only query or transaction.
+	replica driver.Conn
+	// onReplica is set while a read-only transaction is open on the replica.
+	onReplica bool
+	// database is the database the transport connection was opened for, as
+	// set by WithDatabase or WithRequestDatabase.
+	database string
+	// replicationIndex is the highest replication index reported for the
+	// writes executed on the connection.
+	replicationIndex uint64
+	// stats are the execution statistics of the statements executed on the
+	// connection.
+	stats Stats
+	// txWrites are the writes of the open transaction, reported to the write
+	// hook once it commits.
+	txWrites []*writeTargets
+}
+
+func newConn(c driver.Conn, connector *Connector) *Conn {
+	return &Conn{transport: c, connector: connector, database: connector.cfg.Database}
+}
+
+func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	// Slices are converted element by element once expanded.
+	if c.connector.expandSlices && isSliceArg(nv.Value) {
+		return nil
+	}
+	return c.connector.checker.CheckNamedValue(nv)
+}
+
+// IsValid lets transports whose connection became unusable have database/sql
+// discard it.
+func (c *Conn) IsValid() bool {
+	if v, ok := c.transport.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// ResetSession lets transports discard a connection before it is reused, for
+// example once its stream was idle for too long.
+func (c *Conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.transport.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// MultipleStatementsError is returned when preparing a query holding more than
+// one statement. Like the tail left by sqlite3_prepare_v2, Offset is the byte
+// offset in Query where the second statement starts.
+type MultipleStatementsError struct {
+	Query  string
+	Offset int
+}
+
+func (e *MultipleStatementsError) Error() string {
+	return fmt.Sprintf("only one statement can be prepared, a second statement starts at offset %d: %s", e.Offset, e.Query[e.Offset:])
+}
+
+func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if offset, ok := shared.SecondStatementOffset(query); ok {
+		return nil, &MultipleStatementsError{Query: query, Offset: offset}
+	}
+	if err := c.route(ctx); err != nil {
+		return nil, err
+	}
+	var s driver.Stmt
+	var err error
+	if p, ok := c.target().(driver.ConnPrepareContext); ok {
+		s, err = p.PrepareContext(ctx, query)
+	} else {
+		s, err = c.target().Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.connector.diagnostics.checkQuery(query)
+	return &stmt{Stmt: s, conn: c, query: query, database: c.database}, nil
+}
+
+func (c *Conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// Begin starts a transaction with the default options.
+//
+// Deprecated: Use BeginTx, which database/sql calls instead.
+func (c *Conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.route(ctx); err != nil {
+		return nil, err
+	}
+	if bufferedTransaction(ctx) {
+		mode := ctxopt.TxMode(ctx)
+		if _, err := shared.BeginStatement(driver.TxOptions{}, mode); err != nil {
+			return nil, err
+		}
+		c.buffered = &bufferedTx{conn: c, mode: mode}
+		return c.connector.diagnostics.watchTx(c.buffered), nil
+	}
+	t := c.beginReplicaTx(ctx, opts)
+	if t == nil {
+		var err error
+		if t, err = c.beginTransportTx(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+	c.inTx, c.txTimedOut = true, nil
+	return c.connector.diagnostics.watchTx(&connTx{Tx: t, conn: c}), nil
+}
+
+// connTx tracks when the transaction of a connection ends.
+type connTx struct {
+	driver.Tx
+	conn *Conn
+}
+
+func (t *connTx) Commit() error {
+	t.conn.inTx, t.conn.onReplica = false, false
+	if timedOut := t.conn.txTimedOut; timedOut != nil {
+		t.conn.txTimedOut = nil
+		t.conn.endTxWrites(false)
+		t.Tx.Rollback()
+		return fmt.Errorf("cannot commit: %w", timedOut)
+	}
+	err := t.Tx.Commit()
+	t.conn.endTxWrites(err == nil)
+	return err
+}
+
+// Rollback of a transaction that exceeded the statement timeout succeeds at
+// once, the server rolled it back when its stream was closed.
+func (t *connTx) Rollback() error {
+	t.conn.inTx, t.conn.onReplica = false, false
+	t.conn.endTxWrites(false)
+	if t.conn.txTimedOut != nil {
+		t.conn.txTimedOut = nil
+		t.Tx.Rollback()
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+func (c *Conn) Close() error {
+	c.closeReplica()
+	return c.transport.Close()
+}
+
+func (c *Conn) beginTransportTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.transport.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.transport.Begin() //nolint:staticcheck
+}
+
+var errBufferedAttach = errors.New("databases cannot be attached or detached in a buffered transaction")
+
+func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, args, err := c.expandSliceArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkMaintenance(query); err != nil {
+		return nil, err
+	}
+	if err := c.route(ctx); err != nil {
+		return nil, err
+	}
+	delta := attachDelta(query)
+	if delta != 0 && c.buffered != nil {
+		return nil, errBufferedAttach
+	}
+	if c.buffered != nil {
+		c.connector.diagnostics.checkQuery(query)
+		return c.buffered.add(query, args), nil
+	}
+	if e, ok := c.target().(driver.ExecerContext); ok {
+		c.connector.diagnostics.checkQuery(query)
+		start := time.Now()
+		sctx, guarded := c.statementContext(ctx)
+		exec := func() (driver.Result, error) { return e.ExecContext(sctx, query, args) }
+		var res driver.Result
+		var err error
+		if delta != 0 {
+			res, err = c.execAttach(delta, exec)
+		} else {
+			res, err = c.retryBusy(sctx, query, exec)
+		}
+		err = c.statementError(ctx, guarded, query, err)
+		c.recordExec(query, start, res, err)
+		if err == nil {
+			c.recordReplicationIndex(ctx, res)
+			c.recordStats(ctx, res)
+			c.recordSchemaChange(query)
+			c.recordWrites(query)
+		}
+		return res, err
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *Conn) recordExec(query string, start time.Time, res driver.Result, err error) {
+	if !c.connector.metrics {
+		return
+	}
+	var rows int64
+	if err == nil {
+		rows, _ = res.RowsAffected()
+	}
+	registry.record(query, time.Since(start), rows, err)
+}
+
+func (c *Conn) recordQuery(query string, start time.Time, err error) {
+	if c.connector.metrics {
+		registry.record(query, time.Since(start), 0, err)
+	}
+}
+
+func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.buffered != nil {
+		return nil, errBufferedQuery
+	}
+	query, args, err := c.expandSliceArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkMaintenance(query); err != nil {
+		return nil, err
+	}
+	if err := c.route(ctx); err != nil {
+		return nil, err
+	}
+	if q, ok := c.target().(driver.QueryerContext); ok {
+		c.connector.diagnostics.checkQuery(query)
+		start := time.Now()
+		sctx, guarded := c.statementContext(ctx)
+		r, err := c.query(sctx, q, query, args)
+		err = c.statementError(ctx, guarded, query, err)
+		c.recordQuery(query, start, err)
+		if err != nil {
+			return nil, err
+		}
+		c.recordWrites(query)
+		return wrapRows(ctx, r, c, query), nil
+	}
+	return nil, driver.ErrSkip
+}
+
+type stmt struct {
+	driver.Stmt
+	conn  *Conn
+	query string
+	// database is the database the statement was prepared for.
+	database string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.conn.checkMaintenance(s.query); err != nil {
+		return nil, err
+	}
+	if err := s.checkDatabase(ctx); err != nil {
+		return nil, err
+	}
+	if s.conn.connector.expandSlices && hasSliceArg(args) {
+		return s.conn.ExecContext(ctx, s.query, args)
+	}
+	delta := attachDelta(s.query)
+	if s.conn.buffered != nil {
+		if delta != 0 {
+			return nil, errBufferedAttach
+		}
+		return s.conn.buffered.add(s.query, args), nil
+	}
+	start := time.Now()
+	sctx, guarded := s.conn.statementContext(ctx)
+	var res driver.Result
+	var err error
+	if delta != 0 {
+		res, err = s.conn.execAttach(delta, func() (driver.Result, error) { return s.exec(sctx, args) })
+	} else {
+		res, err = s.conn.retryBusy(sctx, s.query, func() (driver.Result, error) { return s.exec(sctx, args) })
+	}
+	err = s.conn.statementError(ctx, guarded, s.query, err)
+	s.conn.recordExec(s.query, start, res, err)
+	if err == nil {
+		s.conn.recordReplicationIndex(ctx, res)
+		s.conn.recordStats(ctx, res)
+		s.conn.recordSchemaChange(s.query)
+		s.conn.recordWrites(s.query)
+	}
+	return res, err
+}
+
+func (s *stmt) exec(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values) //nolint:staticcheck
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if s.conn.buffered != nil {
+		return nil, errBufferedQuery
+	}
+	if err := s.conn.checkMaintenance(s.query); err != nil {
+		return nil, err
+	}
+	if err := s.checkDatabase(ctx); err != nil {
+		return nil, err
+	}
+	if s.conn.connector.expandSlices && hasSliceArg(args) {
+		return s.conn.QueryContext(ctx, s.query, args)
+	}
+	start := time.Now()
+	sctx, guarded := s.conn.statementContext(ctx)
+	var r driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		r, err = q.QueryContext(sctx, args)
+		err = s.conn.statementError(ctx, guarded, s.query, err)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err != nil {
+			return nil, err
+		}
+		r, err = s.Stmt.Query(values) //nolint:staticcheck
+	}
+	s.conn.recordQuery(s.query, start, err)
+	if err != nil {
+		return nil, err
+	}
+	s.conn.recordWrites(s.query)
+	return wrapRows(ctx, r, s.conn, s.query), nil
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for idx, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("named parameters are not supported by this statement")
+		}
+		values[idx] = arg.Value
+	}
+	return values, nil
+}
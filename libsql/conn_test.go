@@ -0,0 +1,52 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestPrepareMultipleStatements(t *testing.T) {
+	db := sql.OpenDB(transportConnector{&statelessConn{}})
+	defer db.Close()
+	query := "UPDATE t SET a = 1; DELETE FROM t"
+	_, err := db.PrepareContext(context.Background(), query)
+	var multiple *MultipleStatementsError
+	if !errors.As(err, &multiple) {
+		t.Fatalf("got %v, want MultipleStatementsError", err)
+	}
+	if tail := multiple.Query[multiple.Offset:]; tail != "DELETE FROM t" {
+		t.Errorf("got tail %#v, want the second statement", tail)
+	}
+}
+
+func TestParallelQueries(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		return &libsqltest.Result{Cols: []string{"v"}, Rows: [][]driver.Value{{int64(1)}}}, nil
+	})
+
+	connector, err := NewConnector(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v int
+			if err := db.QueryRowContext(context.Background(), "SELECT ?", 1).Scan(&v); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
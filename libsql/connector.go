@@ -0,0 +1,188 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/dburl"
+	"github.com/libsql/libsql-client-go/libsql/internal/http"
+	"github.com/libsql/libsql-client-go/libsql/internal/params"
+	"github.com/libsql/libsql-client-go/libsql/internal/ws"
+)
+
+// Connector opens connections to a database. Use it with sql.OpenDB to
+// configure the driver with options that cannot be expressed in the URL.
+type Connector struct {
+	dbUrl string
+	// url is nil for file URLs which are handled by a sqlite driver.
+	url          *url.URL
+	token        *auth.Token
+	tls          bool
+	checker      params.Checker
+	parseTime    bool
+	strictTypes  bool
+	expandSlices bool
+	cfg          config.Config
+	diagnostics  diagnosticsConfig
+	metrics      bool
+	deprecations []string
+	attachments  []attachment
+	replica      *replica
+	// replicationIndex is the highest replication index reported by writes.
+	replicationIndex atomic.Uint64
+	columns          columnMetadata
+	writes           writeTracker
+	// txStatementTimeout bounds the statements of transactions, zero if
+	// unbounded.
+	txStatementTimeout time.Duration
+	// busyTimeout bounds the retries of statements failing with SQLITE_BUSY
+	// or SQLITE_LOCKED, zero if they are not retried.
+	busyTimeout time.Duration
+	// wsPools holds the websockets shared by the connections.
+	wsPools ws.Pools
+}
+
+type Option interface {
+	apply(*Connector) error
+}
+
+type option func(*Connector) error
+
+func (o option) apply(c *Connector) error {
+	return o(c)
+}
+
+// NewConnector parses dbUrl the same way sql.Open("libsql", dbUrl) does and
+// applies opts on top of the settings from the URL.
+func NewConnector(dbUrl string, opts ...Option) (*Connector, error) {
+	c, err := parseUrl(dbUrl)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		if err := opt.apply(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.configureDialer(); err != nil {
+		return nil, err
+	}
+	c.token.SetLogger(c.cfg.Logger)
+	if c.replica != nil {
+		c.replica.token.SetLogger(c.cfg.Logger)
+	}
+	for _, msg := range c.deprecations {
+		c.diagnostics.report(Diagnostic{Kind: DiagnosticDeprecated, Message: msg})
+	}
+	return c, nil
+}
+
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.url == nil {
+		dc, err := openSqliteFile(c.dbUrl)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.attach(ctx, dc); err != nil {
+			dc.Close()
+			return nil, err
+		}
+		return dc, nil
+	}
+	if c.cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.ConnectTimeout)
+		defer cancel()
+	}
+	transportConn, err := c.connectTransport(ctx, *c.url, c.tls, c.token, c.cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	lc := newConn(transportConn, c)
+	if err := c.attach(ctx, lc); err != nil {
+		lc.Close()
+		return nil, err
+	}
+	return lc, nil
+}
+
+// connectTransport opens a connection to database on u with the transport
+// selected by its scheme.
+func (c *Connector) connectTransport(ctx context.Context, u url.URL, tls bool, token *auth.Token, database string) (driver.Conn, error) {
+	cfg := &c.cfg
+	if database != cfg.Database {
+		routed := c.cfg
+		routed.Database = database
+		cfg = &routed
+	}
+	switch u.Scheme {
+	case "libsql":
+		return connectNegotiated(ctx, &c.wsPools, &u, tls, token, cfg)
+	case "wss", "ws":
+		wc, err := c.wsPools.Connect(ctx, u.String(), token, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return wc, nil
+	default:
+		return http.Connect(ctx, u.String(), token, cfg)
+	}
+}
+
+// Close releases the resources shared by the connections of the connector:
+// idle websockets and proxied HTTP connections are closed at once and the
+// websockets of open connections once these are closed. sql.DB.Close calls
+// it, after closing the idle connections of the database. The connector can
+// still open connections afterwards, which start new websockets.
+func (c *Connector) Close() error {
+	c.wsPools.Close()
+	if t, ok := c.cfg.Transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (c *Connector) Driver() driver.Driver {
+	return libsqlDriver
+}
+
+// httpUrl returns the base URL used for requests made over plain HTTP,
+// whatever the transport used by connections.
+func (c *Connector) httpUrl() (*url.URL, error) {
+	if c.url == nil {
+		return nil, fmt.Errorf("%s is not a remote database", c.dbUrl)
+	}
+	return dburl.HTTP(*c.url, c.tls), nil
+}
+
+// sqliteDriverName returns the name of the registered sqlite driver used for
+// local database files.
+func sqliteDriverName() (string, error) {
+	expectedDrivers := []string{"sqlite", "sqlite3"}
+	presentDrivers := sql.Drivers()
+	for _, expectedDriver := range expectedDrivers {
+		if contains(presentDrivers, expectedDriver) {
+			return expectedDriver, nil
+		}
+	}
+	return "", fmt.Errorf("no sqlite driver present. Please import sqlite or sqlite3 driver.")
+}
+
+func openSqliteFile(dbUrl string) (driver.Conn, error) {
+	driverName, err := sqliteDriverName()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driverName, dbUrl)
+	if err != nil {
+		return nil, err
+	}
+	return db.Driver().Open(dbUrl)
+}
@@ -0,0 +1,88 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// newWebsocketServer returns the URL of a Hrana server over websockets
+// answering every request with an empty result, and counting the websockets
+// open to it in open.
+func newWebsocketServer(t *testing.T, open *int32) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"hrana1"}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		atomic.AddInt32(open, 1)
+		defer atomic.AddInt32(open, -1)
+		ctx := context.Background()
+		var hello map[string]interface{}
+		if err := wsjson.Read(ctx, c, &hello); err != nil {
+			return
+		}
+		if err := wsjson.Write(ctx, c, map[string]interface{}{"type": "hello_ok"}); err != nil {
+			return
+		}
+		for {
+			var req map[string]interface{}
+			if err := wsjson.Read(ctx, c, &req); err != nil {
+				return
+			}
+			result := map[string]interface{}{"type": req["request"].(map[string]interface{})["type"]}
+			if result["type"] == "execute" {
+				result["result"] = map[string]interface{}{"cols": []interface{}{}, "rows": []interface{}{}, "affected_row_count": 0}
+			}
+			err := wsjson.Write(ctx, c, map[string]interface{}{
+				"type":       "response_ok",
+				"request_id": req["request_id"],
+				"response":   result,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestConnectorClose(t *testing.T) {
+	var open int32
+	connector, err := NewConnector(newWebsocketServer(t, &open), WithWebsocketWarmup(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&open); got != 2 {
+		t.Errorf("got %d open websockets, want the 2 warmed up", got)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := WaitIdle(ctx); err != nil {
+		t.Errorf("got %v, want the readers of the websockets to stop", err)
+	}
+	for atomic.LoadInt32(&open) > 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	if got := atomic.LoadInt32(&open); got != 0 {
+		t.Errorf("got %d open websockets after closing the database, want 0", got)
+	}
+}
@@ -0,0 +1,76 @@
+package libsql
+
+import (
+	"context"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/background"
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+)
+
+// WithAtomicBatch returns a context requesting that a query made of several
+// statements is applied atomically. Batches too large for a single request are
+// then split and executed inside an interactive transaction, unless the
+// connection is already in one.
+func WithAtomicBatch(ctx context.Context) context.Context {
+	return ctxopt.WithAtomicBatch(ctx)
+}
+
+// WithTimeoutHint returns a context whose requests are bounded by d instead of
+// the request timeout of the connector, or the 60 seconds of Hrana over HTTP
+// by default, like a longer limit for a VACUUM or a shorter one for a lookup
+// on a hot path. Unlike context.WithTimeout, which can only shorten the
+// limit, the hint replaces it. A deadline of ctx still applies.
+func WithTimeoutHint(ctx context.Context, d time.Duration) context.Context {
+	return ctxopt.WithTimeoutHint(ctx, d)
+}
+
+// TxMode is the mode a transaction begins in, as described in
+// https://www.sqlite.org/lang_transaction.html.
+type TxMode string
+
+const (
+	// TxDeferred takes no lock until the transaction first reads or writes.
+	TxDeferred TxMode = "DEFERRED"
+	// TxImmediate takes the write lock at once, so a transaction that reads
+	// before writing cannot fail with SQLITE_BUSY when it starts writing.
+	TxImmediate TxMode = "IMMEDIATE"
+	// TxExclusive also keeps other connections from reading, except in WAL
+	// mode where it behaves like TxImmediate.
+	TxExclusive TxMode = "EXCLUSIVE"
+)
+
+// WithTxMode returns a context for BeginTx that starts a transaction in mode,
+// whatever the isolation level of the sql.TxOptions, which otherwise select
+// the mode: LevelSerializable begins in TxImmediate and LevelLinearizable in
+// TxExclusive. Write-heavy transactions use TxImmediate so that two of them
+// reading first cannot deadlock when both upgrade to writing. Read-only
+// transactions may only be deferred. The mode also applies to buffered
+// transactions and to the transaction of WithAtomicBatch.
+func WithTxMode(ctx context.Context, mode TxMode) context.Context {
+	return ctxopt.WithTxMode(ctx, string(mode))
+}
+
+type bufferedTransactionKey struct{}
+
+// WithBufferedTransaction returns a context for BeginTx that starts a buffered
+// transaction. Its statements are queued on the client and sent together as a
+// single atomic batch by Commit, which saves a round trip per statement.
+// Queries are rejected, since they could not see the queued writes, and the
+// results of Exec report no rows affected nor insert ids.
+func WithBufferedTransaction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bufferedTransactionKey{}, true)
+}
+
+func bufferedTransaction(ctx context.Context) bool {
+	v, _ := ctx.Value(bufferedTransactionKey{}).(bool)
+	return v
+}
+
+// WaitIdle blocks until every goroutine the driver runs in the background, like
+// the readers of websockets, has returned or until ctx is done. Background work
+// stops once the connections it serves are closed, so closing every sql.DB
+// first and then calling WaitIdle lets tests check that nothing leaked.
+func WaitIdle(ctx context.Context) error {
+	return background.Wait(ctx)
+}
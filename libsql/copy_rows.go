@@ -0,0 +1,148 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type CopyOptions struct {
+	BulkInsertOptions
+	// Columns are the columns of the table the columns of the source rows are
+	// copied to, in order. They default to the names of the source columns.
+	Columns []string
+	// ChunkRows is the number of rows inserted by each transaction. It
+	// defaults to 1000.
+	ChunkRows int
+	// Skip is the number of source rows to skip, to resume a copy that was
+	// interrupted after Skip rows were committed.
+	Skip int64
+	// Progress, if set, is called after each committed chunk with the number
+	// of source rows copied so far, skipped rows included. It is the value of
+	// Skip that resumes the copy after a failure.
+	Progress func(copied int64)
+}
+
+const defaultCopyChunkRows = 1000
+
+// CopyRows copies the rows of src, typically a query on another database being
+// migrated to libsql, into table. Rows are inserted with BulkInsert in chunks
+// of opts.ChunkRows rows, each in its own transaction, so a failed copy keeps
+// the chunks committed before the failure and can resume from them with
+// opts.Skip. It returns the number of rows copied from src, skipped rows
+// included, which is the value of Skip to resume with when it fails.
+//
+// Scanned byte slices are copied as text unless the database type of their
+// source column is a binary type, since drivers like MySQL's return text
+// columns as byte slices. CopyRows does not close src.
+func CopyRows(ctx context.Context, db *sql.DB, table string, src *sql.Rows, opts *CopyOptions) (int64, error) {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+	types, err := src.ColumnTypes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the source columns: %w", err)
+	}
+	columns := opts.Columns
+	if columns == nil {
+		for _, t := range types {
+			columns = append(columns, t.Name())
+		}
+	}
+	if len(columns) != len(types) {
+		return 0, fmt.Errorf("%d columns cannot hold source rows of %d columns", len(columns), len(types))
+	}
+	chunkRows := opts.ChunkRows
+	if chunkRows <= 0 {
+		chunkRows = defaultCopyChunkRows
+	}
+
+	var copied int64
+	for ; copied < opts.Skip; copied++ {
+		if !src.Next() {
+			if err := src.Err(); err != nil {
+				return copied, fmt.Errorf("failed to skip source rows: %w", err)
+			}
+			return copied, nil
+		}
+	}
+	chunk := &copyChunk{src: src, binary: make([]bool, len(types))}
+	for idx, t := range types {
+		chunk.binary[idx] = isBinaryType(t.DatabaseTypeName())
+	}
+	for {
+		// Checking for a row first spares an empty transaction once src
+		// is exhausted.
+		if !src.Next() {
+			if err := src.Err(); err != nil {
+				return copied, fmt.Errorf("failed to read source rows: %w", err)
+			}
+			return copied, nil
+		}
+		chunk.left, chunk.pending = chunkRows, true
+		n, err := BulkInsert(ctx, db, table, columns, chunk, &opts.BulkInsertOptions)
+		if err != nil {
+			return copied, fmt.Errorf("failed to copy rows after %d rows: %w", copied, err)
+		}
+		copied += n
+		if opts.Progress != nil {
+			opts.Progress(copied)
+		}
+	}
+}
+
+// copyChunk yields up to left rows of src to BulkInsert.
+type copyChunk struct {
+	src    *sql.Rows
+	binary []bool
+	left   int
+	// pending is set when src was already advanced to the next row.
+	pending bool
+}
+
+func (c *copyChunk) Next() ([]any, error) {
+	if c.left == 0 {
+		return nil, io.EOF
+	}
+	if c.pending {
+		c.pending = false
+	} else if !c.src.Next() {
+		if err := c.src.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read source rows: %w", err)
+		}
+		return nil, io.EOF
+	}
+	row := make([]any, len(c.binary))
+	dest := make([]any, len(row))
+	for idx := range row {
+		dest[idx] = &row[idx]
+	}
+	if err := c.src.Scan(dest...); err != nil {
+		return nil, fmt.Errorf("failed to scan source row: %w", err)
+	}
+	for idx, v := range row {
+		if b, ok := v.([]byte); ok && !c.binary[idx] {
+			row[idx] = string(b)
+		}
+	}
+	c.left--
+	return row, nil
+}
+
+// isBinaryType reports whether a column of the database type name holds bytes
+// rather than text. Unknown types are binary, so that bytes are kept as they
+// are.
+func isBinaryType(name string) bool {
+	name = strings.ToUpper(name)
+	if name == "" {
+		return true
+	}
+	for _, binary := range []string{"BLOB", "BINARY", "BYTEA", "BIT"} {
+		if strings.Contains(name, binary) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// sourceRows returns rows of a source database with a text column returned as
+// bytes, like MySQL does, and a blob column.
+func sourceRows(t *testing.T, values [][]driver.Value) *sql.Rows {
+	src := sql.OpenDB(libsqltest.Func(func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		return &libsqltest.Result{
+			Cols:      []string{"id", "name", "data"},
+			DeclTypes: []string{"BIGINT", "VARCHAR", "BLOB"},
+			Rows:      values,
+		}, nil
+	}))
+	t.Cleanup(func() { src.Close() })
+	r, err := src.Query("SELECT id, name, data FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+// newCopyServer returns a database recording the statements it receives and
+// failing those whose record contains fail.
+func newCopyServer(t *testing.T, fail string) (*sql.DB, func() []string) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		if fail != "" && strings.Contains(stmt, fail) {
+			return nil, errors.New("connection lost")
+		}
+		return nil, nil
+	})
+	db, _ := libsqltest.Open(t, f)
+	return db, executed
+}
+
+func TestCopyRows(t *testing.T) {
+	db, executed := newCopyServer(t, "")
+
+	src := sourceRows(t, [][]driver.Value{
+		{int64(1), []byte("a"), []byte{1}},
+		{int64(2), []byte("b"), []byte{2}},
+		{int64(3), []byte("c"), []byte{3}},
+		{int64(4), []byte("d"), nil},
+	})
+	var progress []int64
+	n, err := CopyRows(context.Background(), db, "users", src, &CopyOptions{
+		ChunkRows: 2,
+		Skip:      1,
+		Progress:  func(copied int64) { progress = append(progress, copied) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || !reflect.DeepEqual(progress, []int64{3, 4}) {
+		t.Errorf("got %d rows copied and progress %v, want 4 and [3 4]", n, progress)
+	}
+	want := []string{
+		"BEGIN",
+		`INSERT INTO "users" ("id", "name", "data") VALUES (?, ?, ?), (?, ?, ?) 2 b [2] 3 c [3]`,
+		"COMMIT",
+		"BEGIN",
+		`INSERT INTO "users" ("id", "name", "data") VALUES (?, ?, ?) 4 d <nil>`,
+		"COMMIT",
+	}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCopyRowsResumesFromCommittedChunks(t *testing.T) {
+	db, executed := newCopyServer(t, "VALUES (?, ?, ?) 2")
+
+	src := sourceRows(t, [][]driver.Value{{int64(1), []byte("a"), nil}, {int64(2), []byte("b"), nil}})
+	n, err := CopyRows(context.Background(), db, "t", src, &CopyOptions{Columns: []string{"a", "b", "c"}, ChunkRows: 1})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if n != 1 {
+		t.Errorf("got %d rows copied, want the committed chunk", n)
+	}
+	want := []string{
+		"BEGIN",
+		`INSERT INTO "t" ("a", "b", "c") VALUES (?, ?, ?) 1 a <nil>`,
+		"COMMIT",
+		"BEGIN",
+		`INSERT INTO "t" ("a", "b", "c") VALUES (?, ?, ?) 2 b <nil>`,
+		"ROLLBACK",
+	}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
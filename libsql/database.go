@@ -0,0 +1,124 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/dburl"
+)
+
+// WithDatabase routes the requests of the connector to the logical database,
+// or namespace, name of a multi-tenant sqld, like the database URL
+// libsql://host/name or the database query parameter do. The name is sent in
+// the X-Namespace header of HTTP requests and websocket handshakes.
+func WithDatabase(name string) Option {
+	return option(func(c *Connector) error {
+		if err := dburl.CheckDatabaseName(name); err != nil {
+			return err
+		}
+		c.cfg.Database = name
+		return nil
+	})
+}
+
+type requestDatabaseKey struct{}
+
+// WithRequestDatabase returns a context whose queries, statements and
+// transactions run on the logical database name of a multi-tenant sqld instead
+// of the database of the connector, so a single sql.DB serves every tenant:
+//
+//	rows, err := db.QueryContext(libsql.WithRequestDatabase(ctx, tenant), "SELECT * FROM users")
+//
+// A connection of the pool switches to name by opening a stream, or a
+// websocket, for it, and keeps it until a request targets another database,
+// which cannot happen inside a transaction or while databases are attached.
+// Requests made without WithRequestDatabase go to the database of the
+// connector, except inside a transaction where they stay on its database.
+// Prepared statements are bound to the database they were prepared for, using
+// one for another database has database/sql discard its connection and
+// prepare it again, so queries shared by tenants are better run with
+// db.QueryContext than prepared once.
+func WithRequestDatabase(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, requestDatabaseKey{}, name)
+}
+
+// requestedDatabase returns the database requested by ctx with
+// WithRequestDatabase, and false if it requests none.
+func requestedDatabase(ctx context.Context) (string, bool, error) {
+	name, ok := ctx.Value(requestDatabaseKey{}).(string)
+	if !ok {
+		return "", false, nil
+	}
+	if err := dburl.CheckDatabaseName(name); err != nil {
+		return "", false, err
+	}
+	return name, true, nil
+}
+
+// route switches the connection to the database requested by ctx, or to the
+// database of the connector if ctx requests none, replacing its transport
+// connection by one opened for that database. Inside a transaction or while
+// databases are attached the connection stays on its database, and requests
+// for another one fail.
+func (c *Conn) route(ctx context.Context) error {
+	database, requested, err := requestedDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	if !requested {
+		database = c.connector.cfg.Database
+	}
+	if database == c.database {
+		return nil
+	}
+	if c.inTx || c.buffered != nil || c.attached > 0 {
+		if !requested {
+			return nil
+		}
+		if c.attached > 0 {
+			return fmt.Errorf("cannot switch to database %q while databases are attached", database)
+		}
+		return fmt.Errorf("cannot switch to database %q inside a transaction", database)
+	}
+	if c.connector.cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.connector.cfg.ConnectTimeout)
+		defer cancel()
+	}
+	transportConn, err := c.connector.connectTransport(ctx, *c.connector.url, c.connector.tls, c.connector.token, database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %q: %w", database, err)
+	}
+	if err := c.connector.attach(ctx, transportConn); err != nil {
+		transportConn.Close()
+		return err
+	}
+	c.closeReplica()
+	if err := c.transport.Close(); err != nil {
+		c.connector.cfg.Log().DebugContext(ctx, "failed to close the connection of the previous database", "database", c.database, "error", err)
+	}
+	c.transport, c.database = transportConn, database
+	return nil
+}
+
+// checkDatabase fails with driver.ErrBadConn the executions of s for another
+// database than the one s was prepared for, as routed by route, or once its
+// connection switched to another database, so that database/sql prepares s
+// again on a connection for the right database.
+func (s *stmt) checkDatabase(ctx context.Context) error {
+	database, requested, err := requestedDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	if !requested {
+		database = s.conn.connector.cfg.Database
+		if s.conn.inTx || s.conn.buffered != nil || s.conn.attached > 0 {
+			database = s.database
+		}
+	}
+	if database != s.database || s.conn.database != s.database {
+		return fmt.Errorf("statement was prepared for database %q: %w", s.database, driver.ErrBadConn)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestParseDatabase(t *testing.T) {
+	tests := []struct {
+		dbUrl    string
+		database string
+		url      string
+		wantErr  string
+	}{
+		{dbUrl: "libsql://db.example.com", url: "libsql://db.example.com"},
+		{dbUrl: "libsql://db.example.com/", url: "libsql://db.example.com/"},
+		{dbUrl: "libsql://db.example.com/tenant-1", database: "tenant-1", url: "libsql://db.example.com"},
+		{dbUrl: "libsql://db.example.com?database=tenant_2", database: "tenant_2", url: "libsql://db.example.com"},
+		{dbUrl: "libsql://db.example.com/a?database=a", database: "a", url: "libsql://db.example.com"},
+		{dbUrl: "https://db.example.com/prefix?database=a", database: "a", url: "https://db.example.com/prefix"},
+		{dbUrl: "https://db.example.com/prefix", url: "https://db.example.com/prefix"},
+		{dbUrl: "libsql://db.example.com/a?database=b", wantErr: "in the path"},
+		{dbUrl: "libsql://db.example.com/a/b", wantErr: "is a database name"},
+		{dbUrl: "libsql://db.example.com?database=a.b", wantErr: "invalid database name"},
+	}
+	for _, tt := range tests {
+		c, err := parseUrl(tt.dbUrl)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want %#v", tt.dbUrl, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.dbUrl, err)
+			continue
+		}
+		if c.cfg.Database != tt.database || c.url.String() != tt.url {
+			t.Errorf("%s: got database %q and URL %s, want %q and %s", tt.dbUrl, c.cfg.Database, c.url, tt.database, tt.url)
+		}
+	}
+}
+
+// newNamespaceServer returns a server and a function listing the statements
+// it executed since the previous call, prefixed with the X-Namespace header of
+// their request.
+func newNamespaceServer(t *testing.T) (string, func() []string) {
+	server := libsqltest.NewFuncServer(t, nil)
+	seen := 0
+	return server.URL, func() []string {
+		requests := server.Requests()
+		var res []string
+		for _, r := range requests[seen:] {
+			for _, stmt := range r.Statements {
+				res = append(res, r.Header.Get("X-Namespace")+": "+stmt)
+			}
+		}
+		seen = len(requests)
+		return res
+	}
+}
+
+func TestRequestDatabase(t *testing.T) {
+	url, received := newNamespaceServer(t)
+	connector, err := NewConnector(url, WithDatabase("main"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for _, queryCtx := range []context.Context{ctx, WithRequestDatabase(ctx, "tenant1"), WithRequestDatabase(ctx, "tenant2"), ctx} {
+		if _, err := db.ExecContext(queryCtx, "SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ExecContext(WithRequestDatabase(ctx, "bad/name"), "SELECT 1"); err == nil {
+		t.Error("expected an invalid database name to be rejected")
+	}
+
+	tx, err := db.BeginTx(WithRequestDatabase(ctx, "tenant1"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(WithRequestDatabase(ctx, "tenant2"), "INSERT INTO t VALUES (2)"); err == nil || !strings.Contains(err.Error(), "inside a transaction") {
+		t.Errorf("got %v, want switching databases inside a transaction to fail", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"main: SELECT 1",
+		"tenant1: SELECT 1",
+		"tenant2: SELECT 1",
+		"main: SELECT 1",
+		"tenant1: BEGIN",
+		"tenant1: INSERT INTO t VALUES (1)",
+		"tenant1: COMMIT",
+	}
+	if got := received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRequestDatabasePreparedStatement(t *testing.T) {
+	url, received := newNamespaceServer(t)
+	db, err := sql.Open("libsql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	stmt, err := db.PrepareContext(ctx, "SELECT ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, database := range []string{"", "tenant1", "tenant2", ""} {
+		queryCtx := ctx
+		if database != "" {
+			queryCtx = WithRequestDatabase(ctx, database)
+		}
+		if _, err := stmt.ExecContext(queryCtx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{": SELECT ns", "tenant1: SELECT ns", "tenant2: SELECT ns", ": SELECT ns"}
+	if got := received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDatabaseWebsocketHandshake(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+
+	connector, err := NewConnector("ws"+strings.TrimPrefix(server.URL, "http")+"?database=tenant1", WithHeaders(http.Header{"X-Namespace": {"other"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connector.Connect(context.Background()); err == nil {
+		t.Fatal("expected the websocket upgrade to fail")
+	}
+	requests := server.Requests()
+	if len(requests) == 0 || requests[0].Header.Get("X-Namespace") != "tenant1" {
+		t.Errorf("got requests %+v, want the tenant1 namespace in the handshake", requests)
+	}
+}
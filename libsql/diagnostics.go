@@ -0,0 +1,172 @@
+package libsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr/v4"
+	"github.com/libsql/sqlite-antlr4-parser/sqliteparser"
+)
+
+type DiagnosticKind int
+
+const (
+	// DiagnosticDeprecated reports the use of a deprecated feature.
+	DiagnosticDeprecated DiagnosticKind = iota
+	// DiagnosticUnparameterizedQuery reports a statement that embeds string
+	// literals instead of using parameters, a common sign of SQL built by
+	// string concatenation.
+	DiagnosticUnparameterizedQuery
+	// DiagnosticRowsNotClosed reports rows that were garbage collected without
+	// being closed.
+	DiagnosticRowsNotClosed
+	// DiagnosticLongTransaction reports a transaction that is still open after
+	// the configured threshold.
+	DiagnosticLongTransaction
+)
+
+func (k DiagnosticKind) String() string {
+	switch k {
+	case DiagnosticDeprecated:
+		return "deprecated"
+	case DiagnosticUnparameterizedQuery:
+		return "unparameterized query"
+	case DiagnosticRowsNotClosed:
+		return "rows not closed"
+	case DiagnosticLongTransaction:
+		return "long transaction"
+	default:
+		return fmt.Sprintf("DiagnosticKind(%d)", int(k))
+	}
+}
+
+type Diagnostic struct {
+	Kind    DiagnosticKind
+	Message string
+	// Query is the statement the diagnostic refers to, if any.
+	Query string
+	// Fingerprint is the Fingerprint of Query, which can be logged without
+	// exposing the literals of the statement.
+	Fingerprint string
+}
+
+// Diagnostics receives reports about misuse of the driver. Report may be
+// called concurrently and from background goroutines.
+type Diagnostics interface {
+	Report(d Diagnostic)
+}
+
+type DiagnosticsFunc func(d Diagnostic)
+
+func (f DiagnosticsFunc) Report(d Diagnostic) {
+	f(d)
+}
+
+const defaultLongTransactionThreshold = 10 * time.Second
+
+type diagnosticsConfig struct {
+	d                        Diagnostics
+	longTransactionThreshold time.Duration
+}
+
+// WithDiagnostics reports misuse patterns detected by the driver to d.
+func WithDiagnostics(d Diagnostics) Option {
+	return option(func(c *Connector) error {
+		c.diagnostics.d = d
+		return nil
+	})
+}
+
+// WithLongTransactionThreshold sets how long a transaction may stay open before
+// it is reported as DiagnosticLongTransaction. The default is 10 seconds.
+func WithLongTransactionThreshold(threshold time.Duration) Option {
+	return option(func(c *Connector) error {
+		if threshold <= 0 {
+			return fmt.Errorf("long transaction threshold must be positive")
+		}
+		c.diagnostics.longTransactionThreshold = threshold
+		return nil
+	})
+}
+
+func (c *diagnosticsConfig) report(d Diagnostic) {
+	if c.d != nil {
+		c.d.Report(d)
+	}
+}
+
+// checkQuery reports data-modifying statements that contain string literals
+// and no bind parameters.
+func (c *diagnosticsConfig) checkQuery(query string) {
+	if c.d == nil {
+		return
+	}
+	lexer := sqliteparser.NewSQLiteLexer(antlr.NewInputStream(query))
+	suspicious := false
+	literals, parameters := 0, 0
+	for _, token := range lexer.GetAllTokens() {
+		switch token.GetTokenType() {
+		case sqliteparser.SQLiteLexerINSERT_, sqliteparser.SQLiteLexerUPDATE_, sqliteparser.SQLiteLexerDELETE_, sqliteparser.SQLiteLexerWHERE_:
+			suspicious = true
+		case sqliteparser.SQLiteLexerSTRING_LITERAL:
+			literals++
+		case sqliteparser.SQLiteLexerBIND_PARAMETER:
+			parameters++
+		}
+	}
+	if suspicious && literals > 0 && parameters == 0 {
+		c.report(Diagnostic{
+			Kind:        DiagnosticUnparameterizedQuery,
+			Message:     fmt.Sprintf("statement embeds %d string literals and no parameters, use parameters to avoid SQL injection", literals),
+			Query:       strings.TrimSpace(query),
+			Fingerprint: Fingerprint(query),
+		})
+	}
+}
+
+func (c *diagnosticsConfig) watchRows(r *rows) {
+	if c.d == nil {
+		return
+	}
+	runtime.SetFinalizer(r, func(r *rows) {
+		if !r.closed {
+			c.report(Diagnostic{Kind: DiagnosticRowsNotClosed, Message: "rows were garbage collected without being closed"})
+		}
+	})
+}
+
+// diagnosedTx reports transactions that stay open for too long.
+type diagnosedTx struct {
+	driver.Tx
+	timer *time.Timer
+}
+
+func (c *diagnosticsConfig) watchTx(t driver.Tx) driver.Tx {
+	if c.d == nil {
+		return t
+	}
+	threshold := c.longTransactionThreshold
+	if threshold == 0 {
+		threshold = defaultLongTransactionThreshold
+	}
+	timer := time.AfterFunc(threshold, func() {
+		c.report(Diagnostic{
+			Kind:    DiagnosticLongTransaction,
+			Message: fmt.Sprintf("transaction has been open for more than %s", threshold),
+		})
+	})
+	return &diagnosedTx{Tx: t, timer: timer}
+}
+
+func (t *diagnosedTx) Commit() error {
+	t.timer.Stop()
+	return t.Tx.Commit()
+}
+
+func (t *diagnosedTx) Rollback() error {
+	t.timer.Stop()
+	return t.Tx.Rollback()
+}
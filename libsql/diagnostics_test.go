@@ -0,0 +1,97 @@
+package libsql
+
+import (
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	sync.Mutex
+	diagnostics []Diagnostic
+}
+
+func (r *recorder) Report(d Diagnostic) {
+	r.Lock()
+	defer r.Unlock()
+	r.diagnostics = append(r.diagnostics, d)
+}
+
+func (r *recorder) kinds() []DiagnosticKind {
+	r.Lock()
+	defer r.Unlock()
+	kinds := []DiagnosticKind{}
+	for _, d := range r.diagnostics {
+		kinds = append(kinds, d.Kind)
+	}
+	return kinds
+}
+
+func TestCheckQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		report bool
+	}{
+		{name: "ConcatenatedInsert", query: "INSERT INTO users (name) VALUES ('bob')", report: true},
+		{name: "ConcatenatedWhere", query: "SELECT * FROM users WHERE name = 'bob' OR '1'='1'", report: true},
+		{name: "ParameterizedInsert", query: "INSERT INTO users (name) VALUES (?)"},
+		{name: "SelectLiteral", query: "SELECT 'hello'"},
+		{name: "NumericLiteral", query: "DELETE FROM users WHERE id = 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			c := diagnosticsConfig{d: r}
+			c.checkQuery(tt.query)
+			if got := len(r.kinds()) > 0; got != tt.report {
+				t.Errorf("got reported %v, want %v", got, tt.report)
+			}
+		})
+	}
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func TestWatchTx(t *testing.T) {
+	r := &recorder{}
+	c := diagnosticsConfig{d: r, longTransactionThreshold: 10 * time.Millisecond}
+
+	committed := c.watchTx(fakeTx{})
+	if err := committed.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	long := c.watchTx(fakeTx{})
+	time.Sleep(50 * time.Millisecond)
+	if err := long.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := r.kinds()
+	if len(kinds) != 1 || kinds[0] != DiagnosticLongTransaction {
+		t.Errorf("got diagnostics %v, want a single long transaction", kinds)
+	}
+}
+
+func TestWatchTxWithoutDiagnostics(t *testing.T) {
+	var c diagnosticsConfig
+	var tx driver.Tx = fakeTx{}
+	if c.watchTx(tx) != tx {
+		t.Error("transaction should not be wrapped without diagnostics")
+	}
+}
+
+func TestDeprecatedJwtParameter(t *testing.T) {
+	r := &recorder{}
+	if _, err := NewConnector("https://example.com?jwt=token", WithDiagnostics(r)); err != nil {
+		t.Fatal(err)
+	}
+	kinds := r.kinds()
+	if len(kinds) != 1 || kinds[0] != DiagnosticDeprecated {
+		t.Errorf("got diagnostics %v, want a single deprecation", kinds)
+	}
+}
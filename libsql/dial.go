@@ -0,0 +1,65 @@
+//go:build !js
+
+package libsql
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// WithDialTimeout bounds opening a TCP connection to the server over HTTP and
+// websockets. A host with several addresses gets a share of d for each of
+// them, so an unreachable address cannot use up the whole timeout of a
+// request.
+func WithDialTimeout(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		if d <= 0 {
+			return fmt.Errorf("dial timeout must be positive")
+		}
+		c.cfg.DialTimeout = d
+		return nil
+	})
+}
+
+// WithHappyEyeballsDelay sets how long connecting to a host with both IPv6 and
+// IPv4 addresses tries the first family before racing the other one, as
+// described by RFC 6555. It defaults to 300 milliseconds; a negative delay
+// disables the race, trying IPv4 addresses only once IPv6 ones failed.
+func WithHappyEyeballsDelay(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		c.cfg.FallbackDelay = d
+		return nil
+	})
+}
+
+// dialKeepAlive is the keep-alive period of the connections of the default
+// transport.
+const dialKeepAlive = 30 * time.Second
+
+// configureDialer sets up the transport of the connector to dial with the
+// settings of WithDialTimeout and WithHappyEyeballsDelay, on top of the proxy
+// of WithProxyURL if set.
+func (c *Connector) configureDialer() error {
+	if c.cfg.DialTimeout == 0 && c.cfg.FallbackDelay == 0 {
+		return nil
+	}
+	var transport *http.Transport
+	switch t := c.cfg.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return fmt.Errorf("the dial settings cannot be applied to a transport of type %T", t)
+	}
+	dialer := &net.Dialer{
+		Timeout:       c.cfg.DialTimeout,
+		KeepAlive:     dialKeepAlive,
+		FallbackDelay: c.cfg.FallbackDelay,
+	}
+	transport.DialContext = dialer.DialContext
+	c.cfg.Transport = transport
+	return nil
+}
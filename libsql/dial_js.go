@@ -0,0 +1,31 @@
+//go:build js
+
+package libsql
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithDialTimeout is accepted for portability but has no effect: browsers and
+// other JavaScript hosts open the connections of fetch and websockets
+// themselves.
+func WithDialTimeout(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		if d <= 0 {
+			return fmt.Errorf("dial timeout must be positive")
+		}
+		return nil
+	})
+}
+
+// WithHappyEyeballsDelay has no effect, like WithDialTimeout.
+func WithHappyEyeballsDelay(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		return nil
+	})
+}
+
+func (c *Connector) configureDialer() error {
+	return nil
+}
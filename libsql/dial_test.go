@@ -0,0 +1,63 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestDialSettings(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+
+	connector, err := NewConnector(server.URL, WithDialTimeout(time.Second), WithHappyEyeballsDelay(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, ok := connector.cfg.Transport.(*http.Transport)
+	if !ok || transport.DialContext == nil {
+		t.Fatalf("got transport %#v, want an *http.Transport with the dialer", connector.cfg.Transport)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialSettingsKeepProxy(t *testing.T) {
+	connector, err := NewConnector("https://db", WithProxyURL("http://proxy:3128"), WithDialTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := connector.cfg.Transport.(*http.Transport)
+	if transport.Proxy == nil || transport.DialContext == nil {
+		t.Error("expected the transport to dial with the settings through the proxy")
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	// Addresses of TEST-NET-1 are not routed, dialing them hangs or fails.
+	connector, err := NewConnector("http://192.0.2.1:8080?insecure=1", WithDialTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	start := time.Now()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("dialing took %s, want it bounded by the dial timeout", elapsed)
+	}
+}
+
+func TestDialSettingsInvalid(t *testing.T) {
+	if _, err := NewConnector("https://db", WithDialTimeout(0)); err == nil {
+		t.Error("expected a zero dial timeout to be rejected")
+	}
+}
@@ -0,0 +1,42 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+)
+
+// NewConnectorFromEnv creates a connector for the database URL in LIBSQL_URL,
+// authenticated with the token in LIBSQL_AUTH_TOKEN if it is set, like the
+// other libsql SDKs. Proxies are configured with the standard HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY variables, which every transport honors. opts are
+// applied on top of the settings from the environment.
+func NewConnectorFromEnv(opts ...Option) (*Connector, error) {
+	dbUrl := os.Getenv("LIBSQL_URL")
+	if dbUrl == "" {
+		return nil, errors.New("LIBSQL_URL is not set")
+	}
+	if token := os.Getenv("LIBSQL_AUTH_TOKEN"); token != "" {
+		opts = append([]Option{option(func(c *Connector) error {
+			if urlToken, _ := c.token.Get(context.Background()); urlToken != "" {
+				return errors.New("LIBSQL_AUTH_TOKEN cannot be used with an auth token in LIBSQL_URL")
+			}
+			c.token = auth.Static(token)
+			return nil
+		})}, opts...)
+	}
+	return NewConnector(dbUrl, opts...)
+}
+
+// OpenFromEnv opens the database configured by the environment, see
+// NewConnectorFromEnv.
+func OpenFromEnv(opts ...Option) (*sql.DB, error) {
+	connector, err := NewConnectorFromEnv(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
@@ -0,0 +1,40 @@
+package libsql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewConnectorFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		token     string
+		wantToken string
+		wantErr   bool
+	}{
+		{name: "unset", wantErr: true},
+		{name: "url only", url: "libsql://db.turso.io?authToken=url", wantToken: "url"},
+		{name: "token", url: "libsql://db.turso.io", token: "env", wantToken: "env"},
+		{name: "both tokens", url: "libsql://db.turso.io?authToken=url", token: "env", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LIBSQL_URL", tt.url)
+			t.Setenv("LIBSQL_AUTH_TOKEN", tt.token)
+			c, err := NewConnectorFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := c.token.Get(context.Background()); got != tt.wantToken {
+				t.Errorf("got token %#v, want %#v", got, tt.wantToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package libsql
+
+import (
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr/v4"
+	"github.com/libsql/sqlite-antlr4-parser/sqliteparser"
+)
+
+// Fingerprint returns the shape of query, which groups the executions of a
+// statement in dashboards without exposing the values it embeds: string,
+// numeric and blob literals are replaced with ?, keywords are upper-cased,
+// comments are dropped and runs of whitespace become a single space. Bind
+// parameters and identifiers are kept as written, so
+//
+//	select * from users  -- admin
+//	where id = 42 and name = 'bob'
+//
+// and SELECT * FROM users WHERE id = 7 AND name = 'alice' both have the
+// fingerprint SELECT * FROM users WHERE id = ? AND name = ?. Statement
+// metrics are recorded per fingerprint.
+func Fingerprint(query string) string {
+	lexer := sqliteparser.NewSQLiteLexer(antlr.NewInputStream(query))
+	lexer.RemoveErrorListeners()
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	for _, token := range lexer.GetAllTokens() {
+		tokenType := token.GetTokenType()
+		switch tokenType {
+		case sqliteparser.SQLiteLexerSPACES, sqliteparser.SQLiteLexerSINGLE_LINE_COMMENT, sqliteparser.SQLiteLexerMULTILINE_COMMENT:
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		switch {
+		case tokenType == sqliteparser.SQLiteLexerSTRING_LITERAL || tokenType == sqliteparser.SQLiteLexerNUMERIC_LITERAL || tokenType == sqliteparser.SQLiteLexerBLOB_LITERAL:
+			b.WriteByte('?')
+		case tokenType >= sqliteparser.SQLiteLexerABORT_ && tokenType <= sqliteparser.SQLiteLexerNOTHING_:
+			b.WriteString(strings.ToUpper(token.GetText()))
+		default:
+			b.WriteString(token.GetText())
+		}
+	}
+	return b.String()
+}
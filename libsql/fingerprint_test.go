@@ -0,0 +1,24 @@
+package libsql
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT * FROM t", want: "SELECT * FROM t"},
+		{query: "  select *\n\tfrom t  ", want: "SELECT * FROM t"},
+		{query: "SELECT * FROM users WHERE id = 42 AND name = 'bob'", want: "SELECT * FROM users WHERE id = ? AND name = ?"},
+		{query: "INSERT INTO t VALUES (1.5e3, x'cafe', 'it''s', -7)", want: "INSERT INTO t VALUES (?, ?, ?, -?)"},
+		{query: "SELECT a FROM t -- by id\nWHERE /* hot */ id=?", want: "SELECT a FROM t WHERE id=?"},
+		{query: "UPDATE t SET a = :a, b = ?2 WHERE c IS NULL", want: "UPDATE t SET a = :a, b = ?2 WHERE c IS NULL"},
+		{query: `SELECT "Mixed Case", count(*) FROM main.t`, want: `SELECT "Mixed Case", count(*) FROM main.t`},
+		{query: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := Fingerprint(tt.query); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
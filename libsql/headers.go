@@ -0,0 +1,67 @@
+package libsql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+)
+
+// WithHeaders sends header with every request to the database and with the
+// handshake of websockets, for proxies and API gateways routing requests on
+// headers. The headers the driver sets itself, like Content-Type, take
+// precedence, as does the Authorization header when an auth token is set.
+func WithHeaders(header http.Header) Option {
+	return option(func(c *Connector) error {
+		if c.cfg.Headers == nil {
+			c.cfg.Headers = http.Header{}
+		}
+		for name, values := range canonicalHeader(header) {
+			c.cfg.Headers[name] = values
+		}
+		return nil
+	})
+}
+
+// WithClientName identifies the application in the User-Agent header of
+// requests and websocket handshakes, before the name and version of the
+// driver, as in "my-service/1.2 libsql-client-go/v0.9.0 (go1.21.0)", so
+// servers can tell which service sent a query. The driver also reports its
+// version in the X-Libsql-Client-Version header. A User-Agent set with
+// WithHeaders replaces the whole header.
+func WithClientName(name string) Option {
+	return option(func(c *Connector) error {
+		if name == "" {
+			return fmt.Errorf("client name must not be empty")
+		}
+		for _, r := range name {
+			if r < 0x20 || r > 0x7e {
+				return fmt.Errorf("invalid client name %q: only printable ASCII characters are allowed", name)
+			}
+		}
+		c.cfg.ClientName = name
+		return nil
+	})
+}
+
+// WithRequestHeaders returns a context whose requests carry header on top of
+// the headers of WithHeaders, replacing the values of the names both set, like
+// an X-Request-Id for a single query. The headers of a context passed to
+// BeginTx are sent with the commit or rollback of the transaction as well.
+// Over websockets requests are messages on a websocket shared by connections,
+// only the headers of WithHeaders are sent then.
+func WithRequestHeaders(ctx context.Context, header http.Header) context.Context {
+	return ctxopt.WithHeaders(ctx, canonicalHeader(header))
+}
+
+// canonicalHeader copies header with canonical names, as http.Header.Set would
+// store them.
+func canonicalHeader(header http.Header) http.Header {
+	canonical := make(http.Header, len(header))
+	for name, values := range header {
+		name = http.CanonicalHeaderKey(name)
+		canonical[name] = append(canonical[name], values...)
+	}
+	return canonical
+}
@@ -0,0 +1,109 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestHeaders(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+
+	connector, err := NewConnector(server.URL, WithHeaders(http.Header{"x-tenant": {"acme"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	requestCtx := WithRequestHeaders(ctx, http.Header{"X-Request-Id": {"1"}})
+	if _, err := db.ExecContext(requestCtx, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(WithRequestHeaders(ctx, http.Header{"X-Tenant": {"other"}}), "SELECT 2"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTx(requestCtx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(requestCtx, "SELECT 3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"GET /v3 tenant=acme request=1",
+		"POST /v3/pipeline tenant=acme request=1",
+		"POST /v3/pipeline tenant=other request=",
+		"POST /v3/pipeline tenant=acme request=1",
+		"POST /v3/pipeline tenant=acme request=1",
+	}
+	var received []string
+	for _, r := range server.Requests() {
+		received = append(received, fmt.Sprintf("%s %s tenant=%s request=%s", r.Method, r.URL.Path, r.Header.Get("X-Tenant"), r.Header.Get("X-Request-Id")))
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("got %#v, want %#v", received, want)
+	}
+}
+
+func TestHeadersWebsocketHandshake(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+
+	connector, err := NewConnector("ws"+strings.TrimPrefix(server.URL, "http"), WithHeaders(http.Header{"X-Tenant": {"acme"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connector.Connect(context.Background()); err == nil {
+		t.Fatal("expected the websocket upgrade to fail")
+	}
+	requests := server.Requests()
+	if len(requests) == 0 {
+		t.Fatal("expected a websocket handshake")
+	}
+	if tenant := requests[0].Header.Get("X-Tenant"); tenant != "acme" {
+		t.Errorf("got tenant header %q in the handshake, want acme", tenant)
+	}
+	if userAgent := requests[0].Header.Get("User-Agent"); !strings.HasPrefix(userAgent, "libsql-client-go/") {
+		t.Errorf("got User-Agent %q in the handshake, want the driver", userAgent)
+	}
+}
+
+func TestClientName(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+
+	connector, err := NewConnector(server.URL, WithClientName("my-service/1.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range server.Requests() {
+		if userAgent := r.Header.Get("User-Agent"); !strings.HasPrefix(userAgent, "my-service/1.2 libsql-client-go/") {
+			t.Errorf("got User-Agent %q, want the client name followed by the driver", userAgent)
+		}
+		if version := r.Header.Get("X-Libsql-Client-Version"); !strings.HasPrefix(version, "libsql-client-go-") {
+			t.Errorf("got X-Libsql-Client-Version %q", version)
+		}
+	}
+
+	for _, name := range []string{"", "bad\r\nX-Injected: 1", "café"} {
+		if _, err := NewConnector(server.URL, WithClientName(name)); err == nil {
+			t.Errorf("%q: expected an invalid client name to be rejected", name)
+		}
+	}
+}
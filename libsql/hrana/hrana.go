@@ -0,0 +1,144 @@
+// Package hrana describes the messages of Hrana, the protocol spoken by sqld
+// over HTTP and websockets, with the types the driver itself encodes and
+// decodes. It lets tools build and read Hrana over HTTP pipelines, for
+// example to proxy or record the traffic of the driver, without reimplementing
+// the wire format.
+//
+// The types follow version 3 of the protocol. Integers are sent as decimal
+// text, blobs as base64 without padding, as sqld expects them.
+package hrana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+)
+
+type (
+	// PipelineRequest is the body of a request to the /v3/pipeline endpoint.
+	// Baton continues the stream of a previous response, empty to open one.
+	PipelineRequest = hrana.PipelineRequest
+	// PipelineResponse is the body of the response to a PipelineRequest, with
+	// a result per request.
+	PipelineResponse = hrana.PipelineResponse
+
+	// StreamRequest is a request run on a stream, built with Execute,
+	// ExecuteBatch, StoreSql, CloseSql, Close or Raw.
+	StreamRequest = hrana.StreamRequest
+	// StreamResult is the result of a StreamRequest: its Response if Type is
+	// "ok", its Error if Type is "error".
+	StreamResult = hrana.StreamResult
+	// StreamResponse is the response of a successful request, decoded with
+	// ExecuteResult or BatchResult.
+	StreamResponse = hrana.StreamResponse
+	// Error is the error of a request or of a batch step. Code is the SQLite
+	// or server error code, like SQLITE_CONSTRAINT, when known. Err converts
+	// it to the *libsql.SQLiteError returned by the driver.
+	Error = hrana.Error
+
+	// Stmt is a statement, either SQL text or the id of a statement stored with
+	// StoreSql, with its arguments.
+	Stmt = hrana.Stmt
+	// NamedArg is an argument bound to a named parameter.
+	NamedArg = hrana.NamedArg
+	// StmtResult is the result of a statement.
+	StmtResult = hrana.StmtResult
+	// Column is a column of a StmtResult, with its declared type.
+	Column = hrana.Column
+
+	// Batch is a list of statements run in a single request. A step runs if its
+	// Condition, over the outcome of earlier steps, holds or is nil.
+	Batch = hrana.Batch
+	// BatchStep is a statement of a Batch.
+	BatchStep = hrana.BatchStep
+	// BatchCondition is "ok" or "error" for the outcome of Step, or "not",
+	// "and" and "or" combining Cond or Conds.
+	BatchCondition = hrana.BatchCondition
+	// BatchResult holds, for every step of a batch, its result or its error,
+	// both nil for steps that did not run.
+	BatchResult = hrana.BatchResult
+
+	// Value is a SQLite value: null, integer, float, text or blob.
+	Value = hrana.Value
+	// Row is a row of a StmtResult and Rows all of its rows.
+	Row  = hrana.Row
+	Rows = hrana.Rows
+)
+
+// NewValue returns the Value of v, which is nil, an int64, int, float64,
+// string or []byte. Value.ToValue converts it back.
+func NewValue(v any) (Value, error) {
+	return hrana.ToValue(v)
+}
+
+// Execute returns a request running stmt.
+func Execute(stmt Stmt) StreamRequest {
+	return StreamRequest{Type: "execute", Stmt: &stmt}
+}
+
+// ExecuteBatch returns a request running the steps of batch.
+func ExecuteBatch(batch Batch) StreamRequest {
+	return StreamRequest{Type: "batch", Batch: &batch}
+}
+
+// StoreSql returns a request storing sql on the stream under sqlId, which
+// statements may then run by id.
+func StoreSql(sql string, sqlId int32) StreamRequest {
+	return hrana.StoreSqlStream(sql, sqlId)
+}
+
+// CloseSql returns a request removing the SQL stored under sqlId.
+func CloseSql(sqlId int32) StreamRequest {
+	return hrana.CloseStoredSqlStream(sqlId)
+}
+
+// Close returns a request closing the stream, which rolls back its open
+// transaction.
+func Close() StreamRequest {
+	return hrana.CloseStream()
+}
+
+// Raw returns a request sending request, a JSON object with a "type" field,
+// as it is, for request types this package does not describe.
+func Raw(request json.RawMessage) (StreamRequest, error) {
+	return hrana.RawStream(request)
+}
+
+// MarshalRequest returns the JSON encoding of req, as sent to the pipeline
+// endpoint.
+func MarshalRequest(req *PipelineRequest) ([]byte, error) {
+	return json.Marshal(req)
+}
+
+// UnmarshalResponse decodes the body of a pipeline response. Results of type
+// "ok" must have a response and those of type "error" an error.
+func UnmarshalResponse(data []byte) (*PipelineResponse, error) {
+	var resp PipelineResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("invalid pipeline response: %w", err)
+	}
+	for idx, result := range resp.Results {
+		switch {
+		case result.Type == "ok" && result.Response == nil:
+			return nil, fmt.Errorf("invalid pipeline response: result %d has no response", idx)
+		case result.Type == "error" && result.Error == nil:
+			return nil, fmt.Errorf("invalid pipeline response: result %d has no error", idx)
+		case result.Type != "ok" && result.Type != "error":
+			return nil, fmt.Errorf("invalid pipeline response: result %d has unknown type %q", idx, result.Type)
+		}
+	}
+	return &resp, nil
+}
+
+// ResultError returns the error of result, nil if it succeeded.
+func ResultError(result StreamResult) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.Response == nil {
+		return errors.New("no response received")
+	}
+	return nil
+}
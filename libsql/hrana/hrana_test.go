@@ -0,0 +1,55 @@
+package hrana
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMarshalRequest(t *testing.T) {
+	sql := "INSERT INTO t VALUES (?)"
+	arg, err := NewValue(int64(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &PipelineRequest{Baton: "b"}
+	req.Add(Execute(Stmt{Sql: &sql, Args: []Value{arg}}))
+	req.Add(Close())
+	data, err := MarshalRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"baton":"b","requests":[{"type":"execute","stmt":{"sql":"INSERT INTO t VALUES (?)","args":[{"type":"integer","value":"42"}],"want_rows":false}},{"type":"close"}]}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
+
+func TestUnmarshalResponse(t *testing.T) {
+	data := []byte(`{"baton":"b","results":[` +
+		`{"type":"ok","response":{"type":"execute","result":{"cols":[{"name":"v","decltype":"INTEGER"}],"rows":[[{"type":"integer","value":"7"}]],"affected_row_count":0,"last_insert_rowid":null}}},` +
+		`{"type":"error","error":{"message":"no such table: t","code":"SQLITE_ERROR"}}]}`)
+	resp, err := UnmarshalResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResultError(resp.Results[0]); err != nil {
+		t.Fatal(err)
+	}
+	res, err := resp.Results[0].Response.ExecuteResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Rows[0][0].ToValue(); got != int64(7) {
+		t.Errorf("got %#v, want 7", got)
+	}
+	var hranaErr *Error
+	if err := ResultError(resp.Results[1]); !errors.As(err, &hranaErr) || err.Error() != "SQLITE_ERROR: no such table: t" {
+		t.Errorf("got %v, want the error of the second result", err)
+	}
+
+	for _, invalid := range []string{`{"results":[{"type":"ok"}]}`, `{"results":[{"type":"other"}]}`, `{"results":`} {
+		if _, err := UnmarshalResponse([]byte(invalid)); err == nil {
+			t.Errorf("expected %s to be rejected", invalid)
+		}
+	}
+}
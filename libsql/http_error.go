@@ -0,0 +1,34 @@
+package libsql
+
+import "github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+
+// HTTPError is the error of a request the server answered with an
+// unsuccessful HTTP status, before or instead of running its statements. It
+// carries the status, the error code and message of the body, the raw body
+// and the response headers, so failures can be matched with the logs of the
+// server through RequestID. Retrieve it with errors.As:
+//
+//	var httpErr *libsql.HTTPError
+//	if errors.As(err, &httpErr) {
+//		log.Printf("status %d, request %s", httpErr.StatusCode, httpErr.RequestID())
+//	}
+//
+// A *RateLimitError wraps the *HTTPError of its response. Errors of
+// statements that ran and failed, and errors over websockets, are not
+// *HTTPError.
+type HTTPError = shared.HTTPError
+
+// ErrStreamExpired is wrapped by the errors of a transaction whose stream
+// the server expired, usually because the transaction was left idle for too
+// long. The server rolled the transaction back, so it has to be run again from
+// BeginTx; IsRetryable reports true for it. Outside transactions, expired
+// streams are retried by database/sql on another connection instead.
+var ErrStreamExpired = shared.ErrStreamExpired
+
+// ErrSessionLost is wrapped by the errors of statements calling
+// last_insert_rowid(), changes() or total_changes() after the stream of their
+// connection was lost, over websockets when the server closed an idle
+// websocket. These functions would otherwise silently report on the new stream
+// instead of on the earlier statements of the connection. The statements that
+// follow run on the new stream.
+var ErrSessionLost = shared.ErrSessionLost
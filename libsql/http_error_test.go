@@ -0,0 +1,84 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestHTTPError(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+	server.InjectFailures(1, libsqltest.Failure{
+		Status:  http.StatusBadRequest,
+		Header:  http.Header{"X-Request-Id": {"req-42"}},
+		Code:    "SQLITE_ERROR",
+		Message: "no such table: t",
+	})
+
+	db, err := sql.Open("libsql", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.ExecContext(context.Background(), "SELECT * FROM t")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("got %v, want an *HTTPError", err)
+	}
+	if httpErr.StatusCode != http.StatusBadRequest || httpErr.Code != "SQLITE_ERROR" || httpErr.Message != "no such table: t" {
+		t.Errorf("got status %d, code %q and message %q", httpErr.StatusCode, httpErr.Code, httpErr.Message)
+	}
+	if got := httpErr.RequestID(); got != "req-42" {
+		t.Errorf("got request ID %q, want req-42", got)
+	}
+	if !strings.Contains(err.Error(), "(request ID req-42)") {
+		t.Errorf("got %q, want the request ID in the message", err)
+	}
+	if IsRetryable(err) {
+		t.Error("got a retryable error for a rejected request")
+	}
+}
+
+func TestStreamExpired(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+
+	db, err := sql.Open("libsql", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// BEGIN is sent with the first statement, which opens the stream.
+	if _, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	server.ExpireStreams()
+	for i := 0; i < 2; i++ {
+		_, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+		if !errors.Is(err, ErrStreamExpired) || !IsRetryable(err) {
+			t.Errorf("got %v, want a retryable ErrStreamExpired", err)
+		}
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrStreamExpired) {
+		t.Errorf("got %v from Commit, want ErrStreamExpired", err)
+	}
+
+	// Outside transactions, the statement is retried on a new stream.
+	for i := 0; i < 2; i++ {
+		server.ExpireStreams()
+		if _, err := db.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
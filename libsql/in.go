@@ -0,0 +1,214 @@
+package libsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr/v4"
+	"github.com/libsql/sqlite-antlr4-parser/sqliteparser"
+)
+
+// In expands the slice arguments of query into one placeholder per element, so
+// a list can be bound to an IN clause:
+//
+//	query, args, err := libsql.In("SELECT * FROM users WHERE id IN (?)", []int{1, 2, 3})
+//	rows, err := db.QueryContext(ctx, query, args...)
+//
+// A positional slice argument turns its ? into ?, ?, ? and numbered
+// placeholders are renumbered to keep pointing at their arguments. A named
+// slice argument, passed with sql.Named, turns :ids into :ids_1, :ids_2, :ids_3
+// wherever it is used. An empty slice expands to nothing, which SQLite accepts
+// as the empty list of IN (). Byte slices and driver.Valuer implementations are
+// bound as single values.
+func In(query string, args ...any) (string, []any, error) {
+	var positional [][]any
+	named := map[string][]any{}
+	namedSlices := map[string]bool{}
+	var namedOrder []string
+	for _, arg := range args {
+		if n, ok := arg.(sql.NamedArg); ok {
+			values, isSlice := expandArg(n.Value)
+			if _, ok := named[n.Name]; ok {
+				return "", nil, fmt.Errorf("named argument %s passed twice", n.Name)
+			}
+			named[n.Name] = values
+			namedSlices[n.Name] = isSlice
+			namedOrder = append(namedOrder, n.Name)
+			continue
+		}
+		values, _ := expandArg(arg)
+		positional = append(positional, values)
+	}
+
+	// offsets[i] is the index of the first placeholder of positional argument i
+	// in the expanded query, counting from 1.
+	offsets := make([]int, len(positional))
+	next := 1
+	for idx, values := range positional {
+		offsets[idx] = next
+		next += len(values)
+	}
+
+	input := []rune(query)
+	var b strings.Builder
+	last := 0
+	largest := 0
+	tokens := sqliteparser.NewSQLiteLexer(antlr.NewInputStream(query)).GetAllTokens()
+	// Plain ? are kept unless the query also has numbered placeholders, whose
+	// indexes would no longer match.
+	numbered := false
+	for _, token := range tokens {
+		if token.GetTokenType() == sqliteparser.SQLiteLexerBIND_PARAMETER && len(token.GetText()) > 1 && token.GetText()[0] == '?' {
+			numbered = true
+		}
+	}
+	for _, token := range tokens {
+		if token.GetTokenType() != sqliteparser.SQLiteLexerBIND_PARAMETER {
+			continue
+		}
+		b.WriteString(string(input[last:token.GetStart()]))
+		last = token.GetStop() + 1
+		parameter := token.GetText()
+		if parameter[0] != '?' {
+			name := parameter[1:]
+			if !namedSlices[name] {
+				b.WriteString(parameter)
+				continue
+			}
+			for idx := range named[name] {
+				if idx > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(parameter + "_" + strconv.Itoa(idx+1))
+			}
+			continue
+		}
+
+		// Like SQLite, a plain ? takes the index following the largest one
+		// used so far.
+		index := largest + 1
+		if len(parameter) > 1 {
+			n, err := strconv.Atoi(parameter[1:])
+			if err != nil || n < 1 {
+				return "", nil, fmt.Errorf("invalid positional parameter %s", parameter)
+			}
+			index = n
+		}
+		if index > largest {
+			largest = index
+		}
+		if index > len(positional) {
+			return "", nil, fmt.Errorf("query needs argument %d but got %d positional arguments", index, len(positional))
+		}
+		for idx := range positional[index-1] {
+			if idx > 0 {
+				b.WriteString(", ")
+			}
+			if numbered {
+				b.WriteString("?" + strconv.Itoa(offsets[index-1]+idx))
+			} else {
+				b.WriteString("?")
+			}
+		}
+	}
+	b.WriteString(string(input[last:]))
+	if largest != len(positional) {
+		return "", nil, fmt.Errorf("query needs %d positional arguments but got %d", largest, len(positional))
+	}
+
+	expanded := make([]any, 0, next-1+len(namedOrder))
+	for _, values := range positional {
+		expanded = append(expanded, values...)
+	}
+	for _, name := range namedOrder {
+		if !namedSlices[name] {
+			expanded = append(expanded, sql.Named(name, named[name][0]))
+			continue
+		}
+		for idx, v := range named[name] {
+			expandedName := name + "_" + strconv.Itoa(idx+1)
+			if _, ok := named[expandedName]; ok {
+				return "", nil, fmt.Errorf("expanding %s conflicts with named argument %s", name, expandedName)
+			}
+			expanded = append(expanded, sql.Named(expandedName, v))
+		}
+	}
+	return b.String(), expanded, nil
+}
+
+// WithExpandSlices makes the driver expand the slice arguments of queries like
+// In does, like the expandSlices query parameter, so that a slice can be
+// passed for the ? of an IN clause without calling In:
+//
+//	rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE id IN (?)", []int{1, 2, 3})
+//
+// The query sent to the server has one placeholder per element, so queries
+// with slices of different lengths are different statements. Prepared
+// statements given a slice run their expanded query instead.
+func WithExpandSlices() Option {
+	return option(func(c *Connector) error {
+		c.expandSlices = true
+		return nil
+	})
+}
+
+// expandSliceArgs expands the slice arguments of query like In if the
+// connector expands slices, and returns query and args unchanged otherwise.
+func (c *Conn) expandSliceArgs(query string, args []driver.NamedValue) (string, []driver.NamedValue, error) {
+	if !c.connector.expandSlices || !hasSliceArg(args) {
+		return query, args, nil
+	}
+	values := make([]any, len(args))
+	for idx, arg := range args {
+		values[idx] = arg.Value
+		if arg.Name != "" {
+			values[idx] = sql.Named(arg.Name, arg.Value)
+		}
+	}
+	query, values, err := In(query, values...)
+	if err != nil {
+		return "", nil, err
+	}
+	expanded, err := c.namedValues(values)
+	return query, expanded, err
+}
+
+func hasSliceArg(args []driver.NamedValue) bool {
+	for _, arg := range args {
+		if isSliceArg(arg.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSliceArg reports whether arg is a slice or an array to expand.
+func isSliceArg(arg any) bool {
+	if _, ok := arg.(driver.Valuer); ok {
+		return false
+	}
+	v := reflect.ValueOf(arg)
+	if !v.IsValid() {
+		return false
+	}
+	kind := v.Kind()
+	return (kind == reflect.Slice || kind == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8
+}
+
+// expandArg returns the elements of arg if it is a slice or an array to
+// expand, and arg itself otherwise.
+func expandArg(arg any) ([]any, bool) {
+	if !isSliceArg(arg) {
+		return []any{arg}, false
+	}
+	v := reflect.ValueOf(arg)
+	values := make([]any, v.Len())
+	for idx := range values {
+		values[idx] = v.Index(idx).Interface()
+	}
+	return values, true
+}
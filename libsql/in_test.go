@@ -0,0 +1,180 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		args      []any
+		wantQuery string
+		wantArgs  []any
+		wantErr   bool
+	}{
+		{
+			name:      "positional",
+			query:     "SELECT * FROM t WHERE a = ? AND id IN (?) AND b = ?",
+			args:      []any{"x", []int{1, 2, 3}, 4},
+			wantQuery: "SELECT * FROM t WHERE a = ? AND id IN (?, ?, ?) AND b = ?",
+			wantArgs:  []any{"x", 1, 2, 3, 4},
+		},
+		{
+			name:      "numbered",
+			query:     "SELECT * FROM t WHERE id IN (?2) AND a = ?1 OR b = ?1",
+			args:      []any{"x", []string{"a", "b"}},
+			wantQuery: "SELECT * FROM t WHERE id IN (?2, ?3) AND a = ?1 OR b = ?1",
+			wantArgs:  []any{"x", "a", "b"},
+		},
+		{
+			name:      "numbered after slice",
+			query:     "SELECT * FROM t WHERE id IN (?1) AND a = ?2",
+			args:      []any{[]int{1, 2}, "x"},
+			wantQuery: "SELECT * FROM t WHERE id IN (?1, ?2) AND a = ?3",
+			wantArgs:  []any{1, 2, "x"},
+		},
+		{
+			name:      "named",
+			query:     "SELECT * FROM t WHERE id IN (:ids) AND a = :a OR parent IN (:ids)",
+			args:      []any{sql.Named("a", "x"), sql.Named("ids", [2]int64{1, 2})},
+			wantQuery: "SELECT * FROM t WHERE id IN (:ids_1, :ids_2) AND a = :a OR parent IN (:ids_1, :ids_2)",
+			wantArgs:  []any{sql.Named("a", "x"), sql.Named("ids_1", int64(1)), sql.Named("ids_2", int64(2))},
+		},
+		{
+			name:      "literals and comments are kept",
+			query:     "SELECT '?', \"é\" FROM t /* (?) */ WHERE id IN (?)",
+			args:      []any{[]int{1, 2}},
+			wantQuery: "SELECT '?', \"é\" FROM t /* (?) */ WHERE id IN (?, ?)",
+			wantArgs:  []any{1, 2},
+		},
+		{
+			name:      "bytes and valuers are single values",
+			query:     "SELECT ?, ?",
+			args:      []any{[]byte{1, 2}, JSON([]int{1})},
+			wantQuery: "SELECT ?, ?",
+			wantArgs:  []any{[]byte{1, 2}, JSON([]int{1})},
+		},
+		{
+			name:      "empty slice",
+			query:     "SELECT * FROM t WHERE id IN (?)",
+			args:      []any{[]int{}},
+			wantQuery: "SELECT * FROM t WHERE id IN ()",
+			wantArgs:  []any{},
+		},
+		{
+			name:    "missing argument",
+			query:   "SELECT * FROM t WHERE id IN (?) AND a = ?",
+			args:    []any{[]int{1}},
+			wantErr: true,
+		},
+		{
+			name:    "extra argument",
+			query:   "SELECT * FROM t WHERE id IN (?)",
+			args:    []any{[]int{1}, 2},
+			wantErr: true,
+		},
+		{
+			name:    "conflicting names",
+			query:   "SELECT * FROM t WHERE id IN (:ids) AND a = :ids_1",
+			args:    []any{sql.Named("ids", []int{1}), sql.Named("ids_1", 2)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := In(tt.query, tt.args...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s %v", query, args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("got query %s, want %s", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("got args %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestWithExpandSlices(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var executed []string
+	url := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		mu.Lock()
+		executed = append(executed, libsqltest.Statement(query, args))
+		mu.Unlock()
+		return nil, nil
+	}).URL
+
+	disabled, err := sql.Open("libsql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disabled.Close()
+	if _, err := disabled.ExecContext(ctx, "DELETE FROM t WHERE id IN (?)", []int{1, 2}); err == nil {
+		t.Error("expected slices to be rejected without WithExpandSlices")
+	}
+
+	connector, err := NewConnector(url, WithExpandSlices())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "DELETE FROM t WHERE id IN (?) AND a = ?", []int{1, 2}, true); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT * FROM t WHERE id IN (:ids)", sql.Named("ids", []string{"a", "b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	stmt, err := db.PrepareContext(ctx, "UPDATE t SET a = 1 WHERE id IN (?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.ExecContext(ctx, []int64{3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.ExecContext(ctx, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	byParam, err := sql.Open("libsql", url+"?expandSlices=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer byParam.Close()
+	if _, err := byParam.ExecContext(ctx, "DELETE FROM t WHERE id IN (?)", []int{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"DELETE FROM t WHERE id IN (?, ?) AND a = ? 1 2 1",
+		"SELECT * FROM t WHERE id IN (:ids_1, :ids_2) ids_1=a ids_2=b",
+		"UPDATE t SET a = 1 WHERE id IN (?) 3",
+		"UPDATE t SET a = 1 WHERE id IN (?) 4",
+		"DELETE FROM t WHERE id IN ()",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(executed, want) {
+		t.Errorf("got %q, want %q", executed, want)
+	}
+}
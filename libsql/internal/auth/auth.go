@@ -0,0 +1,147 @@
+// Package auth provides the auth tokens sent to sqld by every transport.
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/debug"
+)
+
+// refreshMargin is how long before its expiry a token from a provider is
+// replaced.
+const refreshMargin = time.Minute
+
+// Provider returns a fresh auth token.
+type Provider func(ctx context.Context) (string, error)
+
+// Token is either a static auth token or a token fetched from a Provider, which
+// is cached until it nears its expiry or is rejected by the server.
+type Token struct {
+	static   string
+	provider Provider
+	// logger receives the refreshes of the token, never the token itself.
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	current string
+	expiry  time.Time
+}
+
+func Static(token string) *Token {
+	return &Token{static: token}
+}
+
+func FromProvider(provider Provider) *Token {
+	return &Token{provider: provider}
+}
+
+// SetLogger sets the logger of the refreshes of t. It must be called before t
+// is used.
+func (t *Token) SetLogger(logger *slog.Logger) {
+	if t != nil {
+		t.logger = logger
+	}
+}
+
+func (t *Token) log() *slog.Logger {
+	return debug.Logger(t.logger)
+}
+
+// Get returns the token to send, which is empty when no authentication is used.
+func (t *Token) Get(ctx context.Context) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	if t.provider == nil {
+		return t.static, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current != "" && (t.expiry.IsZero() || time.Until(t.expiry) > refreshMargin) {
+		return t.current, nil
+	}
+	t.log().DebugContext(ctx, "refreshing auth token", "expiry", t.expiry)
+	token, err := t.provider(ctx)
+	if err != nil {
+		t.log().WarnContext(ctx, "failed to refresh auth token", "error", err)
+		return "", fmt.Errorf("failed to get auth token: %w", err)
+	}
+	t.current, t.expiry = token, expiry(token)
+	return token, nil
+}
+
+// Refreshable reports whether a rejected token can be replaced by a new one.
+func (t *Token) Refreshable() bool {
+	return t != nil && t.provider != nil
+}
+
+// Invalidate forgets token, if it is the cached one, after the server rejected
+// it.
+func (t *Token) Invalidate(token string) {
+	if !t.Refreshable() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current == token {
+		t.current = ""
+	}
+}
+
+// expiry returns the exp claim of a JWT, or the zero time if token is not a JWT
+// or has no expiry. The signature is not verified, this is only used to refresh
+// tokens before the server starts rejecting them.
+func expiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(*claims.Exp), 0)
+}
+
+// Do sends the request built by newRequest with client and the token as bearer
+// authorization. If the server answers 401 and the token is refreshable, the
+// request is built and sent once more with a new token.
+func (t *Token) Do(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := t.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 || !t.Refreshable() {
+			return resp, nil
+		}
+		resp.Body.Close()
+		t.log().InfoContext(ctx, "auth token rejected, refreshing it", "url", debug.URL(req.URL.String()))
+		t.Invalidate(token)
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func jwt(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "header." + payload + ".signature"
+}
+
+func TestExpiry(t *testing.T) {
+	exp := time.Unix(1700000000, 0)
+	if got := expiry(jwt(exp)); !got.Equal(exp) {
+		t.Errorf("got %s, want %s", got, exp)
+	}
+	for _, token := range []string{"", "opaque", "a.b.c", "a." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".c"} {
+		if got := expiry(token); !got.IsZero() {
+			t.Errorf("got expiry %s for %q, want none", got, token)
+		}
+	}
+}
+
+func TestGetRefreshesNearExpiry(t *testing.T) {
+	calls := 0
+	exp := time.Now().Add(time.Hour)
+	token := FromProvider(func(ctx context.Context) (string, error) {
+		calls++
+		return jwt(exp), nil
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := token.Get(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %d provider calls, want the token to be cached", calls)
+	}
+	exp = time.Now().Add(refreshMargin / 2)
+	token.Invalidate(token.current)
+	for i := 0; i < 2; i++ {
+		if _, err := token.Get(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("got %d provider calls, want a token near expiry to be refreshed on every use", calls)
+	}
+}
+
+func TestDoRetriesUnauthorized(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	tokens := []string{"stale", "fresh"}
+	token := FromProvider(func(ctx context.Context) (string, error) {
+		next := tokens[0]
+		tokens = tokens[1:]
+		return next, nil
+	})
+	resp, err := token.Do(context.Background(), http.DefaultClient, func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want 200", resp.StatusCode)
+	}
+	if len(seen) != 2 || seen[0] != "Bearer stale" {
+		t.Errorf("got authorizations %v", seen)
+	}
+
+	resp, err = Static("stale").Do(context.Background(), http.DefaultClient, func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || len(seen) != 3 {
+		t.Errorf("static tokens should not be retried, got status %d after %d requests", resp.StatusCode, len(seen))
+	}
+}
@@ -0,0 +1,65 @@
+// Package background tracks the goroutines the driver runs on its own, so
+// tests and applications shutting down can wait for them to finish.
+//
+// Every goroutine started by the driver must go through Go and must stop
+// promptly once the context or connection it serves is canceled or closed.
+package background
+
+import (
+	"context"
+	"sync"
+)
+
+var state = struct {
+	sync.Mutex
+	running int
+	// idle is closed when running drops to zero.
+	idle chan struct{}
+}{}
+
+// Go runs fn in a new goroutine tracked by Wait.
+func Go(fn func()) {
+	state.Lock()
+	if state.running == 0 {
+		state.idle = make(chan struct{})
+	}
+	state.running++
+	state.Unlock()
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+func done() {
+	state.Lock()
+	defer state.Unlock()
+	state.running--
+	if state.running == 0 {
+		close(state.idle)
+	}
+}
+
+// Running returns the number of goroutines started by Go that did not return.
+func Running() int {
+	state.Lock()
+	defer state.Unlock()
+	return state.running
+}
+
+// Wait blocks until every goroutine started by Go returned or ctx is done.
+func Wait(ctx context.Context) error {
+	state.Lock()
+	if state.running == 0 {
+		state.Unlock()
+		return nil
+	}
+	idle := state.idle
+	state.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,29 @@
+package background
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	release := make(chan struct{})
+	Go(func() { <-release })
+	if Running() != 1 {
+		t.Fatalf("got %d running goroutines, want 1", Running())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v while a goroutine runs, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	if err := Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if Running() != 0 {
+		t.Errorf("got %d running goroutines, want 0", Running())
+	}
+}
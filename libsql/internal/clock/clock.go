@@ -0,0 +1,70 @@
+// Package clock estimates the offset between the local clock and the clock of
+// the server from the Date headers of its responses.
+package clock
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// smoothing is the weight of a new sample in the moving average of the
+// offset. Date headers only have a resolution of one second, averaging
+// smooths the resulting jitter out.
+const smoothing = 0.2
+
+type Estimate struct {
+	// ServerTime is the time in the last Date header received.
+	ServerTime time.Time
+	// ReceivedAt is the local time the last Date header was received at.
+	ReceivedAt time.Time
+	// Offset is the estimated time to add to the local clock to get the time
+	// of the server.
+	Offset time.Duration
+	// Samples is the number of responses the estimate is based on.
+	Samples int
+}
+
+type Estimator struct {
+	mu       sync.Mutex
+	estimate Estimate
+}
+
+// Observe records the Date header of resp to a request sent at sent. It does
+// nothing on a nil Estimator or when the header is missing.
+func (e *Estimator) Observe(sent time.Time, resp *http.Response) {
+	if e == nil || resp == nil {
+		return
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	received := time.Now()
+	// The server wrote the header around the middle of the round trip and
+	// truncated it to the second.
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	offset := date.Add(500 * time.Millisecond).Sub(midpoint)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.estimate.Samples == 0 {
+		e.estimate.Offset = offset
+	} else {
+		e.estimate.Offset += time.Duration(smoothing * float64(offset-e.estimate.Offset))
+	}
+	e.estimate.ServerTime = date
+	e.estimate.ReceivedAt = received
+	e.estimate.Samples++
+}
+
+// Estimate returns the current estimate, ok is false until a Date header was
+// observed.
+func (e *Estimator) Estimate() (estimate Estimate, ok bool) {
+	if e == nil {
+		return Estimate{}, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.estimate, e.estimate.Samples > 0
+}
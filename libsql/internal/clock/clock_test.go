@@ -0,0 +1,50 @@
+package clock
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func response(date time.Time) *http.Response {
+	return &http.Response{Header: http.Header{"Date": []string{date.UTC().Format(http.TimeFormat)}}}
+}
+
+func TestEstimator(t *testing.T) {
+	var e Estimator
+	if _, ok := e.Estimate(); ok {
+		t.Fatal("expected no estimate before any response")
+	}
+	e.Observe(time.Now(), &http.Response{Header: http.Header{}})
+	if _, ok := e.Estimate(); ok {
+		t.Fatal("responses without Date should be ignored")
+	}
+
+	ahead := time.Now().Add(time.Hour)
+	e.Observe(time.Now(), response(ahead))
+	estimate, ok := e.Estimate()
+	if !ok || estimate.Samples != 1 {
+		t.Fatalf("got %#v, %v", estimate, ok)
+	}
+	if diff := estimate.Offset - time.Hour; diff < -time.Second || diff > time.Second {
+		t.Errorf("got offset %s, want about 1h", estimate.Offset)
+	}
+	if !estimate.ServerTime.Equal(ahead.Truncate(time.Second)) {
+		t.Errorf("got server time %s, want %s", estimate.ServerTime, ahead)
+	}
+
+	// A single outlier only moves the average partially.
+	e.Observe(time.Now(), response(time.Now()))
+	estimate, _ = e.Estimate()
+	if estimate.Offset < 45*time.Minute || estimate.Offset > 50*time.Minute {
+		t.Errorf("got offset %s after an outlier, want about 48m", estimate.Offset)
+	}
+}
+
+func TestNilEstimator(t *testing.T) {
+	var e *Estimator
+	e.Observe(time.Now(), response(time.Now()))
+	if _, ok := e.Estimate(); ok {
+		t.Error("nil estimator should have no estimate")
+	}
+}
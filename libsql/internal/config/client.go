@@ -0,0 +1,60 @@
+package config
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+const modulePath = "github.com/libsql/libsql-client-go"
+
+var (
+	versionOnce sync.Once
+	version     string
+)
+
+// Version returns the version of the module the binary was built with, as
+// recorded in its build information, or "devel" when it is unknown, as in the
+// tests of the module itself.
+func Version() string {
+	versionOnce.Do(func() {
+		version = "devel"
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				if dep.Replace != nil {
+					dep = dep.Replace
+				}
+				if dep.Version != "" {
+					version = dep.Version
+				}
+			}
+		}
+	})
+	return version
+}
+
+// UserAgent returns the User-Agent of the driver, after ClientName if set.
+func (c *Config) UserAgent() string {
+	ua := "libsql-client-go/" + Version() + " (" + runtime.Version() + ")"
+	if c.ClientName != "" {
+		ua = c.ClientName + " " + ua
+	}
+	return ua
+}
+
+// ClientHeader returns the headers identifying the client, which are sent
+// before Headers, so Headers may replace them.
+func (c *Config) ClientHeader() http.Header {
+	return http.Header{
+		"User-Agent":              {c.UserAgent()},
+		"X-Libsql-Client-Version": {"libsql-client-go-" + Version()},
+	}
+}
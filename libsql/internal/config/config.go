@@ -0,0 +1,205 @@
+// Package config holds the connection settings shared by the transports.
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/clock"
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+	"github.com/libsql/libsql-client-go/libsql/internal/debug"
+	"github.com/libsql/libsql-client-go/libsql/internal/retry"
+)
+
+type Config struct {
+	// StreamRows decodes query results incrementally as rows are read instead
+	// of buffering the whole response first.
+	StreamRows bool
+
+	// WebsocketMaxStreams is the number of streams a websocket may host, 1 if
+	// zero.
+	WebsocketMaxStreams int
+	// WebsocketWarmup is the number of websockets opened by the first
+	// connection and kept open while idle.
+	WebsocketWarmup int
+	// WebsocketMaxRequests and WebsocketMaxAge retire a websocket after that
+	// many requests or that much time. Zero disables the limit.
+	WebsocketMaxRequests int
+	WebsocketMaxAge      time.Duration
+
+	// StatementCacheSize is the number of parsed queries cached by each
+	// connection, zero disables the cache.
+	StatementCacheSize int
+
+	// ConnectTimeout bounds opening a connection and RequestTimeout every
+	// request, whatever the deadline of the context passed by the caller. Zero
+	// keeps the default of the transport.
+	ConnectTimeout time.Duration
+	RequestTimeout time.Duration
+	// DialTimeout bounds opening a TCP connection to the server, across all
+	// of its addresses, and FallbackDelay is how long the first address family
+	// is tried before racing the other one, as in Happy Eyeballs. The dialer
+	// of the default transport is used when both are zero.
+	DialTimeout   time.Duration
+	FallbackDelay time.Duration
+	// StreamIdleTimeout discards connections whose stream was idle for longer
+	// when they are taken from the pool. Zero disables the limit.
+	StreamIdleTimeout time.Duration
+
+	// RequestCompressionThreshold is the size from which request bodies sent
+	// over HTTP are compressed with gzip, zero disables compression.
+	RequestCompressionThreshold int
+	// DisableResponseCompression stops asking for gzip compressed responses
+	// over HTTP.
+	DisableResponseCompression bool
+
+	// MaxRows and MaxResponseBytes bound the number of rows of a result and
+	// the size of a response, zero disables the limit.
+	MaxRows          int
+	MaxResponseBytes int64
+	// MaxRequestBytes is the size from which batches are split into several
+	// requests, DefaultMaxRequestBytes if zero.
+	MaxRequestBytes int
+
+	// RateLimitRetries is the number of times a request answered with HTTP 429
+	// is sent again, after the delay asked by the server, within the deadline
+	// of the request.
+	RateLimitRetries int
+
+	// Headers are sent with every HTTP request and with the handshake of
+	// websockets.
+	Headers http.Header
+	// Transport, if set, sends the HTTP requests and websocket handshakes
+	// instead of the transport of the default clients, as configured by a
+	// proxy.
+	Transport http.RoundTripper
+	// RequestMiddleware, if set, is called with every HTTP request and
+	// websocket handshake right before it is sent, once all its headers are
+	// set. An error fails the request without sending it.
+	RequestMiddleware func(*http.Request) error
+	// ClientName identifies the application in the User-Agent header, before
+	// the name and version of the driver.
+	ClientName string
+	// Database is the logical database, or namespace, of a multi-tenant
+	// server that requests are routed to, sent in the NamespaceHeader header.
+	// Empty targets the database selected by the host name.
+	Database string
+
+	// Clock, if set, estimates the clock offset of the server from the
+	// responses of the transports.
+	Clock *clock.Estimator
+
+	// Breaker, if set, short-circuits requests while the server is
+	// unreachable.
+	Breaker *retry.Breaker
+
+	// Logger receives the debug events of the transports. Nil logs them only
+	// when LIBSQL_DEBUG is set.
+	Logger *slog.Logger
+}
+
+// Log returns the logger of the transports.
+func (c *Config) Log() *slog.Logger {
+	return debug.Logger(c.Logger)
+}
+
+// DefaultMaxRequestBytes keeps requests well below the payload limit of sqld.
+const DefaultMaxRequestBytes = 4 << 20
+
+// RequestBytes returns MaxRequestBytes, or DefaultMaxRequestBytes if it is
+// zero.
+func (c *Config) RequestBytes() int {
+	if c.MaxRequestBytes > 0 {
+		return c.MaxRequestBytes
+	}
+	return DefaultMaxRequestBytes
+}
+
+// RequestContext bounds ctx by the timeout hint of ctx, or else by
+// RequestTimeout, or by def if RequestTimeout is zero. A zero def leaves ctx
+// unbounded.
+func (c *Config) RequestContext(ctx context.Context, def time.Duration) (context.Context, context.CancelFunc) {
+	timeout := def
+	if c.RequestTimeout > 0 {
+		timeout = c.RequestTimeout
+	}
+	if hint := ctxopt.TimeoutHint(ctx); hint > 0 {
+		timeout = hint
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Client returns def, or a copy of def sending requests with Transport if it is
+// set, through RequestMiddleware if it is set.
+func (c *Config) Client(def *http.Client) *http.Client {
+	if c.Transport == nil && c.RequestMiddleware == nil {
+		return def
+	}
+	client := *def
+	if c.Transport != nil {
+		client.Transport = c.Transport
+	}
+	if c.RequestMiddleware != nil {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		client.Transport = &middlewareTransport{next: next, middleware: c.RequestMiddleware}
+	}
+	return &client
+}
+
+// middlewareTransport calls middleware with a copy of every request before
+// sending it with next, as round trippers must not change their requests.
+type middlewareTransport struct {
+	next       http.RoundTripper
+	middleware func(*http.Request) error
+}
+
+func (t *middlewareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := t.middleware(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("request middleware failed: %w", err)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// SetHeaders adds the headers of ClientHeader and Headers to req, then the
+// headers of the context of req, which replace the values of the names both
+// set. Transports call it before setting their own headers, which take
+// precedence.
+func (c *Config) SetHeaders(req *http.Request) {
+	for name, values := range c.HandshakeHeader() {
+		req.Header[name] = values
+	}
+	for name, values := range ctxopt.Headers(req.Context()) {
+		req.Header[name] = values
+	}
+}
+
+// NamespaceHeader is the header sqld selects the database of a request by
+// when it hosts several of them.
+const NamespaceHeader = "X-Namespace"
+
+// HandshakeHeader returns the headers of ClientHeader and Headers, which
+// replace the values of the names both set, and the NamespaceHeader of
+// Database, as sent with the handshake of websockets.
+func (c *Config) HandshakeHeader() http.Header {
+	header := c.ClientHeader()
+	for name, values := range c.Headers {
+		header[name] = values
+	}
+	if c.Database != "" {
+		header.Set(NamespaceHeader, c.Database)
+	}
+	return header
+}
@@ -0,0 +1,69 @@
+// Package ctxopt holds the per-call options that the public libsql package
+// stores in a context and the transports read back.
+package ctxopt
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type key int
+
+const (
+	atomicBatchKey key = iota
+	headersKey
+	timeoutHintKey
+	txModeKey
+)
+
+func WithAtomicBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, atomicBatchKey, true)
+}
+
+func AtomicBatch(ctx context.Context) bool {
+	v, _ := ctx.Value(atomicBatchKey).(bool)
+	return v
+}
+
+// WithHeaders returns a context whose HTTP requests carry header on top of the
+// headers of ctx, replacing the values of the names both set.
+func WithHeaders(ctx context.Context, header http.Header) context.Context {
+	merged := Headers(ctx).Clone()
+	if merged == nil {
+		merged = http.Header{}
+	}
+	for name, values := range header {
+		merged[name] = values
+	}
+	return context.WithValue(ctx, headersKey, merged)
+}
+
+func Headers(ctx context.Context) http.Header {
+	header, _ := ctx.Value(headersKey).(http.Header)
+	return header
+}
+
+// WithTimeoutHint returns a context whose requests are bounded by d instead of
+// the request timeout of the connection.
+func WithTimeoutHint(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutHintKey, d)
+}
+
+// TimeoutHint returns the timeout set by WithTimeoutHint, zero if none.
+func TimeoutHint(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(timeoutHintKey).(time.Duration)
+	return d
+}
+
+// WithTxMode returns a context whose transactions begin in mode, one of
+// DEFERRED, IMMEDIATE and EXCLUSIVE.
+func WithTxMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, txModeKey, mode)
+}
+
+// TxMode returns the transaction mode set by WithTxMode, empty if none.
+func TxMode(ctx context.Context) string {
+	mode, _ := ctx.Value(txModeKey).(string)
+	return mode
+}
@@ -0,0 +1,317 @@
+// Package dburl parses the database URLs of the driver, for the libsql package
+// and the clients built next to it, so that they all accept the same URLs.
+package dburl
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/params"
+)
+
+// URL holds the settings of a database URL.
+type URL struct {
+	// URL is the address of the server, without the settings of the query
+	// string, nil for file URLs which are handled by a sqlite driver.
+	URL   *url.URL
+	Token string
+	TLS   bool
+
+	TimeFormat   params.TimeFormat
+	ParseTime    bool
+	StreamRows   bool
+	StrictTypes  bool
+	ExpandSlices bool
+	BusyTimeout  time.Duration
+
+	MaxRows          int
+	MaxResponseBytes int64
+	MaxRequestBytes  int
+
+	// Database is the logical database selected by the URL, if any.
+	Database string
+
+	// Deprecations are the warnings about deprecated settings of the URL.
+	Deprecations []string
+}
+
+// Parse validates dbUrl and extracts the settings from its query string.
+func Parse(dbUrl string) (*URL, error) {
+	p := &URL{}
+	u, err := url.Parse(dbUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("invalid database URL: missing scheme, database URLs start with libsql://, https://, wss:// or file:")
+	}
+	if u.Scheme == "file" {
+		if strings.HasPrefix(dbUrl, "file://") && !strings.HasPrefix(dbUrl, "file:///") {
+			return nil, fmt.Errorf("invalid database URL: %s. File URLs should not have double leading slashes. ", dbUrl)
+		}
+		return p, nil
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid database URL: missing host, use for example %s://db.example.com", u.Scheme)
+	}
+
+	query := u.Query()
+	if query.Get("jwt") != "" {
+		p.Deprecations = append(p.Deprecations, "the jwt query parameter is deprecated, use authToken instead")
+	}
+	if p.Token, err = extractJwt(&query); err != nil {
+		return nil, err
+	}
+
+	if p.TLS, err = extractTls(&query, u.Scheme); err != nil {
+		return nil, err
+	}
+
+	if p.TimeFormat, err = extractTimeFormat(&query); err != nil {
+		return nil, err
+	}
+
+	if p.ParseTime, err = extractBool(&query, "parseTime"); err != nil {
+		return nil, err
+	}
+
+	if p.StreamRows, err = extractBool(&query, "streamRows"); err != nil {
+		return nil, err
+	}
+
+	if p.StrictTypes, err = extractBool(&query, "strictTypes"); err != nil {
+		return nil, err
+	}
+	if p.ExpandSlices, err = extractBool(&query, "expandSlices"); err != nil {
+		return nil, err
+	}
+
+	if p.BusyTimeout, err = extractBusyTimeout(&query); err != nil {
+		return nil, err
+	}
+
+	maxRows, err := extractLimit(&query, "maxRows")
+	if err != nil {
+		return nil, err
+	}
+	p.MaxRows = int(maxRows)
+
+	if p.MaxResponseBytes, err = extractLimit(&query, "maxResponseBytes"); err != nil {
+		return nil, err
+	}
+
+	maxRequestBytes, err := extractLimit(&query, "maxRequestBytes")
+	if err != nil {
+		return nil, err
+	}
+	p.MaxRequestBytes = int(maxRequestBytes)
+
+	insecure, err := extractBool(&query, "insecure")
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Database, err = extractDatabase(&query, u); err != nil {
+		return nil, err
+	}
+
+	for name := range query {
+		return nil, fmt.Errorf("unknown query parameter %#v", name)
+	}
+	u.RawQuery = ""
+
+	switch u.Scheme {
+	case "libsql":
+		if !p.TLS && u.Port() == "" {
+			return nil, fmt.Errorf("libsql:// URL with ?tls=0 must specify an explicit port")
+		}
+		if !p.TLS && !insecure && !isLoopback(u.Hostname()) {
+			return nil, fmt.Errorf("libsql:// URL with ?tls=0 sends the auth token and data unencrypted to %s, remove ?tls=0 or add ?insecure=1 to allow it (only localhost and loopback addresses are allowed without it)", u.Hostname())
+		}
+	case "wss", "https":
+		if !p.TLS {
+			return nil, fmt.Errorf("%s:// URL cannot opt out of TLS using ?tls=0", u.Scheme)
+		}
+	case "ws", "http":
+		if p.TLS {
+			return nil, fmt.Errorf("%s:// URL cannot opt in to TLS using ?tls=1", u.Scheme)
+		}
+		if !insecure && !isLoopback(u.Hostname()) {
+			return nil, fmt.Errorf("%s:// URL sends the auth token and data unencrypted to %s, use %ss:// or add ?insecure=1 to allow it (only localhost and loopback addresses are allowed without it)", u.Scheme, u.Hostname(), u.Scheme)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme: %s\nThis driver supports only URLs that start with libsql://, file://, https://, http://, wss:// and ws://", u.Scheme)
+	}
+	if insecure && p.TLS {
+		return nil, fmt.Errorf("the insecure query parameter only applies to http://, ws:// and libsql:// URLs with ?tls=0")
+	}
+	p.URL = u
+	return p, nil
+}
+
+// HTTP returns the base URL of the requests made over plain HTTP to the server
+// at u, whatever the transport selected by its scheme.
+func HTTP(u url.URL, tls bool) *url.URL {
+	if tls {
+		u.Scheme = "https"
+	} else {
+		u.Scheme = "http"
+	}
+	return &u
+}
+
+// extractJwt extracts the JWT from the URL and removes it from the url.
+func extractJwt(query *url.Values) (string, error) {
+	authTokenSnake := query.Get("auth_token")
+	authTokenCamel := query.Get("authToken")
+	jwt := query.Get("jwt")
+	query.Del("auth_token")
+	query.Del("authToken")
+	query.Del("jwt")
+
+	countNonEmpty := func(slice ...string) int {
+		count := 0
+		for _, s := range slice {
+			if s != "" {
+				count++
+			}
+		}
+		return count
+	}
+
+	if countNonEmpty(authTokenSnake, authTokenCamel, jwt) > 1 {
+		return "", fmt.Errorf("please use at most one of the following query parameters: 'auth_token', 'authToken', 'jwt'")
+	}
+
+	if authTokenSnake != "" {
+		return authTokenSnake, nil
+	} else if authTokenCamel != "" {
+		return authTokenCamel, nil
+	} else {
+		return jwt, nil
+	}
+}
+
+func extractTls(query *url.Values, scheme string) (bool, error) {
+	tls := query.Get("tls")
+	query.Del("tls")
+	if tls == "" {
+		if scheme == "http" || scheme == "ws" {
+			return false, nil
+		} else {
+			return true, nil
+		}
+	} else if tls == "0" {
+		return false, nil
+	} else if tls == "1" {
+		return true, nil
+	} else {
+		return true, fmt.Errorf("unknown value of tls query parameter. Valid values are 0 and 1")
+	}
+}
+
+func extractTimeFormat(query *url.Values) (params.TimeFormat, error) {
+	timeFormat := query.Get("timeFormat")
+	query.Del("timeFormat")
+	return params.ParseTimeFormat(timeFormat)
+}
+
+func extractBool(query *url.Values, name string) (bool, error) {
+	value := query.Get(name)
+	query.Del(name)
+	switch value {
+	case "", "false", "0":
+		return false, nil
+	case "true", "1":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown value of %s query parameter. Valid values are true and false", name)
+	}
+}
+
+func extractLimit(query *url.Values, name string) (int64, error) {
+	value := query.Get(name)
+	query.Del(name)
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid value of %s query parameter. It must be a positive integer", name)
+	}
+	return limit, nil
+}
+
+// extractBusyTimeout returns the busy timeout of the busyTimeout query
+// parameter, given in milliseconds like the busy_timeout pragma or as a
+// duration like 5s.
+func extractBusyTimeout(query *url.Values) (time.Duration, error) {
+	value := query.Get("busyTimeout")
+	query.Del("busyTimeout")
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if ms, msErr := strconv.ParseInt(value, 10, 64); msErr == nil {
+		d, err = time.Duration(ms)*time.Millisecond, nil
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid value of busyTimeout query parameter: %s. Use milliseconds or a duration like 5s", value)
+	}
+	return d, nil
+}
+
+// extractDatabase returns the logical database selected by the database query
+// parameter or, for libsql:// URLs, by the path, which it removes from u. The
+// paths of other URLs are a prefix of the endpoints of the server, as set by a
+// reverse proxy.
+func extractDatabase(query *url.Values, u *url.URL) (string, error) {
+	database := query.Get("database")
+	query.Del("database")
+	if u.Scheme == "libsql" && strings.Trim(u.Path, "/") != "" {
+		name := strings.Trim(u.Path, "/")
+		if strings.Contains(name, "/") {
+			return "", fmt.Errorf("invalid database URL: the path of a libsql:// URL is a database name, got %s", u.Path)
+		}
+		if database != "" && database != name {
+			return "", fmt.Errorf("invalid database URL: database %q in the path and %q in the query string", name, database)
+		}
+		database = name
+		u.Path, u.RawPath = "", ""
+	}
+	if database == "" {
+		return "", nil
+	}
+	if err := CheckDatabaseName(database); err != nil {
+		return "", err
+	}
+	return database, nil
+}
+
+// isLoopback reports whether host names the local machine, which plain HTTP
+// and websocket URLs may reach without ?insecure=1.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// CheckDatabaseName fails names that are not valid sqld namespaces.
+func CheckDatabaseName(name string) error {
+	if name == "" {
+		return fmt.Errorf("database name must not be empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("invalid database name %q: only letters, digits, '-' and '_' are allowed", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Package debug provides the logger of the driver.
+package debug
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"os"
+)
+
+// enabled is set when the LIBSQL_DEBUG environment variable is not empty.
+var enabled = os.Getenv("LIBSQL_DEBUG") != ""
+
+// fallback logs to the standard error at the debug level when debugging is
+// enabled, and discards messages otherwise.
+var fallback = newFallback()
+
+func newFallback() *slog.Logger {
+	if enabled {
+		return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).With("logger", "libsql")
+	}
+	return slog.New(discardHandler{})
+}
+
+// Logger returns l, or the logger used when none was configured.
+func Logger(l *slog.Logger) *slog.Logger {
+	if l != nil {
+		return l
+	}
+	return fallback
+}
+
+// URL returns u without the credentials its user info or query string may
+// hold, to be logged.
+func URL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	parsed.User, parsed.RawQuery, parsed.Fragment = nil, "", ""
+	return parsed.String()
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
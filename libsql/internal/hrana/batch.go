@@ -1,5 +1,7 @@
 package hrana
 
+import "encoding/json"
+
 type Batch struct {
 	Steps []BatchStep `json:"steps"`
 }
@@ -19,3 +21,40 @@ type BatchCondition struct {
 func (b *Batch) Add(stmt Stmt) {
 	b.Steps = append(b.Steps, BatchStep{Stmt: stmt})
 }
+
+// Split divides the batch into batches whose JSON encoding does not exceed
+// maxBytes. Steps keep their order and a step larger than maxBytes is placed in
+// a batch of its own. Conditions referencing other steps do not survive a
+// split, so batches with conditions are never split.
+func (b *Batch) Split(maxBytes int) ([]*Batch, error) {
+	for _, step := range b.Steps {
+		if step.Condition != nil {
+			return []*Batch{b}, nil
+		}
+	}
+	// Room for `{"steps":[]}`.
+	const overhead = 12
+	var batches []*Batch
+	current := &Batch{}
+	size := overhead
+	for _, step := range b.Steps {
+		encoded, err := json.Marshal(step)
+		if err != nil {
+			return nil, err
+		}
+		stepSize := len(encoded)
+		if len(current.Steps) > 0 {
+			// Steps are separated by a comma.
+			stepSize++
+			if size+stepSize > maxBytes {
+				batches = append(batches, current)
+				current = &Batch{}
+				size = overhead
+				stepSize--
+			}
+		}
+		current.Steps = append(current.Steps, step)
+		size += stepSize
+	}
+	return append(batches, current), nil
+}
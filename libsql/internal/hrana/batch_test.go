@@ -1 +1,85 @@
 package hrana
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBatchSplit(t *testing.T) {
+	batch := &Batch{}
+	for i := 0; i < 10; i++ {
+		sql := "INSERT INTO t VALUES ('" + strings.Repeat("x", 100) + "')"
+		batch.Add(Stmt{Sql: &sql})
+	}
+	encoded, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts, err := batch.Split(len(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 {
+		t.Fatalf("got %d parts for a batch within the limit, want 1", len(parts))
+	}
+
+	limit := len(encoded) / 3
+	parts, err = batch.Split(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) < 3 {
+		t.Fatalf("got %d parts, want at least 3", len(parts))
+	}
+	total := 0
+	for _, part := range parts {
+		encoded, err := json.Marshal(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(encoded) > limit && len(part.Steps) > 1 {
+			t.Errorf("part of %d bytes exceeds the limit", len(encoded))
+		}
+		total += len(part.Steps)
+	}
+	if total != len(batch.Steps) {
+		t.Errorf("got %d steps after split, want %d", total, len(batch.Steps))
+	}
+}
+
+func TestBatchSplitOversizedStep(t *testing.T) {
+	batch := &Batch{}
+	small, big := "SELECT 1", "SELECT '"+strings.Repeat("x", 1000)+"'"
+	batch.Add(Stmt{Sql: &small})
+	batch.Add(Stmt{Sql: &big})
+	batch.Add(Stmt{Sql: &small})
+
+	parts, err := batch.Split(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("got %d parts, want 3", len(parts))
+	}
+	if *parts[1].Steps[0].Stmt.Sql != big {
+		t.Errorf("oversized step was not kept in order")
+	}
+}
+
+func TestBatchSplitWithConditions(t *testing.T) {
+	batch := &Batch{}
+	sql := "SELECT 1"
+	step := int32(0)
+	batch.Add(Stmt{Sql: &sql})
+	batch.Steps = append(batch.Steps, BatchStep{Stmt: Stmt{Sql: &sql}, Condition: &BatchCondition{Type: "ok", Step: &step}})
+
+	parts, err := batch.Split(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 {
+		t.Fatalf("got %d parts for a batch with conditions, want 1", len(parts))
+	}
+}
@@ -0,0 +1,86 @@
+package hrana
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// RequestType describes a type of stream request. Transports look request types
+// up to check that the server supports them, so new types only need to be
+// registered.
+type RequestType struct {
+	Name string
+	// MinVersion is the first version of Hrana supporting the type.
+	MinVersion int
+	// Decode, if set, decodes the response of a request of this type.
+	Decode func(response json.RawMessage) (any, error)
+}
+
+var requestTypes = struct {
+	sync.RWMutex
+	byName map[string]*RequestType
+}{byName: map[string]*RequestType{}}
+
+// RegisterRequestType adds t to the registry, replacing a type of the same
+// name.
+func RegisterRequestType(t *RequestType) {
+	requestTypes.Lock()
+	defer requestTypes.Unlock()
+	requestTypes.byName[t.Name] = t
+}
+
+// LookupRequestType returns the registered type called name.
+func LookupRequestType(name string) (*RequestType, bool) {
+	requestTypes.RLock()
+	defer requestTypes.RUnlock()
+	t, ok := requestTypes.byName[name]
+	return t, ok
+}
+
+// decodeResult returns a decoder of the "result" field of a response into T.
+func decodeResult[T any]() func(json.RawMessage) (any, error) {
+	return func(response json.RawMessage) (any, error) {
+		var r struct {
+			Result *T `json:"result"`
+		}
+		if err := json.Unmarshal(response, &r); err != nil {
+			return nil, err
+		}
+		return r.Result, nil
+	}
+}
+
+func init() {
+	for _, t := range []*RequestType{
+		{Name: "close", MinVersion: 2},
+		{Name: "execute", MinVersion: 2, Decode: decodeResult[StmtResult]()},
+		{Name: "batch", MinVersion: 2, Decode: decodeResult[BatchResult]()},
+		{Name: "sequence", MinVersion: 2},
+		{Name: "describe", MinVersion: 2, Decode: decodeResult[json.RawMessage]()},
+		{Name: "store_sql", MinVersion: 2},
+		{Name: "close_sql", MinVersion: 2},
+		{Name: "get_autocommit", MinVersion: 3, Decode: func(response json.RawMessage) (any, error) {
+			var r struct {
+				IsAutocommit bool `json:"is_autocommit"`
+			}
+			err := json.Unmarshal(response, &r)
+			return r.IsAutocommit, err
+		}},
+	} {
+		RegisterRequestType(t)
+	}
+}
+
+// RawPipelineResponse is a pipeline response whose stream responses are left
+// undecoded.
+type RawPipelineResponse struct {
+	Baton   string            `json:"baton,omitempty"`
+	BaseUrl string            `json:"base_url,omitempty"`
+	Results []RawStreamResult `json:"results"`
+}
+
+type RawStreamResult struct {
+	Type     string          `json:"type"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    *Error          `json:"error,omitempty"`
+}
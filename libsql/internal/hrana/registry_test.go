@@ -0,0 +1,48 @@
+package hrana
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	execute, ok := LookupRequestType("execute")
+	if !ok {
+		t.Fatal("execute is not registered")
+	}
+	result, err := execute.Decode(json.RawMessage(`{"type":"execute","result":{"cols":[],"rows":[],"affected_row_count":3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.(*StmtResult).AffectedRowCount; got != 3 {
+		t.Errorf("got %d affected rows, want 3", got)
+	}
+
+	RegisterRequestType(&RequestType{Name: "test_future", MinVersion: 4})
+	if typ, ok := LookupRequestType("test_future"); !ok || typ.MinVersion != 4 {
+		t.Errorf("got %#v, want the registered type", typ)
+	}
+	if _, ok := LookupRequestType("unknown"); ok {
+		t.Error("unknown type should not be registered")
+	}
+}
+
+func TestRawStream(t *testing.T) {
+	r, err := RawStream(json.RawMessage(`{"type":"future","x":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := PipelineRequest{}
+	msg.Add(r)
+	msg.Add(CloseStream())
+	got, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"requests":[{"type":"future","x":1},{"type":"close"}]}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, err := RawStream(json.RawMessage(`{}`)); err == nil {
+		t.Error("expected error for a request without type")
+	}
+}
@@ -0,0 +1,248 @@
+package hrana
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Rows are the rows of a statement result. They are decoded by a scanner
+// specialized for Hrana values rather than by reflection, and the values of
+// all rows share a single backing array.
+type Rows [][]Value
+
+func (r *Rows) UnmarshalJSON(data []byte) error {
+	d := valueDecoder{data: data}
+	// Every value has a type field, counting them sizes the backing array
+	// without growing it, only text values mentioning "type" inflate it.
+	values := make([]Value, 0, bytes.Count(data, typeKey))
+	var ends []int
+	ok := d.list(func() bool {
+		ok := d.list(func() bool {
+			v, ok := d.value()
+			values = append(values, v)
+			return ok
+		})
+		ends = append(ends, len(values))
+		return ok
+	})
+	if !ok || !d.end() {
+		*r = nil
+		return json.Unmarshal(data, (*[][]Value)(r))
+	}
+	rows := make(Rows, len(ends))
+	start := 0
+	for idx, end := range ends {
+		rows[idx] = values[start:end:end]
+		start = end
+	}
+	*r = rows
+	return nil
+}
+
+// Row is a single row, as decoded when rows are streamed. Decoding reuses the
+// capacity of the row.
+type Row []Value
+
+func (r *Row) UnmarshalJSON(data []byte) error {
+	d := valueDecoder{data: data}
+	row := (*r)[:0]
+	ok := d.list(func() bool {
+		v, ok := d.value()
+		row = append(row, v)
+		return ok
+	})
+	if !ok || !d.end() {
+		*r = nil
+		return json.Unmarshal(data, (*[]Value)(r))
+	}
+	*r = row
+	return nil
+}
+
+var typeKey = []byte(`"type"`)
+
+// valueDecoder scans arrays of values in the form servers send them, with
+// only the type, value and base64 fields. Its methods return false on anything
+// else, and the caller falls back to encoding/json, so it never has to decode
+// the same input differently.
+type valueDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *valueDecoder) skipSpace() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (d *valueDecoder) consume(c byte) bool {
+	d.skipSpace()
+	if d.pos < len(d.data) && d.data[d.pos] == c {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func (d *valueDecoder) end() bool {
+	d.skipSpace()
+	return d.pos == len(d.data)
+}
+
+// list scans an array, calling elem for every element.
+func (d *valueDecoder) list(elem func() bool) bool {
+	if !d.consume('[') {
+		return false
+	}
+	if d.consume(']') {
+		return true
+	}
+	for {
+		if !elem() {
+			return false
+		}
+		if d.consume(']') {
+			return true
+		}
+		if !d.consume(',') {
+			return false
+		}
+	}
+}
+
+// str scans a string, returning its raw content when it has no escapes.
+func (d *valueDecoder) str() ([]byte, bool) {
+	if !d.consume('"') {
+		return nil, false
+	}
+	start := d.pos
+	ascii := true
+	for d.pos < len(d.data) {
+		switch c := d.data[d.pos]; {
+		case c == '"':
+			s := d.data[start:d.pos]
+			d.pos++
+			// encoding/json replaces invalid UTF-8, leave those strings to it.
+			return s, ascii || utf8.Valid(s)
+		case c == '\\' || c < 0x20:
+			return nil, false
+		case c >= utf8.RuneSelf:
+			ascii = false
+		}
+		d.pos++
+	}
+	return nil, false
+}
+
+// number scans a number, returning its raw text.
+func (d *valueDecoder) number() ([]byte, bool) {
+	d.skipSpace()
+	start := d.pos
+	for d.pos < len(d.data) && isNumberByte(d.data[d.pos]) {
+		d.pos++
+	}
+	return d.data[start:d.pos], d.pos > start
+}
+
+func isNumberByte(c byte) bool {
+	return c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+func (d *valueDecoder) value() (Value, bool) {
+	if !d.consume('{') {
+		return Value{}, false
+	}
+	var typ, text, base64, num []byte
+	var hasText, hasNum, hasBase64, isNull bool
+	if !d.consume('}') {
+		for {
+			key, ok := d.str()
+			if !ok || !d.consume(':') {
+				return Value{}, false
+			}
+			switch string(key) {
+			case "type":
+				if typ, ok = d.str(); !ok {
+					return Value{}, false
+				}
+			case "value":
+				d.skipSpace()
+				switch {
+				case d.pos < len(d.data) && d.data[d.pos] == '"':
+					text, hasText = d.str()
+					if !hasText {
+						return Value{}, false
+					}
+				case len(d.data)-d.pos >= len("null") && string(d.data[d.pos:d.pos+len("null")]) == "null":
+					d.pos += len("null")
+					isNull = true
+				default:
+					if num, hasNum = d.number(); !hasNum {
+						return Value{}, false
+					}
+				}
+			case "base64":
+				if base64, hasBase64 = d.str(); !hasBase64 {
+					return Value{}, false
+				}
+			default:
+				return Value{}, false
+			}
+			if d.consume('}') {
+				break
+			}
+			if !d.consume(',') {
+				return Value{}, false
+			}
+		}
+	}
+	if hasText && hasNum || isNull && (hasText || hasNum) {
+		return Value{}, false
+	}
+
+	switch string(typ) {
+	case "null":
+		if hasText || hasNum || hasBase64 {
+			return Value{}, false
+		}
+		return Value{Type: "null"}, true
+	case "integer":
+		if !hasText || hasBase64 {
+			return Value{}, false
+		}
+		// The integer is parsed once here, ToValue returns it without boxing
+		// it again.
+		if integer, err := strconv.ParseInt(string(text), 10, 64); err == nil {
+			return Value{Type: "integer", Value: integer}, true
+		}
+		return Value{Type: "integer", Value: string(text)}, true
+	case "text":
+		if !hasText || hasBase64 {
+			return Value{}, false
+		}
+		return Value{Type: "text", Value: string(text)}, true
+	case "float":
+		if !hasNum || hasBase64 {
+			return Value{}, false
+		}
+		float, err := strconv.ParseFloat(string(num), 64)
+		if err != nil {
+			return Value{}, false
+		}
+		return Value{Type: "float", Value: float}, true
+	case "blob":
+		if hasText || hasNum {
+			return Value{}, false
+		}
+		return Value{Type: "blob", Base64: string(base64)}, true
+	}
+	return Value{}, false
+}
@@ -0,0 +1,120 @@
+package hrana
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// driverValues converts decoded rows into the values returned by the driver.
+func driverValues(rows [][]Value) [][]any {
+	res := make([][]any, len(rows))
+	for idx, row := range rows {
+		res[idx] = make([]any, len(row))
+		for col, v := range row {
+			res[idx][col] = v.ToValue()
+		}
+	}
+	return res
+}
+
+func TestRowsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "empty", data: `[]`},
+		{name: "emptyRow", data: `[[]]`},
+		{name: "types", data: `[[{"type":"null"},{"type":"integer","value":"-42"},{"type":"float","value":1.5e3},{"type":"text","value":"héllo"},{"type":"blob","base64":"YmFy"}]]`},
+		{name: "spaces", data: " [ [ { \"type\" : \"integer\" , \"value\" : \"1\" } ] ,\n[ {\"value\":\"a\",\"type\":\"text\"} ] ] "},
+		{name: "escapes", data: `[[{"type":"text","value":"a\"b\\cé\n"}]]`},
+		{name: "invalidUTF8", data: "[[{\"type\":\"text\",\"value\":\"a\xffb\"}]]"},
+		{name: "unknownField", data: `[[{"type":"text","value":"a","extra":{"nested":[1,2]}}]]`},
+		{name: "integerOverflow", data: `[[{"type":"integer","value":"99999999999999999999"}]]`},
+		{name: "nullValue", data: `[[{"type":"null","value":null}]]`},
+		{name: "textAsNumber", data: `[[{"type":"text","value":12}]]`},
+		{name: "unknownType", data: `[[{"type":"decimal","value":"1.5"}]]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want [][]Value
+			if err := json.Unmarshal([]byte(tt.data), &want); err != nil {
+				t.Fatal(err)
+			}
+			var got Rows
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(driverValues(got), driverValues(want)) {
+				t.Errorf("got %#v, want %#v", driverValues(got), driverValues(want))
+			}
+		})
+	}
+}
+
+func TestRowsUnmarshalJSONInvalid(t *testing.T) {
+	for _, data := range []string{`[[{"type":"text","value":"a"}]`, `{"rows":[]}`, `[[{"type":"text","value":"a"},]]`} {
+		var got Rows
+		if err := got.UnmarshalJSON([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+}
+
+func TestRowUnmarshalJSONReusesRow(t *testing.T) {
+	var row Row
+	if err := json.Unmarshal([]byte(`[{"type":"blob","base64":"YmFy"},{"type":"integer","value":"1"}]`), &row); err != nil {
+		t.Fatal(err)
+	}
+	first := &row[0]
+	if err := json.Unmarshal([]byte(`[{"type":"text","value":"a"}]`), &row); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Row{{Type: "text", Value: "a"}}); !reflect.DeepEqual(row, want) {
+		t.Errorf("got %#v, want %#v", row, want)
+	}
+	if &row[0] != first {
+		t.Error("expected the row to be decoded into the previous one")
+	}
+}
+
+var sink any
+
+// convertRows converts rows like the driver does, without keeping the values.
+func convertRows(rows [][]Value) {
+	for _, row := range rows {
+		for _, v := range row {
+			sink = v.ToValue()
+		}
+	}
+}
+
+// BenchmarkDecodeRows compares decoding rows with the scanner of Rows to
+// decoding them with reflection, as done before Rows existed.
+func BenchmarkDecodeRows(b *testing.B) {
+	row := `[{"type":"integer","value":"12345"},{"type":"text","value":"some text value"},{"type":"float","value":1.5},{"type":"null"}]`
+	data := []byte("[" + strings.Repeat(row+",", 99) + row + "]")
+	b.Run("Rows", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			var rows Rows
+			if err := json.Unmarshal(data, &rows); err != nil {
+				b.Fatal(err)
+			}
+			convertRows(rows)
+		}
+	})
+	b.Run("reflection", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			var rows [][]Value
+			if err := json.Unmarshal(data, &rows); err != nil {
+				b.Fatal(err)
+			}
+			convertRows(rows)
+		}
+	})
+}
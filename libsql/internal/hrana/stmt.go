@@ -1,6 +1,10 @@
 package hrana
 
-import "github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+import (
+	"sort"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
 
 type Stmt struct {
 	Sql       *string    `json:"sql,omitempty"`
@@ -36,19 +40,22 @@ func (s *Stmt) AddPositionalArgs(args []any) error {
 }
 
 func (s *Stmt) AddNamedArgs(args map[string]any) error {
+	// Sort the names so that requests are deterministic.
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 	argValues := make([]NamedArg, len(args))
-	idx := 0
-	for key, value := range args {
-		var err error
-		var v Value
-		if v, err = ToValue(value); err != nil {
+	for idx, name := range names {
+		v, err := ToValue(args[name])
+		if err != nil {
 			return err
 		}
 		argValues[idx] = NamedArg{
-			Name:  key,
+			Name:  name,
 			Value: v,
 		}
-		idx++
 	}
 	s.NamedArgs = argValues
 	return nil
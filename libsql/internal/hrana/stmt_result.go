@@ -1,6 +1,10 @@
 package hrana
 
-import "strconv"
+import (
+	"strconv"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
 
 type Column struct {
 	Name *string `json:"name"`
@@ -8,10 +12,14 @@ type Column struct {
 }
 
 type StmtResult struct {
-	Cols             []Column  `json:"cols"`
-	Rows             [][]Value `json:"rows"`
-	AffectedRowCount int32     `json:"affected_row_count"`
-	LastInsertRowId  *string   `json:"last_insert_rowid"`
+	Cols             []Column `json:"cols"`
+	Rows             Rows     `json:"rows"`
+	AffectedRowCount int32    `json:"affected_row_count"`
+	LastInsertRowId  *string  `json:"last_insert_rowid"`
+	ReplicationIndex *string  `json:"replication_index,omitempty"`
+	RowsRead         uint64   `json:"rows_read,omitempty"`
+	RowsWritten      uint64   `json:"rows_written,omitempty"`
+	QueryDurationMs  float64  `json:"query_duration_ms,omitempty"`
 }
 
 func (r *StmtResult) GetLastInsertRowId() int64 {
@@ -22,3 +30,20 @@ func (r *StmtResult) GetLastInsertRowId() int64 {
 	}
 	return 0
 }
+
+// GetReplicationIndex returns the replication index reported by servers
+// speaking Hrana 3, or 0 if the server did not report one.
+func (r *StmtResult) GetReplicationIndex() uint64 {
+	if r.ReplicationIndex != nil {
+		if index, err := strconv.ParseUint(*r.ReplicationIndex, 10, 64); err == nil {
+			return index
+		}
+	}
+	return 0
+}
+
+// GetStats returns the execution statistics reported by the server, zero if
+// it reported none.
+func (r *StmtResult) GetStats() shared.Stats {
+	return shared.Stats{RowsRead: r.RowsRead, RowsWritten: r.RowsWritten, QueryDuration: shared.DurationMs(r.QueryDurationMs)}
+}
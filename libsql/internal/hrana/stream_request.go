@@ -1,6 +1,9 @@
 package hrana
 
 import (
+	"encoding/json"
+	"errors"
+
 	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
 )
 
@@ -10,6 +13,31 @@ type StreamRequest struct {
 	Batch *Batch  `json:"batch,omitempty"`
 	Sql   *string `json:"sql,omitempty"`
 	SqlId *int32  `json:"sql_id,omitempty"`
+	// Raw, if set, is sent instead of the other fields.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (r StreamRequest) MarshalJSON() ([]byte, error) {
+	if r.Raw != nil {
+		return r.Raw, nil
+	}
+	type plain StreamRequest
+	return json.Marshal(plain(r))
+}
+
+// RawStream wraps request, a JSON object with a "type" field, to be sent as it
+// is. The type does not need to be registered.
+func RawStream(request json.RawMessage) (StreamRequest, error) {
+	var r struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(request, &r); err != nil {
+		return StreamRequest{}, err
+	}
+	if r.Type == "" {
+		return StreamRequest{}, errors.New("request has no type")
+	}
+	return StreamRequest{Type: r.Type, Raw: request}, nil
 }
 
 func CloseStream() StreamRequest {
@@ -27,6 +55,11 @@ func ExecuteStream(sql string, params shared.Params, wantRows bool) (*StreamRequ
 	return &StreamRequest{Type: "execute", Stmt: stmt}, nil
 }
 
+// ExecuteSqlStream executes sql without arguments.
+func ExecuteSqlStream(sql string, wantRows bool) StreamRequest {
+	return StreamRequest{Type: "execute", Stmt: &Stmt{Sql: &sql, WantRows: wantRows}}
+}
+
 func ExecuteStoredStream(sqlId int32, params shared.Params, wantRows bool) (*StreamRequest, error) {
 	stmt := &Stmt{
 		SqlId:    &sqlId,
@@ -2,8 +2,9 @@ package hrana
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
 )
 
 type StreamResult struct {
@@ -40,7 +41,7 @@ func (r *StreamResponse) BatchResult() (*BatchResult, error) {
 	}
 	for _, e := range res.StepErrors {
 		if e != nil {
-			return nil, errors.New(e.Message)
+			return nil, e.Err()
 		}
 	}
 	return &res, nil
@@ -50,3 +51,20 @@ type Error struct {
 	Message string  `json:"message"`
 	Code    *string `json:"code,omitempty"`
 }
+
+// Err returns the error as a *shared.SQLiteError, whose message is the message
+// of e alone.
+func (e *Error) Err() error {
+	code := ""
+	if e.Code != nil {
+		code = *e.Code
+	}
+	return shared.NewSQLiteError(code, e.Message)
+}
+
+func (e *Error) Error() string {
+	if e.Code != nil {
+		return fmt.Sprintf("%s: %s", *e.Code, e.Message)
+	}
+	return e.Message
+}
@@ -4,23 +4,30 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type Value struct {
-	Type   string `json:"type"`
+	Type string `json:"type"`
+	// Value is the decimal text of integers as sent on the wire, or the int64
+	// parsed by the decoder of Rows. Only the former may be encoded.
 	Value  any    `json:"value,omitempty"`
 	Base64 string `json:"base64,omitempty"`
 }
 
 func (v Value) ToValue() any {
 	if v.Type == "blob" {
-		bytes, err := base64.StdEncoding.WithPadding(base64.NoPadding).DecodeString(v.Base64)
-		if err != nil {
+		bytes, ok := v.AppendBlob(nil)
+		if !ok {
 			return nil
 		}
 		return bytes
 	} else if v.Type == "integer" {
-		integer, err := strconv.ParseInt(v.Value.(string), 10, 64)
+		if _, ok := v.Value.(int64); ok {
+			return v.Value
+		}
+		text, _ := v.Value.(string)
+		integer, err := strconv.ParseInt(text, 10, 64)
 		if err != nil {
 			return nil
 		}
@@ -29,6 +36,55 @@ func (v Value) ToValue() any {
 	return v.Value
 }
 
+// ToValueBuffer is like ToValue but decodes blobs into *buf, growing it if
+// needed, so that decoding rows one after the other allocates only for the
+// largest blob of a column. The returned bytes are overwritten by the next call
+// with buf.
+func (v Value) ToValueBuffer(buf *[]byte) any {
+	if v.Type != "blob" {
+		return v.ToValue()
+	}
+	bytes, ok := v.AppendBlob((*buf)[:0])
+	if !ok {
+		return nil
+	}
+	*buf = bytes
+	return bytes
+}
+
+// blobChunkLen is the number of base64 characters AppendBlob decodes at once.
+// It is a multiple of 4 so that only the last chunk ends inside a quantum.
+const blobChunkLen = 1024
+
+// AppendBlob appends the bytes of a blob value to dst. The base64 text is
+// decoded through a chunk on the stack rather than converted to a byte slice,
+// so that nothing is allocated when dst is large enough. The result is never
+// nil, which would be NULL, and ok is false if the text is not valid base64.
+func (v Value) AppendBlob(dst []byte) ([]byte, bool) {
+	// Servers send blobs without padding, but accept it rather than turning
+	// the blob into NULL.
+	text := strings.TrimRight(v.Base64, "=")
+	n := base64.RawStdEncoding.DecodedLen(len(text))
+	if dst == nil || cap(dst)-len(dst) < n {
+		grown := make([]byte, len(dst), len(dst)+n)
+		copy(grown, dst)
+		dst = grown
+	}
+	out := dst[len(dst) : len(dst)+n]
+	var chunk [blobChunkLen]byte
+	written := 0
+	for len(text) > 0 {
+		c := copy(chunk[:], text)
+		text = text[c:]
+		m, err := base64.RawStdEncoding.Decode(out[written:], chunk[:c])
+		if err != nil {
+			return dst, false
+		}
+		written += m
+	}
+	return dst[:len(dst)+written], true
+}
+
 func ToValue(v any) (Value, error) {
 	var res Value
 	if v == nil {
@@ -42,7 +98,10 @@ func ToValue(v any) (Value, error) {
 	} else if text, ok := v.(string); ok {
 		res.Type = "text"
 		res.Value = text
-	} else if blob, ok := v.([]byte); ok {
+	} else if blob, ok := v.([]byte); ok && blob == nil {
+		// A nil slice is NULL, as in SQLite drivers, unlike an empty one.
+		res.Type = "null"
+	} else if ok {
 		res.Type = "blob"
 		res.Base64 = base64.StdEncoding.WithPadding(base64.NoPadding).EncodeToString(blob)
 	} else if float, ok := v.(float64); ok {
@@ -1,6 +1,8 @@
 package hrana
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"reflect"
 	"strconv"
@@ -45,6 +47,21 @@ func TestValueToValue(t *testing.T) {
 			},
 			want: []byte("bar"),
 		},
+		{
+			name: "paddedBytes",
+			value: Value{
+				Type:   "blob",
+				Base64: "YmE=",
+			},
+			want: []byte("ba"),
+		},
+		{
+			name: "emptyBytes",
+			value: Value{
+				Type: "blob",
+			},
+			want: []byte{},
+		},
 		{
 			name: "float",
 			value: Value{
@@ -63,6 +80,46 @@ func TestValueToValue(t *testing.T) {
 		})
 	}
 }
+func TestAppendBlob(t *testing.T) {
+	long := bytes.Repeat([]byte("0123456789"), 200)
+	tests := []struct {
+		name   string
+		base64 string
+		want   []byte
+		wantOk bool
+	}{
+		{name: "empty", base64: "", want: []byte{}, wantOk: true},
+		{name: "unpadded", base64: "YmE", want: []byte("ba"), wantOk: true},
+		{name: "padded", base64: "YmE=", want: []byte("ba"), wantOk: true},
+		{name: "several chunks", base64: base64.RawStdEncoding.EncodeToString(long), want: long, wantOk: true},
+		{name: "invalid", base64: "Y!E", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Value{Type: "blob", Base64: tt.base64}.AppendBlob(nil)
+			if ok != tt.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOk)
+			}
+			if ok && (got == nil || !bytes.Equal(got, tt.want)) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToValueBufferAllocs(t *testing.T) {
+	v := Value{Type: "blob", Base64: base64.RawStdEncoding.EncodeToString(bytes.Repeat([]byte("blob"), 1000))}
+	var buf []byte
+	v.ToValueBuffer(&buf)
+	allocs := testing.AllocsPerRun(100, func() {
+		v.ToValueBuffer(&buf)
+	})
+	// Only boxing the slice into the returned interface allocates.
+	if allocs > 1 {
+		t.Errorf("got %v allocations, want at most 1", allocs)
+	}
+}
+
 func TestToValue(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -101,6 +158,13 @@ func TestToValue(t *testing.T) {
 				Base64: "YmFy",
 			},
 		},
+		{
+			name:  "nilBytes",
+			value: []byte(nil),
+			want: Value{
+				Type: "null",
+			},
+		},
 		{
 			name:  "float",
 			value: 3.14,
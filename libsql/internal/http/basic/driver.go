@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql/driver"
 	"fmt"
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
 	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
 )
 
@@ -23,6 +25,11 @@ func (r *httpResultsRowsProvider) Columns(setIdx int) []string {
 	return r.results[setIdx].Results.Columns
 }
 
+func (r *httpResultsRowsProvider) DeclTypes(setIdx int) []string {
+	// The legacy HTTP API does not report declared column types.
+	return nil
+}
+
 func (r *httpResultsRowsProvider) FieldValue(setIdx, rowIdx, columnIdx int) driver.Value {
 	return r.results[setIdx].Results.Rows[rowIdx][columnIdx]
 }
@@ -39,12 +46,13 @@ func (r *httpResultsRowsProvider) HasResult(setIdx int) bool {
 }
 
 type conn struct {
-	url string
-	jwt string
+	url   string
+	token *auth.Token
+	cfg   config.Config
 }
 
-func Connect(url, jwt string) driver.Conn {
-	return &conn{url, jwt}
+func Connect(url string, token *auth.Token, cfg *config.Config) driver.Conn {
+	return &conn{url, token, *cfg}
 }
 
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
@@ -59,13 +67,20 @@ func (c *conn) Begin() (driver.Tx, error) {
 	return nil, fmt.Errorf("begin method not implemented")
 }
 
-func execute(ctx context.Context, url, jwt, query string, args []driver.NamedValue) ([]httpResults, error) {
+func (c *conn) execute(ctx context.Context, query string, args []driver.NamedValue) ([]httpResults, error) {
+	ctx, cancel := c.cfg.RequestContext(ctx, 0)
+	defer cancel()
 	stmts, params, err := shared.ParseStatementAndArgs(query, args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
 	}
+	if len(stmts) > 0 && shared.ReadsSessionState(stmts[0]) {
+		// Every request runs on a new connection of the server, only the
+		// statements of the same query are seen.
+		return nil, fmt.Errorf("failed to execute SQL: %s\nthe legacy HTTP API does not keep the session state read by this statement across requests, run it in the same query as the statements it depends on", query)
+	}
 
-	rs, err := callSqld(ctx, url, jwt, stmts, params)
+	rs, err := callSqld(ctx, c.url, c.token, stmts, params, &c.cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
 	}
@@ -73,7 +88,7 @@ func execute(ctx context.Context, url, jwt, query string, args []driver.NamedVal
 }
 
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	rs, err := execute(ctx, c.url, c.jwt, query, args)
+	rs, err := c.execute(ctx, query, args)
 	if err != nil {
 		return nil, err
 	}
@@ -86,12 +101,12 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 }
 
 func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	rs, err := execute(ctx, c.url, c.jwt, query, args)
+	rs, err := c.execute(ctx, query, args)
 	if err != nil {
 		return nil, err
 	}
 
-	return shared.NewRows(&httpResultsRowsProvider{rs}), nil
+	return shared.NewLimitedRows(&httpResultsRowsProvider{rs}, c.cfg.MaxRows)
 }
 
 func assertNoResultWithError(resultSets []httpResults, query string) error {
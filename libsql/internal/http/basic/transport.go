@@ -9,7 +9,12 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/debug"
 	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+	"github.com/libsql/libsql-client-go/libsql/internal/limits"
+	"github.com/libsql/libsql-client-go/libsql/internal/retry"
 )
 
 var httpClient = &http.Client{Timeout: 120 * time.Second}
@@ -39,44 +44,79 @@ type httpResults struct {
 
 type Row []interface{}
 
-func callSqld(ctx context.Context, url string, jwt string, stmts []string, parameters []shared.Params) ([]httpResults, error) {
-	rawReq, err := generatePostBody(stmts, parameters)
-	if err != nil {
+// post sends reqBody to url and returns the body of the response. Requests
+// answered with HTTP 429 are sent again up to cfg.RateLimitRetries times.
+func post(ctx context.Context, url string, token *auth.Token, reqBody []byte, cfg *config.Config) ([]byte, error) {
+	log := cfg.Log()
+	for attempt := 0; ; attempt++ {
+		if err := cfg.Breaker.Allow(); err != nil {
+			return nil, err
+		}
+		log.DebugContext(ctx, "sending request", "url", debug.URL(url), "bytes", len(reqBody), "attempt", attempt)
+		sent := time.Now()
+		resp, err := token.Do(ctx, cfg.Client(httpClient), func() (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+			if err != nil {
+				return nil, err
+			}
+			cfg.SetHeaders(req)
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		})
+		cfg.Breaker.Record(err)
+		if err != nil {
+			log.DebugContext(ctx, "request failed", "url", debug.URL(url), "duration", time.Since(sent), "error", err)
+			if retry.Unsent(err) {
+				return nil, retry.BadConn(err)
+			}
+			return nil, err
+		}
+		log.DebugContext(ctx, "received response", "url", debug.URL(url), "status", resp.StatusCode, "duration", time.Since(sent))
+		body, err := io.ReadAll(limits.Reader(resp.Body, cfg.MaxResponseBytes))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return body, nil
+		}
+		var errResponse struct {
+			Message string `json:"error"`
+		}
+		message := string(body)
+		if json.Unmarshal(body, &errResponse) == nil {
+			message = errResponse.Message
+		}
+		err = shared.NewHTTPError(resp.StatusCode, resp.Header, body, "", message, nil)
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			err = retry.NewRateLimitError(resp.Header, err)
+			if attempt < cfg.RateLimitRetries && retry.WaitRateLimit(ctx, err, attempt) {
+				log.InfoContext(ctx, "retrying rate limited request", "url", debug.URL(url), "attempt", attempt+1)
+				continue
+			}
+		case retry.Status(resp.StatusCode):
+			err = retry.Mark(err)
+		}
 		return nil, err
 	}
+}
 
-	reqBody, err := json.Marshal(rawReq)
+func callSqld(ctx context.Context, url string, token *auth.Token, stmts []string, parameters []shared.Params, cfg *config.Config) ([]httpResults, error) {
+	rawReq, err := generatePostBody(stmts, parameters)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+	reqBody, err := json.Marshal(rawReq)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	if len(jwt) > 0 {
-		req.Header.Set("Authorization", "Bearer "+jwt)
-	}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := post(ctx, url, token, reqBody, cfg)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		var errResponse struct {
-			Message string `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errResponse); err != nil {
-			return nil, err
-		}
-		return nil, errors.New(errResponse.Message)
-	}
 
 	var results []httpResults
 
@@ -1,14 +1,24 @@
 package http
 
 import (
+	"context"
 	"database/sql/driver"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
 	"github.com/libsql/libsql-client-go/libsql/internal/http/basic"
 	"github.com/libsql/libsql-client-go/libsql/internal/http/hranaV2"
 )
 
-func Connect(url, jwt string) driver.Conn {
-	if hranaV2.IsSupported(url, jwt) {
-		return hranaV2.Connect(url, jwt)
+// Connect uses the newest version of Hrana over HTTP supported by the server
+// and falls back to the legacy JSON API of sqld otherwise.
+func Connect(ctx context.Context, url string, token *auth.Token, cfg *config.Config) (driver.Conn, error) {
+	if version := hranaV2.ProtocolVersion(ctx, url, token, cfg); version > 0 {
+		return hranaV2.Connect(url, token, version, cfg), nil
+	}
+	// A canceled probe must not be mistaken for a server without Hrana.
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	return basic.Connect(url, jwt)
+	return basic.Connect(url, token, cfg), nil
 }
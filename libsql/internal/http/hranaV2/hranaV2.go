@@ -3,29 +3,61 @@ package hranaV2
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/background"
+	"github.com/libsql/libsql-client-go/libsql/internal/clock"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+	"github.com/libsql/libsql-client-go/libsql/internal/debug"
 	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
 	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+	"github.com/libsql/libsql-client-go/libsql/internal/jsonbuf"
+	"github.com/libsql/libsql-client-go/libsql/internal/limits"
+	"github.com/libsql/libsql-client-go/libsql/internal/retry"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
-func IsSupported(url, jwt string) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, "GET", url+"/v2", nil)
-	if err != nil {
-		return false
+// ProtocolVersion returns the newest version of Hrana over HTTP supported by
+// the server at url, or 0 if the server does not support Hrana over HTTP.
+func ProtocolVersion(ctx context.Context, url string, token *auth.Token, cfg *config.Config) int {
+	for _, version := range []int{3, 2} {
+		if isVersionSupported(ctx, url, token, cfg, version) {
+			return version
+		}
 	}
-	if len(jwt) > 0 {
-		req.Header.Set("Authorization", "Bearer "+jwt)
+	return 0
+}
+
+// SupportedVersions returns the versions of Hrana over HTTP supported by the
+// server at url, newest first.
+func SupportedVersions(ctx context.Context, url string, token *auth.Token, cfg *config.Config) []int {
+	var versions []int
+	for _, version := range []int{3, 2} {
+		if isVersionSupported(ctx, url, token, cfg, version) {
+			versions = append(versions, version)
+		}
 	}
-	resp, err := http.DefaultClient.Do(req)
+	return versions
+}
+
+func isVersionSupported(ctx context.Context, url string, token *auth.Token, cfg *config.Config, version int) bool {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := token.Do(ctx, cfg.Client(http.DefaultClient), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/v%d", url, version), nil)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SetHeaders(req)
+		return req, nil
+	})
 	if err != nil {
 		return false
 	}
@@ -33,25 +65,44 @@ func IsSupported(url, jwt string) bool {
 	return resp.StatusCode == http.StatusOK
 }
 
-func Connect(url, jwt string) driver.Conn {
-	return &hranaV2Conn{url, jwt, "", 0, false}
+// Connect returns a connection speaking the given version of Hrana over HTTP.
+// Both versions share the pipeline format, version 3 only adds request types.
+func Connect(url string, token *auth.Token, version int, cfg *config.Config) driver.Conn {
+	return &hranaV2Conn{
+		url:        url,
+		token:      token,
+		version:    version,
+		streamRows: cfg.StreamRows,
+		clock:      cfg.Clock,
+		stmtCache:  newStmtCache(cfg.StatementCacheSize),
+		cfg:        *cfg,
+	}
 }
 
+// hranaV2Stmt is a prepared statement stored on the server with store_sql, so
+// its SQL text is sent once and executions only carry its id. It is stored
+// along with its first execution and closed along with the next request of the
+// connection, which saves the round trips of both.
 type hranaV2Stmt struct {
-	conn     *hranaV2Conn
-	numInput int
-	sqlId    int32
+	conn   *hranaV2Conn
+	query  string
+	params shared.ParamsInfo
+	sqlId  int32
+	stored bool
 }
 
 func (s *hranaV2Stmt) Close() error {
-	var req hrana.PipelineRequest
-	req.Add(hrana.CloseStoredSqlStream(s.sqlId))
-	_, err := s.conn.sendPipelineRequest(context.Background(), &req)
-	return err
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	if s.stored {
+		s.conn.closedSqlIds = append(s.conn.closedSqlIds, s.sqlId)
+		s.stored = false
+	}
+	return nil
 }
 
 func (s *hranaV2Stmt) NumInput() int {
-	return s.numInput
+	return s.params.NumInput()
 }
 
 func convertToNamed(args []driver.Value) []driver.NamedValue {
@@ -74,67 +125,123 @@ func (s *hranaV2Stmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 func (s *hranaV2Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	msg := hrana.PipelineRequest{}
-	params, err := shared.ConvertArgs(args)
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	res, err := s.execute(ctx, args, false)
 	if err != nil {
 		return nil, err
 	}
-	executeStream, err := hrana.ExecuteStoredStream(s.sqlId, params, false)
+	return shared.NewReplicatedResult(res.GetLastInsertRowId(), int64(res.AffectedRowCount), res.GetReplicationIndex(), res.GetStats()), nil
+}
+
+func (s *hranaV2Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	if s.conn.streamRows {
+		msg, err := s.request(args, true)
+		if err != nil {
+			return nil, err
+		}
+		return s.conn.queryStreaming(ctx, msg, func(results []hrana.StreamResult) error {
+			if !s.stored {
+				if results[0].Error != nil {
+					return results[0].Error.Err()
+				}
+				s.stored = true
+			}
+			return nil
+		})
+	}
+	res, err := s.execute(ctx, args, true)
 	if err != nil {
 		return nil, err
 	}
-	msg.Add(*executeStream)
-	result, err := s.conn.sendPipelineRequest(ctx, &msg)
+	return shared.NewLimitedRows(&StmtResultRowsProvider{res}, s.conn.cfg.MaxRows)
+}
+
+// request returns the pipeline request executing the statement by its id,
+// preceded by the request storing it if it is not stored yet.
+func (s *hranaV2Stmt) request(args []driver.NamedValue, wantRows bool) (*hrana.PipelineRequest, error) {
+	params, err := shared.ConvertArgs(args)
 	if err != nil {
 		return nil, err
 	}
-	if result.Results[0].Error != nil {
-		return nil, errors.New(result.Results[0].Error.Message)
+	if err := shared.CheckArgs([]shared.ParamsInfo{s.params}, args); err != nil {
+		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", s.query, err)
 	}
-	if result.Results[0].Response == nil {
-		return nil, errors.New("no response received")
-	}
-	res, err := result.Results[0].Response.ExecuteResult()
+	executeStream, err := hrana.ExecuteStoredStream(s.sqlId, params, wantRows)
 	if err != nil {
 		return nil, err
 	}
-	return shared.NewResult(res.GetLastInsertRowId(), int64(res.AffectedRowCount)), nil
+	msg := &hrana.PipelineRequest{}
+	if !s.stored {
+		msg.Add(hrana.StoreSqlStream(s.query, s.sqlId))
+	}
+	msg.Add(*executeStream)
+	return msg, nil
 }
 
-func (s *hranaV2Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	msg := hrana.PipelineRequest{}
-	params, err := shared.ConvertArgs(args)
+// execute runs the statement by its id, storing it first if this is its first
+// execution.
+func (s *hranaV2Stmt) execute(ctx context.Context, args []driver.NamedValue, wantRows bool) (*hrana.StmtResult, error) {
+	msg, err := s.request(args, wantRows)
 	if err != nil {
 		return nil, err
 	}
-	executeStream, err := hrana.ExecuteStoredStream(s.sqlId, params, true)
+	result, err := s.conn.sendPipelineRequest(ctx, msg)
 	if err != nil {
 		return nil, err
 	}
-	msg.Add(*executeStream)
-	result, err := s.conn.sendPipelineRequest(ctx, &msg)
-	if err != nil {
-		return nil, err
+	if len(result.Results) != len(msg.Requests) {
+		return nil, errors.New("no response received")
 	}
-	if result.Results[0].Error != nil {
-		return nil, errors.New(result.Results[0].Error.Message)
+	if !s.stored {
+		if result.Results[0].Error != nil {
+			return nil, result.Results[0].Error.Err()
+		}
+		s.stored = true
 	}
-	if result.Results[0].Response == nil {
-		return nil, errors.New("no response received")
+	executeResult := result.Results[len(result.Results)-1]
+	if executeResult.Error != nil {
+		return nil, executeResult.Error.Err()
 	}
-	res, err := result.Results[0].Response.ExecuteResult()
-	if err != nil {
-		return nil, err
+	if executeResult.Response == nil {
+		return nil, errors.New("no response received")
 	}
-	return shared.NewRows(&StmtResultRowsProvider{res}), nil
+	return executeResult.Response.ExecuteResult()
 }
 
+// hranaV2Conn is safe for concurrent use. Its requests are sent one at a time
+// because each one must carry the baton returned by the previous one.
 type hranaV2Conn struct {
+	// mu guards the fields below and is held for the whole round trip of a
+	// request.
+	mu           sync.Mutex
 	url          string
-	jwt          string
+	token        *auth.Token
+	version      int
 	baton        string
 	nextSqlId    int32
 	streamClosed bool
+	inTx         bool
+	// pendingBegin is the BEGIN statement of the open transaction until it is
+	// sent along with the first statement of the transaction.
+	pendingBegin string
+	streamRows   bool
+	clock        *clock.Estimator
+	// streamExpired is set when the server expired the stream inside a
+	// transaction, which lets the next requests of the transaction fail with
+	// shared.ErrStreamExpired too.
+	streamExpired bool
+	// stmtCache is nil when the statement cache is disabled.
+	stmtCache *stmtCache
+	// closedSqlIds are the ids of closed prepared statements, closed on the
+	// server with the next request.
+	closedSqlIds []int32
+	// cfg holds the timeouts of the connection.
+	cfg config.Config
+	// lastUsed is when the stream last answered a request.
+	lastUsed time.Time
 }
 
 func (h *hranaV2Conn) Prepare(query string) (driver.Stmt, error) {
@@ -149,21 +256,30 @@ func (h *hranaV2Conn) PrepareContext(ctx context.Context, query string) (driver.
 	if len(stmts) != 1 {
 		return nil, fmt.Errorf("only one statement is supported got %d", len(stmts))
 	}
-	numInput := -1
-	if len(paramInfos[0].NamedParameters) == 0 {
-		numInput = paramInfos[0].PositionalParametersCount
-	}
-	var req hrana.PipelineRequest
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	sqlId := h.nextSqlId
-	req.Add(hrana.StoreSqlStream(query, sqlId))
 	h.nextSqlId++
+	return &hranaV2Stmt{conn: h, query: query, params: paramInfos[0], sqlId: sqlId}, nil
+}
 
-	_, err = h.sendPipelineRequest(ctx, &req)
-	if err != nil {
-		return nil, err
-	}
+// PinState does nothing: a connection never reopens its stream, once the stream
+// is closed every request fails with driver.ErrBadConn.
+func (h *hranaV2Conn) PinState(pinned bool) {}
 
-	return &hranaV2Stmt{h, numInput, sqlId}, nil
+// ResetSession discards the connection once its stream is closed or was idle
+// for longer than the stream idle timeout, the server may have expired it
+// since.
+func (h *hranaV2Conn) ResetSession(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streamClosed {
+		return driver.ErrBadConn
+	}
+	if h.cfg.StreamIdleTimeout > 0 && !h.lastUsed.IsZero() && time.Since(h.lastUsed) > h.cfg.StreamIdleTimeout {
+		return driver.ErrBadConn
+	}
+	return nil
 }
 
 func (h *hranaV2Conn) Close() error {
@@ -176,120 +292,499 @@ func (h *hranaV2Conn) Begin() (driver.Tx, error) {
 
 type hranaV2Tx struct {
 	conn *hranaV2Conn
+	// header holds the headers of the context of BeginTx, which are sent with
+	// the end of the transaction too.
+	header http.Header
 }
 
 func (h hranaV2Tx) Commit() error {
-	_, err := h.conn.ExecContext(context.Background(), "COMMIT", nil)
-	return err
+	return h.conn.endTx("COMMIT", h.header)
 }
 
 func (h hranaV2Tx) Rollback() error {
-	_, err := h.conn.ExecContext(context.Background(), "ROLLBACK", nil)
+	return h.conn.endTx("ROLLBACK", h.header)
+}
+
+func (h *hranaV2Conn) endTx(query string, header http.Header) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// The transaction ends even if the request fails, but an expired stream is
+	// still reported as the end of the transaction.
+	defer func() { h.inTx = false }()
+	if h.pendingBegin != "" {
+		// The transaction ran no statement, the server never saw it.
+		h.pendingBegin = ""
+		return nil
+	}
+	_, err := h.executeStmt(ctxopt.WithHeaders(context.Background(), header), query, nil, false)
 	return err
 }
 
+// BeginTx does not send a deferred BEGIN at once: it is sent in the same
+// request as the first statement of the transaction, which saves a round trip.
+// A deferred transaction takes no lock before its first statement anyway. The
+// BEGIN of immediate and exclusive transactions is sent at once, to take the
+// lock and report failing to.
 func (h *hranaV2Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	if opts.ReadOnly {
-		return nil, fmt.Errorf("read only transactions are not supported")
-	}
-	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
-		return nil, fmt.Errorf("isolation level %d is not supported", opts.Isolation)
-	}
-	_, err := h.ExecContext(ctx, "BEGIN", nil)
+	begin, err := shared.BeginStatement(opts, ctxopt.TxMode(ctx))
 	if err != nil {
 		return nil, err
 	}
-	return &hranaV2Tx{h}, nil
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if begin == "BEGIN" || begin == "BEGIN DEFERRED" {
+		if err := h.streamError(); err != nil {
+			return nil, err
+		}
+		h.pendingBegin = begin
+	} else if _, err := h.executeStmt(ctx, begin, nil, false); err != nil {
+		return nil, err
+	}
+	h.inTx = true
+	return &hranaV2Tx{h, ctxopt.Headers(ctx)}, nil
 }
 
-func (h *hranaV2Conn) sendPipelineRequest(ctx context.Context, msg *hrana.PipelineRequest) (*hrana.PipelineResponse, error) {
-	if h.streamClosed {
-		// If the stream is closed, we can't send any more requests using this connection.
-		return nil, fmt.Errorf("stream is closed: %w", driver.ErrBadConn)
+// flushBegin sends the pending BEGIN of the transaction on its own, before
+// requests whose response is not read as a whole.
+func (h *hranaV2Conn) flushBegin(ctx context.Context) error {
+	if h.pendingBegin == "" {
+		return nil
 	}
-	if h.baton != "" {
-		msg.Baton = h.baton
+	_, err := h.sendPipelineRequest(ctx, &hrana.PipelineRequest{})
+	return err
+}
+
+// sendPipelineRequest sends msg, preceded by the pending BEGIN of the
+// transaction if any.
+func (h *hranaV2Conn) sendPipelineRequest(ctx context.Context, msg *hrana.PipelineRequest) (*hrana.PipelineResponse, error) {
+	begin := h.pendingBegin
+	if begin == "" {
+		return h.sendPipelineMessage(ctx, msg)
 	}
-	reqBody, err := json.Marshal(msg)
+	sent := *msg
+	sent.Requests = append([]hrana.StreamRequest{hrana.ExecuteSqlStream(begin, false)}, msg.Requests...)
+	result, err := h.sendPipelineMessage(ctx, &sent)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", begin, err)
 	}
-	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
-	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, "POST", h.url+"/v2/pipeline", bytes.NewReader(reqBody))
-	if err != nil {
+	if len(result.Results) == 0 {
+		return nil, fmt.Errorf("failed to execute SQL: %s\n%s", begin, "no response received")
+	}
+	if result.Results[0].Error != nil {
+		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", begin, result.Results[0].Error.Err())
+	}
+	h.pendingBegin = ""
+	result.Results = result.Results[1:]
+	return result, nil
+}
+
+// sendPipelineMessage sends msg as it is, along with the requests closing the
+// statements closed since the last request.
+func (h *hranaV2Conn) sendPipelineMessage(ctx context.Context, msg *hrana.PipelineRequest) (*hrana.PipelineResponse, error) {
+	// The statements closed since the last request are closed after the
+	// requests of msg, whose results keep their indexes.
+	count, closed := len(msg.Requests), h.closedSqlIds
+	if len(closed) > 0 {
+		sent := *msg
+		sent.Requests = append(msg.Requests[:count:count], make([]hrana.StreamRequest, 0, len(closed))...)
+		for _, sqlId := range closed {
+			sent.Add(hrana.CloseStoredSqlStream(sqlId))
+		}
+		msg = &sent
+	}
+	var result hrana.PipelineResponse
+	if err := h.sendPipeline(ctx, msg, &result); err != nil {
 		return nil, err
 	}
-	if len(h.jwt) > 0 {
-		req.Header.Set("Authorization", "Bearer "+h.jwt)
+	h.updateStream(result.Baton, result.BaseUrl)
+	if len(closed) > 0 {
+		h.closedSqlIds = nil
+		if len(result.Results) > count {
+			result.Results = result.Results[:count]
+		}
 	}
-	resp, err := http.DefaultClient.Do(req)
+	return &result, nil
+}
+
+// sendPipeline sends msg and decodes the response into result.
+func (h *hranaV2Conn) sendPipeline(ctx context.Context, msg *hrana.PipelineRequest, result any) error {
+	resp, cancel, err := h.doPipelineRequest(ctx, msg)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer cancel()
 	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := jsonbuf.Read(limits.Reader(resp.Body, h.cfg.MaxResponseBytes))
 	if err != nil {
+		return err
+	}
+	defer body.Release()
+	return json.Unmarshal(body.Bytes(), result)
+}
+
+// Pipeline sends msg on the stream of the connection. It lets packages built on
+// top of the transport issue arbitrary Hrana requests.
+func (h *hranaV2Conn) Pipeline(ctx context.Context, msg *hrana.PipelineRequest) (*hrana.PipelineResponse, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sendPipelineRequest(ctx, msg)
+}
+
+// RawPipeline is like Pipeline but leaves the responses undecoded, so request
+// types the transport does not know about can be used. Registered types are
+// checked against the Hrana version of the connection before sending.
+func (h *hranaV2Conn) RawPipeline(ctx context.Context, msg *hrana.PipelineRequest) (*hrana.RawPipelineResponse, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, request := range msg.Requests {
+		if t, ok := hrana.LookupRequestType(request.Type); ok && t.MinVersion > h.version {
+			return nil, fmt.Errorf("request type %s needs Hrana %d, the server speaks Hrana %d", request.Type, t.MinVersion, h.version)
+		}
+	}
+	if err := h.flushBegin(ctx); err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
+	var result hrana.RawPipelineResponse
+	if err := h.sendPipeline(ctx, msg, &result); err != nil {
+		return nil, err
+	}
+	h.updateStream(result.Baton, result.BaseUrl)
+	return &result, nil
+}
+
+// doPipelineRequest sends msg and returns the response once its status is
+// known to be successful. The caller must close the body and call cancel once
+// it is done reading it.
+func (h *hranaV2Conn) doPipelineRequest(ctx context.Context, msg *hrana.PipelineRequest) (*http.Response, context.CancelFunc, error) {
+	if err := h.streamError(); err != nil {
+		return nil, nil, err
+	}
+	if h.baton != "" {
+		msg.Baton = h.baton
+	}
+	reqBody, err := jsonbuf.Encode(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reqBody.Release()
+	compressed := false
+	if threshold := h.cfg.RequestCompressionThreshold; threshold > 0 && reqBody.Len() >= threshold {
+		gzipped, err := reqBody.Gzip()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gzipped.Release()
+		reqBody, compressed = gzipped, true
+	}
+	ctx, cancel := h.cfg.RequestContext(ctx, 60*time.Second)
+	log := h.cfg.Log()
+	for attempt := 0; ; attempt++ {
+		if err := h.cfg.Breaker.Allow(); err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		log.DebugContext(ctx, "sending pipeline request", "url", debug.URL(h.url), "requests", len(msg.Requests), "bytes", reqBody.Len(), "attempt", attempt)
+		sent := time.Now()
+		resp, err := h.token.Do(ctx, h.cfg.Client(http.DefaultClient), func() (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v%d/pipeline", h.url, h.version), reqBody.Body())
+			if err != nil {
+				return nil, err
+			}
+			h.cfg.SetHeaders(req)
+			req.ContentLength = int64(reqBody.Len())
+			req.GetBody = func() (io.ReadCloser, error) { return reqBody.Body(), nil }
+			if compressed {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			if h.cfg.DisableResponseCompression {
+				// net/http asks for gzip and decompresses responses unless the
+				// request sets its own Accept-Encoding.
+				req.Header.Set("Accept-Encoding", "identity")
+			}
+			return req, nil
+		})
+		h.clock.Observe(sent, resp)
+		h.cfg.Breaker.Record(err)
+		if err != nil {
+			log.DebugContext(ctx, "pipeline request failed", "url", debug.URL(h.url), "duration", time.Since(sent), "error", err)
+			if ctx.Err() != nil {
+				// The server may still be running the request, the state of the
+				// stream is unknown from now on.
+				h.abandonStream(ctxopt.Headers(ctx))
+			}
+			cancel()
+			if retry.Unsent(err) {
+				return nil, nil, retry.BadConn(err)
+			}
+			return nil, nil, err
+		}
+		log.DebugContext(ctx, "received pipeline response", "url", debug.URL(h.url), "status", resp.StatusCode, "duration", time.Since(sent))
+		if resp.StatusCode == http.StatusOK {
+			return resp, cancel, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil {
+			err = statusError(resp.StatusCode, resp.Header, body, h.inTx)
+			h.streamExpired = errors.Is(err, shared.ErrStreamExpired)
+			// Rate limited requests were not run, the stream can go on.
+			if attempt < h.cfg.RateLimitRetries && retry.WaitRateLimit(ctx, err, attempt) {
+				log.InfoContext(ctx, "retrying rate limited request", "url", debug.URL(h.url), "attempt", attempt+1)
+				continue
+			}
+		}
+		cancel()
 		// We need to remember that the stream is closed so we don't try to send any more requests using this connection.
 		h.streamClosed = true
-		var errResponse hrana.Error
-		if err := json.Unmarshal(body, &errResponse); err == nil {
-			if errResponse.Code != nil {
-				if *errResponse.Code == "STREAM_EXPIRED" {
-					return nil, fmt.Errorf("error code %s: %s\n%w", *errResponse.Code, errResponse.Message, driver.ErrBadConn)
-				} else {
-					return nil, fmt.Errorf("error code %s: %s", *errResponse.Code, errResponse.Message)
-				}
+		return nil, nil, err
+	}
+}
+
+// streamError returns the error of requests made once the stream is closed,
+// nil while it is open.
+func (h *hranaV2Conn) streamError() error {
+	if !h.streamClosed {
+		return nil
+	}
+	// If the stream is closed, we can't send any more requests using this connection.
+	if h.streamExpired && h.inTx {
+		return retry.Mark(fmt.Errorf("stream is closed: %w", shared.ErrStreamExpired))
+	}
+	return fmt.Errorf("stream is closed: %w", driver.ErrBadConn)
+}
+
+// statusError returns the *shared.HTTPError of a response with an
+// unsuccessful status. Expired streams wrap driver.ErrBadConn, since the
+// request was not executed, or shared.ErrStreamExpired inside a transaction,
+// which database/sql cannot retry on another connection. Rate limited requests
+// are wrapped in a *retry.RateLimitError and statuses of overloaded or failing
+// servers are marked retryable.
+func statusError(status int, header http.Header, body []byte, inTx bool) error {
+	var code, message string
+	var cause error
+	var errResponse hrana.Error
+	if json.Unmarshal(body, &errResponse) == nil {
+		message = errResponse.Message
+		if errResponse.Code != nil {
+			code = *errResponse.Code
+		}
+		if code == "STREAM_EXPIRED" {
+			cause = driver.ErrBadConn
+			if inTx {
+				cause = shared.ErrStreamExpired
 			}
-			return nil, errors.New(errResponse.Message)
 		}
-		return nil, errors.New(string(body))
+	} else {
+		message = string(body)
 	}
-	var result hrana.PipelineResponse
-	if err = json.Unmarshal(body, &result); err != nil {
-		return nil, err
+	var err error = shared.NewHTTPError(status, header, body, code, message, cause)
+	if cause == driver.ErrBadConn {
+		return err
+	}
+	if cause != nil {
+		// The whole transaction can be run again.
+		return retry.Mark(err)
 	}
-	h.baton = result.Baton
-	if result.Baton == "" {
+	if status == http.StatusTooManyRequests {
+		return retry.NewRateLimitError(header, err)
+	}
+	if retry.Status(status) {
+		return retry.Mark(err)
+	}
+	return err
+}
+
+// abandonStream closes the stream after a request was canceled. The close
+// request asks the server to drop the stream, which interrupts the statement
+// it runs, and is sent in the background so that the caller returns at once.
+// It carries the headers of the canceled request, which proxies may route on.
+func (h *hranaV2Conn) abandonStream(header http.Header) {
+	h.streamClosed = true
+	if h.baton == "" {
+		// The stream was opened by the canceled request, the server closes
+		// it once it expires.
+		return
+	}
+	msg := &hrana.PipelineRequest{Baton: h.baton}
+	msg.Add(hrana.CloseStream())
+	reqBody, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	url, token, cfg := fmt.Sprintf("%s/v%d/pipeline", h.url, h.version), h.token, h.cfg
+	background.Go(func() {
+		ctx, cancel := context.WithTimeout(ctxopt.WithHeaders(context.Background(), header), 5*time.Second)
+		defer cancel()
+		resp, err := token.Do(ctx, cfg.Client(http.DefaultClient), func() (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+			if err != nil {
+				return nil, err
+			}
+			cfg.SetHeaders(req)
+			return req, nil
+		})
+		if err != nil {
+			cfg.Log().Warn("failed to close abandoned stream", "url", debug.URL(url), "error", err)
+			return
+		}
+		resp.Body.Close()
+	})
+}
+
+func (h *hranaV2Conn) updateStream(baton, baseUrl string) {
+	h.baton = baton
+	h.lastUsed = time.Now()
+	if baton == "" {
 		// We need to remember that the stream is closed so we don't try to send any more requests using this connection.
 		h.streamClosed = true
 	}
-	if result.BaseUrl != "" {
-		h.url = result.BaseUrl
+	if baseUrl != "" {
+		h.url = baseUrl
 	}
-	return &result, nil
 }
 
 func (h *hranaV2Conn) executeStmt(ctx context.Context, query string, args []driver.NamedValue, wantRows bool) (*hrana.PipelineResponse, error) {
-	stmts, params, err := shared.ParseStatementAndArgs(query, args)
+	msg := &hrana.PipelineRequest{}
+	if sql, ok := shared.SingleStatement(query); ok && len(args) == 0 {
+		// Statements without arguments, like BEGIN and COMMIT, need neither
+		// splitting nor parameter matching, so the parser is skipped. Their
+		// placeholders are still checked, like over websockets.
+		if err := shared.CheckQueryArgs(query, nil); err != nil {
+			return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+		}
+		msg.Add(hrana.ExecuteSqlStream(sql, wantRows))
+		return h.sendExecute(ctx, query, msg)
+	}
+	stmts, params, cached, err := h.parse(query, args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
 	}
-	msg := &hrana.PipelineRequest{}
 	if len(stmts) == 1 {
-		executeStream, err := hrana.ExecuteStream(stmts[0], params[0], wantRows)
+		var executeStream *hrana.StreamRequest
+		if cached != nil && cached.stored {
+			executeStream, err = hrana.ExecuteStoredStream(cached.sqlId, params[0], wantRows)
+		} else {
+			executeStream, err = hrana.ExecuteStream(stmts[0], params[0], wantRows)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
 		}
 		msg.Add(*executeStream)
+		if cached != nil {
+			// The cache requests follow the execute request so that its
+			// result stays the first one.
+			storeIdx := h.addCacheRequests(msg, cached)
+			result, err := h.sendExecute(ctx, query, msg)
+			if storeIdx >= 0 {
+				if err == nil && storeIdx < len(result.Results) && result.Results[storeIdx].Error == nil {
+					cached.stored = true
+				} else {
+					// The statement may have been stored anyway, close it
+					// with the next request so that it does not leak.
+					h.stmtCache.evicted = append(h.stmtCache.evicted, cached.sqlId)
+				}
+			}
+			return result, err
+		}
 	} else {
 		batchStream, err := hrana.BatchStream(stmts, params, wantRows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
 		}
+		batches, err := batchStream.Batch.Split(h.cfg.RequestBytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+		}
+		if len(batches) > 1 {
+			result, err := h.executeSplitBatch(ctx, batches, ctxopt.AtomicBatch(ctx) && !h.inTx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+			}
+			return result, nil
+		}
 		msg.Add(*batchStream)
 	}
+	return h.sendExecute(ctx, query, msg)
+}
 
+// parse splits query into statements and matches args to them. With the
+// statement cache enabled, queries are only parsed the first time and the
+// cache entry is returned.
+func (h *hranaV2Conn) parse(query string, args []driver.NamedValue) ([]string, []shared.Params, *cachedStmt, error) {
+	if h.stmtCache == nil {
+		stmts, params, err := shared.ParseStatementAndArgs(query, args)
+		return stmts, params, nil, err
+	}
+	cached, err := h.stmtCache.get(query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	params, err := shared.BindArgs(cached.stmts, cached.infos, args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cached.stmts, params, cached, nil
+}
+
+// addCacheRequests adds requests closing the statements evicted from the cache
+// and, if cached is worth storing, a request storing it. It returns the index
+// of the store request, -1 if there is none.
+func (h *hranaV2Conn) addCacheRequests(msg *hrana.PipelineRequest, cached *cachedStmt) int {
+	for _, sqlId := range h.stmtCache.takeEvicted() {
+		msg.Add(hrana.CloseStoredSqlStream(sqlId))
+	}
+	if !h.stmtCache.shouldStore(cached) {
+		return -1
+	}
+	cached.sqlId = h.nextSqlId
+	h.nextSqlId++
+	msg.Add(hrana.StoreSqlStream(cached.stmts[0], cached.sqlId))
+	return len(msg.Requests) - 1
+}
+
+// queryStreamingStmt streams the rows of sql, executed by its id if cached is
+// stored on the server. Like executeStmt, it stores cached if it is worth it,
+// with a request preceding the statement so that whether it was stored is known
+// before the rows are read.
+func (h *hranaV2Conn) queryStreamingStmt(ctx context.Context, sql string, params shared.Params, cached *cachedStmt) (driver.Rows, error) {
+	msg := &hrana.PipelineRequest{}
+	storeIdx := -1
+	if cached != nil {
+		storeIdx = h.addCacheRequests(msg, cached)
+	}
+	var executeStream *hrana.StreamRequest
+	var err error
+	if cached != nil && cached.stored {
+		executeStream, err = hrana.ExecuteStoredStream(cached.sqlId, params, true)
+	} else {
+		executeStream, err = hrana.ExecuteStream(sql, params, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg.Add(*executeStream)
+	rows, err := h.queryStreaming(ctx, msg, func(results []hrana.StreamResult) error {
+		if storeIdx >= 0 && results[storeIdx].Error == nil {
+			cached.stored = true
+		}
+		return nil
+	})
+	if storeIdx >= 0 && !cached.stored {
+		// The statement may have been stored anyway, close it with the next
+		// request so that it does not leak.
+		h.stmtCache.evicted = append(h.stmtCache.evicted, cached.sqlId)
+	}
+	return rows, err
+}
+
+func (h *hranaV2Conn) sendExecute(ctx context.Context, query string, msg *hrana.PipelineRequest) (*hrana.PipelineResponse, error) {
 	result, err := h.sendPipelineRequest(ctx, msg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
 	}
 
 	if result.Results[0].Error != nil {
-		return nil, fmt.Errorf("failed to execute SQL: %s\n%s", query, result.Results[0].Error.Message)
+		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, result.Results[0].Error.Err())
 	}
 	if result.Results[0].Response == nil {
 		return nil, fmt.Errorf("failed to execute SQL: %s\n%s", query, "no response received")
@@ -297,7 +792,161 @@ func (h *hranaV2Conn) executeStmt(ctx context.Context, query string, args []driv
 	return result, nil
 }
 
+// executeSplitBatch sends batches one pipeline request at a time on the same
+// stream and merges their results into a single batch response. Execution stops
+// at the first batch with a failed step. When atomic is set the batches are
+// wrapped in an interactive transaction that is rolled back on failure, and the
+// steps of each batch only run if the previous one succeeded.
+func (h *hranaV2Conn) executeSplitBatch(ctx context.Context, batches []*hrana.Batch, atomic bool) (*hrana.PipelineResponse, error) {
+	h.cfg.Log().DebugContext(ctx, "splitting batch", "requests", len(batches), "atomic", atomic)
+	if atomic {
+		begin, err := shared.BeginStatement(driver.TxOptions{}, ctxopt.TxMode(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := h.executeStmt(ctx, begin, nil, false); err != nil {
+			return nil, err
+		}
+	}
+	rollback := func(err error) error {
+		if atomic {
+			if _, rollbackErr := h.executeStmt(context.Background(), "ROLLBACK", nil, false); rollbackErr != nil {
+				h.cfg.Log().WarnContext(ctx, "failed to roll back split batch", "error", rollbackErr)
+			}
+		}
+		return err
+	}
+
+	var merged hrana.BatchResult
+	for idx, batch := range batches {
+		h.cfg.Log().DebugContext(ctx, "sending split batch", "part", idx+1, "parts", len(batches), "steps", len(batch.Steps))
+		if atomic {
+			batch = chainSteps(batch)
+		}
+		msg := &hrana.PipelineRequest{}
+		msg.Add(hrana.StreamRequest{Type: "batch", Batch: batch})
+		result, err := h.sendPipelineRequest(ctx, msg)
+		if err != nil {
+			return nil, rollback(err)
+		}
+		if result.Results[0].Error != nil {
+			return nil, rollback(result.Results[0].Error.Err())
+		}
+		if result.Results[0].Response == nil {
+			return nil, rollback(errors.New("no response received"))
+		}
+		// BatchResult fails on the first failed step.
+		res, err := result.Results[0].Response.BatchResult()
+		if err != nil {
+			return nil, rollback(err)
+		}
+		merged.StepResults = append(merged.StepResults, res.StepResults...)
+		merged.StepErrors = append(merged.StepErrors, res.StepErrors...)
+	}
+
+	if atomic {
+		if _, err := h.executeStmt(ctx, "COMMIT", nil, false); err != nil {
+			return nil, rollback(err)
+		}
+	}
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return &hrana.PipelineResponse{
+		Baton: h.baton,
+		Results: []hrana.StreamResult{{
+			Type:     "ok",
+			Response: &hrana.StreamResponse{Type: "batch", Result: raw},
+		}},
+	}, nil
+}
+
+// chainSteps returns a copy of batch whose steps only run if the previous one
+// succeeded.
+func chainSteps(batch *hrana.Batch) *hrana.Batch {
+	chained := &hrana.Batch{Steps: make([]hrana.BatchStep, len(batch.Steps))}
+	for idx, step := range batch.Steps {
+		if idx > 0 {
+			prev := int32(idx - 1)
+			step.Condition = &hrana.BatchCondition{Type: "ok", Step: &prev}
+		}
+		chained.Steps[idx] = step
+	}
+	return chained
+}
+
+// ExecAtomicBatch executes every query with its arguments in a single batch
+// wrapped in BEGIN and COMMIT. Each step only runs if the previous one
+// succeeded and the transaction is rolled back if any fails. Statements too
+// large for a single request are sent in several requests inside an
+// interactive transaction instead.
+func (h *hranaV2Conn) ExecAtomicBatch(ctx context.Context, queries []string, args [][]driver.NamedValue) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	statements := &hrana.Batch{}
+	for idx, query := range queries {
+		stmts, params, err := shared.ParseStatementAndArgs(query, args[idx])
+		if err != nil {
+			return fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+		}
+		for i := range stmts {
+			stmt := hrana.Stmt{Sql: &stmts[i]}
+			if err := stmt.AddArgs(params[i]); err != nil {
+				return fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+			}
+			statements.Add(stmt)
+		}
+	}
+	batches, err := statements.Split(h.cfg.RequestBytes())
+	if err != nil {
+		return fmt.Errorf("failed to execute transaction: %w", err)
+	}
+	if len(batches) > 1 {
+		if _, err := h.executeSplitBatch(ctx, batches, true); err != nil {
+			return fmt.Errorf("failed to execute transaction: %w", err)
+		}
+		return nil
+	}
+
+	begin, err := shared.BeginStatement(driver.TxOptions{}, ctxopt.TxMode(ctx))
+	if err != nil {
+		return err
+	}
+	batch := &hrana.Batch{}
+	batch.Add(hrana.Stmt{Sql: &begin})
+	batch.Steps = append(batch.Steps, statements.Steps...)
+	commitSql := "COMMIT"
+	batch.Add(hrana.Stmt{Sql: &commitSql})
+	batch = chainSteps(batch)
+	commit := int32(len(batch.Steps) - 1)
+	rollback := "ROLLBACK"
+	batch.Steps = append(batch.Steps, hrana.BatchStep{
+		Stmt:      hrana.Stmt{Sql: &rollback},
+		Condition: &hrana.BatchCondition{Type: "not", Cond: &hrana.BatchCondition{Type: "ok", Step: &commit}},
+	})
+
+	msg := &hrana.PipelineRequest{}
+	msg.Add(hrana.StreamRequest{Type: "batch", Batch: batch})
+	result, err := h.sendPipelineRequest(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to execute transaction: %w", err)
+	}
+	if result.Results[0].Error != nil {
+		return fmt.Errorf("failed to execute transaction: %w", result.Results[0].Error.Err())
+	}
+	if result.Results[0].Response == nil {
+		return fmt.Errorf("failed to execute transaction: %s", "no response received")
+	}
+	if _, err := result.Results[0].Response.BatchResult(); err != nil {
+		return fmt.Errorf("failed to execute transaction: %w", err)
+	}
+	return nil
+}
+
 func (h *hranaV2Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	result, err := h.executeStmt(ctx, query, args, false)
 	if err != nil {
 		return nil, err
@@ -308,7 +957,7 @@ func (h *hranaV2Conn) ExecContext(ctx context.Context, query string, args []driv
 		if err != nil {
 			return nil, err
 		}
-		return shared.NewResult(res.GetLastInsertRowId(), int64(res.AffectedRowCount)), nil
+		return shared.NewReplicatedResult(res.GetLastInsertRowId(), int64(res.AffectedRowCount), res.GetReplicationIndex(), res.GetStats()), nil
 	case "batch":
 		res, err := result.Results[0].Response.BatchResult()
 		if err != nil {
@@ -316,14 +965,20 @@ func (h *hranaV2Conn) ExecContext(ctx context.Context, query string, args []driv
 		}
 		lastInsertRowId := int64(0)
 		affectedRowCount := int64(0)
+		replicationIndex := uint64(0)
+		var stats shared.Stats
 		for _, r := range res.StepResults {
 			rowId := r.GetLastInsertRowId()
 			if rowId > 0 {
 				lastInsertRowId = rowId
 			}
 			affectedRowCount += int64(r.AffectedRowCount)
+			if index := r.GetReplicationIndex(); index > replicationIndex {
+				replicationIndex = index
+			}
+			stats.Add(r.GetStats())
 		}
-		return shared.NewResult(lastInsertRowId, affectedRowCount), nil
+		return shared.NewReplicatedResult(lastInsertRowId, affectedRowCount, replicationIndex, stats), nil
 	default:
 		return nil, fmt.Errorf("failed to execute SQL: %s\n%s", query, "unknown response type")
 	}
@@ -357,6 +1012,13 @@ func (p *StmtResultRowsProvider) Columns(setIdx int) []string {
 	return res
 }
 
+func (p *StmtResultRowsProvider) DeclTypes(setIdx int) []string {
+	if setIdx != 0 {
+		return nil
+	}
+	return declTypes(p.r.Cols)
+}
+
 func (p *StmtResultRowsProvider) FieldValue(setIdx, rowIdx, colIdx int) driver.Value {
 	if setIdx != 0 {
 		return nil
@@ -364,6 +1026,21 @@ func (p *StmtResultRowsProvider) FieldValue(setIdx, rowIdx, colIdx int) driver.V
 	return p.r.Rows[rowIdx][colIdx].ToValue()
 }
 
+func (p *StmtResultRowsProvider) BufferedFieldValue(setIdx, rowIdx, colIdx int, buf *[]byte) driver.Value {
+	if setIdx != 0 {
+		return nil
+	}
+	return p.r.Rows[rowIdx][colIdx].ToValueBuffer(buf)
+}
+
+func (p *StmtResultRowsProvider) ReplicationIndex() uint64 {
+	return p.r.GetReplicationIndex()
+}
+
+func (p *StmtResultRowsProvider) Stats() shared.Stats {
+	return p.r.GetStats()
+}
+
 func (p *StmtResultRowsProvider) Error(setIdx int) string {
 	return ""
 }
@@ -372,6 +1049,16 @@ func (p *StmtResultRowsProvider) HasResult(setIdx int) bool {
 	return setIdx == 0
 }
 
+func declTypes(cols []hrana.Column) []string {
+	res := make([]string, len(cols))
+	for i, c := range cols {
+		if c.Type != nil {
+			res[i] = *c.Type
+		}
+	}
+	return res
+}
+
 type BatchResultRowsProvider struct {
 	r *hrana.BatchResult
 }
@@ -400,6 +1087,13 @@ func (p *BatchResultRowsProvider) Columns(setIdx int) []string {
 	return res
 }
 
+func (p *BatchResultRowsProvider) DeclTypes(setIdx int) []string {
+	if setIdx >= len(p.r.StepResults) || p.r.StepResults[setIdx] == nil {
+		return nil
+	}
+	return declTypes(p.r.StepResults[setIdx].Cols)
+}
+
 func (p *BatchResultRowsProvider) FieldValue(setIdx, rowIdx, colIdx int) driver.Value {
 	if setIdx >= len(p.r.StepResults) || p.r.StepResults[setIdx] == nil {
 		return nil
@@ -407,6 +1101,23 @@ func (p *BatchResultRowsProvider) FieldValue(setIdx, rowIdx, colIdx int) driver.
 	return p.r.StepResults[setIdx].Rows[rowIdx][colIdx].ToValue()
 }
 
+func (p *BatchResultRowsProvider) BufferedFieldValue(setIdx, rowIdx, colIdx int, buf *[]byte) driver.Value {
+	if setIdx >= len(p.r.StepResults) || p.r.StepResults[setIdx] == nil {
+		return nil
+	}
+	return p.r.StepResults[setIdx].Rows[rowIdx][colIdx].ToValueBuffer(buf)
+}
+
+func (p *BatchResultRowsProvider) Stats() shared.Stats {
+	var stats shared.Stats
+	for _, r := range p.r.StepResults {
+		if r != nil {
+			stats.Add(r.GetStats())
+		}
+	}
+	return stats
+}
+
 func (p *BatchResultRowsProvider) Error(setIdx int) string {
 	if setIdx >= len(p.r.StepErrors) || p.r.StepErrors[setIdx] == nil {
 		return ""
@@ -419,6 +1130,22 @@ func (p *BatchResultRowsProvider) HasResult(setIdx int) bool {
 }
 
 func (h *hranaV2Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streamRows {
+		stmts, params, cached, err := h.parse(query, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+		}
+		// Batches are small enough to be buffered, only single statements are streamed.
+		if len(stmts) == 1 {
+			rows, err := h.queryStreamingStmt(ctx, stmts[0], params[0], cached)
+			if err != nil {
+				return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+			}
+			return rows, nil
+		}
+	}
 	result, err := h.executeStmt(ctx, query, args, true)
 	if err != nil {
 		return nil, err
@@ -429,13 +1156,13 @@ func (h *hranaV2Conn) QueryContext(ctx context.Context, query string, args []dri
 		if err != nil {
 			return nil, err
 		}
-		return shared.NewRows(&StmtResultRowsProvider{res}), nil
+		return shared.NewLimitedRows(&StmtResultRowsProvider{res}, h.cfg.MaxRows)
 	case "batch":
 		res, err := result.Results[0].Response.BatchResult()
 		if err != nil {
 			return nil, err
 		}
-		return shared.NewRows(&BatchResultRowsProvider{res}), nil
+		return shared.NewLimitedRows(&BatchResultRowsProvider{res}, h.cfg.MaxRows)
 	default:
 		return nil, fmt.Errorf("failed to execute SQL: %s\n%s", query, "unknown response type")
 	}
@@ -0,0 +1,595 @@
+package hranaV2
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+	"github.com/libsql/libsql-client-go/libsql/internal/retry"
+)
+
+func newVersionServer(versions ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, v := range versions {
+			if r.URL.Path == "/"+v {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestProtocolVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []string
+		want     int
+	}{
+		{name: "V3", versions: []string{"v2", "v3"}, want: 3},
+		{name: "V2", versions: []string{"v2"}, want: 2},
+		{name: "Legacy", versions: nil, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newVersionServer(tt.versions...)
+			defer server.Close()
+			if got := ProtocolVersion(context.Background(), server.URL, nil, &config.Config{}); got != tt.want {
+				t.Errorf("got version %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineBaton(t *testing.T) {
+	var paths, batons []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req hrana.PipelineRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		paths = append(paths, r.URL.Path)
+		batons = append(batons, req.Baton)
+		result, _ := json.Marshal(hrana.StmtResult{AffectedRowCount: 1})
+		err := json.NewEncoder(w).Encode(hrana.PipelineResponse{
+			Baton: "baton",
+			Results: []hrana.StreamResult{{
+				Type:     "ok",
+				Response: &hrana.StreamResponse{Type: "execute", Result: result},
+			}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 3, &config.Config{}).(driver.ExecerContext)
+	for i := 0; i < 2; i++ {
+		if _, err := conn.ExecContext(context.Background(), "INSERT INTO t VALUES (1)", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if paths[0] != "/v3/pipeline" {
+		t.Errorf("got path %s, want /v3/pipeline", paths[0])
+	}
+	if batons[0] != "" || batons[1] != "baton" {
+		t.Errorf("got batons %#v, want the baton of the previous response to be sent back", batons)
+	}
+}
+
+func TestExecAtomicBatch(t *testing.T) {
+	var batch *hrana.Batch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Requests []hrana.StreamRequest `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		batch = req.Requests[0].Batch
+		result, _ := json.Marshal(hrana.BatchResult{StepResults: make([]*hrana.StmtResult, len(batch.Steps)), StepErrors: make([]*hrana.Error, len(batch.Steps))})
+		err := json.NewEncoder(w).Encode(hrana.PipelineResponse{
+			Results: []hrana.StreamResult{{
+				Type:     "ok",
+				Response: &hrana.StreamResponse{Type: "batch", Result: result},
+			}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 3, &config.Config{}).(*hranaV2Conn)
+	err := conn.ExecAtomicBatch(context.Background(),
+		[]string{"INSERT INTO t VALUES (?)", "UPDATE t SET a = 2; DELETE FROM t WHERE a = ?"},
+		[][]driver.NamedValue{{{Ordinal: 1, Value: int64(1)}}, {{Ordinal: 1, Value: int64(3)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "INSERT INTO t VALUES (?)", "UPDATE t SET a = 2", "DELETE FROM t WHERE a = ?", "COMMIT", "ROLLBACK"}
+	if len(batch.Steps) != len(want) {
+		t.Fatalf("got %d steps, want %d", len(batch.Steps), len(want))
+	}
+	for idx, step := range batch.Steps {
+		if *step.Stmt.Sql != want[idx] {
+			t.Errorf("step %d: got %s, want %s", idx, *step.Stmt.Sql, want[idx])
+		}
+		switch {
+		case idx == 0 && step.Condition != nil:
+			t.Error("BEGIN should be unconditional")
+		case idx > 0 && idx < len(want)-1 && (step.Condition == nil || step.Condition.Type != "ok" || *step.Condition.Step != int32(idx-1)):
+			t.Errorf("step %d should depend on the previous one, got %#v", idx, step.Condition)
+		case idx == len(want)-1 && (step.Condition == nil || step.Condition.Type != "not"):
+			t.Errorf("ROLLBACK should only run if COMMIT failed, got %#v", step.Condition)
+		}
+	}
+	if len(batch.Steps[3].Stmt.Args) != 1 {
+		t.Errorf("got args %#v for the second statement of the query", batch.Steps[3].Stmt.Args)
+	}
+}
+
+func TestExecAtomicBatchSplit(t *testing.T) {
+	var requests []hrana.StreamRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Requests []hrana.StreamRequest `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		request := req.Requests[0]
+		requests = append(requests, request)
+		var response hrana.StreamResponse
+		if request.Type == "batch" {
+			result, _ := json.Marshal(hrana.BatchResult{StepResults: make([]*hrana.StmtResult, len(request.Batch.Steps)), StepErrors: make([]*hrana.Error, len(request.Batch.Steps))})
+			response = hrana.StreamResponse{Type: "batch", Result: result}
+		} else {
+			result, _ := json.Marshal(hrana.StmtResult{})
+			response = hrana.StreamResponse{Type: "execute", Result: result}
+		}
+		err := json.NewEncoder(w).Encode(hrana.PipelineResponse{
+			Baton:   "baton",
+			Results: []hrana.StreamResult{{Type: "ok", Response: &response}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	queries := make([]string, 6)
+	args := make([][]driver.NamedValue, len(queries))
+	for idx := range queries {
+		queries[idx] = "INSERT INTO t VALUES (?)"
+		args[idx] = []driver.NamedValue{{Ordinal: 1, Value: int64(idx)}}
+	}
+	conn := Connect(server.URL, nil, 3, &config.Config{MaxRequestBytes: 200}).(*hranaV2Conn)
+	if err := conn.ExecAtomicBatch(context.Background(), queries, args); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) < 4 {
+		t.Fatalf("got %d requests, want BEGIN, several batches and COMMIT", len(requests))
+	}
+	first, last := requests[0], requests[len(requests)-1]
+	if first.Type != "execute" || *first.Stmt.Sql != "BEGIN" || last.Type != "execute" || *last.Stmt.Sql != "COMMIT" {
+		t.Errorf("got %#v and %#v, want the batches to run in a transaction", first.Stmt, last.Stmt)
+	}
+	steps := 0
+	for _, request := range requests[1 : len(requests)-1] {
+		if request.Type != "batch" {
+			t.Fatalf("got %s request, want batch", request.Type)
+		}
+		for idx, step := range request.Batch.Steps {
+			if idx > 0 && (step.Condition == nil || *step.Condition.Step != int32(idx-1)) {
+				t.Errorf("step %d should depend on the previous one, got %#v", idx, step.Condition)
+			}
+			if got := step.Stmt.Args[0].Value; got != fmt.Sprint(steps) {
+				t.Errorf("got argument %v for step %d, want the statements to keep their order", got, steps)
+			}
+			steps++
+		}
+	}
+	if steps != len(queries) {
+		t.Errorf("got %d steps, want %d", steps, len(queries))
+	}
+}
+
+func TestBeginSentWithFirstStatement(t *testing.T) {
+	var pipelines [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req hrana.PipelineRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		var sqls []string
+		results := make([]hrana.StreamResult, len(req.Requests))
+		for idx, request := range req.Requests {
+			sqls = append(sqls, *request.Stmt.Sql)
+			result, _ := json.Marshal(hrana.StmtResult{AffectedRowCount: int32(idx)})
+			results[idx] = hrana.StreamResult{Type: "ok", Response: &hrana.StreamResponse{Type: "execute", Result: result}}
+		}
+		pipelines = append(pipelines, sqls)
+		if err := json.NewEncoder(w).Encode(hrana.PipelineResponse{Baton: "baton", Results: results}); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 3, &config.Config{}).(*hranaV2Conn)
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, driver.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pipelines) != 0 {
+		t.Fatalf("got %q, want BEGIN to wait for the first statement", pipelines)
+	}
+	res, err := conn.ExecContext(ctx, "INSERT INTO t VALUES (1)", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("got %d affected rows, want the result of the statement rather than of BEGIN", n)
+	}
+	if _, err := conn.ExecContext(ctx, "INSERT INTO t VALUES (2)", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction without statements sends nothing.
+	tx, err = conn.BeginTx(ctx, driver.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"BEGIN", "INSERT INTO t VALUES (1)"}, {"INSERT INTO t VALUES (2)"}, {"COMMIT"}}
+	if !reflect.DeepEqual(pipelines, want) {
+		t.Errorf("got %q, want %q", pipelines, want)
+	}
+}
+
+func TestExecuteWithoutArgs(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req["requests"].([]any)[0].(map[string]any))
+		var response hrana.StreamResponse
+		if requests[len(requests)-1]["type"] == "batch" {
+			result, _ := json.Marshal(hrana.BatchResult{StepResults: []*hrana.StmtResult{{}, {}}, StepErrors: []*hrana.Error{nil, nil}})
+			response = hrana.StreamResponse{Type: "batch", Result: result}
+		} else {
+			result, _ := json.Marshal(hrana.StmtResult{})
+			response = hrana.StreamResponse{Type: "execute", Result: result}
+		}
+		err := json.NewEncoder(w).Encode(hrana.PipelineResponse{
+			Baton:   "baton",
+			Results: []hrana.StreamResult{{Type: "ok", Response: &response}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 3, &config.Config{}).(driver.ExecerContext)
+	for _, query := range []string{"COMMIT;", "SELECT 1; SELECT 2"} {
+		if _, err := conn.ExecContext(context.Background(), query, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]any{"type": "execute", "stmt": map[string]any{"sql": "COMMIT", "want_rows": false}}
+	if !reflect.DeepEqual(requests[0], want) {
+		t.Errorf("got %#v, want %#v", requests[0], want)
+	}
+	if requests[1]["type"] != "batch" {
+		t.Errorf("got %#v, want several statements to be sent as a batch", requests[1])
+	}
+
+	// Placeholders without arguments are rejected before sending, like over
+	// websockets.
+	_, err := conn.ExecContext(context.Background(), "INSERT INTO t VALUES (?)", nil)
+	if !errors.Is(err, shared.ErrArgs) {
+		t.Errorf("got %v, want an argument mismatch", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("got %d requests, want the statement not to be sent", len(requests))
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req hrana.PipelineRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if want := fmt.Sprintf("baton%d", requests); requests > 0 && req.Baton != want {
+			t.Errorf("got baton %#v, want %#v", req.Baton, want)
+		}
+		requests++
+		result, _ := json.Marshal(hrana.StmtResult{AffectedRowCount: 1})
+		err := json.NewEncoder(w).Encode(hrana.PipelineResponse{
+			Baton: fmt.Sprintf("baton%d", requests),
+			Results: []hrana.StreamResult{{
+				Type:     "ok",
+				Response: &hrana.StreamResponse{Type: "execute", Result: result},
+			}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 3, &config.Config{StatementCacheSize: 4})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = conn.(driver.ExecerContext).ExecContext(context.Background(), "INSERT INTO t VALUES (?)", []driver.NamedValue{{Ordinal: 1, Value: int64(i)}})
+			} else {
+				var rows driver.Rows
+				rows, err = conn.(driver.QueryerContext).QueryContext(context.Background(), "SELECT 1", nil)
+				if err == nil {
+					rows.Close()
+				}
+			}
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCancelClosesStream(t *testing.T) {
+	closed := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req hrana.PipelineRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		switch {
+		case req.Requests[0].Type == "close":
+			closed <- req.Baton
+			return
+		case *req.Requests[0].Stmt.Sql == "SELECT slow()":
+			<-r.Context().Done()
+			return
+		}
+		result, _ := json.Marshal(hrana.StmtResult{})
+		err := json.NewEncoder(w).Encode(hrana.PipelineResponse{
+			Baton:   "baton",
+			Results: []hrana.StreamResult{{Type: "ok", Response: &hrana.StreamResponse{Type: "execute", Result: result}}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 3, &config.Config{}).(driver.ExecerContext)
+	if _, err := conn.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SELECT slow()", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case baton := <-closed:
+		if baton != "baton" {
+			t.Errorf("got close request with baton %#v, want the baton of the stream", baton)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the stream of the canceled request was not closed")
+	}
+	if _, err := conn.ExecContext(context.Background(), "SELECT 1", nil); !errors.Is(err, driver.ErrBadConn) {
+		t.Errorf("got %v, want driver.ErrBadConn once the stream was abandoned", err)
+	}
+}
+
+// newBenchmarkServer returns the URL of a server answering every pipeline
+// request with response.
+func newBenchmarkServer(b *testing.B, response []byte) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			b.Error(err)
+		}
+		if _, err := w.Write(response); err != nil {
+			b.Error(err)
+		}
+	}))
+	b.Cleanup(server.Close)
+	return server.URL
+}
+
+// benchmarkRows is a response of 100 rows of an integer, a text and a float.
+func benchmarkRows() []byte {
+	row := `[{"type":"integer","value":"12345"},{"type":"text","value":"some text value"},{"type":"float","value":1.5}]`
+	rows := row
+	for i := 1; i < 100; i++ {
+		rows += "," + row
+	}
+	return []byte(`{"baton":"b","base_url":null,"results":[{"type":"ok","response":{"type":"execute","result":` +
+		`{"cols":[{"name":"a"},{"name":"b"},{"name":"c"}],"rows":[` + rows + `],"affected_row_count":0,"last_insert_rowid":null}}}]}`)
+}
+
+// BenchmarkQuery measures a query returning 100 rows over a local server, the
+// allocations reported include those of the server.
+func BenchmarkQuery(b *testing.B) {
+	for _, streamRows := range []bool{false, true} {
+		name := "buffered"
+		if streamRows {
+			name = "streamed"
+		}
+		b.Run(name, func(b *testing.B) {
+			url := newBenchmarkServer(b, benchmarkRows())
+			conn := Connect(url, nil, 3, &config.Config{StreamRows: streamRows}).(driver.QueryerContext)
+			args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}, {Ordinal: 2, Value: "a text argument"}}
+			dest := make([]driver.Value, 3)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rows, err := conn.QueryContext(context.Background(), "SELECT a, b, c FROM t WHERE a > ? AND b != ?", args)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for rows.Next(dest) == nil {
+				}
+				rows.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkExec measures the round trip of a statement without rows.
+func BenchmarkExec(b *testing.B) {
+	url := newBenchmarkServer(b, []byte(`{"baton":"b","base_url":null,"results":[{"type":"ok","response":{"type":"execute","result":`+
+		`{"cols":[],"rows":[],"affected_row_count":1,"last_insert_rowid":"7"}}}]}`))
+	conn := Connect(url, nil, 3, &config.Config{}).(driver.ExecerContext)
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}, {Ordinal: 2, Value: "a text argument"}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.ExecContext(context.Background(), "INSERT INTO t (a, b) VALUES (?, ?)", args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status        int
+		body          string
+		inTx          bool
+		retryable     bool
+		badConn       bool
+		streamExpired bool
+	}{
+		{status: 400, body: `{"message":"SQL parse error"}`},
+		{status: 400, body: `{"message":"stream expired","code":"STREAM_EXPIRED"}`, retryable: true, badConn: true},
+		{status: 400, body: `{"message":"stream expired","code":"STREAM_EXPIRED"}`, inTx: true, retryable: true, streamExpired: true},
+		{status: 400, body: `{"message":"SQL parse error"}`, inTx: true},
+		{status: 429, body: `{"message":"too many requests"}`, retryable: true},
+		{status: 502, body: `<html>Bad Gateway</html>`, retryable: true},
+		{status: 503, body: `{"message":"overloaded","code":"SERVER_OVERLOADED"}`, retryable: true},
+	}
+	for _, tt := range tests {
+		err := statusError(tt.status, nil, []byte(tt.body), tt.inTx)
+		if got := retry.Is(err); got != tt.retryable {
+			t.Errorf("%d %s: got retryable %t, want %t", tt.status, tt.body, got, tt.retryable)
+		}
+		if got := errors.Is(err, driver.ErrBadConn); got != tt.badConn {
+			t.Errorf("%d %s: got ErrBadConn %t, want %t", tt.status, tt.body, got, tt.badConn)
+		}
+		if got := errors.Is(err, shared.ErrStreamExpired); got != tt.streamExpired {
+			t.Errorf("%d %s: got ErrStreamExpired %t, want %t", tt.status, tt.body, got, tt.streamExpired)
+		}
+		var httpErr *shared.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status || string(httpErr.Body) != tt.body {
+			t.Errorf("%d %s: got %#v, want an HTTPError with the status and body", tt.status, tt.body, err)
+		}
+	}
+}
+
+func TestUnreachableServerIsBadConn(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	conn := Connect(server.URL, nil, 3, &config.Config{}).(driver.ExecerContext)
+	if _, err := conn.ExecContext(context.Background(), "SELECT 1", nil); !errors.Is(err, driver.ErrBadConn) {
+		t.Errorf("got %v, want driver.ErrBadConn for a request that was never sent", err)
+	}
+}
+
+func TestPreparedStatement(t *testing.T) {
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req hrana.PipelineRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		var types []string
+		var results []hrana.StreamResult
+		for _, request := range req.Requests {
+			result := hrana.StreamResult{Type: "ok", Response: &hrana.StreamResponse{Type: request.Type}}
+			switch {
+			case request.Type == "execute" && len(request.Stmt.Args) > 0 && request.Stmt.Args[0].Value == "fail":
+				result = hrana.StreamResult{Type: "error", Error: &hrana.Error{Message: "constraint failed"}}
+			case request.Type == "execute":
+				result.Response.Result, _ = json.Marshal(hrana.StmtResult{AffectedRowCount: 1})
+			}
+			if request.Stmt != nil && request.Stmt.SqlId != nil {
+				types = append(types, fmt.Sprintf("%s %d", request.Type, *request.Stmt.SqlId))
+			} else if request.SqlId != nil {
+				types = append(types, fmt.Sprintf("%s %d", request.Type, *request.SqlId))
+			} else {
+				types = append(types, request.Type)
+			}
+			results = append(results, result)
+		}
+		requests = append(requests, types)
+		if err := json.NewEncoder(w).Encode(hrana.PipelineResponse{Baton: "baton", Results: results}); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	conn := Connect(server.URL, nil, 3, &config.Config{}).(*hranaV2Conn)
+	stmt, err := conn.PrepareContext(ctx, "INSERT INTO t VALUES (?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := stmt.(driver.StmtExecContext)
+	if _, err := exec.ExecContext(ctx, []driver.NamedValue{{Ordinal: 1, Value: "fail"}}); err == nil {
+		t.Error("expected the execution to fail")
+	}
+	res, err := exec.ExecContext(ctx, []driver.NamedValue{{Ordinal: 1, Value: int64(1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected, _ := res.RowsAffected(); affected != 1 {
+		t.Errorf("got %d rows affected, want 1", affected)
+	}
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT 1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"store_sql 0", "execute 0"}, {"execute 0"}, {"execute", "close_sql 0"}}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+}
@@ -0,0 +1,76 @@
+package hranaV2
+
+import (
+	"container/list"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
+
+// stmtCache is a least recently used cache of parsed queries keyed by their SQL
+// text. Single statements executed more than once are also stored on the
+// server with store_sql, so later executions only send their id.
+type stmtCache struct {
+	size    int
+	entries map[string]*list.Element
+	// order holds the entries, most recently used first.
+	order *list.List
+	// evicted are the ids of stored statements to close with the next request.
+	evicted []int32
+}
+
+type cachedStmt struct {
+	query string
+	stmts []string
+	infos []shared.ParamsInfo
+	uses  int
+	// sqlId is the id the statement is stored under, valid if stored is set.
+	stored bool
+	sqlId  int32
+}
+
+// newStmtCache returns a cache holding up to size queries, nil if size is not
+// positive.
+func newStmtCache(size int) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+	return &stmtCache{size: size, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// get returns the entry of query, parsing it if it is not cached.
+func (c *stmtCache) get(query string) (*cachedStmt, error) {
+	if e, ok := c.entries[query]; ok {
+		c.order.MoveToFront(e)
+		entry := e.Value.(*cachedStmt)
+		entry.uses++
+		return entry, nil
+	}
+	stmts, infos, err := shared.ParseStatement(query)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cachedStmt{query: query, stmts: stmts, infos: infos, uses: 1}
+	c.entries[query] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		evicted := oldest.Value.(*cachedStmt)
+		delete(c.entries, evicted.query)
+		if evicted.stored {
+			c.evicted = append(c.evicted, evicted.sqlId)
+		}
+	}
+	return entry, nil
+}
+
+// shouldStore reports whether entry is worth storing on the server.
+func (c *stmtCache) shouldStore(entry *cachedStmt) bool {
+	return !entry.stored && entry.uses > 1 && len(entry.stmts) == 1
+}
+
+// takeEvicted returns the ids to close and forgets them.
+func (c *stmtCache) takeEvicted() []int32 {
+	evicted := c.evicted
+	c.evicted = nil
+	return evicted
+}
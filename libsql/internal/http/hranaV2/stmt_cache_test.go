@@ -0,0 +1,81 @@
+package hranaV2
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+)
+
+func TestStmtCacheEviction(t *testing.T) {
+	c := newStmtCache(2)
+	for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 1", "SELECT 3"} {
+		entry, err := c.get(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.stored, entry.sqlId = true, int32(len(query))
+	}
+	if _, ok := c.entries["SELECT 2"]; ok {
+		t.Error("least recently used query should be evicted")
+	}
+	if entry, _ := c.get("SELECT 1"); entry.uses != 3 {
+		t.Errorf("got %d uses, want 3", entry.uses)
+	}
+	if evicted := c.takeEvicted(); len(evicted) != 1 {
+		t.Errorf("got evicted ids %v, want the id of the evicted query", evicted)
+	}
+}
+
+func TestStatementCache(t *testing.T) {
+	var requests [][]hrana.StreamRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req hrana.PipelineRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req.Requests)
+		var results []hrana.StreamResult
+		for _, request := range req.Requests {
+			response := &hrana.StreamResponse{Type: request.Type}
+			if request.Type == "execute" {
+				response.Result, _ = json.Marshal(hrana.StmtResult{})
+			}
+			results = append(results, hrana.StreamResult{Type: "ok", Response: response})
+		}
+		if err := json.NewEncoder(w).Encode(hrana.PipelineResponse{Baton: "baton", Results: results}); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 3, &config.Config{StatementCacheSize: 1}).(driver.ExecerContext)
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}
+	for _, query := range []string{"INSERT INTO t VALUES (?)", "INSERT INTO t VALUES (?)", "INSERT INTO t VALUES (?)", "SELECT ?"} {
+		if _, err := conn.ExecContext(context.Background(), query, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	types := make([][]string, len(requests))
+	for idx, reqs := range requests {
+		for _, req := range reqs {
+			types[idx] = append(types[idx], req.Type)
+		}
+	}
+	want := [][]string{{"execute"}, {"execute", "store_sql"}, {"execute"}, {"execute", "close_sql"}}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("got requests %v, want %v", types, want)
+	}
+	if stmt := requests[2][0].Stmt; stmt.Sql != nil || stmt.SqlId == nil || *stmt.SqlId != *requests[1][1].SqlId {
+		t.Errorf("third execution should use the stored statement, got %#v", stmt)
+	}
+	if *requests[3][1].SqlId != *requests[1][1].SqlId {
+		t.Error("the evicted statement should be closed")
+	}
+}
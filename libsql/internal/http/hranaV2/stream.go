@@ -0,0 +1,279 @@
+package hranaV2
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+	"github.com/libsql/libsql-client-go/libsql/internal/limits"
+)
+
+// queryStreaming sends msg, whose last request executes a statement, and
+// returns rows that are decoded from the response body as they are read, so the
+// first row is available before the whole response has been received. The
+// results of the requests preceding the statement are decoded first and passed
+// to prelude, if not nil, which may fail the query.
+func (h *hranaV2Conn) queryStreaming(ctx context.Context, msg *hrana.PipelineRequest, prelude func(results []hrana.StreamResult) error) (driver.Rows, error) {
+	if err := h.flushBegin(ctx); err != nil {
+		return nil, err
+	}
+	skip := len(msg.Requests) - 1
+	// The statements closed since the last request are closed after the query,
+	// their results are drained along with the rest of the response.
+	closed := h.closedSqlIds
+	for _, sqlId := range closed {
+		msg.Add(hrana.CloseStoredSqlStream(sqlId))
+	}
+	resp, cancel, err := h.doPipelineRequest(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(closed) > 0 {
+		h.closedSqlIds = nil
+	}
+	rows := &streamingRows{
+		resp:    resp,
+		cancel:  cancel,
+		dec:     json.NewDecoder(limits.Reader(resp.Body, h.cfg.MaxResponseBytes)),
+		maxRows: h.cfg.MaxRows,
+	}
+	if err := rows.decodeHeader(h, skip, prelude); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return rows, nil
+}
+
+// streamingRows walks the pipeline response with a json.Decoder. decodeHeader
+// stops right after the opening bracket of the rows array, every call to Next
+// then decodes a single row.
+type streamingRows struct {
+	resp   *http.Response
+	cancel context.CancelFunc
+	dec    *json.Decoder
+	cols   []hrana.Column
+	done   bool
+	// count is the number of rows read, which may not exceed maxRows.
+	count   int
+	maxRows int
+	// row is the last row read, its values are reused by the next one.
+	row hrana.Row
+	// buffers are the blob buffers of the columns, reused like row.
+	buffers [][]byte
+	// stats are decoded from the fields following the rows, once all of them
+	// were read.
+	stats shared.Stats
+}
+
+func (r *streamingRows) expectDelim(delim json.Delim) error {
+	token, err := r.dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected token %v in response, expected %v", token, delim)
+	}
+	return nil
+}
+
+// forEachKey calls fn with every key of the object at the decoder position
+// until fn returns stop or the object ends.
+func (r *streamingRows) forEachKey(fn func(key string) (stop bool, err error)) error {
+	if err := r.expectDelim('{'); err != nil {
+		return err
+	}
+	for r.dec.More() {
+		token, err := r.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("unexpected token %v in response, expected object key", token)
+		}
+		stop, err := fn(key)
+		if err != nil || stop {
+			return err
+		}
+	}
+	return errors.New("no rows in response")
+}
+
+func (r *streamingRows) skip() error {
+	var ignored json.RawMessage
+	return r.dec.Decode(&ignored)
+}
+
+// decodeHeader decodes the response up to the first row of the statement
+// result, which follows the skip results passed to prelude.
+func (r *streamingRows) decodeHeader(h *hranaV2Conn, skip int, prelude func(results []hrana.StreamResult) error) error {
+	var baton, baseUrl string
+	return r.forEachKey(func(key string) (bool, error) {
+		switch key {
+		case "baton":
+			return false, r.dec.Decode(&baton)
+		case "base_url":
+			return false, r.dec.Decode(&baseUrl)
+		case "results":
+			// The baton precedes the results in sqld responses.
+			h.updateStream(baton, baseUrl)
+			if err := r.expectDelim('['); err != nil {
+				return false, err
+			}
+			results := make([]hrana.StreamResult, skip)
+			for i := range results {
+				if !r.dec.More() {
+					return false, errors.New("no response received")
+				}
+				if err := r.dec.Decode(&results[i]); err != nil {
+					return false, err
+				}
+			}
+			if prelude != nil {
+				if err := prelude(results); err != nil {
+					return false, err
+				}
+			}
+			return true, r.decodeStreamResult()
+		default:
+			return false, r.skip()
+		}
+	})
+}
+
+func (r *streamingRows) decodeStreamResult() error {
+	return r.forEachKey(func(key string) (bool, error) {
+		switch key {
+		case "error":
+			var e hrana.Error
+			if err := r.dec.Decode(&e); err != nil {
+				return false, err
+			}
+			return false, e.Err()
+		case "response":
+			return true, r.forEachKey(func(key string) (bool, error) {
+				if key != "result" {
+					return false, r.skip()
+				}
+				return true, r.decodeStmtResult()
+			})
+		default:
+			return false, r.skip()
+		}
+	})
+}
+
+func (r *streamingRows) decodeStmtResult() error {
+	return r.forEachKey(func(key string) (bool, error) {
+		switch key {
+		case "cols":
+			return false, r.dec.Decode(&r.cols)
+		case "rows":
+			if r.cols == nil {
+				return false, errors.New("rows received before columns")
+			}
+			return true, r.expectDelim('[')
+		default:
+			return false, r.skip()
+		}
+	})
+}
+
+func (r *streamingRows) Columns() []string {
+	res := make([]string, len(r.cols))
+	for i, c := range r.cols {
+		if c.Name != nil {
+			res[i] = *c.Name
+		}
+	}
+	return res
+}
+
+func (r *streamingRows) ColumnTypeDatabaseTypeName(index int) string {
+	if index >= len(r.cols) || r.cols[index].Type == nil {
+		return ""
+	}
+	return strings.ToUpper(*r.cols[index].Type)
+}
+
+func (r *streamingRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	if !r.dec.More() {
+		r.done = true
+		if err := r.decodeTrailer(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	r.count++
+	if err := limits.Rows(r.count, r.maxRows); err != nil {
+		return err
+	}
+	if err := r.dec.Decode(&r.row); err != nil {
+		return err
+	}
+	if len(r.buffers) < len(r.row) {
+		r.buffers = make([][]byte, len(r.row))
+	}
+	for idx := range dest {
+		if idx < len(r.row) {
+			dest[idx] = r.row[idx].ToValueBuffer(&r.buffers[idx])
+		}
+	}
+	return nil
+}
+
+// decodeTrailer decodes the fields of the statement result following its
+// rows, keeping the statistics.
+func (r *streamingRows) decodeTrailer() error {
+	if err := r.expectDelim(']'); err != nil {
+		return err
+	}
+	var res hrana.StmtResult
+	for r.dec.More() {
+		token, err := r.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case "rows_read":
+			err = r.dec.Decode(&res.RowsRead)
+		case "rows_written":
+			err = r.dec.Decode(&res.RowsWritten)
+		case "query_duration_ms":
+			err = r.dec.Decode(&res.QueryDurationMs)
+		default:
+			err = r.skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	r.stats = res.GetStats()
+	return nil
+}
+
+// Stats returns the execution statistics of the statement, zero until all its
+// rows were read.
+func (r *streamingRows) Stats() shared.Stats {
+	return r.stats
+}
+
+func (r *streamingRows) Close() error {
+	defer r.cancel()
+	// Drain the rest of the response so the HTTP connection can be reused.
+	if _, err := io.Copy(io.Discard, r.resp.Body); err != nil {
+		r.resp.Body.Close()
+		return err
+	}
+	return r.resp.Body.Close()
+}
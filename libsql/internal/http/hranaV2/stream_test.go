@@ -0,0 +1,142 @@
+package hranaV2
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+)
+
+func TestQueryStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"baton":"b1","base_url":null,"results":[{"type":"ok","response":{"type":"execute","result":{` +
+			`"cols":[{"name":"a","decltype":"integer"},{"name":"b","decltype":"text"}],` +
+			`"rows":[[{"type":"integer","value":"1"},{"type":"text","value":"one"}],[{"type":"integer","value":"2"},{"type":"null"}]],` +
+			`"affected_row_count":0,"last_insert_rowid":null}}},{"type":"ok","response":{"type":"close"}}]}`))
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 2, &config.Config{StreamRows: true}).(*hranaV2Conn)
+	rows, err := conn.QueryContext(context.Background(), "SELECT a, b FROM t", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	if conn.baton != "b1" {
+		t.Errorf("got baton %#v, want b1", conn.baton)
+	}
+	if !reflect.DeepEqual(rows.Columns(), []string{"a", "b"}) {
+		t.Errorf("got columns %v", rows.Columns())
+	}
+	if got := rows.(driver.RowsColumnTypeDatabaseTypeName).ColumnTypeDatabaseTypeName(0); got != "INTEGER" {
+		t.Errorf("got decltype %s, want INTEGER", got)
+	}
+	want := [][]driver.Value{{int64(1), "one"}, {int64(2), nil}}
+	for _, w := range want {
+		dest := make([]driver.Value, 2)
+		if err := rows.Next(dest); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dest, w) {
+			t.Errorf("got row %#v, want %#v", dest, w)
+		}
+	}
+	if err := rows.Next(make([]driver.Value, 2)); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+}
+
+func TestQueryStreamingError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"baton":"b1","results":[{"type":"error","error":{"message":"no such table: t"}}]}`))
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 2, &config.Config{StreamRows: true}).(*hranaV2Conn)
+	if _, err := conn.QueryContext(context.Background(), "SELECT a FROM t", nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestQueryStreamingStoredStatements(t *testing.T) {
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req hrana.PipelineRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		var types []string
+		var results []hrana.StreamResult
+		for _, request := range req.Requests {
+			if request.Type == "execute" && request.Stmt.SqlId != nil {
+				types = append(types, "execute stored")
+			} else {
+				types = append(types, request.Type)
+			}
+			response := &hrana.StreamResponse{Type: request.Type}
+			if request.Type == "execute" {
+				response.Result = json.RawMessage(`{"cols":[{"name":"a"}],"rows":[[{"type":"integer","value":"1"}]]}`)
+			}
+			results = append(results, hrana.StreamResult{Type: "ok", Response: response})
+		}
+		requests = append(requests, types)
+		if err := json.NewEncoder(w).Encode(hrana.PipelineResponse{Baton: "baton", Results: results}); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	conn := Connect(server.URL, nil, 3, &config.Config{StreamRows: true, StatementCacheSize: 1}).(*hranaV2Conn)
+	readAll := func(rows driver.Rows, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		dest := make([]driver.Value, 1)
+		if err := rows.Next(dest); err != nil || dest[0] != int64(1) {
+			t.Fatalf("got %v, %v, want 1", dest[0], err)
+		}
+		if err := rows.Next(dest); err != io.EOF {
+			t.Fatalf("got %v, want io.EOF", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		readAll(conn.QueryContext(context.Background(), "SELECT ?", []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}))
+	}
+	stmt, err := conn.PrepareContext(context.Background(), "SELECT a FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		readAll(stmt.(driver.StmtQueryContext).QueryContext(context.Background(), nil))
+	}
+	stmt.Close()
+	readAll(conn.QueryContext(context.Background(), "SELECT ?", []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}))
+
+	want := [][]string{
+		{"execute"},
+		{"store_sql", "execute"},
+		{"execute stored"},
+		{"store_sql", "execute stored"},
+		{"execute stored"},
+		{"execute stored", "close_sql"},
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %v, want %v", requests, want)
+	}
+}
@@ -0,0 +1,58 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrStreamExpired is wrapped by the errors of requests made inside a
+// transaction whose stream the server expired. The transaction is lost and
+// has to be run again from the start.
+var ErrStreamExpired = errors.New("stream expired, the transaction was rolled back")
+
+// HTTPError is the error of a request the server answered with an
+// unsuccessful status.
+type HTTPError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the error code of the response body, like SQLITE_CONSTRAINT,
+	// empty if it had none.
+	Code string
+	// Message is the error message of the response body, or the whole body
+	// when it is not an error object.
+	Message string
+	// Body is the response body as received.
+	Body []byte
+	// Header holds the headers of the response.
+	Header http.Header
+
+	cause error
+}
+
+// NewHTTPError returns the error of a response. Its Unwrap returns cause, if
+// not nil.
+func NewHTTPError(status int, header http.Header, body []byte, code, message string, cause error) *HTTPError {
+	return &HTTPError{StatusCode: status, Code: code, Message: message, Body: body, Header: header, cause: cause}
+}
+
+// RequestID returns the X-Request-Id header of the response, which identifies
+// the request in the logs of the server, empty if the response had none.
+func (e *HTTPError) RequestID() string {
+	return e.Header.Get("X-Request-Id")
+}
+
+func (e *HTTPError) Error() string {
+	msg := e.Message
+	if e.Code != "" {
+		msg = fmt.Sprintf("error code %s: %s", e.Code, e.Message)
+	}
+	if id := e.RequestID(); id != "" {
+		msg += fmt.Sprintf(" (request ID %s)", id)
+	}
+	return msg
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.cause
+}
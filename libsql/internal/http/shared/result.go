@@ -1,14 +1,22 @@
 package shared
 
 type result struct {
-	id      int64
-	changes int64
+	id               int64
+	changes          int64
+	replicationIndex uint64
+	stats            Stats
 }
 
 func NewResult(id, changes int64) *result {
 	return &result{id: id, changes: changes}
 }
 
+// NewReplicatedResult is like NewResult for servers reporting the replication
+// index of writes, zero if they did not, and execution statistics.
+func NewReplicatedResult(id, changes int64, replicationIndex uint64, stats Stats) *result {
+	return &result{id: id, changes: changes, replicationIndex: replicationIndex, stats: stats}
+}
+
 func (r *result) LastInsertId() (int64, error) {
 	return r.id, nil
 }
@@ -16,3 +24,14 @@ func (r *result) LastInsertId() (int64, error) {
 func (r *result) RowsAffected() (int64, error) {
 	return r.changes, nil
 }
+
+// ReplicationIndex returns the replication index of the write, zero if the
+// server did not report one.
+func (r *result) ReplicationIndex() uint64 {
+	return r.replicationIndex
+}
+
+// Stats returns the execution statistics of the statements.
+func (r *result) Stats() Stats {
+	return r.stats
+}
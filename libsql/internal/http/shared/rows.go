@@ -4,29 +4,68 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"io"
+	"strings"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/limits"
 )
 
 type rowsProvider interface {
 	SetsCount() int
 	RowsCount(setIdx int) int
 	Columns(setIdx int) []string
+	DeclTypes(setIdx int) []string
 	FieldValue(setIdx, rowIdx int, columnIdx int) driver.Value
 	Error(setIdx int) string
 	HasResult(setIdx int) bool
 }
 
+// bufferedRowsProvider is implemented by the providers that can decode blobs
+// into a buffer owned by the rows, which reuse one buffer per column for every
+// row like database/sql allows for the values returned by Next.
+type bufferedRowsProvider interface {
+	BufferedFieldValue(setIdx, rowIdx, columnIdx int, buf *[]byte) driver.Value
+}
+
 func NewRows(result rowsProvider) driver.Rows {
 	return &rows{result: result}
 }
 
+// NewLimitedRows is like NewRows but fails if a result set has more than
+// maxRows rows. A zero maxRows disables the limit.
+func NewLimitedRows(result rowsProvider, maxRows int) (driver.Rows, error) {
+	for idx := 0; idx < result.SetsCount(); idx++ {
+		if err := limits.Rows(result.RowsCount(idx), maxRows); err != nil {
+			return nil, err
+		}
+	}
+	return NewRows(result), nil
+}
+
 type rows struct {
 	result                rowsProvider
 	currentResultSetIndex int
 	currentRowIdx         int
+	// columns caches the columns of the current result set, which Next needs
+	// for every row.
+	columns []string
+	// buffers are the blob buffers of the columns, reused by every call to
+	// Next when result is a bufferedRowsProvider.
+	buffers [][]byte
 }
 
 func (r *rows) Columns() []string {
-	return r.result.Columns(r.currentResultSetIndex)
+	if r.columns == nil {
+		r.columns = r.result.Columns(r.currentResultSetIndex)
+	}
+	return r.columns
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	declTypes := r.result.DeclTypes(r.currentResultSetIndex)
+	if index >= len(declTypes) {
+		return ""
+	}
+	return strings.ToUpper(declTypes[index])
 }
 
 func (r *rows) Close() error {
@@ -37,7 +76,17 @@ func (r *rows) Next(dest []driver.Value) error {
 	if r.currentRowIdx == r.result.RowsCount(r.currentResultSetIndex) {
 		return io.EOF
 	}
-	count := len(r.result.Columns(r.currentResultSetIndex))
+	count := len(r.Columns())
+	if p, ok := r.result.(bufferedRowsProvider); ok {
+		if len(r.buffers) < count {
+			r.buffers = make([][]byte, count)
+		}
+		for idx := 0; idx < count; idx++ {
+			dest[idx] = p.BufferedFieldValue(r.currentResultSetIndex, r.currentRowIdx, idx, &r.buffers[idx])
+		}
+		r.currentRowIdx++
+		return nil
+	}
 	for idx := 0; idx < count; idx++ {
 		dest[idx] = r.result.FieldValue(r.currentResultSetIndex, r.currentRowIdx, idx)
 	}
@@ -45,6 +94,24 @@ func (r *rows) Next(dest []driver.Value) error {
 	return nil
 }
 
+// ReplicationIndex returns the replication index of the database the rows
+// were read from, zero if the server did not report it.
+func (r *rows) ReplicationIndex() uint64 {
+	if p, ok := r.result.(interface{ ReplicationIndex() uint64 }); ok {
+		return p.ReplicationIndex()
+	}
+	return 0
+}
+
+// Stats returns the execution statistics of the statements of all result
+// sets, zero if the server did not report them.
+func (r *rows) Stats() Stats {
+	if p, ok := r.result.(interface{ Stats() Stats }); ok {
+		return p.Stats()
+	}
+	return Stats{}
+}
+
 func (r *rows) HasNextResultSet() bool {
 	return r.currentResultSetIndex < r.result.SetsCount()-1
 }
@@ -56,6 +123,7 @@ func (r *rows) NextResultSet() error {
 
 	r.currentResultSetIndex++
 	r.currentRowIdx = 0
+	r.columns = nil
 
 	errStr := r.result.Error(r.currentResultSetIndex)
 	if errStr != "" {
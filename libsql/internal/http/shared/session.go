@@ -0,0 +1,21 @@
+package shared
+
+import (
+	"errors"
+	"regexp"
+)
+
+// ErrSessionLost is wrapped by the errors of statements reading the state of
+// the connection left by earlier statements, like last_insert_rowid(), after
+// the stream holding that state was lost and replaced by a new one.
+var ErrSessionLost = errors.New("stream lost, last_insert_rowid(), changes() and total_changes() no longer see the earlier statements of the connection")
+
+var sessionFunctionRegexp = regexp.MustCompile(`(?i)\b(last_insert_rowid|changes|total_changes)\s*\(`)
+
+// ReadsSessionState reports whether sql calls a function whose result depends
+// on the earlier statements of the connection, which only hold when it runs on
+// the same server stream as them. Calls in literals and comments are matched
+// too, which errs on the side of keeping the stream.
+func ReadsSessionState(sql string) bool {
+	return sessionFunctionRegexp.MatchString(sql)
+}
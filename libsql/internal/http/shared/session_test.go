@@ -0,0 +1,22 @@
+package shared
+
+import "testing"
+
+func TestReadsSessionState(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{sql: "SELECT last_insert_rowid()", want: true},
+		{sql: "select CHANGES ()", want: true},
+		{sql: "SELECT total_changes()", want: true},
+		{sql: "SELECT changes FROM log", want: false},
+		{sql: "SELECT my_changes(1)", want: false},
+		{sql: "INSERT INTO t VALUES (1)", want: false},
+	}
+	for _, tt := range tests {
+		if got := ReadsSessionState(tt.sql); got != tt.want {
+			t.Errorf("ReadsSessionState(%q) = %t, want %t", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorCode is a SQLite result code. Extended codes carry their primary code in
+// their lowest byte, see https://www.sqlite.org/rescode.html.
+type ErrorCode int
+
+const (
+	CodeError      ErrorCode = 1
+	CodeInternal   ErrorCode = 2
+	CodePerm       ErrorCode = 3
+	CodeAbort      ErrorCode = 4
+	CodeBusy       ErrorCode = 5
+	CodeLocked     ErrorCode = 6
+	CodeNoMem      ErrorCode = 7
+	CodeReadOnly   ErrorCode = 8
+	CodeInterrupt  ErrorCode = 9
+	CodeIOErr      ErrorCode = 10
+	CodeCorrupt    ErrorCode = 11
+	CodeNotFound   ErrorCode = 12
+	CodeFull       ErrorCode = 13
+	CodeCantOpen   ErrorCode = 14
+	CodeProtocol   ErrorCode = 15
+	CodeEmpty      ErrorCode = 16
+	CodeSchema     ErrorCode = 17
+	CodeTooBig     ErrorCode = 18
+	CodeConstraint ErrorCode = 19
+	CodeMismatch   ErrorCode = 20
+	CodeMisuse     ErrorCode = 21
+	CodeNoLFS      ErrorCode = 22
+	CodeAuth       ErrorCode = 23
+	CodeFormat     ErrorCode = 24
+	CodeRange      ErrorCode = 25
+	CodeNotADB     ErrorCode = 26
+
+	CodeBusyRecovery         = CodeBusy | 1<<8
+	CodeBusySnapshot         = CodeBusy | 2<<8
+	CodeBusyTimeout          = CodeBusy | 3<<8
+	CodeLockedSharedCache    = CodeLocked | 1<<8
+	CodeLockedVTab           = CodeLocked | 2<<8
+	CodeReadOnlyRecovery     = CodeReadOnly | 1<<8
+	CodeReadOnlyCantLock     = CodeReadOnly | 2<<8
+	CodeReadOnlyRollback     = CodeReadOnly | 3<<8
+	CodeReadOnlyDBMoved      = CodeReadOnly | 4<<8
+	CodeAbortRollback        = CodeAbort | 2<<8
+	CodeConstraintCheck      = CodeConstraint | 1<<8
+	CodeConstraintCommitHook = CodeConstraint | 2<<8
+	CodeConstraintForeignKey = CodeConstraint | 3<<8
+	CodeConstraintFunction   = CodeConstraint | 4<<8
+	CodeConstraintNotNull    = CodeConstraint | 5<<8
+	CodeConstraintPrimaryKey = CodeConstraint | 6<<8
+	CodeConstraintTrigger    = CodeConstraint | 7<<8
+	CodeConstraintUnique     = CodeConstraint | 8<<8
+	CodeConstraintVTab       = CodeConstraint | 9<<8
+	CodeConstraintRowID      = CodeConstraint | 10<<8
+	CodeConstraintPinned     = CodeConstraint | 11<<8
+	CodeConstraintDataType   = CodeConstraint | 12<<8
+)
+
+// codeNames are the names of the codes, as sent by the server.
+var codeNames = map[ErrorCode]string{
+	CodeError:      "SQLITE_ERROR",
+	CodeInternal:   "SQLITE_INTERNAL",
+	CodePerm:       "SQLITE_PERM",
+	CodeAbort:      "SQLITE_ABORT",
+	CodeBusy:       "SQLITE_BUSY",
+	CodeLocked:     "SQLITE_LOCKED",
+	CodeNoMem:      "SQLITE_NOMEM",
+	CodeReadOnly:   "SQLITE_READONLY",
+	CodeInterrupt:  "SQLITE_INTERRUPT",
+	CodeIOErr:      "SQLITE_IOERR",
+	CodeCorrupt:    "SQLITE_CORRUPT",
+	CodeNotFound:   "SQLITE_NOTFOUND",
+	CodeFull:       "SQLITE_FULL",
+	CodeCantOpen:   "SQLITE_CANTOPEN",
+	CodeProtocol:   "SQLITE_PROTOCOL",
+	CodeEmpty:      "SQLITE_EMPTY",
+	CodeSchema:     "SQLITE_SCHEMA",
+	CodeTooBig:     "SQLITE_TOOBIG",
+	CodeConstraint: "SQLITE_CONSTRAINT",
+	CodeMismatch:   "SQLITE_MISMATCH",
+	CodeMisuse:     "SQLITE_MISUSE",
+	CodeNoLFS:      "SQLITE_NOLFS",
+	CodeAuth:       "SQLITE_AUTH",
+	CodeFormat:     "SQLITE_FORMAT",
+	CodeRange:      "SQLITE_RANGE",
+	CodeNotADB:     "SQLITE_NOTADB",
+
+	CodeBusyRecovery:         "SQLITE_BUSY_RECOVERY",
+	CodeBusySnapshot:         "SQLITE_BUSY_SNAPSHOT",
+	CodeBusyTimeout:          "SQLITE_BUSY_TIMEOUT",
+	CodeLockedSharedCache:    "SQLITE_LOCKED_SHAREDCACHE",
+	CodeLockedVTab:           "SQLITE_LOCKED_VTAB",
+	CodeReadOnlyRecovery:     "SQLITE_READONLY_RECOVERY",
+	CodeReadOnlyCantLock:     "SQLITE_READONLY_CANTLOCK",
+	CodeReadOnlyRollback:     "SQLITE_READONLY_ROLLBACK",
+	CodeReadOnlyDBMoved:      "SQLITE_READONLY_DBMOVED",
+	CodeAbortRollback:        "SQLITE_ABORT_ROLLBACK",
+	CodeConstraintCheck:      "SQLITE_CONSTRAINT_CHECK",
+	CodeConstraintCommitHook: "SQLITE_CONSTRAINT_COMMITHOOK",
+	CodeConstraintForeignKey: "SQLITE_CONSTRAINT_FOREIGNKEY",
+	CodeConstraintFunction:   "SQLITE_CONSTRAINT_FUNCTION",
+	CodeConstraintNotNull:    "SQLITE_CONSTRAINT_NOTNULL",
+	CodeConstraintPrimaryKey: "SQLITE_CONSTRAINT_PRIMARYKEY",
+	CodeConstraintTrigger:    "SQLITE_CONSTRAINT_TRIGGER",
+	CodeConstraintUnique:     "SQLITE_CONSTRAINT_UNIQUE",
+	CodeConstraintVTab:       "SQLITE_CONSTRAINT_VTAB",
+	CodeConstraintRowID:      "SQLITE_CONSTRAINT_ROWID",
+	CodeConstraintPinned:     "SQLITE_CONSTRAINT_PINNED",
+	CodeConstraintDataType:   "SQLITE_CONSTRAINT_DATATYPE",
+}
+
+var codesByName = func() map[string]ErrorCode {
+	codes := make(map[string]ErrorCode, len(codeNames))
+	for code, name := range codeNames {
+		codes[name] = code
+	}
+	return codes
+}()
+
+// ParseErrorCode returns the code named name, like SQLITE_CONSTRAINT_UNIQUE.
+// Extended codes it does not know are reduced to their primary code, and names
+// that are not SQLite codes return 0.
+func ParseErrorCode(name string) ErrorCode {
+	name = strings.ToUpper(name)
+	for {
+		if code, ok := codesByName[name]; ok {
+			return code
+		}
+		idx := strings.LastIndexByte(name, '_')
+		if idx <= len("SQLITE") {
+			return 0
+		}
+		name = name[:idx]
+	}
+}
+
+// Primary returns the primary code of c, which is c itself for primary codes.
+func (c ErrorCode) Primary() ErrorCode {
+	return c & 0xff
+}
+
+func (c ErrorCode) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// SQLiteError is the error of a statement the server failed to execute, with
+// the SQLite result code it reported.
+type SQLiteError struct {
+	// Code is the extended result code of the error, or its primary code when
+	// the server only sent that, zero if the server sent no SQLite code.
+	Code ErrorCode
+	// Name is the code as sent by the server, like SQLITE_CONSTRAINT_UNIQUE,
+	// empty if it sent none.
+	Name    string
+	Message string
+}
+
+// NewSQLiteError returns the error of a statement the server answered with
+// message and the code named code.
+func NewSQLiteError(code, message string) *SQLiteError {
+	return &SQLiteError{Code: ParseErrorCode(code), Name: code, Message: message}
+}
+
+func (e *SQLiteError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,30 @@
+package shared
+
+import "testing"
+
+func TestParseErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		want ErrorCode
+	}{
+		{name: "SQLITE_CONSTRAINT_UNIQUE", want: 2067},
+		{name: "SQLITE_CONSTRAINT", want: CodeConstraint},
+		{name: "sqlite_busy_timeout", want: CodeBusyTimeout},
+		{name: "SQLITE_IOERR_SHORT_READ", want: CodeIOErr},
+		{name: "SQLITE_UNKNOWN", want: 0},
+		{name: "SQLITE", want: 0},
+		{name: "HRANA_PROTO_ERROR", want: 0},
+		{name: "", want: 0},
+	}
+	for _, tt := range tests {
+		if got := ParseErrorCode(tt.name); got != tt.want {
+			t.Errorf("ParseErrorCode(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if CodeBusyTimeout.Primary() != CodeBusy || CodeBusy.Primary() != CodeBusy {
+		t.Error("expected the primary code of SQLITE_BUSY_TIMEOUT to be SQLITE_BUSY")
+	}
+	if got := ErrorCode(4242).String(); got != "ErrorCode(4242)" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -3,9 +3,12 @@ package shared
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/antlr/antlr4/runtime/Go/antlr/v4"
 	"github.com/libsql/sqlite-antlr4-parser/sqliteparser"
@@ -17,6 +20,20 @@ type ParamsInfo struct {
 	PositionalParametersCount int
 }
 
+// NumInput returns the number of arguments needed by the statement, as
+// reported by driver.Stmt.NumInput: the largest positional index, or the
+// number of distinct named parameters. It returns -1, which leaves arguments
+// unchecked by database/sql, for statements mixing both forms.
+func (p ParamsInfo) NumInput() int {
+	if len(p.NamedParameters) == 0 {
+		return p.PositionalParametersCount
+	}
+	if p.PositionalParametersCount == 0 {
+		return len(p.NamedParameters)
+	}
+	return -1
+}
+
 func ParseStatement(sql string) ([]string, []ParamsInfo, error) {
 	stmts, _ := sqliteparserutils.SplitStatement(sql)
 
@@ -31,27 +48,161 @@ func ParseStatement(sql string) ([]string, []ParamsInfo, error) {
 	return stmts, stmtsParams, nil
 }
 
+// SingleStatement returns sql without its trailing semicolons when it contains
+// no other semicolon and so is known to be a single statement without parsing
+// it. Statements with a semicolon in a literal or a comment are not detected.
+func SingleStatement(sql string) (string, bool) {
+	trimmed := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(sql), ";"))
+	if trimmed == "" || strings.Contains(trimmed, ";") {
+		return "", false
+	}
+	return trimmed, true
+}
+
+// SecondStatementOffset returns the byte offset in sql of the statement
+// following the first one, and false if sql holds a single statement.
+func SecondStatementOffset(sql string) (int, bool) {
+	if _, ok := SingleStatement(sql); ok {
+		return 0, false
+	}
+	stmts, _ := sqliteparserutils.SplitStatement(sql)
+	if len(stmts) < 2 {
+		return 0, false
+	}
+	// The statements are substrings of sql, so the second one is found after
+	// the end of the first one.
+	first := strings.Index(sql, stmts[0])
+	if first < 0 {
+		first = 0
+	} else {
+		first += len(stmts[0])
+	}
+	second := strings.Index(sql[first:], stmts[1])
+	if second < 0 {
+		return first, true
+	}
+	return first + second, true
+}
+
 func ParseStatementAndArgs(sql string, args []driver.NamedValue) ([]string, []Params, error) {
-	parameters, err := ConvertArgs(args)
+	stmts, infos, err := ParseStatement(sql)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to generate statement parameter. error: %v", err)
+	}
+	stmtsParams, err := BindArgs(stmts, infos, args)
 	if err != nil {
 		return nil, nil, err
 	}
+	return stmts, stmtsParams, nil
+}
 
-	stmts, _ := sqliteparserutils.SplitStatement(sql)
-
+// BindArgs matches args to the statements and parameters returned by
+// ParseStatement the same way ParseStatementAndArgs does, without parsing the
+// statements again.
+func BindArgs(stmts []string, infos []ParamsInfo, args []driver.NamedValue) ([]Params, error) {
+	parameters, err := ConvertArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckArgs(infos, args); err != nil {
+		return nil, err
+	}
 	stmtsParams := make([]Params, len(stmts))
 	totalParametersAlreadyUsed := 0
 	for idx, stmt := range stmts {
-		stmtParams, err := generateStatementParameters(stmt, parameters, totalParametersAlreadyUsed)
+		stmtParams, err := bindStatementParameters(infos[idx], parameters, totalParametersAlreadyUsed)
 		if err != nil {
-			return nil, nil, fmt.Errorf("fail to generate statement parameter. statement: %s. error: %v", stmt, err)
+			return nil, fmt.Errorf("fail to generate statement parameter. statement: %s. error: %v", stmt, err)
 		}
 		stmtsParams[idx] = stmtParams
 		totalParametersAlreadyUsed += stmtParams.Len()
 	}
-	return stmts, stmtsParams, nil
+	return stmtsParams, nil
+}
+
+// ErrArgs is wrapped by the errors of statements run with arguments that do
+// not match their parameters, which are reported before sending the statements
+// to the server.
+var ErrArgs = errors.New("arguments do not match the statement parameters")
+
+// CheckArgs reports an error wrapping ErrArgs when args do not match the
+// parameters of the statements described by infos: positional arguments have
+// to fill every positional parameter with none left over, and named arguments
+// have to be given for every named parameter and used by one of them. Queries
+// mixing positional and named parameters are not checked, as the arguments
+// they need cannot be determined.
+func CheckArgs(infos []ParamsInfo, args []driver.NamedValue) error {
+	needed := 0
+	names := map[string]bool{}
+	for _, info := range infos {
+		needed += info.PositionalParametersCount
+		for _, name := range info.NamedParameters {
+			names[name] = true
+		}
+	}
+	if needed > 0 && len(names) > 0 {
+		return nil
+	}
+	named := len(args) > 0 && args[0].Name != ""
+	if !named {
+		if len(names) > 0 {
+			if len(args) > 0 {
+				return nil
+			}
+			return fmt.Errorf("%w: missing arguments for the parameters %s", ErrArgs, joinNames(names))
+		}
+		if len(args) != needed {
+			return fmt.Errorf("%w: the query needs %d arguments, got %d", ErrArgs, needed, len(args))
+		}
+		return nil
+	}
+	if needed > 0 {
+		return fmt.Errorf("%w: the query has positional parameters only, got named arguments", ErrArgs)
+	}
+	unknown := map[string]bool{}
+	for _, arg := range args {
+		if names[arg.Name] {
+			delete(names, arg.Name)
+		} else {
+			unknown[arg.Name] = true
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: the query has no parameters named %s", ErrArgs, joinNames(unknown))
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("%w: missing arguments for the parameters %s", ErrArgs, joinNames(names))
+	}
+	return nil
+}
+
+// CheckQueryArgs checks args against the parameters of query the way CheckArgs
+// does, for a query known to hold a single statement. Queries without
+// arguments and parameters are checked without looking at their tokens.
+func CheckQueryArgs(query string, args []driver.NamedValue) error {
+	if len(args) == 0 && !strings.ContainsAny(query, "?:@$") {
+		return nil
+	}
+	nameParams, positionalParamsCount, err := extractParameters(query)
+	if err != nil {
+		return err
+	}
+	return CheckArgs([]ParamsInfo{{nameParams, positionalParamsCount}}, args)
 }
 
+func joinNames(names map[string]bool) string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
+}
+
+// maxParameterIndex is the largest parameter index accepted by SQLite by
+// default (SQLITE_MAX_VARIABLE_NUMBER).
+const maxParameterIndex = 32766
+
 type paramsType int
 
 const (
@@ -147,12 +298,8 @@ func ConvertArgs(args []driver.NamedValue) (Params, error) {
 	return parameters, nil
 }
 
-func generateStatementParameters(stmt string, queryParams Params, positionalParametersOffset int) (Params, error) {
-	nameParams, positionalParamsCount, err := extractParameters(stmt)
-	if err != nil {
-		return Params{}, err
-	}
-
+func bindStatementParameters(info ParamsInfo, queryParams Params, positionalParametersOffset int) (Params, error) {
+	nameParams, positionalParamsCount := info.NamedParameters, info.PositionalParametersCount
 	stmtParams := NewParams(queryParams.Type())
 
 	switch queryParams.Type() {
@@ -177,9 +324,12 @@ func generateStatementParameters(stmt string, queryParams Params, positionalPara
 	return stmtParams, nil
 }
 
+// extractParameters returns the names of the named parameters of stmt and the
+// number of positional arguments it needs. Like SQLite, a plain ? takes the
+// index following the largest index used so far, ?N takes index N, and the
+// number of arguments needed is the largest index.
 func extractParameters(stmt string) (nameParams []string, positionalParamsCount int, err error) {
 	statementStream := antlr.NewInputStream(stmt)
-	sqliteparser.NewSQLiteLexer(statementStream)
 	lexer := sqliteparser.NewSQLiteLexer(statementStream)
 
 	allTokens := lexer.GetAllTokens()
@@ -191,13 +341,18 @@ func extractParameters(stmt string) (nameParams []string, positionalParamsCount
 		if tokenType == sqliteparser.SQLiteLexerBIND_PARAMETER {
 			parameter := token.GetText()
 
-			isPositionalParameter, err := isPositionalParameter(parameter)
+			isPositionalParameter, index, err := parsePositionalParameter(parameter)
 			if err != nil {
 				return []string{}, 0, err
 			}
 
 			if isPositionalParameter {
-				positionalParamsCount++
+				if index == 0 {
+					index = positionalParamsCount + 1
+				}
+				if index > positionalParamsCount {
+					positionalParamsCount = index
+				}
 			} else {
 				paramWithoutPrefix, err := removeParamPrefix(parameter)
 				if err != nil {
@@ -217,19 +372,25 @@ func extractParameters(stmt string) (nameParams []string, positionalParamsCount
 	return nameParams, positionalParamsCount, nil
 }
 
-func isPositionalParameter(param string) (ok bool, err error) {
-	re := regexp.MustCompile(`\?([0-9]*).*`)
-	match := re.FindSubmatch([]byte(param))
+var positionalParameterRegexp = regexp.MustCompile(`^\?([0-9]*)$`)
+
+// parsePositionalParameter reports whether param is a positional parameter and
+// returns its index, or 0 for a parameter without an index.
+func parsePositionalParameter(param string) (ok bool, index int, err error) {
+	match := positionalParameterRegexp.FindStringSubmatch(param)
 	if match == nil {
-		return false, nil
+		return false, 0, nil
 	}
 
-	posS := string(match[1])
-	if posS == "" {
-		return true, nil
+	if match[1] == "" {
+		return true, 0, nil
 	}
 
-	return true, fmt.Errorf("unsuppoted positional parameter. This driver does not accept positional parameters with indexes (like ?<number>)")
+	index, err = strconv.Atoi(match[1])
+	if err != nil || index < 1 || index > maxParameterIndex {
+		return true, 0, fmt.Errorf("invalid positional parameter %s. Indexes must be between 1 and %d", param, maxParameterIndex)
+	}
+	return true, index, nil
 }
 
 func removeParamPrefix(paramName string) (string, error) {
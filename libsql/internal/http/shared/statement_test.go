@@ -1,9 +1,13 @@
 package shared
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -49,10 +53,28 @@ func TestExtractParameters(t *testing.T) {
 		},
 		{
 			name:                  "PositionalParamsWithIndexes",
-			value:                 "select ? from ?1",
+			value:                 "select ?1, ?3 from ?2",
+			nameParams:            []string{},
+			positionalParamsCount: 3,
+		},
+		{
+			name:                  "RepeatedPositionalParamsWithIndexes",
+			value:                 "select ?2, ?2",
+			nameParams:            []string{},
+			positionalParamsCount: 2,
+		},
+		{
+			name:                  "MixedPositionalParams",
+			value:                 "select ?2, ?, ?1",
+			nameParams:            []string{},
+			positionalParamsCount: 3,
+		},
+		{
+			name:                  "InvalidPositionalParamIndex",
+			value:                 "select ?0",
 			nameParams:            []string{},
 			positionalParamsCount: 0,
-			err:                   fmt.Errorf("unsuppoted positional parameter. This driver does not accept positional parameters with indexes (like ?<number>)"),
+			err:                   fmt.Errorf("invalid positional parameter ?0. Indexes must be between 1 and 32766"),
 		},
 		{
 			name:                  "MixedParams",
@@ -77,9 +99,187 @@ func TestExtractParameters(t *testing.T) {
 			if !reflect.DeepEqual(gotPositionalParamsCount, tt.positionalParamsCount) {
 				t.Errorf("got positionalParams %#v, want %#v", gotPositionalParamsCount, tt.positionalParamsCount)
 			}
-			if !reflect.DeepEqual(gotPositionalParamsCount, tt.positionalParamsCount) {
+			if !reflect.DeepEqual(gotErr, tt.err) {
 				t.Errorf("got err %v, want %v", gotErr, tt.err)
 			}
 		})
 	}
 }
+
+func TestParseStatementAndArgsWithIndexes(t *testing.T) {
+	args := []driver.NamedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Ordinal: 2, Value: int64(2)},
+		{Ordinal: 3, Value: int64(3)},
+	}
+	stmts, params, err := ParseStatementAndArgs("select ?2, ?1; select ?1", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 2 {
+		t.Fatalf("got %d statements, want 2", len(stmts))
+	}
+	if !reflect.DeepEqual(params[0].Positional(), []any{int64(1), int64(2)}) {
+		t.Errorf("got %v for the first statement", params[0].Positional())
+	}
+	if !reflect.DeepEqual(params[1].Positional(), []any{int64(3)}) {
+		t.Errorf("got %v for the second statement", params[1].Positional())
+	}
+}
+
+func TestSingleStatement(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+		ok   bool
+	}{
+		{sql: "BEGIN", want: "BEGIN", ok: true},
+		{sql: " COMMIT; \n", want: "COMMIT", ok: true},
+		{sql: "SELECT 1 -- comment", want: "SELECT 1 -- comment", ok: true},
+		{sql: "SELECT 1; SELECT 2", ok: false},
+		{sql: "SELECT ';'", ok: false},
+		{sql: " ; ", ok: false},
+		{sql: "", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := SingleStatement(tt.sql)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("SingleStatement(%#v) = %#v, %t, want %#v, %t", tt.sql, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSecondStatementOffset(t *testing.T) {
+	tests := []struct {
+		sql    string
+		offset int
+		ok     bool
+	}{
+		{sql: "SELECT 1", ok: false},
+		{sql: "SELECT 1;", ok: false},
+		{sql: "SELECT ';'; ", ok: false},
+		{sql: "SELECT 1; SELECT 2", offset: 10, ok: true},
+		{sql: "SELECT ';';\n-- next\nDELETE FROM t", offset: 20, ok: true},
+		{sql: "CREATE TRIGGER tr AFTER INSERT ON t BEGIN DELETE FROM u; END", ok: false},
+	}
+	for _, tt := range tests {
+		offset, ok := SecondStatementOffset(tt.sql)
+		if offset != tt.offset || ok != tt.ok {
+			t.Errorf("SecondStatementOffset(%#v) = %d, %t, want %d, %t", tt.sql, offset, ok, tt.offset, tt.ok)
+		}
+	}
+}
+
+func TestNumInput(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want int
+	}{
+		{sql: "SELECT 1", want: 0},
+		{sql: "SELECT ?, ?", want: 2},
+		{sql: "SELECT ?3, ?1", want: 3},
+		{sql: "SELECT '?', \"?\" /* ? */ -- ?\n, ?", want: 1},
+		{sql: "SELECT :a, @b, $a, :a", want: 2},
+		{sql: "SELECT :a, ?", want: -1},
+	}
+	for _, tt := range tests {
+		_, infos, err := ParseStatement(tt.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := infos[0].NumInput(); got != tt.want {
+			t.Errorf("NumInput of %#v = %d, want %d", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestBeginStatement(t *testing.T) {
+	tests := []struct {
+		opts    driver.TxOptions
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{opts: driver.TxOptions{}, want: "BEGIN"},
+		{opts: driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelReadCommitted)}, want: "BEGIN"},
+		{opts: driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable)}, want: "BEGIN IMMEDIATE"},
+		{opts: driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable), ReadOnly: true}, want: "BEGIN"},
+		{opts: driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelLinearizable)}, want: "BEGIN EXCLUSIVE"},
+		{opts: driver.TxOptions{ReadOnly: true}, want: "BEGIN"},
+		{opts: driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelWriteCommitted)}, wantErr: true},
+		{opts: driver.TxOptions{Isolation: 42}, wantErr: true},
+		{mode: "DEFERRED", want: "BEGIN DEFERRED"},
+		{mode: "IMMEDIATE", want: "BEGIN IMMEDIATE"},
+		{mode: "EXCLUSIVE", want: "BEGIN EXCLUSIVE"},
+		{opts: driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable)}, mode: "DEFERRED", want: "BEGIN DEFERRED"},
+		{opts: driver.TxOptions{ReadOnly: true}, mode: "DEFERRED", want: "BEGIN DEFERRED"},
+		{opts: driver.TxOptions{ReadOnly: true}, mode: "IMMEDIATE", wantErr: true},
+		{opts: driver.TxOptions{Isolation: 42}, mode: "IMMEDIATE", wantErr: true},
+		{mode: "EAGER", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := BeginStatement(tt.opts, tt.mode)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("BeginStatement(%+v, %q) = %#v, %v, want %#v", tt.opts, tt.mode, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	positional := func(values ...any) []driver.NamedValue {
+		args := make([]driver.NamedValue, len(values))
+		for idx, value := range values {
+			args[idx] = driver.NamedValue{Ordinal: idx + 1, Value: value}
+		}
+		return args
+	}
+	named := func(names ...string) []driver.NamedValue {
+		args := make([]driver.NamedValue, len(names))
+		for idx, name := range names {
+			args[idx] = driver.NamedValue{Name: name, Ordinal: idx + 1, Value: int64(idx)}
+		}
+		return args
+	}
+	tests := []struct {
+		sql     string
+		args    []driver.NamedValue
+		wantErr string
+	}{
+		{sql: "SELECT 1"},
+		{sql: "SELECT ?, ?", args: positional(1, 2)},
+		{sql: "SELECT ?3, ?1", args: positional(1, 2, 3)},
+		{sql: "SELECT ?; SELECT ?, ?", args: positional(1, 2, 3)},
+		{sql: "SELECT :a, @b, $a", args: named("b", "a")},
+		{sql: "SELECT :a; SELECT :a, :b", args: named("a", "b")},
+		{sql: "SELECT :a, ?", args: positional(1)},
+		{sql: "SELECT :a, ?", args: named("b")},
+		{sql: "SELECT :a", args: positional(1)},
+		{sql: "SELECT ?, ?", args: positional(1), wantErr: "the query needs 2 arguments, got 1"},
+		{sql: "SELECT ?2", args: positional(1, 2, 3), wantErr: "the query needs 2 arguments, got 3"},
+		{sql: "SELECT ?; SELECT ?", args: positional(1), wantErr: "the query needs 2 arguments, got 1"},
+		{sql: "SELECT 1", args: positional(1), wantErr: "the query needs 0 arguments, got 1"},
+		{sql: "SELECT ?", args: named("a"), wantErr: "positional parameters only"},
+		{sql: "SELECT :a, :b", args: named("a"), wantErr: "missing arguments for the parameters b"},
+		{sql: "SELECT :b, :a", wantErr: "missing arguments for the parameters a, b"},
+		{sql: "SELECT :a", args: named("a", "c", "b"), wantErr: "the query has no parameters named b, c"},
+	}
+	for _, tt := range tests {
+		_, infos, err := ParseStatement(tt.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CheckArgs(infos, tt.args)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%#v: unexpected error %v", tt.sql, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrArgs) || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%#v: got error %v, want %#v", tt.sql, err, tt.wantErr)
+		}
+		if _, _, err := ParseStatementAndArgs(tt.sql, tt.args); !errors.Is(err, ErrArgs) {
+			t.Errorf("%#v: got error %v from ParseStatementAndArgs, want ErrArgs", tt.sql, err)
+		}
+	}
+}
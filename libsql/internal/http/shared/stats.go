@@ -0,0 +1,27 @@
+package shared
+
+import "time"
+
+// Stats are the execution statistics servers report with the results of
+// statements, zero when they report none.
+type Stats struct {
+	// RowsRead is the number of rows read to execute the statements, which
+	// includes the rows scanned but not returned.
+	RowsRead uint64
+	// RowsWritten is the number of rows inserted, updated or deleted.
+	RowsWritten uint64
+	// QueryDuration is the time the server spent executing the statements.
+	QueryDuration time.Duration
+}
+
+// Add adds the statistics of other to s.
+func (s *Stats) Add(other Stats) {
+	s.RowsRead += other.RowsRead
+	s.RowsWritten += other.RowsWritten
+	s.QueryDuration += other.QueryDuration
+}
+
+// DurationMs converts a duration in milliseconds, as reported by servers.
+func DurationMs(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,46 @@
+package shared
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// BeginStatement returns the statement starting a transaction with opts and
+// mode, the DEFERRED, IMMEDIATE or EXCLUSIVE mode asked for with the context
+// of the transaction, empty if none was.
+//
+// SQLite transactions are always serializable: without a mode, levels up to
+// snapshot isolation use a deferred transaction, LevelSerializable takes the
+// write lock at once with BEGIN IMMEDIATE, so a transaction that reads before
+// writing cannot fail with SQLITE_BUSY when it starts writing, and
+// LevelLinearizable uses BEGIN EXCLUSIVE. Read-only transactions are always
+// deferred, and cannot use another mode.
+func BeginStatement(opts driver.TxOptions, mode string) (string, error) {
+	begin := ""
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSnapshot:
+		begin = "BEGIN"
+	case sql.LevelSerializable:
+		begin = "BEGIN IMMEDIATE"
+	case sql.LevelLinearizable:
+		begin = "BEGIN EXCLUSIVE"
+	default:
+		return "", fmt.Errorf("isolation level %s is not supported, SQLite transactions are serializable", sql.IsolationLevel(opts.Isolation))
+	}
+	switch mode {
+	case "":
+		if opts.ReadOnly {
+			return "BEGIN", nil
+		}
+		return begin, nil
+	case "DEFERRED":
+		return "BEGIN DEFERRED", nil
+	case "IMMEDIATE", "EXCLUSIVE":
+		if opts.ReadOnly {
+			return "", fmt.Errorf("a read-only transaction cannot begin in %s mode", mode)
+		}
+		return "BEGIN " + mode, nil
+	}
+	return "", fmt.Errorf("unknown transaction mode %q, valid modes are DEFERRED, IMMEDIATE and EXCLUSIVE", mode)
+}
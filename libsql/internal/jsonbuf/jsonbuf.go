@@ -0,0 +1,103 @@
+// Package jsonbuf pools the buffers that requests are encoded into and
+// responses are read into, so a round trip does not allocate buffers of the
+// size of its payloads.
+package jsonbuf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// maxPooledSize is the capacity above which buffers are left to the garbage
+// collector, so that a single large result does not stay pinned in the pool.
+const maxPooledSize = 1 << 20
+
+var pool = sync.Pool{New: func() any { return new(Buffer) }}
+
+// Buffer is a pooled buffer. It goes back to the pool once Release was called
+// and every body returned by Body was closed.
+type Buffer struct {
+	bytes.Buffer
+	refs int32
+}
+
+// Get returns an empty buffer from the pool, to be released by the caller.
+func Get() *Buffer {
+	b := pool.Get().(*Buffer)
+	b.refs = 1
+	return b
+}
+
+// Encode returns a buffer holding the JSON encoding of v.
+func Encode(v any) (*Buffer, error) {
+	b := Get()
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		b.Release()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Read returns a buffer holding what is left of r.
+func Read(r io.Reader) (*Buffer, error) {
+	b := Get()
+	if _, err := b.ReadFrom(r); err != nil {
+		b.Release()
+		return nil, err
+	}
+	return b, nil
+}
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// Gzip returns a buffer holding the content of b compressed with gzip.
+func (b *Buffer) Gzip() (*Buffer, error) {
+	compressed := Get()
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(compressed)
+	if _, err := w.Write(b.Bytes()); err != nil {
+		compressed.Release()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		compressed.Release()
+		return nil, err
+	}
+	return compressed, nil
+}
+
+// Body returns a request body reading the content of the buffer. The HTTP
+// transport may still read a request body after the round trip returned, so
+// the buffer is only reused once the body is closed.
+func (b *Buffer) Body() io.ReadCloser {
+	atomic.AddInt32(&b.refs, 1)
+	return &body{Reader: bytes.NewReader(b.Bytes()), buf: b}
+}
+
+// Release gives back the reference of the caller of Get, Encode or Read.
+func (b *Buffer) Release() {
+	if atomic.AddInt32(&b.refs, -1) > 0 {
+		return
+	}
+	if b.Cap() > maxPooledSize {
+		return
+	}
+	b.Reset()
+	pool.Put(b)
+}
+
+type body struct {
+	*bytes.Reader
+	buf  *Buffer
+	once sync.Once
+}
+
+func (b *body) Close() error {
+	b.once.Do(b.buf.Release)
+	return nil
+}
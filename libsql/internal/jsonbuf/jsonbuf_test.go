@@ -0,0 +1,45 @@
+package jsonbuf
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	b, err := Encode(map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Release()
+	if got := b.String(); got != "{\"a\":1}\n" {
+		t.Errorf("got %#v", got)
+	}
+	if _, err := Encode(func() {}); err == nil {
+		t.Error("expected unsupported value to fail")
+	}
+}
+
+func TestBodyKeepsBuffer(t *testing.T) {
+	b, err := Read(strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := b.Body()
+	b.Release()
+	if b.refs != 1 {
+		t.Fatalf("got %d references, want the open body to hold the buffer", b.refs)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "payload" {
+		t.Errorf("got %#v", string(content))
+	}
+	body.Close()
+	body.Close()
+	if b.refs != 0 || b.Len() != 0 {
+		t.Errorf("got %d references and %d bytes, want the buffer to be released once", b.refs, b.Len())
+	}
+}
@@ -0,0 +1,62 @@
+// Package limits bounds the size of the responses read by the transports, so
+// a query returning a huge result fails instead of exhausting memory.
+package limits
+
+import (
+	"fmt"
+	"io"
+)
+
+// Error is returned when a response exceeds MaxRows rows or MaxBytes bytes.
+// Only the limit that was exceeded is set.
+type Error struct {
+	MaxRows  int
+	MaxBytes int64
+}
+
+func (e *Error) Error() string {
+	if e.MaxRows > 0 {
+		return fmt.Sprintf("result has more than %d rows", e.MaxRows)
+	}
+	return fmt.Sprintf("response is larger than %d bytes", e.MaxBytes)
+}
+
+// Rows returns an *Error if count exceeds max. A zero max disables the limit.
+func Rows(count, max int) error {
+	if max > 0 && count > max {
+		return &Error{MaxRows: max}
+	}
+	return nil
+}
+
+// Reader returns a reader failing with an *Error once more than max bytes
+// were read from r. A zero max returns r.
+func Reader(r io.Reader, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &reader{r: r, left: max, max: max}
+}
+
+type reader struct {
+	r    io.Reader
+	left int64
+	max  int64
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.left < 0 {
+		return 0, &Error{MaxBytes: r.max}
+	}
+	// Reading one byte more than allowed tells a response of exactly max
+	// bytes from a larger one.
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+	n, err := r.r.Read(p)
+	r.left -= int64(n)
+	if r.left < 0 {
+		return n + int(r.left), &Error{MaxBytes: r.max}
+	}
+	return n, err
+}
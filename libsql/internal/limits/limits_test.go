@@ -0,0 +1,53 @@
+package limits
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		content string
+		max     int64
+		wantErr bool
+	}{
+		{content: "12345", max: 0},
+		{content: "12345", max: 5},
+		{content: "12345", max: 10},
+		{content: "123456", max: 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		for _, r := range []io.Reader{strings.NewReader(tt.content), iotest.OneByteReader(strings.NewReader(tt.content))} {
+			got, err := io.ReadAll(Reader(r, tt.max))
+			var limitErr *Error
+			if tt.wantErr {
+				if !errors.As(err, &limitErr) || limitErr.MaxBytes != tt.max {
+					t.Errorf("got %v reading %#v with a limit of %d, want a limit error", err, tt.content, tt.max)
+				}
+				if int64(len(got)) > tt.max {
+					t.Errorf("read %d bytes beyond the limit of %d", len(got), tt.max)
+				}
+				continue
+			}
+			if err != nil || string(got) != tt.content {
+				t.Errorf("got %#v, %v reading %#v with a limit of %d", string(got), err, tt.content, tt.max)
+			}
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	if err := Rows(10, 0); err != nil {
+		t.Error(err)
+	}
+	if err := Rows(10, 10); err != nil {
+		t.Error(err)
+	}
+	var limitErr *Error
+	if err := Rows(11, 10); !errors.As(err, &limitErr) || limitErr.MaxRows != 10 {
+		t.Errorf("got %v, want a limit error", err)
+	}
+}
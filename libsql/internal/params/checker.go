@@ -0,0 +1,148 @@
+package params
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+type TimeFormat int
+
+const (
+	// TimeFormatRFC3339 encodes time.Time as RFC3339 text with nanoseconds.
+	TimeFormatRFC3339 TimeFormat = iota
+	// TimeFormatUnix encodes time.Time as an integer number of seconds since the epoch.
+	TimeFormatUnix
+	// TimeFormatSQLite encodes time.Time as UTC text in the format of the date
+	// and time functions of SQLite, YYYY-MM-DD HH:MM:SS, with the fraction of
+	// the second if it has one.
+	TimeFormatSQLite
+	// TimeFormatUnixMilli encodes time.Time as an integer number of
+	// milliseconds since the epoch.
+	TimeFormatUnixMilli
+)
+
+// SQLiteTimeLayout is the layout of TimeFormatSQLite.
+const SQLiteTimeLayout = "2006-01-02 15:04:05.999999999"
+
+func ParseTimeFormat(s string) (TimeFormat, error) {
+	switch s {
+	case "", "rfc3339":
+		return TimeFormatRFC3339, nil
+	case "unix":
+		return TimeFormatUnix, nil
+	case "sqlite":
+		return TimeFormatSQLite, nil
+	case "unixmilli":
+		return TimeFormatUnixMilli, nil
+	default:
+		return TimeFormatRFC3339, fmt.Errorf("unknown time format %#v. Valid values are rfc3339, sqlite, unix and unixmilli", s)
+	}
+}
+
+// Checker converts arguments into the values supported by the libsql wire
+// protocols: nil, int64, float64, string and []byte.
+type Checker struct {
+	TimeFormat TimeFormat
+	// BigIntText binds *big.Int arguments as their decimal text, they are
+	// rejected otherwise.
+	BigIntText bool
+}
+
+func (c Checker) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := c.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = v
+	return nil
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+func (c Checker) ConvertValue(v any) (driver.Value, error) {
+	switch v := v.(type) {
+	case nil, int64, float64, string:
+		return v, nil
+	case []byte:
+		// A nil slice binds NULL and an empty one a zero-length blob, like
+		// mattn/go-sqlite3 does.
+		if v == nil {
+			return nil, nil
+		}
+		return v, nil
+	case json.RawMessage:
+		return string(v), nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case time.Time:
+		return c.convertTime(v), nil
+	case *big.Int:
+		return c.convertBigInt(v)
+	case big.Int:
+		return c.convertBigInt(&v)
+	case driver.Valuer:
+		// Mirror database/sql and treat nil pointers implementing Valuer on a
+		// value receiver as NULL instead of panicking.
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() &&
+			rv.Type().Elem().Implements(valuerType) {
+			return nil, nil
+		}
+		value, err := v.Value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := value.(driver.Valuer); ok {
+			return nil, fmt.Errorf("driver.Valuer %T returned another driver.Valuer", v)
+		}
+		return c.ConvertValue(value)
+	}
+
+	// The default converter rejects large unsigned integers with a message
+	// that does not say why.
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u > math.MaxInt64 {
+			return nil, fmt.Errorf("unsigned integer %d of type %T overflows the signed 64-bit integers of SQLite", u, v)
+		}
+	}
+
+	value, err := driver.DefaultParameterConverter.ConvertValue(v)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(value) == reflect.TypeOf(v) {
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+	return c.ConvertValue(value)
+}
+
+func (c Checker) convertBigInt(v *big.Int) (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if !c.BigIntText {
+		return nil, fmt.Errorf("unsupported type *big.Int, bind it as text with WithBigIntText")
+	}
+	return v.String(), nil
+}
+
+func (c Checker) convertTime(t time.Time) driver.Value {
+	switch c.TimeFormat {
+	case TimeFormatUnix:
+		return t.Unix()
+	case TimeFormatUnixMilli:
+		return t.UnixMilli()
+	case TimeFormatSQLite:
+		return t.UTC().Format(SQLiteTimeLayout)
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
@@ -0,0 +1,91 @@
+package params
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type point struct{ x, y int }
+
+type id uint64
+
+func (p point) Value() (driver.Value, error) {
+	return json.Marshal([]int{p.x, p.y})
+}
+
+func TestCheckerConvertValue(t *testing.T) {
+	ts := time.Date(2023, 8, 1, 12, 30, 0, 500, time.UTC)
+	var nilPoint *point
+	tests := []struct {
+		name    string
+		checker Checker
+		value   any
+		want    driver.Value
+		wantErr bool
+	}{
+		{name: "nil", value: nil, want: nil},
+		{name: "int", value: 42, want: int64(42)},
+		{name: "uint8", value: uint8(7), want: int64(7)},
+		{name: "float32", value: float32(1.5), want: float64(1.5)},
+		{name: "string", value: "foo", want: "foo"},
+		{name: "bytes", value: []byte("bar"), want: []byte("bar")},
+		{name: "nilBytes", value: []byte(nil), want: nil},
+		{name: "true", value: true, want: int64(1)},
+		{name: "false", value: false, want: int64(0)},
+		{name: "rawJSON", value: json.RawMessage(`{"a":1}`), want: `{"a":1}`},
+		{name: "timeRFC3339", value: ts, want: "2023-08-01T12:30:00.0000005Z"},
+		{name: "timeUnix", checker: Checker{TimeFormat: TimeFormatUnix}, value: ts, want: ts.Unix()},
+		{name: "timeUnixMilli", checker: Checker{TimeFormat: TimeFormatUnixMilli}, value: ts.Add(250 * time.Millisecond), want: ts.UnixMilli() + 250},
+		{name: "timeSQLite", checker: Checker{TimeFormat: TimeFormatSQLite}, value: ts.Add(-500), want: "2023-08-01 12:30:00"},
+		{name: "timeSQLiteFraction", checker: Checker{TimeFormat: TimeFormatSQLite}, value: ts.In(time.FixedZone("", 3600)), want: "2023-08-01 12:30:00.0000005"},
+		{name: "valuer", value: point{1, 2}, want: []byte("[1,2]")},
+		{name: "nilValuer", value: nilPoint, want: nil},
+		{name: "nullString", value: sql.NullString{String: "a", Valid: true}, want: "a"},
+		{name: "nullInt", value: sql.NullInt64{}, want: nil},
+		{name: "pointer", value: &ts, want: "2023-08-01T12:30:00.0000005Z"},
+		{name: "uint64", value: uint64(math.MaxInt64), want: int64(math.MaxInt64)},
+		{name: "uint64Overflow", value: uint64(math.MaxInt64) + 1, wantErr: true},
+		{name: "uintOverflow", value: uint(math.MaxUint), wantErr: true},
+		{name: "namedUintOverflow", value: id(math.MaxUint64), wantErr: true},
+		{name: "bigInt", value: big.NewInt(1), wantErr: true},
+		{name: "bigIntText", checker: Checker{BigIntText: true}, value: new(big.Int).Lsh(big.NewInt(1), 70), want: "1180591620717411303424"},
+		{name: "bigIntValue", checker: Checker{BigIntText: true}, value: *big.NewInt(-5), want: "-5"},
+		{name: "nilBigInt", value: (*big.Int)(nil), want: nil},
+		{name: "unsupported", value: struct{}{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.checker.ConvertValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeFormat(t *testing.T) {
+	if f, err := ParseTimeFormat(""); err != nil || f != TimeFormatRFC3339 {
+		t.Errorf("got %v, %v for empty time format", f, err)
+	}
+	if f, err := ParseTimeFormat("unix"); err != nil || f != TimeFormatUnix {
+		t.Errorf("got %v, %v for unix time format", f, err)
+	}
+	if f, err := ParseTimeFormat("sqlite"); err != nil || f != TimeFormatSQLite {
+		t.Errorf("got %v, %v for sqlite time format", f, err)
+	}
+	if f, err := ParseTimeFormat("unixmilli"); err != nil || f != TimeFormatUnixMilli {
+		t.Errorf("got %v, %v for unixmilli time format", f, err)
+	}
+	if _, err := ParseTimeFormat("bogus"); err == nil {
+		t.Error("expected error for unknown time format")
+	}
+}
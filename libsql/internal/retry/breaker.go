@@ -0,0 +1,72 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrUnreachable is wrapped by the errors of requests that did not reach the
+// server because no connection to it could be opened, and of the requests
+// short-circuited by an open Breaker.
+var ErrUnreachable = errors.New("database is unreachable")
+
+// Breaker short-circuits the requests to a server for a cool-down period once
+// a number of consecutive attempts failed to connect to it, so that queries
+// fail at once while the server is unreachable instead of each waiting for
+// the dial to fail. Once the cool-down is over, requests are sent again and the
+// first one that fails to connect opens the breaker anew.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	lastErr   error
+}
+
+// NewBreaker returns a Breaker opening after threshold consecutive connection
+// failures, for cooldown.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow returns an error wrapping ErrUnreachable while the breaker is open,
+// and nil otherwise. A nil Breaker allows every request.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wait := b.openUntil.Sub(b.now())
+	if wait <= 0 {
+		return nil
+	}
+	return Mark(fmt.Errorf("%w: %d consecutive connection failures, next attempt in %v: %s",
+		ErrUnreachable, b.failures, wait.Round(time.Millisecond), b.lastErr))
+}
+
+// Record counts err as a failure if it proves the server could not be
+// reached, see Unsent, and closes the breaker if err is nil. Other errors
+// leave it unchanged.
+func (b *Breaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case err == nil:
+		b.failures, b.openUntil, b.lastErr = 0, time.Time{}, nil
+	case Unsent(err):
+		b.failures++
+		b.lastErr = err
+		if b.failures >= b.threshold {
+			b.openUntil = b.now().Add(b.cooldown)
+		}
+	}
+}
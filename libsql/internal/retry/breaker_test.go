@@ -0,0 +1,50 @@
+package retry
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := NewBreaker(2, time.Second)
+	b.now = func() time.Time { return now }
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	b.Record(refused)
+	b.Record(errors.New("syntax error"))
+	if err := b.Allow(); err != nil {
+		t.Fatalf("got %v, want the breaker closed below the threshold", err)
+	}
+	b.Record(refused)
+	err := b.Allow()
+	if !errors.Is(err, ErrUnreachable) || !Is(err) {
+		t.Fatalf("got %v, want a retryable unreachable error", err)
+	}
+	if want := "database is unreachable: 2 consecutive connection failures, next attempt in 1s: dial tcp: connection refused"; err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+
+	now = now.Add(time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("got %v, want the breaker to let a request through after the cool-down", err)
+	}
+	b.Record(refused)
+	if err := b.Allow(); err == nil {
+		t.Fatal("expected the breaker to open again on the next failure")
+	}
+	now = now.Add(time.Second)
+	b.Record(nil)
+	b.Record(refused)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("got %v, want a success to reset the failures", err)
+	}
+
+	var nilBreaker *Breaker
+	nilBreaker.Record(refused)
+	if err := nilBreaker.Allow(); err != nil {
+		t.Errorf("got %v, want a nil breaker to allow every request", err)
+	}
+}
@@ -0,0 +1,95 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitError is returned for requests answered with HTTP 429 Too Many
+// Requests. The server did not run them, so they can be sent again once the
+// delay it asked for passed.
+type RateLimitError struct {
+	// RetryAfter is the delay of the Retry-After header of the response, zero
+	// if it had none.
+	RetryAfter time.Duration
+	// Err is the error reported in the body of the response.
+	Err error
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited, retry after %s: %s", e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("rate limited: %s", e.Err)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// NewRateLimitError returns the error of a 429 response with header.
+func NewRateLimitError(header http.Header, err error) *RateLimitError {
+	return &RateLimitError{RetryAfter: parseRetryAfter(header.Get("Retry-After"), time.Now()), Err: err}
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, which holds either
+// a number of seconds or an HTTP date, or zero if it is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 || seconds > int64(24*time.Hour/time.Second) {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// maxBackoff bounds the wait before retrying a rate limited request without
+// Retry-After.
+const maxBackoff = 5 * time.Second
+
+// WaitRateLimit waits before sending again the request that failed with err on
+// the given attempt, counted from zero, if err is a *RateLimitError. The wait
+// is the Retry-After delay of the response, or grows from 100ms with every
+// attempt if the server did not ask for one. It reports whether the request
+// should be sent again, which is not the case when the wait would outlast the
+// deadline of ctx.
+func WaitRateLimit(ctx context.Context, err error, attempt int) bool {
+	var rateLimit *RateLimitError
+	if !errors.As(err, &rateLimit) {
+		return false
+	}
+	wait := rateLimit.RetryAfter
+	if wait <= 0 {
+		wait = maxBackoff
+		if attempt < 6 {
+			wait = 100 * time.Millisecond << attempt
+		}
+		if wait > maxBackoff {
+			wait = maxBackoff
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,57 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "3", want: 3 * time.Second},
+		{value: " 120 ", want: 2 * time.Minute},
+		{value: "0", want: 0},
+		{value: "-1", want: 0},
+		{value: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWaitRateLimit(t *testing.T) {
+	rateLimited := NewRateLimitError(http.Header{"Retry-After": {"60"}}, errors.New("too many requests"))
+	if !Is(rateLimited) {
+		t.Error("expected rate limit errors to be retryable")
+	}
+	if rateLimited.Error() != "rate limited, retry after 1m0s: too many requests" {
+		t.Errorf("got %q", rateLimited.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if WaitRateLimit(ctx, rateLimited, 0) {
+		t.Error("expected no retry when the server asks to wait past the deadline")
+	}
+	if WaitRateLimit(ctx, errors.New("other"), 0) {
+		t.Error("expected no retry for other errors")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("gave up after %s, want at once", elapsed)
+	}
+	if !WaitRateLimit(ctx, NewRateLimitError(http.Header{}, errors.New("too many requests")), 0) {
+		t.Error("expected a retry after the backoff when the server gives no delay")
+	}
+}
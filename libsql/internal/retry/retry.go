@@ -0,0 +1,82 @@
+// Package retry classifies the errors of the transports into the ones worth
+// retrying, like network failures, overloaded servers and expired streams, and
+// the ones that would fail again, like SQL errors and rejected requests.
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// Mark wraps err so that Is reports it as retryable.
+func Mark(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err}
+}
+
+// Is reports whether the operation that failed with err may succeed if it is
+// tried again. Errors of canceled contexts and expired deadlines are not
+// retryable, the caller gave up on the operation.
+func Is(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var r *retryableError
+	if errors.As(err, &r) {
+		return true
+	}
+	var rateLimit *RateLimitError
+	if errors.As(err, &rateLimit) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Status reports whether a request answered with the HTTP status code may
+// succeed later: when the server is overloaded, unavailable or failed
+// unexpectedly. Other 4xx and 5xx statuses reject the request itself.
+func Status(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Unsent reports whether err proves that the request never reached the
+// server, because no connection to it could be opened. Such requests can be
+// sent again on another connection without running twice, which is what
+// driver.ErrBadConn makes database/sql do.
+func Unsent(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+// BadConn wraps err, which must be unsent, into driver.ErrBadConn and
+// ErrUnreachable.
+func BadConn(err error) error {
+	return fmt.Errorf("%w: %w: %s", driver.ErrBadConn, ErrUnreachable, err.Error())
+}
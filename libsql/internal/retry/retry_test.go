@@ -0,0 +1,49 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestIs(t *testing.T) {
+	dialErr := &url.Error{Op: "Post", URL: "http://db", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	resetErr := &url.Error{Op: "Post", URL: "http://db", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+		unsent    bool
+	}{
+		{name: "nil"},
+		{name: "sql error", err: errors.New("SQLITE_CONSTRAINT: UNIQUE constraint failed")},
+		{name: "bad conn", err: fmt.Errorf("stream is closed: %w", driver.ErrBadConn), retryable: true},
+		{name: "marked", err: fmt.Errorf("failed to execute: %w", Mark(errors.New("service unavailable"))), retryable: true},
+		{name: "dial", err: dialErr, retryable: true, unsent: true},
+		{name: "reset", err: resetErr, retryable: true},
+		{name: "canceled", err: &url.Error{Op: "Post", URL: "http://db", Err: context.Canceled}},
+		{name: "deadline", err: fmt.Errorf("failed: %w", context.DeadlineExceeded)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Is(tt.err); got != tt.retryable {
+				t.Errorf("Is(%v) = %t, want %t", tt.err, got, tt.retryable)
+			}
+			if got := Unsent(tt.err); got != tt.unsent {
+				t.Errorf("Unsent(%v) = %t, want %t", tt.err, got, tt.unsent)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	for code, want := range map[int]bool{400: false, 401: false, 404: false, 429: true, 500: true, 501: false, 502: true, 503: true, 504: true} {
+		if got := Status(code); got != want {
+			t.Errorf("Status(%d) = %t, want %t", code, got, want)
+		}
+	}
+}
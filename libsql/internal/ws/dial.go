@@ -0,0 +1,21 @@
+//go:build !js
+
+package ws
+
+import (
+	"net/http"
+
+	"nhooyr.io/websocket"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+)
+
+// dialOptions returns the options of the handshake of a websocket, sent with
+// the headers and the HTTP client of cfg.
+func dialOptions(cfg *config.Config) *websocket.DialOptions {
+	return &websocket.DialOptions{
+		Subprotocols: []string{"hrana1"},
+		HTTPHeader:   cfg.HandshakeHeader(),
+		HTTPClient:   cfg.Client(http.DefaultClient),
+	}
+}
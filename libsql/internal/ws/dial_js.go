@@ -0,0 +1,17 @@
+//go:build js
+
+package ws
+
+import (
+	"nhooyr.io/websocket"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+)
+
+// dialOptions returns the options of the handshake of a websocket. Browsers
+// open websockets themselves and do not let pages set the headers of the
+// handshake, so the headers, the database and the request middleware of cfg
+// are not sent; the auth token is sent in the hello message anyway.
+func dialOptions(cfg *config.Config) *websocket.DialOptions {
+	return &websocket.DialOptions{Subprotocols: []string{"hrana1"}}
+}
@@ -3,13 +3,23 @@ package ws
 import (
 	"context"
 	"database/sql/driver"
+	"fmt"
 	"io"
 	"sort"
+	"strings"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+	"github.com/libsql/libsql-client-go/libsql/internal/limits"
 )
 
 type result struct {
-	id      int64
-	changes int64
+	id               int64
+	changes          int64
+	replicationIndex uint64
+	stats            shared.Stats
 }
 
 func (r *result) LastInsertId() (int64, error) {
@@ -20,15 +30,37 @@ func (r *result) RowsAffected() (int64, error) {
 	return r.changes, nil
 }
 
+// ReplicationIndex returns the replication index of the write, zero if the
+// server did not report one.
+func (r *result) ReplicationIndex() uint64 {
+	return r.replicationIndex
+}
+
+// Stats returns the execution statistics of the statement.
+func (r *result) Stats() shared.Stats {
+	return r.stats
+}
+
 type rows struct {
 	res           *execResponse
 	currentRowIdx int
+	// next are the results of the statements following the one of res, for
+	// queries made of several statements.
+	next []*execResponse
 }
 
 func (r *rows) Columns() []string {
 	return r.res.columns()
 }
 
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	declTypes := r.res.declTypes()
+	if index >= len(declTypes) {
+		return ""
+	}
+	return strings.ToUpper(declTypes[index])
+}
+
 func (r *rows) Close() error {
 	return nil
 }
@@ -49,12 +81,41 @@ func (r *rows) Next(dest []driver.Value) error {
 	return nil
 }
 
+// Stats returns the execution statistics of the statements of all result
+// sets.
+func (r *rows) Stats() shared.Stats {
+	stats := r.res.stats()
+	for _, res := range r.next {
+		stats.Add(res.stats())
+	}
+	return stats
+}
+
+func (r *rows) HasNextResultSet() bool {
+	return len(r.next) > 0
+}
+
+func (r *rows) NextResultSet() error {
+	if len(r.next) == 0 {
+		return io.EOF
+	}
+	r.res, r.next = r.next[0], r.next[1:]
+	r.currentRowIdx = 0
+	return nil
+}
+
 type conn struct {
 	ws *websocketConn
 }
 
-func Connect(url string, jwt string) (*conn, error) {
-	c, err := connect(url, jwt)
+// Connect opens a stream on a websocket to url from the pool of ps for url,
+// token and cfg. Connections of a pool share websockets as configured by cfg.
+func (ps *Pools) Connect(ctx context.Context, url string, token *auth.Token, cfg *config.Config) (*conn, error) {
+	p := ps.get(url, token, cfg)
+	if err := p.warmUp(ctx); err != nil {
+		return nil, err
+	}
+	c, err := p.openStream(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -62,8 +123,9 @@ func Connect(url string, jwt string) (*conn, error) {
 }
 
 type stmt struct {
-	c     *conn
-	query string
+	c        *conn
+	query    string
+	numInput int
 }
 
 func (s stmt) Close() error {
@@ -71,7 +133,7 @@ func (s stmt) Close() error {
 }
 
 func (s stmt) NumInput() int {
-	return -1
+	return s.numInput
 }
 
 func convertToNamed(args []driver.Value) []driver.NamedValue {
@@ -105,19 +167,50 @@ func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	return c.PrepareContext(context.Background(), query)
 }
 
+// PrepareContext only parses the query to count its parameters, statements are
+// sent along with their arguments.
 func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
-	return stmt{c, query}, nil
+	_, infos, err := shared.ParseStatement(query)
+	if err != nil {
+		return nil, err
+	}
+	numInput := -1
+	if len(infos) == 1 {
+		numInput = infos[0].NumInput()
+	}
+	return stmt{c, query, numInput}, nil
 }
 
 func (c *conn) Close() error {
 	return c.ws.Close()
 }
 
+// IsValid reports false once the websocket of the connection is retired, so
+// database/sql replaces the connection instead of reusing it.
+func (c *conn) IsValid() bool {
+	return c.ws.usable()
+}
+
+// ResetSession discards the connection once its stream was idle for longer
+// than the stream idle timeout.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.ws.idle() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// PinState makes the connection fail instead of reconnecting while pinned.
+func (c *conn) PinState(pinned bool) {
+	c.ws.setPinned(pinned)
+}
+
 type tx struct {
 	c *conn
 }
 
 func (t tx) Commit() error {
+	defer t.c.ws.setInTx(false)
 	_, err := t.c.ExecContext(context.Background(), "COMMIT", nil)
 	if err != nil {
 		return err
@@ -126,6 +219,7 @@ func (t tx) Commit() error {
 }
 
 func (t tx) Rollback() error {
+	defer t.c.ws.setInTx(false)
 	_, err := t.c.ExecContext(context.Background(), "ROLLBACK", nil)
 	if err != nil {
 		return err
@@ -137,11 +231,15 @@ func (c *conn) Begin() (driver.Tx, error) {
 	return c.BeginTx(context.Background(), driver.TxOptions{})
 }
 
-func (c *conn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
-	_, err := c.ExecContext(ctx, "BEGIN", nil)
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	begin, err := shared.BeginStatement(opts, ctxopt.TxMode(ctx))
 	if err != nil {
 		return tx{nil}, err
 	}
+	if _, err := c.ExecContext(ctx, begin, nil); err != nil {
+		return tx{nil}, err
+	}
+	c.ws.setInTx(true)
 	return tx{c}, nil
 }
 
@@ -169,17 +267,74 @@ func convertArgs(args []driver.NamedValue) params {
 }
 
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, ok := shared.SingleStatement(query); ok {
+		if err := shared.CheckQueryArgs(query, args); err != nil {
+			return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+		}
+	}
 	res, err := c.ws.exec(ctx, query, convertArgs(args), false)
 	if err != nil {
 		return nil, err
 	}
-	return &result{res.lastInsertId(), res.affectedRowCount()}, nil
+	return &result{res.lastInsertId(), res.affectedRowCount(), res.replicationIndex(), res.stats()}, nil
 }
 
 func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if _, ok := shared.SingleStatement(query); !ok {
+		stmts, stmtsParams, err := shared.ParseStatementAndArgs(query, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+		}
+		if len(stmts) > 1 {
+			return c.queryBatch(ctx, query, stmts, stmtsParams)
+		}
+	} else if err := shared.CheckQueryArgs(query, args); err != nil {
+		return nil, fmt.Errorf("failed to execute SQL: %s\n%w", query, err)
+	}
 	res, err := c.ws.exec(ctx, query, convertArgs(args), true)
 	if err != nil {
 		return nil, err
 	}
-	return &rows{res, 0}, nil
+	if err := limits.Rows(res.rowsCount(), c.ws.pool.cfg.MaxRows); err != nil {
+		return nil, err
+	}
+	return &rows{res: res}, nil
+}
+
+// queryBatch runs the statements of a query made of several of them in a batch,
+// every statement giving a result set of the rows.
+func (c *conn) queryBatch(ctx context.Context, query string, stmts []string, stmtsParams []shared.Params) (driver.Rows, error) {
+	wsParams := make([]params, len(stmtsParams))
+	for idx := range stmtsParams {
+		wsParams[idx] = sharedParams(stmtsParams[idx])
+	}
+	results, err := c.ws.batch(ctx, query, stmts, wsParams, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		if err := limits.Rows(res.rowsCount(), c.ws.pool.cfg.MaxRows); err != nil {
+			return nil, err
+		}
+	}
+	return &rows{res: results[0], next: results[1:]}, nil
+}
+
+// sharedParams converts the parameters bound by shared.ParseStatementAndArgs.
+func sharedParams(p shared.Params) params {
+	named := p.Named()
+	if len(named) == 0 {
+		return params{PositinalArgs: p.Positional()}
+	}
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	// Sort the names so that requests are deterministic.
+	sort.Strings(names)
+	res := params{NamedArgs: make([]namedParam, len(names))}
+	for idx, name := range names {
+		res.NamedArgs[idx] = namedParam{name, named[name]}
+	}
+	return res
 }
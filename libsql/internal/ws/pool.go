@@ -0,0 +1,390 @@
+package ws
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/background"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/limits"
+	"github.com/libsql/libsql-client-go/libsql/internal/retry"
+)
+
+// socket is a websocket shared by the streams of several connections.
+// Responses are read by a single goroutine and dispatched to the pending
+// requests by request id.
+type socket struct {
+	conn *websocket.Conn
+	// ctx is canceled when the socket is closed to stop the reader.
+	ctx       context.Context
+	cancel    context.CancelFunc
+	requestId *idPool
+	streamId  *idPool
+	created   time.Time
+	// readLimit is the size of the largest message accepted, zero for the
+	// default of the websocket library.
+	readLimit int64
+
+	mu       sync.Mutex
+	pending  map[uint32]chan interface{}
+	requests int
+	// err is set once the socket can no longer be read.
+	err error
+
+	// streams is the number of open streams, guarded by the lock of the pool.
+	streams int
+}
+
+// newSocket starts reading the responses of c, accepting messages of up to
+// readLimit bytes, or the default of the websocket library if zero.
+func newSocket(c *websocket.Conn, readLimit int64) *socket {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &socket{
+		conn:      c,
+		ctx:       ctx,
+		cancel:    cancel,
+		requestId: newIDPool(),
+		streamId:  newIDPool(),
+		created:   time.Now(),
+		readLimit: readLimit,
+		pending:   map[uint32]chan interface{}{},
+	}
+	if readLimit > 0 {
+		c.SetReadLimit(readLimit)
+	}
+	background.Go(s.readLoop)
+	return s
+}
+
+func (s *socket) readLoop() {
+	for {
+		var resp interface{}
+		if err := wsjson.Read(s.ctx, s.conn, &resp); err != nil {
+			// The websocket library does not export the error of messages
+			// over the read limit, it closes the websocket after it.
+			if s.readLimit > 0 && strings.Contains(err.Error(), "read limited at") {
+				err = &limits.Error{MaxBytes: s.readLimit}
+			}
+			s.fail(err)
+			return
+		}
+		msg, ok := resp.(map[string]interface{})
+		if !ok {
+			s.fail(fmt.Errorf("unexpected message from the server: %.100v", resp))
+			return
+		}
+		id, ok := msg["request_id"].(float64)
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		ch, ok := s.pending[uint32(id)]
+		delete(s.pending, uint32(id))
+		s.mu.Unlock()
+		if ok {
+			ch <- resp
+			// The id is only reused once its response arrived, so a late
+			// response cannot be mistaken for the one of a newer request.
+			s.requestId.Put(uint32(id))
+		}
+	}
+}
+
+func (s *socket) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	for id, ch := range s.pending {
+		close(ch)
+		delete(s.pending, id)
+	}
+}
+
+// request sends request and waits for its response.
+func (s *socket) request(ctx context.Context, request map[string]interface{}) (interface{}, error) {
+	ch := make(chan interface{}, 1)
+	s.mu.Lock()
+	if s.err != nil {
+		err := s.err
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", driver.ErrBadConn, err.Error())
+	}
+	id := s.requestId.Get()
+	s.pending[id] = ch
+	s.requests++
+	s.mu.Unlock()
+
+	err := wsjson.Write(ctx, s.conn, map[string]interface{}{
+		"type":       "request",
+		"request_id": id,
+		"request":    request,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", driver.ErrBadConn, err.Error())
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			s.mu.Lock()
+			err := s.err
+			s.mu.Unlock()
+			var tooLarge *limits.Error
+			if errors.As(err, &tooLarge) {
+				return nil, err
+			}
+			// The server may have run the request, so it is retryable but
+			// not driver.ErrBadConn, which would make database/sql run it
+			// again without the caller knowing.
+			return nil, retry.Mark(fmt.Errorf("websocket closed before the response was received: %s", err.Error()))
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// failure returns the error that closed the socket, nil while it is open.
+func (s *socket) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// usable reports whether new streams may be opened on the socket.
+func (s *socket) usable(cfg *config.Config) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false
+	}
+	if cfg.WebsocketMaxRequests > 0 && s.requests >= cfg.WebsocketMaxRequests {
+		return false
+	}
+	return cfg.WebsocketMaxAge <= 0 || time.Since(s.created) < cfg.WebsocketMaxAge
+}
+
+func (s *socket) close() {
+	s.conn.Close(websocket.StatusNormalClosure, "All's good")
+	s.cancel()
+}
+
+// pool holds the websockets opened to one database with the same settings.
+type pool struct {
+	url   string
+	token *auth.Token
+	cfg   config.Config
+
+	mu      sync.Mutex
+	sockets []*socket
+	warm    bool
+	// closed is set once the pool was removed from its Pools, its sockets are
+	// closed as soon as they have no streams.
+	closed bool
+}
+
+// poolKey leaves the settings out, which are the same for every connection of
+// a connector.
+type poolKey struct {
+	url   string
+	token *auth.Token
+	// database is part of the key since websockets are opened for the
+	// database of their handshake.
+	database string
+}
+
+// Pools holds the pools of websockets of a connector. Connections to the same
+// database share the websockets of a pool. The zero
+// value is ready to use.
+type Pools struct {
+	mu    sync.Mutex
+	byKey map[poolKey]*pool
+}
+
+func (ps *Pools) get(url string, token *auth.Token, cfg *config.Config) *pool {
+	key := poolKey{url, token, cfg.Database}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p, ok := ps.byKey[key]
+	if !ok {
+		if ps.byKey == nil {
+			ps.byKey = map[poolKey]*pool{}
+		}
+		p = &pool{url: url, token: token, cfg: *cfg}
+		ps.byKey[key] = p
+	}
+	return p
+}
+
+// Close closes the websockets of every pool: idle ones at once, and the others
+// when their last stream is closed. Connections opened afterwards start new
+// pools.
+func (ps *Pools) Close() {
+	ps.mu.Lock()
+	pools := ps.byKey
+	ps.byKey = nil
+	ps.mu.Unlock()
+	for _, p := range pools {
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		p.closeIdle()
+	}
+}
+
+func (p *pool) maxStreams() int {
+	if p.cfg.WebsocketMaxStreams <= 0 {
+		return 1
+	}
+	return p.cfg.WebsocketMaxStreams
+}
+
+// warmUp opens the configured number of websockets the first time it is
+// called successfully.
+func (p *pool) warmUp(ctx context.Context) error {
+	p.mu.Lock()
+	warm := p.warm
+	p.mu.Unlock()
+	if warm {
+		return nil
+	}
+	var sockets []*socket
+	for len(sockets) < p.cfg.WebsocketWarmup {
+		s, err := dial(ctx, p.url, p.token, &p.cfg)
+		if err != nil {
+			for _, s := range sockets {
+				s.close()
+			}
+			return err
+		}
+		sockets = append(sockets, s)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sockets = append(p.sockets, sockets...)
+	p.warm = true
+	return nil
+}
+
+// acquire reserves a stream on a socket with spare capacity, dialing a new
+// socket if there is none.
+func (p *pool) acquire(ctx context.Context) (*socket, error) {
+	p.mu.Lock()
+	for _, s := range p.sockets {
+		if s.streams < p.maxStreams() && s.usable(&p.cfg) {
+			s.streams++
+			p.mu.Unlock()
+			return s, nil
+		}
+	}
+	p.mu.Unlock()
+
+	s, err := dial(ctx, p.url, p.token, &p.cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.streams++
+	p.sockets = append(p.sockets, s)
+	return s, nil
+}
+
+// release gives back a stream reserved by acquire. Sockets without streams are
+// closed once retired or when more than the warm-up count are idle.
+func (p *pool) release(s *socket) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.streams--
+	if s.streams > 0 {
+		return
+	}
+	idle := 0
+	for _, other := range p.sockets {
+		if other.streams == 0 {
+			idle++
+		}
+	}
+	if !p.closed && s.usable(&p.cfg) && idle <= p.cfg.WebsocketWarmup {
+		return
+	}
+	for idx, other := range p.sockets {
+		if other == s {
+			p.sockets = append(p.sockets[:idx], p.sockets[idx+1:]...)
+			break
+		}
+	}
+	s.close()
+}
+
+// closeIdle closes the sockets without streams, including warmed up ones.
+func (p *pool) closeIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sockets := p.sockets[:0]
+	for _, s := range p.sockets {
+		if s.streams > 0 {
+			sockets = append(sockets, s)
+			continue
+		}
+		s.close()
+	}
+	p.sockets = sockets
+}
+
+func (p *pool) openStream(ctx context.Context) (*websocketConn, error) {
+	s, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	streamId := s.streamId.Get()
+	ctx, cancel := context.WithTimeout(ctx, defaultWSTimeout)
+	defer cancel()
+	resp, err := s.request(ctx, map[string]interface{}{
+		"type":      "open_stream",
+		"stream_id": streamId,
+	})
+	if err != nil {
+		// The server may have opened the stream before the request failed, so
+		// the id is not reused on this socket, like in closeStream.
+		p.release(s)
+		return nil, err
+	}
+	if isErrorResp(resp) {
+		s.streamId.Put(streamId)
+		p.release(s)
+		return nil, fmt.Errorf("unable to open stream: %s", errorMsg(resp))
+	}
+	return &websocketConn{pool: p, socket: s, streamId: streamId}, nil
+}
+
+func (p *pool) closeStream(s *socket, streamId uint32) error {
+	defer p.release(s)
+	if s.failure() != nil {
+		// The server dropped the stream along with the websocket.
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultWSTimeout)
+	defer cancel()
+	resp, err := s.request(ctx, map[string]interface{}{
+		"type":      "close_stream",
+		"stream_id": streamId,
+	})
+	if err != nil {
+		return err
+	}
+	s.streamId.Put(streamId)
+	if isErrorResp(resp) {
+		return fmt.Errorf("unable to close stream: %s", errorMsg(resp))
+	}
+	return nil
+}
@@ -0,0 +1,593 @@
+package ws
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/background"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+	"github.com/libsql/libsql-client-go/libsql/internal/retry"
+)
+
+// newHranaServer answers every request with an empty result, and the steps of
+// batches with a row holding their statement or with an error for statements
+// starting with FAIL, and counts the websockets opened to it. If dropAfter is positive, websockets are closed
+// without answering once they received that many requests. The statement
+// MALFORMED is answered with a message that is not a JSON object.
+func newHranaServer(t *testing.T, dials *int32, dropAfter int) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"hrana1"}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		atomic.AddInt32(dials, 1)
+		ctx := context.Background()
+		var hello map[string]interface{}
+		if err := wsjson.Read(ctx, c, &hello); err != nil {
+			return
+		}
+		if err := wsjson.Write(ctx, c, map[string]interface{}{"type": "hello_ok"}); err != nil {
+			return
+		}
+		for requests := 1; ; requests++ {
+			var req map[string]interface{}
+			if err := wsjson.Read(ctx, c, &req); err != nil {
+				return
+			}
+			if requests == dropAfter {
+				return
+			}
+			if stmt, ok := req["request"].(map[string]interface{})["stmt"].(map[string]interface{}); ok && stmt["sql"] == "MALFORMED" {
+				if err := wsjson.Write(ctx, c, []interface{}{req["request_id"]}); err != nil {
+					return
+				}
+				continue
+			}
+			result := map[string]interface{}{"type": req["request"].(map[string]interface{})["type"]}
+			switch result["type"] {
+			case "execute":
+				result["result"] = map[string]interface{}{"cols": []interface{}{}, "rows": []interface{}{}, "affected_row_count": 0}
+			case "batch":
+				steps := req["request"].(map[string]interface{})["batch"].(map[string]interface{})["steps"].([]interface{})
+				stepResults, stepErrors := make([]interface{}, len(steps)), make([]interface{}, len(steps))
+				for idx, step := range steps {
+					sql := step.(map[string]interface{})["stmt"].(map[string]interface{})["sql"].(string)
+					if strings.HasPrefix(sql, "FAIL") {
+						stepErrors[idx] = map[string]interface{}{"message": "no such statement"}
+						continue
+					}
+					stepResults[idx] = map[string]interface{}{
+						"cols":               []interface{}{map[string]interface{}{"name": "sql"}},
+						"rows":               []interface{}{[]interface{}{map[string]interface{}{"type": "text", "value": sql}}},
+						"affected_row_count": 0,
+					}
+				}
+				result["result"] = map[string]interface{}{"step_results": stepResults, "step_errors": stepErrors}
+			}
+			err := wsjson.Write(ctx, c, map[string]interface{}{
+				"type":       "response_ok",
+				"request_id": req["request_id"],
+				"response":   result,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestPoolMaxStreams(t *testing.T) {
+	var pools Pools
+	var dials int32
+	url := newHranaServer(t, &dials, 0)
+	cfg := &config.Config{WebsocketMaxStreams: 2}
+	var conns []*conn
+	for i := 0; i < 3; i++ {
+		c, err := pools.Connect(context.Background(), url, nil, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+	}
+	for _, c := range conns {
+		if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt32(&dials); got != 2 {
+		t.Errorf("got %d websockets, want 2", got)
+	}
+	if conns[0].ws.socket != conns[1].ws.socket || conns[0].ws.streamId == conns[1].ws.streamId {
+		t.Error("first two connections should use distinct streams of one websocket")
+	}
+	for _, c := range conns {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(pools.get(url, nil, cfg).sockets); n != 0 {
+		t.Errorf("got %d open websockets after closing all connections, want 0", n)
+	}
+}
+
+func TestPoolWarmup(t *testing.T) {
+	var pools Pools
+	var dials int32
+	url := newHranaServer(t, &dials, 0)
+	cfg := &config.Config{WebsocketWarmup: 3}
+	c, err := pools.Connect(context.Background(), url, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&dials); got != 3 {
+		t.Errorf("got %d websockets, want 3", got)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := pools.get(url, nil, cfg)
+	if n := len(p.sockets); n != 3 {
+		t.Errorf("got %d idle websockets, want 3", n)
+	}
+	p.closeIdle()
+	if n := len(p.sockets); n != 0 {
+		t.Errorf("got %d websockets after closing idle ones, want 0", n)
+	}
+}
+
+func TestPoolsClose(t *testing.T) {
+	var pools Pools
+	var dials int32
+	url := newHranaServer(t, &dials, 0)
+	cfg := &config.Config{WebsocketWarmup: 2}
+	c, err := pools.Connect(context.Background(), url, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pools.get(url, nil, cfg)
+	pools.Close()
+	if n := len(p.sockets); n != 1 {
+		t.Errorf("got %d websockets after closing the pools, want only the one of the open stream", n)
+	}
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+		t.Errorf("got %v, want open streams to keep working", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(p.sockets); n != 0 {
+		t.Errorf("got %d websockets after closing the last stream, want 0", n)
+	}
+	if pools.get(url, nil, cfg) == p {
+		t.Error("expected connections after Close to use a new pool")
+	}
+}
+
+func TestPoolRecycle(t *testing.T) {
+	var pools Pools
+	var dials int32
+	url := newHranaServer(t, &dials, 0)
+	cfg := &config.Config{WebsocketMaxStreams: 10, WebsocketMaxRequests: 3}
+	c, err := pools.Connect(context.Background(), url, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The open_stream request counts towards the limit.
+	for i := 0; i < 2; i++ {
+		if !c.IsValid() {
+			t.Fatalf("connection retired after %d requests", i+1)
+		}
+		if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.IsValid() {
+		t.Error("connection should be retired")
+	}
+	other, err := pools.Connect(context.Background(), url, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ws.socket == c.ws.socket {
+		t.Error("retired websocket should not host new streams")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	var pools Pools
+	var dials int32
+	// The websocket is dropped on the second execute.
+	url := newHranaServer(t, &dials, 3)
+	cfg := &config.Config{}
+	c, err := pools.Connect(context.Background(), url, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); errors.Is(err, driver.ErrBadConn) || !retry.Is(err) {
+		t.Fatalf("got %v for an in-flight request, want a retryable error other than driver.ErrBadConn", err)
+	}
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+		t.Fatalf("idle connection did not reconnect: %v", err)
+	}
+	if got := atomic.LoadInt32(&dials); got != 2 {
+		t.Errorf("got %d websockets, want 2", got)
+	}
+}
+
+func TestReconnectInTransaction(t *testing.T) {
+	var pools Pools
+	var dials int32
+	// The websocket is dropped on the execute following BEGIN.
+	url := newHranaServer(t, &dials, 3)
+	c, err := pools.Connect(context.Background(), url, nil, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tx, err := c.BeginTx(context.Background(), driver.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); errors.Is(err, driver.ErrBadConn) || !retry.Is(err) {
+		t.Fatalf("got %v for an in-flight request, want a retryable error other than driver.ErrBadConn", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, driver.ErrBadConn) {
+		t.Fatalf("got %v for commit of a lost transaction, want driver.ErrBadConn", err)
+	}
+}
+
+func TestReconnectSessionLost(t *testing.T) {
+	var pools Pools
+	var dials int32
+	// The websocket is dropped on the second execute.
+	url := newHranaServer(t, &dials, 3)
+	c, err := pools.Connect(context.Background(), url, nil, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.ExecContext(context.Background(), "INSERT INTO t VALUES (1)", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); !retry.Is(err) {
+		t.Fatalf("got %v for an in-flight request, want a retryable error", err)
+	}
+	if _, err := c.QueryContext(context.Background(), "SELECT last_insert_rowid()", nil); !errors.Is(err, shared.ErrSessionLost) {
+		t.Fatalf("got %v, want shared.ErrSessionLost", err)
+	}
+	rows, err := c.QueryContext(context.Background(), "SELECT last_insert_rowid()", nil)
+	if err != nil {
+		t.Fatalf("new stream was not used after the session was lost: %v", err)
+	}
+	rows.Close()
+	if got := atomic.LoadInt32(&dials); got != 2 {
+		t.Errorf("got %d websockets, want 2", got)
+	}
+}
+
+func TestConnectCanceled(t *testing.T) {
+	var pools Pools
+	// The server accepts the websocket but never answers the hello.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"hrana1"}})
+		if err != nil {
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := pools.Connect(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil, &config.Config{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Connect took %s after the context was canceled", elapsed)
+	}
+}
+
+func TestOpenStreamTimeout(t *testing.T) {
+	var pools Pools
+	var dials int32
+	opened := make(chan uint32, 3)
+	// The server does not answer the second open_stream, as if it opened the
+	// stream after the client gave up.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"hrana1"}})
+		if err != nil {
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		atomic.AddInt32(&dials, 1)
+		ctx := context.Background()
+		var hello map[string]interface{}
+		if err := wsjson.Read(ctx, c, &hello); err != nil {
+			return
+		}
+		if err := wsjson.Write(ctx, c, map[string]interface{}{"type": "hello_ok"}); err != nil {
+			return
+		}
+		for {
+			var req map[string]interface{}
+			if err := wsjson.Read(ctx, c, &req); err != nil {
+				return
+			}
+			request := req["request"].(map[string]interface{})
+			if request["type"] == "open_stream" {
+				opened <- uint32(request["stream_id"].(float64))
+				if len(opened) == 2 {
+					continue
+				}
+			}
+			err := wsjson.Write(ctx, c, map[string]interface{}{
+				"type":       "response_ok",
+				"request_id": req["request_id"],
+				"response":   map[string]interface{}{"type": request["type"]},
+			})
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	cfg := &config.Config{WebsocketMaxStreams: 2}
+
+	// The first connection keeps the websocket open.
+	first, err := pools.Connect(context.Background(), url, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pools.Connect(ctx, url, nil, cfg); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	c, err := pools.Connect(context.Background(), url, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-opened
+	if timedOut, next := <-opened, <-opened; timedOut == next {
+		t.Errorf("got stream %d opened twice, want the id of the timed out open_stream not reused", next)
+	}
+	if got := atomic.LoadInt32(&dials); got != 1 {
+		t.Errorf("got %d websockets, want the socket reused", got)
+	}
+}
+
+func TestReaderStopsOnClose(t *testing.T) {
+	var pools Pools
+	var dials int32
+	url := newHranaServer(t, &dials, 0)
+	c, err := pools.Connect(context.Background(), url, nil, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if background.Running() == 0 {
+		t.Fatal("expected the reader of the websocket to run")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := background.Wait(ctx); err != nil {
+		t.Fatalf("reader still running after Close: %v", err)
+	}
+}
+
+func TestReconnectPinned(t *testing.T) {
+	var pools Pools
+	var dials int32
+	// The websocket is dropped on the second execute.
+	url := newHranaServer(t, &dials, 3)
+	c, err := pools.Connect(context.Background(), url, nil, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.PinState(true)
+	if _, err := c.ExecContext(context.Background(), "ATTACH DATABASE ':memory:' AS scratch", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); !retry.Is(err) {
+		t.Fatalf("got %v for an in-flight request, want a retryable error", err)
+	}
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); !errors.Is(err, driver.ErrBadConn) {
+		t.Fatalf("got %v, want driver.ErrBadConn", err)
+	}
+	if got := atomic.LoadInt32(&dials); got != 1 {
+		t.Errorf("got %d websockets, want a pinned connection not to reconnect", got)
+	}
+}
+
+func TestMalformedMessage(t *testing.T) {
+	var pools Pools
+	var dials int32
+	url := newHranaServer(t, &dials, 0)
+	c, err := pools.Connect(context.Background(), url, nil, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.ExecContext(context.Background(), "MALFORMED", nil)
+	if err == nil || !strings.Contains(err.Error(), "unexpected message from the server") {
+		t.Fatalf("got %v, want the socket to fail on the message", err)
+	}
+	if c.IsValid() {
+		t.Error("expected the connection to be invalid once its socket failed")
+	}
+}
+
+func TestConcurrentExecs(t *testing.T) {
+	var pools Pools
+	var dials int32
+	url := newHranaServer(t, &dials, 0)
+	c, err := pools.Connect(context.Background(), url, nil, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+				t.Error(err)
+			}
+			c.IsValid()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCancelClosesStream(t *testing.T) {
+	var pools Pools
+	closed := make(chan uint32, 1)
+	var opened int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"hrana1"}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		ctx := context.Background()
+		var hello map[string]interface{}
+		if err := wsjson.Read(ctx, c, &hello); err != nil {
+			return
+		}
+		if err := wsjson.Write(ctx, c, map[string]interface{}{"type": "hello_ok"}); err != nil {
+			return
+		}
+		for {
+			var req map[string]interface{}
+			if err := wsjson.Read(ctx, c, &req); err != nil {
+				return
+			}
+			request := req["request"].(map[string]interface{})
+			result := map[string]interface{}{"type": request["type"]}
+			switch request["type"] {
+			case "open_stream":
+				atomic.AddInt32(&opened, 1)
+			case "close_stream":
+				closed <- uint32(request["stream_id"].(float64))
+			case "execute":
+				if request["stmt"].(map[string]interface{})["sql"] == "SELECT slow()" {
+					// The statement never finishes.
+					continue
+				}
+				result["result"] = map[string]interface{}{"cols": []interface{}{}, "rows": []interface{}{}, "affected_row_count": 0}
+			}
+			err := wsjson.Write(ctx, c, map[string]interface{}{
+				"type":       "response_ok",
+				"request_id": req["request_id"],
+				"response":   result,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	c, err := pools.Connect(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	streamId := c.ws.streamId
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.ExecContext(ctx, "SELECT slow()", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case id := <-closed:
+		if id != streamId {
+			t.Errorf("got close_stream for stream %d, want %d", id, streamId)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the stream of the canceled request was not closed")
+	}
+	if _, err := c.ExecContext(context.Background(), "SELECT 1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&opened); got != 2 {
+		t.Errorf("got %d streams, want a new stream after the canceled request", got)
+	}
+}
+
+func TestQueryMultipleResultSets(t *testing.T) {
+	var pools Pools
+	var dials int32
+	url := newHranaServer(t, &dials, 0)
+	c, err := pools.Connect(context.Background(), url, nil, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	rs, err := c.QueryContext(context.Background(), "SELECT 1; SELECT ?", []driver.NamedValue{{Ordinal: 1, Value: int64(2)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rs.(driver.RowsNextResultSet)
+	dest := make([]driver.Value, 1)
+	if err := r.Next(dest); err != nil || dest[0] != "SELECT 1" {
+		t.Fatalf("got %v and %v, want the row of the first statement", dest[0], err)
+	}
+	if err := r.Next(dest); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF at the end of the first result set", err)
+	}
+	if !r.HasNextResultSet() {
+		t.Fatal("expected a second result set")
+	}
+	if err := r.NextResultSet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Next(dest); err != nil || dest[0] != "SELECT ?" {
+		t.Fatalf("got %v and %v, want the row of the second statement", dest[0], err)
+	}
+	if r.HasNextResultSet() || r.NextResultSet() != io.EOF {
+		t.Error("expected no more result sets")
+	}
+
+	if _, err := c.QueryContext(context.Background(), "SELECT 1; FAIL 2", nil); err == nil || !strings.Contains(err.Error(), "no such statement") {
+		t.Errorf("got %v, want the error of the failed statement", err)
+	}
+}
@@ -4,29 +4,124 @@ import (
 	"context"
 	"database/sql/driver"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"nhooyr.io/websocket"
 	"nhooyr.io/websocket/wsjson"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/background"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/debug"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+	"github.com/libsql/libsql-client-go/libsql/internal/retry"
 )
 
 // defaultWSTimeout specifies the timeout used for initial http connection
 var defaultWSTimeout = 120 * time.Second
 
+// ErrUpgradeFailed is returned when the server refused to upgrade the HTTP
+// connection to a websocket, answering 404, 405 or 426, for example because
+// it or a proxy in front of it does not serve websockets.
+var ErrUpgradeFailed = errors.New("websocket upgrade failed")
+
 func errorMsg(errorResp interface{}) string {
 	return errorResp.(map[string]interface{})["error"].(map[string]interface{})["message"].(string)
 }
 
+// sqliteError returns the error object e as a *shared.SQLiteError.
+func sqliteError(e interface{}) error {
+	obj, _ := e.(map[string]interface{})
+	message, _ := obj["message"].(string)
+	code, _ := obj["code"].(string)
+	return shared.NewSQLiteError(code, message)
+}
+
 func isErrorResp(resp interface{}) bool {
 	return resp.(map[string]interface{})["type"] == "response_error"
 }
 
+// websocketConn is a stream on a socket. It is safe for concurrent use, the
+// requests of a stream are executed one at a time.
 type websocketConn struct {
-	conn   *websocket.Conn
-	idPool *idPool
+	pool *pool
+
+	// mu guards the fields below and is held while a request is in flight.
+	mu sync.Mutex
+	// socket is nil once a reconnect failed.
+	socket   *socket
+	streamId uint32
+	// inTx is set while a transaction is open on the stream, its state would be
+	// lost by a reconnect.
+	inTx bool
+	// pinned is set while the stream holds other state that a reconnect would
+	// lose, like attached databases.
+	pinned bool
+	// lastUsed is when the stream last answered a request.
+	lastUsed time.Time
+	// abandoned is set once the stream was closed after a canceled request.
+	abandoned bool
+}
+
+// reconnect opens a new stream if the websocket of ws was closed while ws was
+// idle. The state of open transactions and pinned streams cannot be recovered,
+// so they fail with driver.ErrBadConn instead. A request reading the session
+// state of a stream that already ran statements fails with
+// shared.ErrSessionLost once the new stream is open, since it would not see
+// them; the requests that follow run on the new stream.
+func (ws *websocketConn) reconnect(ctx context.Context, session bool) error {
+	if ws.abandoned && (ws.inTx || ws.pinned) {
+		return fmt.Errorf("%w: stream closed after a canceled request", driver.ErrBadConn)
+	}
+	if ws.socket != nil {
+		err := ws.socket.failure()
+		if err == nil {
+			return nil
+		}
+		if ws.inTx {
+			return fmt.Errorf("%w: websocket closed during transaction: %s", driver.ErrBadConn, err.Error())
+		}
+		if ws.pinned {
+			return fmt.Errorf("%w: websocket closed while connection state was pinned: %s", driver.ErrBadConn, err.Error())
+		}
+		ws.pool.cfg.Log().InfoContext(ctx, "reconnecting websocket", "url", debug.URL(ws.pool.url), "error", err)
+		ws.pool.release(ws.socket)
+		ws.socket = nil
+	}
+	c, err := ws.pool.openStream(ctx)
+	if err != nil {
+		ws.pool.cfg.Log().WarnContext(ctx, "failed to open websocket stream", "url", debug.URL(ws.pool.url), "error", err)
+		return fmt.Errorf("%w: %s", driver.ErrBadConn, err.Error())
+	}
+	ws.socket, ws.streamId = c.socket, c.streamId
+	ws.abandoned = false
+	used := !ws.lastUsed.IsZero()
+	ws.lastUsed = time.Time{}
+	if session && used {
+		return fmt.Errorf("websocket closed: %w", shared.ErrSessionLost)
+	}
+	return nil
+}
+
+// abandon closes the stream after a canceled request. Closing the stream makes
+// the server drop the statement it runs instead of finishing it for nobody.
+// The close request is sent in the background so that the caller returns at
+// once.
+func (ws *websocketConn) abandon() {
+	s, streamId := ws.socket, ws.streamId
+	ws.socket = nil
+	ws.abandoned = true
+	background.Go(func() {
+		if err := ws.pool.closeStream(s, streamId); err != nil {
+			ws.pool.cfg.Log().Warn("failed to close abandoned stream", "url", debug.URL(ws.pool.url), "error", err)
+		}
+	})
 }
 
 type namedParam struct {
@@ -49,7 +144,9 @@ func convertValue(v any) (map[string]interface{}, error) {
 	} else if text, ok := v.(string); ok {
 		res["type"] = "text"
 		res["value"] = text
-	} else if blob, ok := v.([]byte); ok {
+	} else if blob, ok := v.([]byte); ok && blob == nil {
+		res["type"] = "null"
+	} else if ok {
 		res["type"] = "blob"
 		res["base64"] = base64.StdEncoding.WithPadding(base64.NoPadding).EncodeToString(blob)
 	} else if float, ok := v.(float64); ok {
@@ -78,6 +175,24 @@ func (r *execResponse) lastInsertId() int64 {
 	return value
 }
 
+func (r *execResponse) replicationIndex() uint64 {
+	index, ok := r.resp["replication_index"].(string)
+	if !ok {
+		return 0
+	}
+	value, _ := strconv.ParseUint(index, 10, 64)
+	return value
+}
+
+// stats returns the execution statistics of the statement, zero if the server
+// did not report them.
+func (r *execResponse) stats() shared.Stats {
+	rowsRead, _ := r.resp["rows_read"].(float64)
+	rowsWritten, _ := r.resp["rows_written"].(float64)
+	duration, _ := r.resp["query_duration_ms"].(float64)
+	return shared.Stats{RowsRead: uint64(rowsRead), RowsWritten: uint64(rowsWritten), QueryDuration: shared.DurationMs(duration)}
+}
+
 func (r *execResponse) columns() []string {
 	res := []string{}
 	cols := r.resp["cols"].([]interface{})
@@ -91,6 +206,19 @@ func (r *execResponse) columns() []string {
 	return res
 }
 
+func (r *execResponse) declTypes() []string {
+	res := []string{}
+	cols := r.resp["cols"].([]interface{})
+	for idx := range cols {
+		var v string = ""
+		if cols[idx].(map[string]interface{})["decltype"] != nil {
+			v = cols[idx].(map[string]interface{})["decltype"].(string)
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
 func (r *execResponse) rowsCount() int {
 	return len(r.resp["rows"].([]interface{}))
 }
@@ -114,7 +242,7 @@ func (r *execResponse) value(rowIdx int, colIdx int) (any, error) {
 		return val["value"].(string), nil
 	case "blob":
 		base64Encoded := val["base64"].(string)
-		v, err := base64.StdEncoding.WithPadding(base64.NoPadding).DecodeString(base64Encoded)
+		v, err := base64.StdEncoding.WithPadding(base64.NoPadding).DecodeString(strings.TrimRight(base64Encoded, "="))
 		if err != nil {
 			return nil, err
 		}
@@ -126,8 +254,96 @@ func (r *execResponse) value(rowIdx int, colIdx int) (any, error) {
 }
 
 func (ws *websocketConn) exec(ctx context.Context, sql string, sqlParams params, wantRows bool) (*execResponse, error) {
-	requestId := ws.idPool.Get()
-	defer ws.idPool.Put(requestId)
+	stmt, err := stmtRequest(sql, sqlParams, wantRows)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ws.request(ctx, map[string]interface{}{
+		"type": "execute",
+		"stmt": stmt,
+	}, shared.ReadsSessionState(sql))
+	if err != nil {
+		return nil, err
+	}
+	if isErrorResp(resp) {
+		err = fmt.Errorf("unable to execute %s: %w", sql, sqliteError(resp.(map[string]interface{})["error"]))
+		return nil, err
+	}
+
+	return &execResponse{resp.(map[string]interface{})["response"].(map[string]interface{})["result"].(map[string]interface{})}, nil
+}
+
+// batch executes the statements of sql, split into stmts bound to stmtsParams,
+// in a single batch request and returns their results. Like over HTTP, it
+// fails if any statement failed.
+func (ws *websocketConn) batch(ctx context.Context, sql string, stmts []string, stmtsParams []params, wantRows bool) ([]*execResponse, error) {
+	steps := make([]interface{}, len(stmts))
+	for idx := range stmts {
+		stmt, err := stmtRequest(stmts[idx], stmtsParams[idx], wantRows)
+		if err != nil {
+			return nil, err
+		}
+		steps[idx] = map[string]interface{}{"stmt": stmt}
+	}
+	resp, err := ws.request(ctx, map[string]interface{}{
+		"type":  "batch",
+		"batch": map[string]interface{}{"steps": steps},
+	}, shared.ReadsSessionState(stmts[0]))
+	if err != nil {
+		return nil, err
+	}
+	if isErrorResp(resp) {
+		return nil, fmt.Errorf("unable to execute %s: %w", sql, sqliteError(resp.(map[string]interface{})["error"]))
+	}
+	result, _ := resp.(map[string]interface{})["response"].(map[string]interface{})["result"].(map[string]interface{})
+	stepResults, _ := result["step_results"].([]interface{})
+	stepErrors, _ := result["step_errors"].([]interface{})
+	for idx := range stepErrors {
+		if e, ok := stepErrors[idx].(map[string]interface{}); ok {
+			return nil, fmt.Errorf("unable to execute %s: %w", stmts[idx], sqliteError(e))
+		}
+	}
+	res := make([]*execResponse, len(stmts))
+	for idx := range res {
+		var r map[string]interface{}
+		if idx < len(stepResults) {
+			r, _ = stepResults[idx].(map[string]interface{})
+		}
+		if r == nil {
+			return nil, fmt.Errorf("unable to execute %s: no results for statement", stmts[idx])
+		}
+		res[idx] = &execResponse{r}
+	}
+	return res, nil
+}
+
+// request sends req on the stream, reconnecting it first if needed, and returns
+// the response. The stream id of req is set here, under the lock, since a
+// reconnect changes it. Requests reading the session state of the stream, as
+// told by session, fail with shared.ErrSessionLost instead of running on a new
+// stream.
+func (ws *websocketConn) request(ctx context.Context, req map[string]interface{}, session bool) (interface{}, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ctx, cancel := ws.pool.cfg.RequestContext(ctx, 0)
+	defer cancel()
+	if err := ws.reconnect(ctx, session); err != nil {
+		return nil, err
+	}
+	req["stream_id"] = ws.streamId
+	resp, err := ws.socket.request(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil && ws.socket.failure() == nil {
+			ws.abandon()
+		}
+		return nil, err
+	}
+	ws.lastUsed = time.Now()
+	return resp, nil
+}
+
+// stmtRequest returns the Hrana statement running sql with sqlParams.
+func stmtRequest(sql string, sqlParams params, wantRows bool) (map[string]interface{}, error) {
 	stmt := map[string]interface{}{
 		"sql":       sql,
 		"want_rows": wantRows,
@@ -158,62 +374,120 @@ func (ws *websocketConn) exec(ctx context.Context, sql string, sqlParams params,
 		}
 		stmt["named_args"] = args
 	}
-	err := wsjson.Write(ctx, ws.conn, map[string]interface{}{
-		"type":       "request",
-		"request_id": requestId,
-		"request": map[string]interface{}{
-			"type":      "execute",
-			"stream_id": 0,
-			"stmt":      stmt,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("%w: %s", driver.ErrBadConn, err.Error())
-	}
+	return stmt, nil
+}
+
+// setInTx records whether a transaction is open on the stream.
+func (ws *websocketConn) setInTx(inTx bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.inTx = inTx
+}
+
+// setPinned records whether the stream holds state lost by a reconnect.
+func (ws *websocketConn) setPinned(pinned bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.pinned = pinned
+}
+
+// idle reports whether the stream was idle for longer than the stream idle
+// timeout.
+func (ws *websocketConn) idle() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	timeout := ws.pool.cfg.StreamIdleTimeout
+	return timeout > 0 && !ws.lastUsed.IsZero() && time.Since(ws.lastUsed) > timeout
+}
 
-	var resp interface{}
-	if err = wsjson.Read(ctx, ws.conn, &resp); err != nil {
-		return nil, fmt.Errorf("%w: %s", driver.ErrBadConn, err.Error())
+// usable reports whether the socket of the stream may still be used.
+func (ws *websocketConn) usable() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.socket != nil && ws.socket.usable(&ws.pool.cfg)
+}
+
+func (ws *websocketConn) Close() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.socket == nil {
+		return nil
 	}
+	return ws.pool.closeStream(ws.socket, ws.streamId)
+}
 
-	if isErrorResp(resp) {
-		err = fmt.Errorf("unable to execute %s: %s", sql, errorMsg(resp))
-		return nil, err
+// dial opens a websocket and performs the hello handshake. Handshakes answered
+// with HTTP 429 are tried again up to cfg.RateLimitRetries times.
+func dial(ctx context.Context, url string, token *auth.Token, cfg *config.Config) (*socket, error) {
+	for attempt := 0; ; attempt++ {
+		s, err := dialWithToken(ctx, url, token, cfg)
+		var rejected *rejectedTokenError
+		if errors.As(err, &rejected) && token.Refreshable() {
+			cfg.Log().InfoContext(ctx, "auth token rejected, refreshing it", "url", debug.URL(url))
+			token.Invalidate(rejected.token)
+			s, err = dialWithToken(ctx, url, token, cfg)
+		}
+		if attempt < cfg.RateLimitRetries && retry.WaitRateLimit(ctx, err, attempt) {
+			cfg.Log().InfoContext(ctx, "retrying rate limited handshake", "url", debug.URL(url), "attempt", attempt+1)
+			continue
+		}
+		return s, err
 	}
+}
 
-	return &execResponse{resp.(map[string]interface{})["response"].(map[string]interface{})["result"].(map[string]interface{})}, nil
+// rejectedTokenError is returned when the server refused the hello.
+type rejectedTokenError struct {
+	token string
+	msg   string
 }
 
-func (ws *websocketConn) Close() error {
-	return ws.conn.Close(websocket.StatusNormalClosure, "All's good")
+func (e *rejectedTokenError) Error() string {
+	return fmt.Sprintf("handshake error: %s", e.msg)
 }
 
-func connect(url string, jwt string) (*websocketConn, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultWSTimeout)
+// refusedUpgrade reports whether status answers an upgrade request of a server
+// that does not serve websockets at that URL.
+func refusedUpgrade(status int) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusUpgradeRequired:
+		return true
+	}
+	return false
+}
+
+func dialWithToken(ctx context.Context, url string, token *auth.Token, cfg *config.Config) (*socket, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultWSTimeout)
 	defer cancel()
-	c, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{
-		Subprotocols: []string{"hrana1"},
-	})
+	jwt, err := token.Get(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	err = wsjson.Write(ctx, c, map[string]interface{}{
-		"type": "hello",
-		"jwt":  jwt,
-	})
+	if err := cfg.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+	sent := time.Now()
+	c, resp, err := websocket.Dial(ctx, url, dialOptions(cfg))
+	cfg.Clock.Observe(sent, resp)
+	cfg.Breaker.Record(err)
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		// The server is reachable and speaks websockets, HTTP would be rate
+		// limited just the same.
+		return nil, retry.NewRateLimitError(resp.Header, err)
+	}
+	if retry.Unsent(err) {
+		// HTTP would not reach the server either.
+		return nil, retry.BadConn(err)
+	}
 	if err != nil {
-		c.Close(websocket.StatusInternalError, err.Error())
+		if resp != nil && refusedUpgrade(resp.StatusCode) {
+			return nil, fmt.Errorf("%w: %s", ErrUpgradeFailed, err.Error())
+		}
 		return nil, err
 	}
 
 	err = wsjson.Write(ctx, c, map[string]interface{}{
-		"type":       "request",
-		"request_id": 0,
-		"request": map[string]interface{}{
-			"type":      "open_stream",
-			"stream_id": 0,
-		},
+		"type": "hello",
+		"jwt":  jwt,
 	})
 	if err != nil {
 		c.Close(websocket.StatusInternalError, err.Error())
@@ -227,24 +501,11 @@ func connect(url string, jwt string) (*websocketConn, error) {
 		return nil, err
 	}
 	if helloResp.(map[string]interface{})["type"] == "hello_error" {
-		err = fmt.Errorf("handshake error: %s", errorMsg(helloResp))
-		c.Close(websocket.StatusProtocolError, err.Error())
-		return nil, err
-	}
-
-	var openStreamResp interface{}
-	err = wsjson.Read(ctx, c, &openStreamResp)
-	if err != nil {
-		c.Close(websocket.StatusInternalError, err.Error())
-		return nil, err
-	}
-
-	if isErrorResp(openStreamResp) {
-		err = fmt.Errorf("unable to open stream: %s", errorMsg(helloResp))
+		err = &rejectedTokenError{token: jwt, msg: errorMsg(helloResp)}
 		c.Close(websocket.StatusProtocolError, err.Error())
 		return nil, err
 	}
-	return &websocketConn{c, newIDPool()}, nil
+	return newSocket(c, cfg.MaxResponseBytes), nil
 }
 
 // Below is modified IDPool from "vitess.io/vitess/go/pools"
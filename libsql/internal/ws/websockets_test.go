@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
 )
 
 func TestConvertValue(t *testing.T) {
@@ -49,6 +52,14 @@ func TestConvertValue(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			name:  "nil blob",
+			value: []byte(nil),
+			want: map[string]any{
+				"type": "null",
+			},
+			err: nil,
+		},
 		{
 			name:  "float",
 			value: 3.14,
@@ -116,3 +127,32 @@ func Test_execResponse_lastInsertId(t *testing.T) {
 		})
 	}
 }
+
+func Test_execResponse_stats(t *testing.T) {
+	tests := []struct {
+		name  string
+		value map[string]interface{}
+		want  shared.Stats
+	}{
+		{
+			name:  "reported",
+			value: map[string]interface{}{"rows_read": 10.0, "rows_written": 2.0, "query_duration_ms": 0.25},
+			want:  shared.Stats{RowsRead: 10, RowsWritten: 2, QueryDuration: 250 * time.Microsecond},
+		},
+		{
+			name:  "empty",
+			value: map[string]interface{}{},
+			want:  shared.Stats{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &execResponse{
+				resp: tt.value,
+			}
+			if got := r.stats(); got != tt.want {
+				t.Errorf("stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package libsql
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONValue scans a TEXT column holding a JSON document into a Go value and
+// binds a Go value as a JSON document. It is created with JSON.
+type JSONValue struct {
+	v any
+}
+
+// JSON wraps v for use with database/sql. As a destination of Scan, v must be
+// a pointer and the column is decoded with json.Unmarshal. A NULL column is
+// decoded like the JSON null, which sets maps, slices and pointers to nil and
+// leaves other values unchanged. As a query argument, v is encoded with
+// json.Marshal and bound as TEXT.
+//
+//	var tags map[string]string
+//	err := db.QueryRow("SELECT tags FROM users WHERE id = ?", id).Scan(libsql.JSON(&tags))
+//	_, err = db.Exec("UPDATE users SET tags = ? WHERE id = ?", libsql.JSON(tags), id)
+func JSON(v any) JSONValue {
+	return JSONValue{v: v}
+}
+
+func (j JSONValue) Scan(src any) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		data = []byte("null")
+	case string:
+		data = []byte(src)
+	case []byte:
+		data = src
+	default:
+		return fmt.Errorf("cannot scan %T into JSON, the column must hold text", src)
+	}
+	if err := json.Unmarshal(data, j.v); err != nil {
+		return fmt.Errorf("failed to decode JSON column: %w", err)
+	}
+	return nil
+}
+
+func (j JSONValue) Value() (driver.Value, error) {
+	data, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON argument: %w", err)
+	}
+	return string(data), nil
+}
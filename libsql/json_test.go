@@ -0,0 +1,72 @@
+package libsql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONScan(t *testing.T) {
+	type doc struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	tests := []struct {
+		name    string
+		dst     map[string]any
+		src     any
+		want    map[string]any
+		wantErr bool
+	}{
+		{name: "text", src: `{"a":1}`, want: map[string]any{"a": float64(1)}},
+		{name: "blob", src: []byte(`{"a":"b"}`), want: map[string]any{"a": "b"}},
+		{name: "null", dst: map[string]any{"old": true}, src: nil, want: nil},
+		{name: "invalid", src: `{`, wantErr: true},
+		{name: "integer", src: int64(1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.dst
+			err := JSON(&got).Scan(tt.src)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	var d doc
+	if err := JSON(&d).Scan(`{"name":"x","tags":["a"]}`); err != nil {
+		t.Fatal(err)
+	}
+	if want := (doc{Name: "x", Tags: []string{"a"}}); !reflect.DeepEqual(d, want) {
+		t.Errorf("got %#v, want %#v", d, want)
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	v, err := JSON(map[string]int{"a": 1}).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `{"a":1}` {
+		t.Errorf("got %#v, want the JSON text", v)
+	}
+	if _, err := JSON(func() {}).Value(); err == nil {
+		t.Error("expected error for a value that cannot be encoded")
+	}
+	nv, err := (Connector{}).checker.ConvertValue(JSON([]int{1, 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nv != "[1,2]" {
+		t.Errorf("got %#v, want the argument bound as text", nv)
+	}
+}
@@ -0,0 +1,298 @@
+// Package libsqlbranch creates branches of Turso databases with the Turso
+// Platform API, so integration tests can run against a throwaway copy of a
+// database:
+//
+//	client := libsqlbranch.New("my-org", os.Getenv("TURSO_API_TOKEN"))
+//	db := libsqlbranch.ForTest(t, client, "my-db")
+//
+// A branch is a database of its own, seeded with the content of its parent and
+// billed like any other database until it is deleted.
+package libsqlbranch
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql"
+)
+
+// DefaultBaseURL is the URL of the Turso Platform API.
+const DefaultBaseURL = "https://api.turso.tech"
+
+// Client manages the databases of a Turso organization.
+type Client struct {
+	organization string
+	apiToken     string
+	baseURL      string
+	httpClient   *http.Client
+}
+
+// New returns a client for the databases of organization, authenticated with a
+// Platform API token as created by `turso auth api-tokens mint`.
+func New(organization, apiToken string) *Client {
+	return &Client{organization: organization, apiToken: apiToken, baseURL: DefaultBaseURL, httpClient: http.DefaultClient}
+}
+
+// BranchOptions configures a new branch.
+type BranchOptions struct {
+	// Name is the name of the branch, the name of the parent followed by a
+	// random suffix if empty.
+	Name string
+	// Group is the group the branch is placed in, the group of the parent if
+	// empty.
+	Group string
+	// Timestamp, if set, seeds the branch with the parent as it was at that
+	// point in time instead of its current content.
+	Timestamp time.Time
+}
+
+// Branch is a database created by CreateBranch.
+type Branch struct {
+	Name   string
+	Parent string
+	// Hostname is the host serving the branch, like name-org.turso.io.
+	Hostname string
+	// AuthToken grants full access to the branch.
+	AuthToken string
+
+	client *Client
+}
+
+type database struct {
+	Name     string `json:"Name"`
+	Hostname string `json:"Hostname"`
+	Group    string `json:"group"`
+}
+
+// CreateBranch creates a database seeded with the content of parent and an
+// auth token for it.
+func (c *Client) CreateBranch(ctx context.Context, parent string, opts *BranchOptions) (*Branch, error) {
+	if opts == nil {
+		opts = &BranchOptions{}
+	}
+	name, group := opts.Name, opts.Group
+	if name == "" {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, fmt.Errorf("failed to create branch of %s: %w", parent, err)
+		}
+		name = parent + "-" + hex.EncodeToString(suffix)
+	}
+	if group == "" {
+		db, err := c.database(ctx, parent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create branch of %s: %w", parent, err)
+		}
+		group = db.Group
+	}
+
+	seed := map[string]string{"type": "database", "name": parent}
+	if !opts.Timestamp.IsZero() {
+		seed["timestamp"] = opts.Timestamp.UTC().Format(time.RFC3339)
+	}
+	var created struct {
+		Database database `json:"database"`
+	}
+	body := map[string]any{"name": name, "group": group, "seed": seed}
+	if err := c.do(ctx, "POST", c.databasesPath(), body, &created); err != nil {
+		return nil, fmt.Errorf("failed to create branch %s of %s: %w", name, parent, err)
+	}
+	branch := &Branch{Name: name, Parent: parent, Hostname: created.Database.Hostname, client: c}
+	token, err := c.authToken(ctx, name)
+	if err != nil {
+		branch.Delete(ctx)
+		return nil, fmt.Errorf("failed to create branch %s of %s: %w", name, parent, err)
+	}
+	branch.AuthToken = token
+	return branch, nil
+}
+
+// DeleteBranch deletes the database name. Deleting a database that does not
+// exist is not an error.
+func (c *Client) DeleteBranch(ctx context.Context, name string) error {
+	err := c.do(ctx, "DELETE", c.databasesPath()+"/"+url.PathEscape(name), nil, nil)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w", name, err)
+	}
+	return nil
+}
+
+// Connector returns a connector to the database name, such as a branch created
+// earlier, with a new auth token for it.
+func (c *Client) Connector(ctx context.Context, name string, opts ...libsql.Option) (*libsql.Connector, error) {
+	db, err := c.database(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", name, err)
+	}
+	token, err := c.authToken(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", name, err)
+	}
+	return libsql.NewConnector(databaseURL(db.Hostname, token), opts...)
+}
+
+// URL returns the database URL of the branch, including its auth token, for
+// sql.Open("libsql", branch.URL()).
+func (b *Branch) URL() string {
+	return databaseURL(b.Hostname, b.AuthToken)
+}
+
+// Connector returns a connector to the branch.
+func (b *Branch) Connector(opts ...libsql.Option) (*libsql.Connector, error) {
+	return libsql.NewConnector(b.URL(), opts...)
+}
+
+// Delete deletes the branch.
+func (b *Branch) Delete(ctx context.Context) error {
+	return b.client.DeleteBranch(ctx, b.Name)
+}
+
+// ForTest creates a branch of parent for the test and returns a database
+// connected to it. The database is closed and the branch deleted when the test
+// and its subtests complete. The test fails at once if the branch cannot be
+// created.
+func ForTest(t testing.TB, client *Client, parent string, opts ...libsql.Option) *sql.DB {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	branch, err := client.CreateBranch(ctx, parent, &BranchOptions{Name: testBranchName(t, parent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector, err := branch.Connector(opts...)
+	if err != nil {
+		branch.Delete(ctx)
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() {
+		db.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := branch.Delete(ctx); err != nil {
+			t.Errorf("%s, delete it by hand", err)
+		}
+	})
+	return db
+}
+
+// testBranchName names the branch of a test after the test, so leftover
+// branches can be traced back to it. Database names are made of lower case
+// letters, digits and dashes.
+func testBranchName(t testing.TB, parent string) string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, t.Name())
+	name = parent + "-" + name
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	return strings.Trim(name, "-") + "-" + hex.EncodeToString(suffix)
+}
+
+func databaseURL(hostname, token string) string {
+	return "libsql://" + hostname + "?authToken=" + url.QueryEscape(token)
+}
+
+func (c *Client) databasesPath() string {
+	return "/v1/organizations/" + url.PathEscape(c.organization) + "/databases"
+}
+
+func (c *Client) database(ctx context.Context, name string) (*database, error) {
+	var resp struct {
+		Database database `json:"database"`
+	}
+	if err := c.do(ctx, "GET", c.databasesPath()+"/"+url.PathEscape(name), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Database, nil
+}
+
+func (c *Client) authToken(ctx context.Context, name string) (string, error) {
+	var resp struct {
+		JWT string `json:"jwt"`
+	}
+	path := c.databasesPath() + "/" + url.PathEscape(name) + "/auth/tokens?authorization=full-access"
+	if err := c.do(ctx, "POST", path, nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to create auth token: %w", err)
+	}
+	return resp.JWT, nil
+}
+
+// apiError is an error response of the Platform API.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("platform API returned %d %s: %s", e.status, http.StatusText(e.status), e.message)
+}
+
+// do sends a request to the Platform API and decodes its response into out,
+// unless out is nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.baseURL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var msg struct {
+			Error string `json:"error"`
+		}
+		message := strings.TrimSpace(string(respBody))
+		if json.Unmarshal(respBody, &msg) == nil && msg.Error != "" {
+			message = msg.Error
+		}
+		return &apiError{status: resp.StatusCode, message: message}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode platform API response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package libsqlbranch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newPlatformServer fakes the Platform API for the parent database of the
+// default group, recording the requests it receives.
+func newPlatformServer(t *testing.T, mu *sync.Mutex, received *[]string) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer api-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid token"}`)
+			return
+		}
+		var body map[string]any
+		if r.Method == "POST" && r.Body != nil {
+			json.NewDecoder(r.Body).Decode(&body)
+		}
+		mu.Lock()
+		request := r.Method + " " + r.URL.RequestURI()
+		if body != nil {
+			encoded, _ := json.Marshal(body)
+			request += " " + string(encoded)
+		}
+		*received = append(*received, request)
+		mu.Unlock()
+		path := strings.TrimPrefix(r.URL.Path, "/v1/organizations/org/databases")
+		switch {
+		case r.Method == "GET" && path == "/parent":
+			fmt.Fprint(w, `{"database":{"Name":"parent","Hostname":"parent-org.turso.io","group":"default"}}`)
+		case r.Method == "POST" && path == "":
+			fmt.Fprintf(w, `{"database":{"Name":%q,"Hostname":"%s-org.turso.io"}}`, body["name"], body["name"])
+		case r.Method == "POST" && strings.HasSuffix(path, "/auth/tokens"):
+			fmt.Fprint(w, `{"jwt":"db-token"}`)
+		case r.Method == "DELETE" && path == "/branch":
+			fmt.Fprint(w, `{"database":"branch"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"database not found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	client := New("org", "api-token")
+	client.baseURL = server.URL
+	return client
+}
+
+func TestCreateBranch(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	client := newPlatformServer(t, &mu, &received)
+	ctx := context.Background()
+
+	branch, err := client.CreateBranch(ctx, "parent", &BranchOptions{Name: "branch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "libsql://branch-org.turso.io?authToken=db-token"; branch.URL() != want {
+		t.Errorf("got URL %s, want %s", branch.URL(), want)
+	}
+	if err := branch.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteBranch(ctx, "missing"); err != nil {
+		t.Errorf("got %v, want deleting a missing database to succeed", err)
+	}
+
+	want := []string{
+		"GET /v1/organizations/org/databases/parent",
+		`POST /v1/organizations/org/databases {"group":"default","name":"branch","seed":{"name":"parent","type":"database"}}`,
+		"POST /v1/organizations/org/databases/branch/auth/tokens?authorization=full-access",
+		"DELETE /v1/organizations/org/databases/branch",
+		"DELETE /v1/organizations/org/databases/missing",
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("got requests %#v, want %#v", received, want)
+	}
+}
+
+func TestCreateBranchErrors(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	client := newPlatformServer(t, &mu, &received)
+	ctx := context.Background()
+
+	_, err := client.CreateBranch(ctx, "missing", nil)
+	if err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Errorf("got %v, want the error of the API", err)
+	}
+	client.apiToken = "wrong"
+	_, err = client.CreateBranch(ctx, "parent", &BranchOptions{Group: "default"})
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized: invalid token") {
+		t.Errorf("got %v, want the API token to be rejected", err)
+	}
+}
+
+func TestForTest(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	client := newPlatformServer(t, &mu, &received)
+	var name string
+	t.Run("Sub/Test", func(t *testing.T) {
+		if db := ForTest(t, client, "parent"); db == nil {
+			t.Fatal("expected a database")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var body struct{ Name string }
+		json.Unmarshal([]byte(received[1][strings.Index(received[1], "{"):]), &body)
+		name = body.Name
+	})
+	if !strings.HasPrefix(name, "parent-testfortest-sub-test-") {
+		t.Errorf("got branch name %s, want it to be named after the test", name)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if last := received[len(received)-1]; last != "DELETE /v1/organizations/org/databases/"+name {
+		t.Errorf("got last request %s, want the branch to be deleted", last)
+	}
+}
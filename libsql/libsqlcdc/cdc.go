@@ -0,0 +1,251 @@
+// Package libsqlcdc delivers the rows inserted, updated and deleted in tables
+// to a Go channel, for cache invalidation and pipelines reacting to changes
+// in near real time:
+//
+//	err := libsqlcdc.Install(ctx, db, []string{"users", "orders"}, nil)
+//	// ...
+//	w := libsqlcdc.Watch(ctx, db, &libsqlcdc.Options{After: lastSeen})
+//	for change := range w.Changes() {
+//		log.Printf("%s %s rowid %d", change.Op, change.Table, change.RowID)
+//	}
+//	err = w.Err()
+//
+// sqld does not expose the update hooks of SQLite over its protocols, so the
+// changes are captured by triggers writing them to a changes table in the same
+// transaction as the change itself, which Watch polls. Changes are delivered
+// once committed, in commit order, whichever client made them.
+package libsqlcdc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql"
+)
+
+// DefaultTable is the table the changes are written to.
+const DefaultTable = "_libsql_changes"
+
+// DefaultInterval is the default delay between two polls of the changes table.
+const DefaultInterval = time.Second
+
+// DefaultBatchSize is the default number of changes read by a poll.
+const DefaultBatchSize = 100
+
+// Op is the kind of change made to a row.
+type Op string
+
+const (
+	Insert Op = "INSERT"
+	Update Op = "UPDATE"
+	Delete Op = "DELETE"
+)
+
+var ops = []Op{Insert, Update, Delete}
+
+// Change is a row inserted, updated or deleted.
+type Change struct {
+	// ID is the position of the change in the changes table. IDs increase
+	// with every change and are never reused, so the ID of the last change
+	// handled is where to resume watching with Options.After.
+	ID    int64
+	Table string
+	Op    Op
+	// RowID is the rowid of the row, after the change for inserts and
+	// updates, before it for deletes.
+	RowID int64
+	// ChangedAt is the time the change was recorded, in seconds.
+	ChangedAt time.Time
+}
+
+type Options struct {
+	// Table is the changes table, DefaultTable if empty.
+	Table string
+	// Interval is the delay between two polls, DefaultInterval if zero. The
+	// changes table is polled again at once while polls return full batches.
+	Interval time.Duration
+	// BatchSize is the number of changes read by a poll, DefaultBatchSize if
+	// zero.
+	BatchSize int
+	// After makes Watch deliver only the changes with a greater ID.
+	After int64
+}
+
+func (o *Options) table() string {
+	if o == nil || o.Table == "" {
+		return DefaultTable
+	}
+	return o.Table
+}
+
+// Install creates the changes table and the triggers recording the changes
+// made to tables into it, unless they exist. Tables must have a rowid, WITHOUT
+// ROWID tables are not supported. The triggers run in the transaction of the
+// change, so they add a write to each changed row.
+func Install(ctx context.Context, db *sql.DB, tables []string, opts *Options) error {
+	changes := opts.table()
+	stmts := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id INTEGER PRIMARY KEY AUTOINCREMENT, "+
+		"tbl TEXT NOT NULL, op TEXT NOT NULL, row_id INTEGER NOT NULL, changed_at INTEGER NOT NULL DEFAULT (CAST(strftime('%%s', 'now') AS INTEGER)))",
+		quoteIdentifier(changes))}
+	for _, table := range tables {
+		for _, op := range ops {
+			row := "NEW"
+			if op == Delete {
+				row = "OLD"
+			}
+			stmts = append(stmts, fmt.Sprintf("CREATE TRIGGER IF NOT EXISTS %s AFTER %s ON %s BEGIN "+
+				"INSERT INTO %s (tbl, op, row_id) VALUES (%s, '%s', %s.rowid); END",
+				quoteIdentifier(triggerName(changes, table, op)), op, quoteIdentifier(table),
+				quoteIdentifier(changes), quoteString(table), op, row))
+		}
+	}
+	return inTx(ctx, db, stmts)
+}
+
+// Uninstall drops the triggers recording the changes made to tables. The
+// changes table is kept, drop it once no table is watched.
+func Uninstall(ctx context.Context, db *sql.DB, tables []string, opts *Options) error {
+	changes := opts.table()
+	var stmts []string
+	for _, table := range tables {
+		for _, op := range ops {
+			stmts = append(stmts, "DROP TRIGGER IF EXISTS "+quoteIdentifier(triggerName(changes, table, op)))
+		}
+	}
+	return inTx(ctx, db, stmts)
+}
+
+// Prune deletes the changes up to the one with ID upTo, once every watcher has
+// handled them.
+func Prune(ctx context.Context, db *sql.DB, upTo int64, opts *Options) (int64, error) {
+	res, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id <= ?", quoteIdentifier(opts.table())), upTo)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Watcher polls the changes table.
+type Watcher struct {
+	changes chan Change
+	err     error
+}
+
+// Watch polls the changes table of db and delivers its changes in order to
+// the channel of the returned Watcher, until ctx is done or a poll fails with
+// an error that is not retryable, see libsql.IsRetryable. Polls failing with
+// retryable errors, like while the server is unreachable, are tried again
+// after the interval.
+func Watch(ctx context.Context, db *sql.DB, opts *Options) *Watcher {
+	if opts == nil {
+		opts = &Options{}
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	query := fmt.Sprintf("SELECT id, tbl, op, row_id, changed_at FROM %s WHERE id > ? ORDER BY id LIMIT ?", quoteIdentifier(opts.table()))
+	w := &Watcher{changes: make(chan Change)}
+	go func() {
+		defer close(w.changes)
+		w.err = w.poll(ctx, db, query, opts.After, interval, batchSize)
+	}()
+	return w
+}
+
+// Changes returns the channel the changes are delivered to. It is closed once
+// the watcher stopped, see Err.
+func (w *Watcher) Changes() <-chan Change {
+	return w.changes
+}
+
+// Err returns the error that stopped the watcher, the error of its context if
+// it was canceled. It must only be called once the channel of Changes is
+// closed.
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+func (w *Watcher) poll(ctx context.Context, db *sql.DB, query string, after int64, interval time.Duration, batchSize int) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		batch, err := readChanges(ctx, db, query, after, batchSize)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && !libsql.IsRetryable(err) {
+			return fmt.Errorf("failed to read changes: %w", err)
+		}
+		for _, change := range batch {
+			select {
+			case w.changes <- change:
+				after = change.ID
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if len(batch) == batchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(interval)
+		}
+	}
+}
+
+func readChanges(ctx context.Context, db *sql.DB, query string, after int64, batchSize int) ([]Change, error) {
+	rows, err := db.QueryContext(ctx, query, after, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var batch []Change
+	for rows.Next() {
+		var c Change
+		var changedAt int64
+		if err := rows.Scan(&c.ID, &c.Table, &c.Op, &c.RowID, &changedAt); err != nil {
+			return nil, err
+		}
+		c.ChangedAt = time.Unix(changedAt, 0)
+		batch = append(batch, c)
+	}
+	return batch, rows.Err()
+}
+
+func inTx(ctx context.Context, db *sql.DB, stmts []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func triggerName(changes, table string, op Op) string {
+	return changes + "_" + table + "_" + strings.ToLower(string(op))
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,124 @@
+package libsqlcdc
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+const pollQuery = `SELECT id, tbl, op, row_id, changed_at FROM "_libsql_changes" WHERE id > ? ORDER BY id LIMIT ?`
+
+// changes returns the result of a poll returning rows.
+func changes(rows ...[]driver.Value) *libsqltest.Result {
+	return &libsqltest.Result{Cols: []string{"id", "tbl", "op", "row_id", "changed_at"}, Rows: rows}
+}
+
+func TestInstallUninstall(t *testing.T) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		return &libsqltest.Result{Affected: 42}, nil
+	})
+	db, _ := libsqltest.Open(t, f)
+	ctx := context.Background()
+	if err := Install(ctx, db, []string{"o'rders"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := Uninstall(ctx, db, []string{"users"}, &Options{Table: "changes"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Prune(ctx, db, 42, nil); err != nil || n != 42 {
+		t.Errorf("got %d, %v, want 42 pruned changes", n, err)
+	}
+
+	want := []string{
+		"BEGIN",
+		`CREATE TABLE IF NOT EXISTS "_libsql_changes" (id INTEGER PRIMARY KEY AUTOINCREMENT, tbl TEXT NOT NULL, op TEXT NOT NULL, ` +
+			`row_id INTEGER NOT NULL, changed_at INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER)))`,
+		`CREATE TRIGGER IF NOT EXISTS "_libsql_changes_o'rders_insert" AFTER INSERT ON "o'rders" BEGIN ` +
+			`INSERT INTO "_libsql_changes" (tbl, op, row_id) VALUES ('o''rders', 'INSERT', NEW.rowid); END`,
+		`CREATE TRIGGER IF NOT EXISTS "_libsql_changes_o'rders_update" AFTER UPDATE ON "o'rders" BEGIN ` +
+			`INSERT INTO "_libsql_changes" (tbl, op, row_id) VALUES ('o''rders', 'UPDATE', NEW.rowid); END`,
+		`CREATE TRIGGER IF NOT EXISTS "_libsql_changes_o'rders_delete" AFTER DELETE ON "o'rders" BEGIN ` +
+			`INSERT INTO "_libsql_changes" (tbl, op, row_id) VALUES ('o''rders', 'DELETE', OLD.rowid); END`,
+		"COMMIT",
+		"BEGIN",
+		`DROP TRIGGER IF EXISTS "changes_users_insert"`,
+		`DROP TRIGGER IF EXISTS "changes_users_update"`,
+		`DROP TRIGGER IF EXISTS "changes_users_delete"`,
+		"COMMIT",
+		`DELETE FROM "_libsql_changes" WHERE id <= ? 42`,
+	}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	failure := errors.New("no such table: _libsql_changes")
+	polls := 0
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		polls++
+		switch polls {
+		case 1:
+			return changes(
+				[]driver.Value{int64(11), "users", "INSERT", int64(1), int64(1704164645)},
+				[]driver.Value{int64(12), "users", "UPDATE", int64(1), int64(1704164646)}), nil
+		case 2:
+			return changes([]driver.Value{int64(13), "orders", "DELETE", int64(7), int64(1704164647)}), nil
+		case 3:
+			return changes(), nil
+		}
+		return nil, failure
+	})
+	db, _ := libsqltest.Open(t, f)
+
+	w := Watch(context.Background(), db, &Options{Interval: time.Millisecond, BatchSize: 2, After: 10})
+	var got []Change
+	for change := range w.Changes() {
+		got = append(got, change)
+	}
+	want := []Change{
+		{ID: 11, Table: "users", Op: Insert, RowID: 1, ChangedAt: time.Unix(1704164645, 0)},
+		{ID: 12, Table: "users", Op: Update, RowID: 1, ChangedAt: time.Unix(1704164646, 0)},
+		{ID: 13, Table: "orders", Op: Delete, RowID: 7, ChangedAt: time.Unix(1704164647, 0)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if w.Err() == nil || !strings.Contains(w.Err().Error(), failure.Error()) {
+		t.Errorf("got %v, want the poll to fail with %v", w.Err(), failure)
+	}
+	// A full batch is followed by another poll at once.
+	wantPolls := []string{pollQuery + " 10 2", pollQuery + " 12 2", pollQuery + " 13 2", pollQuery + " 13 2"}
+	if got := executed(); !reflect.DeepEqual(got, wantPolls) {
+		t.Errorf("got %q, want %q", got, wantPolls)
+	}
+}
+
+func TestWatchCanceled(t *testing.T) {
+	f, _ := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		if stmt == fmt.Sprintf("%s 0 %d", pollQuery, DefaultBatchSize) {
+			return changes([]driver.Value{int64(1), "users", "INSERT", int64(1), int64(0)}), nil
+		}
+		return changes(), nil
+	})
+	db, _ := libsqltest.Open(t, f)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := Watch(ctx, db, nil)
+	if change := <-w.Changes(); change.ID != 1 {
+		t.Errorf("got %+v, want change 1", change)
+	}
+	cancel()
+	for range w.Changes() {
+	}
+	if !errors.Is(w.Err(), context.Canceled) {
+		t.Errorf("got %v, want the context error", w.Err())
+	}
+}
@@ -0,0 +1,238 @@
+// Package libsqlclient is a low-level client for sqld that does not go through
+// database/sql. It exposes features that do not map onto database/sql, such as
+// batches, replication indexes and column metadata.
+//
+// Every call is sent as a single Hrana over HTTP pipeline on a fresh stream, so
+// a Client holds no connection state and is safe for concurrent use.
+package libsqlclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/dburl"
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/hranaV2"
+	"github.com/libsql/libsql-client-go/libsql/internal/params"
+)
+
+type Client struct {
+	url   string
+	token *auth.Token
+
+	// version is the detected Hrana version, 0 until detected.
+	versionMu sync.Mutex
+	version   int
+}
+
+// Statement is a SQL statement with either positional or named arguments.
+type Statement struct {
+	SQL       string
+	Args      []any
+	NamedArgs map[string]any
+}
+
+type Column struct {
+	Name     string
+	DeclType string
+}
+
+type ResultSet struct {
+	Columns          []Column
+	Rows             [][]any
+	AffectedRowCount int64
+	LastInsertRowId  int64
+	// ReplicationIndex is the replication index of the write, if the server
+	// reported it.
+	ReplicationIndex uint64
+}
+
+type pipeliner interface {
+	Pipeline(ctx context.Context, msg *hrana.PipelineRequest) (*hrana.PipelineResponse, error)
+}
+
+// New creates a client for a database URL, parsed like sql.Open("libsql",
+// dbUrl) does: libsql://, https:// and http:// URLs, as well as wss:// and ws://
+// ones, are reached over HTTP, with the same auth token and tls query
+// parameters. Query parameters configuring the connections of database/sql
+// are validated but do not apply to the client.
+func New(dbUrl string) (*Client, error) {
+	p, err := dburl.Parse(dbUrl)
+	if err != nil {
+		return nil, err
+	}
+	if p.URL == nil {
+		return nil, fmt.Errorf("%s is not a remote database", dbUrl)
+	}
+	return &Client{url: dburl.HTTP(*p.URL, p.TLS).String(), token: auth.Static(p.Token)}, nil
+}
+
+func (c *Client) protocolVersion(ctx context.Context) (int, error) {
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	if c.version > 0 {
+		return c.version, nil
+	}
+	version := hranaV2.ProtocolVersion(ctx, c.url, c.token, &config.Config{})
+	if version == 0 {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("server does not support Hrana over HTTP")
+	}
+	c.version = version
+	return version, nil
+}
+
+func (c *Client) pipeline(ctx context.Context, requests ...hrana.StreamRequest) (*hrana.PipelineResponse, error) {
+	version, err := c.protocolVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := &hrana.PipelineRequest{}
+	for _, request := range requests {
+		msg.Add(request)
+	}
+	msg.Add(hrana.CloseStream())
+	conn := hranaV2.Connect(c.url, c.token, version, &config.Config{}).(pipeliner)
+	result, err := conn.Pipeline(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Results) == 0 {
+		return nil, errors.New("no response received")
+	}
+	if result.Results[0].Error != nil {
+		return nil, result.Results[0].Error.Err()
+	}
+	if result.Results[0].Response == nil {
+		return nil, errors.New("no response received")
+	}
+	return result, nil
+}
+
+func toStmt(s Statement) (*hrana.Stmt, error) {
+	if len(s.Args) > 0 && len(s.NamedArgs) > 0 {
+		return nil, fmt.Errorf("statement cannot have both positional and named arguments")
+	}
+	var checker params.Checker
+	sql := s.SQL
+	stmt := &hrana.Stmt{Sql: &sql, WantRows: true}
+	args := make([]any, len(s.Args))
+	for idx, arg := range s.Args {
+		v, err := checker.ConvertValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[idx] = v
+	}
+	if err := stmt.AddPositionalArgs(args); err != nil {
+		return nil, err
+	}
+	if len(s.NamedArgs) > 0 {
+		namedArgs := make(map[string]any, len(s.NamedArgs))
+		for name, arg := range s.NamedArgs {
+			v, err := checker.ConvertValue(arg)
+			if err != nil {
+				return nil, err
+			}
+			namedArgs[name] = v
+		}
+		if err := stmt.AddNamedArgs(namedArgs); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func toResultSet(r *hrana.StmtResult) *ResultSet {
+	res := &ResultSet{
+		Columns:          make([]Column, len(r.Cols)),
+		Rows:             make([][]any, len(r.Rows)),
+		AffectedRowCount: int64(r.AffectedRowCount),
+		LastInsertRowId:  r.GetLastInsertRowId(),
+		ReplicationIndex: r.GetReplicationIndex(),
+	}
+	for idx, col := range r.Cols {
+		if col.Name != nil {
+			res.Columns[idx].Name = *col.Name
+		}
+		if col.Type != nil {
+			res.Columns[idx].DeclType = *col.Type
+		}
+	}
+	for idx, row := range r.Rows {
+		values := make([]any, len(row))
+		for col, v := range row {
+			values[col] = v.ToValue()
+		}
+		res.Rows[idx] = values
+	}
+	return res
+}
+
+// Execute runs a single statement with positional arguments.
+func (c *Client) Execute(ctx context.Context, sql string, args ...any) (*ResultSet, error) {
+	return c.ExecuteStatement(ctx, Statement{SQL: sql, Args: args})
+}
+
+func (c *Client) ExecuteStatement(ctx context.Context, s Statement) (*ResultSet, error) {
+	stmt, err := toStmt(s)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.pipeline(ctx, hrana.StreamRequest{Type: "execute", Stmt: stmt})
+	if err != nil {
+		return nil, err
+	}
+	res, err := result.Results[0].Response.ExecuteResult()
+	if err != nil {
+		return nil, err
+	}
+	return toResultSet(res), nil
+}
+
+// Batch runs the statements in order in a single round trip and returns one
+// result set per statement. Execution stops at the first statement that fails
+// and its error is returned. The batch is not atomic, statements that ran
+// before the failure are not rolled back.
+func (c *Client) Batch(ctx context.Context, stmts []Statement) ([]*ResultSet, error) {
+	batch := &hrana.Batch{}
+	for idx, s := range stmts {
+		stmt, err := toStmt(s)
+		if err != nil {
+			return nil, err
+		}
+		step := hrana.BatchStep{Stmt: *stmt}
+		if idx > 0 {
+			prev := int32(idx - 1)
+			step.Condition = &hrana.BatchCondition{Type: "ok", Step: &prev}
+		}
+		batch.Steps = append(batch.Steps, step)
+	}
+	result, err := c.pipeline(ctx, hrana.StreamRequest{Type: "batch", Batch: batch})
+	if err != nil {
+		return nil, err
+	}
+	res, err := result.Results[0].Response.BatchResult()
+	if err != nil {
+		return nil, err
+	}
+	sets := make([]*ResultSet, len(res.StepResults))
+	for idx, r := range res.StepResults {
+		if r != nil {
+			sets[idx] = toResultSet(r)
+		}
+	}
+	return sets, nil
+}
+
+// Value converts v the same way the libsql driver converts query arguments.
+func Value(v any) (driver.Value, error) {
+	return params.Checker{}.ConvertValue(v)
+}
@@ -0,0 +1,175 @@
+package libsqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newServer(t *testing.T, response string, requests *[]map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			if r.URL.Path != "/v3" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+		if r.URL.Path != "/v3/pipeline" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("got Authorization %#v", got)
+		}
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		*requests = append(*requests, req)
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+func TestExecute(t *testing.T) {
+	var requests []map[string]any
+	server := newServer(t, `{"baton":null,"base_url":null,"results":[{"type":"ok","response":{"type":"execute","result":{`+
+		`"cols":[{"name":"id","decltype":"INTEGER"},{"name":"name","decltype":"TEXT"}],`+
+		`"rows":[[{"type":"integer","value":"1"},{"type":"text","value":"one"}]],`+
+		`"affected_row_count":0,"last_insert_rowid":"7","replication_index":"42"}}},{"type":"ok","response":{"type":"close"}}]}`, &requests)
+	defer server.Close()
+
+	client, err := New(server.URL + "?authToken=token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := client.Execute(context.Background(), "SELECT id, name FROM t WHERE id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ResultSet{
+		Columns:          []Column{{Name: "id", DeclType: "INTEGER"}, {Name: "name", DeclType: "TEXT"}},
+		Rows:             [][]any{{int64(1), "one"}},
+		LastInsertRowId:  7,
+		ReplicationIndex: 42,
+	}
+	if !reflect.DeepEqual(rs, want) {
+		t.Errorf("got %#v, want %#v", rs, want)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if got := len(requests[0]["requests"].([]any)); got != 2 {
+		t.Errorf("got %d stream requests, want execute and close", got)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var requests []map[string]any
+	server := newServer(t, `{"baton":null,"base_url":null,"results":[{"type":"ok","response":{"type":"batch","result":{`+
+		`"step_results":[{"cols":[],"rows":[],"affected_row_count":1,"last_insert_rowid":"1"},null],`+
+		`"step_errors":[null,{"message":"UNIQUE constraint failed"}]}}},{"type":"ok","response":{"type":"close"}}]}`, &requests)
+	defer server.Close()
+
+	client, err := New(server.URL + "?auth_token=token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Batch(context.Background(), []Statement{
+		{SQL: "INSERT INTO t VALUES (?)", Args: []any{1}},
+		{SQL: "INSERT INTO t VALUES (:id)", NamedArgs: map[string]any{"id": 1}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "UNIQUE") {
+		t.Fatalf("got %v, want error of the second statement", err)
+	}
+	steps := requests[0]["requests"].([]any)[0].(map[string]any)["batch"].(map[string]any)["steps"].([]any)
+	if _, ok := steps[0].(map[string]any)["condition"]; ok {
+		t.Error("first step should not have a condition")
+	}
+	if _, ok := steps[1].(map[string]any)["condition"]; !ok {
+		t.Error("second step should depend on the first")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{url: "libsql://db.turso.io?authToken=x", want: "https://db.turso.io"},
+		{url: "libsql://db.turso.io?auth_token=x", want: "https://db.turso.io"},
+		{url: "libsql://db.turso.io?jwt=x", want: "https://db.turso.io"},
+		{url: "libsql://127.0.0.1:8080?tls=0", want: "http://127.0.0.1:8080"},
+		{url: "https://db.turso.io", want: "https://db.turso.io"},
+		{url: "http://127.0.0.1:8080", want: "http://127.0.0.1:8080"},
+		{url: "wss://db.turso.io", want: "https://db.turso.io"},
+		{url: "ws://127.0.0.1:8080", want: "http://127.0.0.1:8080"},
+		{url: "libsql://db.turso.io?tls=0", wantErr: true},
+		{url: "libsql://db.turso.io:8080?tls=0", wantErr: true},
+		{url: "http://db.turso.io", wantErr: true},
+		{url: "libsql://db.turso.io?authToken=x&jwt=y", wantErr: true},
+		{url: "libsql://db.turso.io?foo=bar", wantErr: true},
+		{url: "file:test.db", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			client, err := New(tt.url)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if client.url != tt.want {
+				t.Errorf("got %s, want %s", client.url, tt.want)
+			}
+		})
+	}
+}
+
+func TestRaw(t *testing.T) {
+	var requests []map[string]any
+	server := newServer(t, `{"baton":null,"base_url":null,"results":[`+
+		`{"type":"ok","response":{"type":"get_autocommit","is_autocommit":true}},`+
+		`{"type":"error","error":{"message":"unknown request type"}},`+
+		`{"type":"ok","response":{"type":"close"}}]}`, &requests)
+	defer server.Close()
+
+	client, err := New(server.URL + "?authToken=token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses, err := client.Raw(context.Background(), json.RawMessage(`{"type":"get_autocommit"}`), json.RawMessage(`{"type":"future","x":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(responses[0].Response); got != `{"type":"get_autocommit","is_autocommit":true}` {
+		t.Errorf("got response %s", got)
+	}
+	if responses[1].Err == nil || responses[1].Response != nil {
+		t.Errorf("got %#v, want the error of the second request", responses[1])
+	}
+	sent := requests[0]["requests"].([]any)
+	if want := map[string]any{"type": "future", "x": float64(1)}; !reflect.DeepEqual(sent[1], want) {
+		t.Errorf("sent %#v, want %#v", sent[1], want)
+	}
+
+	client.version = 2
+	if _, err := client.Raw(context.Background(), json.RawMessage(`{"type":"get_autocommit"}`)); err == nil {
+		t.Error("expected get_autocommit to be rejected on Hrana 2")
+	}
+	if _, err := client.Raw(context.Background(), json.RawMessage(`{"x":1}`)); err == nil {
+		t.Error("expected a request without type to be rejected")
+	}
+	if len(requests) != 1 {
+		t.Errorf("got %d requests, want rejected requests not to be sent", len(requests))
+	}
+}
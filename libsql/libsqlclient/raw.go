@@ -0,0 +1,64 @@
+package libsqlclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/hranaV2"
+)
+
+// RawResponse is the response to a request sent with Raw.
+type RawResponse struct {
+	// Response is the response object, nil if the request failed.
+	Response json.RawMessage
+	// Err is the error returned by the server for the request.
+	Err error
+}
+
+type rawPipeliner interface {
+	RawPipeline(ctx context.Context, msg *hrana.PipelineRequest) (*hrana.RawPipelineResponse, error)
+}
+
+// Raw sends Hrana stream requests as they are, in a single pipeline on a fresh
+// stream, and returns their responses in order. Each request is a JSON object
+// with a "type" field, so request types this package does not know about yet
+// can be tried. Requests of known types that need a newer Hrana version than
+// the server supports are rejected before sending.
+//
+// Raw is experimental, it may change or be removed in any release.
+func (c *Client) Raw(ctx context.Context, requests ...json.RawMessage) ([]RawResponse, error) {
+	version, err := c.protocolVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := &hrana.PipelineRequest{}
+	for _, request := range requests {
+		r, err := hrana.RawStream(request)
+		if err != nil {
+			return nil, err
+		}
+		msg.Add(r)
+	}
+	msg.Add(hrana.CloseStream())
+	conn := hranaV2.Connect(c.url, c.token, version, &config.Config{}).(rawPipeliner)
+	result, err := conn.RawPipeline(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Results) < len(requests) {
+		return nil, errors.New("no response received")
+	}
+	responses := make([]RawResponse, len(requests))
+	for idx := range requests {
+		r := result.Results[idx]
+		if r.Error != nil {
+			responses[idx].Err = r.Error.Err()
+			continue
+		}
+		responses[idx].Response = r.Response
+	}
+	return responses, nil
+}
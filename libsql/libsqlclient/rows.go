@@ -0,0 +1,96 @@
+package libsqlclient
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/hranaV2"
+)
+
+// Rows is a result set decoded from the response of the server as it is read,
+// see Query.
+type Rows struct {
+	conn    driver.Conn
+	rows    driver.Rows
+	columns []Column
+	values  []driver.Value
+	row     []any
+}
+
+// Query runs a statement and returns its rows without buffering the whole
+// result set, so tables larger than memory can be exported. A statement
+// holding several queries is buffered like with Execute. Rows must be closed.
+func (c *Client) Query(ctx context.Context, s Statement) (*Rows, error) {
+	if _, err := toStmt(s); err != nil {
+		return nil, err
+	}
+	version, err := c.protocolVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]driver.NamedValue, 0, len(s.Args)+len(s.NamedArgs))
+	for idx, arg := range s.Args {
+		v, err := Value(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, driver.NamedValue{Ordinal: idx + 1, Value: v})
+	}
+	for name, arg := range s.NamedArgs {
+		v, err := Value(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, driver.NamedValue{Name: name, Value: v})
+	}
+	conn := hranaV2.Connect(c.url, c.token, version, &config.Config{StreamRows: true})
+	rows, err := conn.(driver.QueryerContext).QueryContext(ctx, s.SQL, args)
+	if err != nil {
+		return nil, err
+	}
+	r := &Rows{conn: conn, rows: rows}
+	names := rows.Columns()
+	r.columns = make([]Column, len(names))
+	for idx, name := range names {
+		r.columns[idx].Name = name
+		if t, ok := rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+			r.columns[idx].DeclType = t.ColumnTypeDatabaseTypeName(idx)
+		}
+	}
+	r.values = make([]driver.Value, len(names))
+	r.row = make([]any, len(names))
+	return r, nil
+}
+
+func (r *Rows) Columns() []Column {
+	return r.columns
+}
+
+// Next returns the next row, or io.EOF after the last one. Values are int64,
+// float64, string, []byte or nil. The returned slice is reused by the next
+// call, but not the values in it.
+func (r *Rows) Next() ([]any, error) {
+	if err := r.rows.Next(r.values); err != nil {
+		return nil, err
+	}
+	for idx, v := range r.values {
+		// The driver reuses the memory of blobs from row to row.
+		if blob, ok := v.([]byte); ok {
+			v = append([]byte{}, blob...)
+		}
+		r.row[idx] = v
+	}
+	return r.row, nil
+}
+
+// Close releases the response and closes the stream of the query.
+func (r *Rows) Close() error {
+	err := r.rows.Close()
+	msg := &hrana.PipelineRequest{}
+	msg.Add(hrana.CloseStream())
+	// The stream is already closed if the server did not return a baton.
+	r.conn.(pipeliner).Pipeline(context.Background(), msg)
+	return err
+}
@@ -0,0 +1,168 @@
+// Package libsqlintrospect reads the schema of a database through the libsql
+// driver, for tooling and migration frameworks. Results come from sqlite_master
+// and the table-valued pragma functions, so the helpers work over every
+// transport and with a local sqlite driver alike.
+package libsqlintrospect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Querier is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type Table struct {
+	Name string
+	// Type is "table" or "view".
+	Type string
+	// SQL is the statement that created the table.
+	SQL string
+}
+
+type Column struct {
+	Name string
+	// Type is the declared type, empty if the column has none.
+	Type    string
+	NotNull bool
+	// Default is the SQL text of the default value, nil if there is none.
+	Default *string
+	// PrimaryKey is the position of the column in the primary key starting at
+	// 1, 0 if it is not part of it.
+	PrimaryKey int
+	// Hidden is set for generated columns and hidden columns of virtual
+	// tables.
+	Hidden bool
+}
+
+type Index struct {
+	Name   string
+	Unique bool
+	// Origin is "c" for an index created by CREATE INDEX, "u" for a UNIQUE
+	// constraint and "pk" for a PRIMARY KEY.
+	Origin  string
+	Partial bool
+	// Columns are the indexed columns in order, empty names stand for
+	// expressions.
+	Columns []string
+}
+
+type ForeignKey struct {
+	// ID groups the columns of a composite foreign key.
+	ID       int
+	Table    string
+	From     string
+	To       string
+	OnUpdate string
+	OnDelete string
+}
+
+// tablesQuery lists user tables and views, internal sqlite_ tables are left
+// out.
+const tablesQuery = "SELECT name, type, COALESCE(sql, '') FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name"
+
+const columnsQuery = `SELECT name, type, "notnull", dflt_value, pk, hidden FROM pragma_table_xinfo(?) ORDER BY cid`
+
+const indexesQuery = `SELECT name, "unique", origin, partial FROM pragma_index_list(?) ORDER BY name`
+
+const indexColumnsQuery = "SELECT COALESCE(name, '') FROM pragma_index_info(?) ORDER BY seqno"
+
+const foreignKeysQuery = `SELECT id, "table", "from", COALESCE("to", ''), on_update, on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq`
+
+// Tables returns the tables and views of the main schema sorted by name.
+func Tables(ctx context.Context, db Querier) ([]Table, error) {
+	var tables []Table
+	err := query(ctx, db, "tables", tablesQuery, nil, func(rows *sql.Rows) error {
+		var t Table
+		if err := rows.Scan(&t.Name, &t.Type, &t.SQL); err != nil {
+			return err
+		}
+		tables = append(tables, t)
+		return nil
+	})
+	return tables, err
+}
+
+// Columns returns the columns of table in declaration order. It returns no
+// columns if the table does not exist.
+func Columns(ctx context.Context, db Querier, table string) ([]Column, error) {
+	var columns []Column
+	err := query(ctx, db, "columns of "+table, columnsQuery, []any{table}, func(rows *sql.Rows) error {
+		var c Column
+		var hidden int
+		if err := rows.Scan(&c.Name, &c.Type, &c.NotNull, &c.Default, &c.PrimaryKey, &hidden); err != nil {
+			return err
+		}
+		c.Hidden = hidden != 0
+		columns = append(columns, c)
+		return nil
+	})
+	return columns, err
+}
+
+// Indexes returns the indexes of table sorted by name, including the ones
+// SQLite creates for UNIQUE and PRIMARY KEY constraints.
+func Indexes(ctx context.Context, db Querier, table string) ([]Index, error) {
+	var indexes []Index
+	err := query(ctx, db, "indexes of "+table, indexesQuery, []any{table}, func(rows *sql.Rows) error {
+		var i Index
+		if err := rows.Scan(&i.Name, &i.Unique, &i.Origin, &i.Partial); err != nil {
+			return err
+		}
+		indexes = append(indexes, i)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for idx := range indexes {
+		i := &indexes[idx]
+		err := query(ctx, db, "columns of index "+i.Name, indexColumnsQuery, []any{i.Name}, func(rows *sql.Rows) error {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return err
+			}
+			i.Columns = append(i.Columns, name)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+// ForeignKeys returns the foreign keys of table, one entry per column.
+func ForeignKeys(ctx context.Context, db Querier, table string) ([]ForeignKey, error) {
+	var keys []ForeignKey
+	err := query(ctx, db, "foreign keys of "+table, foreignKeysQuery, []any{table}, func(rows *sql.Rows) error {
+		var k ForeignKey
+		if err := rows.Scan(&k.ID, &k.Table, &k.From, &k.To, &k.OnUpdate, &k.OnDelete); err != nil {
+			return err
+		}
+		keys = append(keys, k)
+		return nil
+	})
+	return keys, err
+}
+
+// query runs q and calls scan for every row.
+func query(ctx context.Context, db Querier, what, q string, args []any, scan func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("failed to read %s: %w", what, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	return nil
+}
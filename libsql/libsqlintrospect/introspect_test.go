@@ -0,0 +1,114 @@
+package libsqlintrospect
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"testing"
+
+	_ "github.com/libsql/libsql-client-go/libsql"
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newDB returns a database answering the statements, recorded with their
+// arguments, with results and failing the others.
+func newDB(t *testing.T, results map[string]*libsqltest.Result) (*sql.DB, func() []string) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		if res, ok := results[stmt]; ok {
+			return res, nil
+		}
+		return nil, fmt.Errorf("unexpected statement %q", stmt)
+	})
+	db, _ := libsqltest.Open(t, f)
+	return db, executed
+}
+
+func TestTables(t *testing.T) {
+	db, executed := newDB(t, map[string]*libsqltest.Result{
+		tablesQuery: {Cols: []string{"name", "type", "sql"}, Rows: [][]driver.Value{
+			{"users", "table", "CREATE TABLE users (id INTEGER PRIMARY KEY)"},
+			{"active_users", "view", "CREATE VIEW active_users AS SELECT * FROM users"},
+		}},
+	})
+	tables, err := Tables(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Table{
+		{Name: "users", Type: "table", SQL: "CREATE TABLE users (id INTEGER PRIMARY KEY)"},
+		{Name: "active_users", Type: "view", SQL: "CREATE VIEW active_users AS SELECT * FROM users"},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("got %#v, want %#v", tables, want)
+	}
+	if got := executed(); !reflect.DeepEqual(got, []string{tablesQuery}) {
+		t.Errorf("got %q, want the tables query", got)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	db, _ := newDB(t, map[string]*libsqltest.Result{
+		columnsQuery + " users": {Cols: []string{"name", "type", "notnull", "dflt_value", "pk", "hidden"}, Rows: [][]driver.Value{
+			{"id", "INTEGER", int64(0), nil, int64(1), int64(0)},
+			{"name", "TEXT", int64(1), "'anonymous'", int64(0), int64(0)},
+			{"upper_name", "TEXT", int64(0), nil, int64(0), int64(2)},
+		}},
+	})
+	columns, err := Columns(context.Background(), db, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dflt := "'anonymous'"
+	want := []Column{
+		{Name: "id", Type: "INTEGER", PrimaryKey: 1},
+		{Name: "name", Type: "TEXT", NotNull: true, Default: &dflt},
+		{Name: "upper_name", Type: "TEXT", Hidden: true},
+	}
+	if !reflect.DeepEqual(columns, want) {
+		t.Errorf("got %#v, want %#v", columns, want)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	db, executed := newDB(t, map[string]*libsqltest.Result{
+		indexesQuery + " users": {Cols: []string{"name", "unique", "origin", "partial"}, Rows: [][]driver.Value{
+			{"users_email", int64(1), "u", int64(0)},
+			{"users_lower_name", int64(0), "c", int64(1)},
+		}},
+		indexColumnsQuery + " users_email":      {Cols: []string{"name"}, Rows: [][]driver.Value{{"email"}}},
+		indexColumnsQuery + " users_lower_name": {Cols: []string{"name"}, Rows: [][]driver.Value{{""}}},
+	})
+	indexes, err := Indexes(context.Background(), db, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Index{
+		{Name: "users_email", Unique: true, Origin: "u", Columns: []string{"email"}},
+		{Name: "users_lower_name", Origin: "c", Partial: true, Columns: []string{""}},
+	}
+	if !reflect.DeepEqual(indexes, want) {
+		t.Errorf("got %#v, want %#v", indexes, want)
+	}
+	wantStmts := []string{indexesQuery + " users", indexColumnsQuery + " users_email", indexColumnsQuery + " users_lower_name"}
+	if got := executed(); !reflect.DeepEqual(got, wantStmts) {
+		t.Errorf("got %q, want %q", got, wantStmts)
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	db, _ := newDB(t, map[string]*libsqltest.Result{
+		foreignKeysQuery + " posts": {Cols: []string{"id", "table", "from", "to", "on_update", "on_delete"}, Rows: [][]driver.Value{
+			{int64(0), "users", "author_id", "id", "NO ACTION", "CASCADE"},
+		}},
+	})
+	keys, err := ForeignKeys(context.Background(), db, "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ForeignKey{{Table: "users", From: "author_id", To: "id", OnUpdate: "NO ACTION", OnDelete: "CASCADE"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %#v, want %#v", keys, want)
+	}
+}
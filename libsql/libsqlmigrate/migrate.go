@@ -0,0 +1,315 @@
+// Package libsqlmigrate applies versioned SQL migrations to a database through
+// the libsql driver, recording the applied versions in a table:
+//
+//	//go:embed migrations/*.sql
+//	var files embed.FS
+//
+//	migrations, err := libsqlmigrate.Load(files, "migrations")
+//	// ...
+//	m, err := libsqlmigrate.New(db, migrations, nil)
+//	// ...
+//	applied, err := m.Up(ctx)
+//
+// Each migration is sent with the batch API of the driver, its statements and
+// the update of the table in a single atomic request, which takes one round
+// trip over HTTP. Migrations with statements that SQLite cannot run in a
+// transaction are applied one statement at a time instead.
+package libsqlmigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/libsql/libsql-client-go/libsql"
+	"github.com/libsql/sqlite-antlr4-parser/sqliteparserutils"
+)
+
+// DefaultTable is the table recording the applied migrations.
+const DefaultTable = "schema_migrations"
+
+// Migration is a versioned change of the schema.
+type Migration struct {
+	// Version orders the migrations. It is positive and unique.
+	Version int64
+	Name    string
+	// Up is the SQL script applying the migration.
+	Up string
+	// Down is the SQL script reverting the migration, empty if it cannot be
+	// reverted.
+	Down string
+}
+
+// Transactional reports whether the statements of the Up script can run in a
+// transaction, which is not the case of VACUUM, ATTACH and DETACH, of
+// transaction control statements and of the pragmas that are no-ops inside a
+// transaction, like foreign_keys.
+func (m Migration) Transactional() bool {
+	return transactional(splitScript(m.Up))
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations of dir in fsys, from files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, the latter being
+// optional. Other files are ignored. Migrations are sorted by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid version in migration file %s", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type Options struct {
+	// Table is the table recording the applied migrations, DefaultTable if
+	// empty. It is created by the first migration applied.
+	Table string
+	// DryRun makes Up and Down return the migrations they would apply or
+	// revert without changing the database.
+	DryRun bool
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	table      string
+	dryRun     bool
+}
+
+// New returns a Migrator applying migrations to db. Migrations must have
+// positive and unique versions, and are sorted by version.
+func New(db *sql.DB, migrations []Migration, opts *Options) (*Migrator, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for idx, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has version %d, want a positive version", m.Name, m.Version)
+		}
+		if idx > 0 && sorted[idx-1].Version == m.Version {
+			return nil, fmt.Errorf("several migrations have version %d", m.Version)
+		}
+	}
+	table := opts.Table
+	if table == "" {
+		table = DefaultTable
+	}
+	return &Migrator{db: db, migrations: sorted, table: table, dryRun: opts.DryRun}, nil
+}
+
+// Applied returns the versions of the applied migrations in ascending order.
+func (m *Migrator) Applied(ctx context.Context) ([]int64, error) {
+	var exists bool
+	err := m.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", m.table).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up table %s: %w", m.table, err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s ORDER BY version", quoteIdentifier(m.table)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// Pending returns the migrations that are not applied, in order.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int64]bool, len(applied))
+	for _, version := range applied {
+		done[version] = true
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies the pending migrations in order and returns them. It stops at the
+// first failing migration, after those applied before it.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil || m.dryRun || len(pending) == 0 {
+		return pending, err
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP)", quoteIdentifier(m.table))
+	if _, err := m.db.ExecContext(ctx, create); err != nil {
+		return nil, fmt.Errorf("failed to create table %s: %w", m.table, err)
+	}
+	record := fmt.Sprintf("INSERT INTO %s (version, name) VALUES (?, ?)", quoteIdentifier(m.table))
+	for idx, migration := range pending {
+		if err := m.run(ctx, migration.Up, record, migration.Version, migration.Name); err != nil {
+			return pending[:idx], fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+// Down reverts the n most recently applied migrations, newest first, and
+// returns them. It fails before reverting anything if one of them has no Down
+// script or is not known to m.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		byVersion[migration.Version] = migration
+	}
+	var reverted []Migration
+	for idx := len(applied) - 1; idx >= 0 && len(reverted) < n; idx-- {
+		migration, ok := byVersion[applied[idx]]
+		if !ok {
+			return nil, fmt.Errorf("applied migration %d is unknown", applied[idx])
+		}
+		if migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+		}
+		reverted = append(reverted, migration)
+	}
+	if m.dryRun {
+		return reverted, nil
+	}
+	record := fmt.Sprintf("DELETE FROM %s WHERE version = ?", quoteIdentifier(m.table))
+	for idx, migration := range reverted {
+		if err := m.run(ctx, migration.Down, record, migration.Version); err != nil {
+			return reverted[:idx], fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return reverted, nil
+}
+
+// errNotLibsql is returned by the function given to Raw for connections of
+// other drivers.
+var errNotLibsql = errors.New("not a libsql connection")
+
+// run executes the statements of script followed by record with args, as an
+// atomic batch when they can run in a transaction.
+func (m *Migrator) run(ctx context.Context, script, record string, args ...any) error {
+	stmts := splitScript(script)
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if !transactional(stmts) {
+		for _, stmt := range stmts {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		_, err := conn.ExecContext(ctx, record, args...)
+		return err
+	}
+
+	queries := append(stmts, record)
+	batchArgs := make([][]any, len(queries))
+	batchArgs[len(queries)-1] = args
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*libsql.Conn)
+		if !ok {
+			return errNotLibsql
+		}
+		return c.ExecBatch(ctx, queries, batchArgs)
+	})
+	if !errors.Is(err, errNotLibsql) {
+		return err
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for idx, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, batchArgs[idx]...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func splitScript(script string) []string {
+	stmts, _ := sqliteparserutils.SplitStatement(script)
+	return stmts
+}
+
+// nonTransactional matches the statements that cannot run in a transaction, or
+// have no effect in one.
+var nonTransactional = regexp.MustCompile(`(?i)^(VACUUM|ATTACH|DETACH|BEGIN|COMMIT|END|ROLLBACK|SAVEPOINT|RELEASE)\b|^PRAGMA\s+(\w+\s*\.\s*)?(foreign_keys|journal_mode)\b`)
+
+func transactional(stmts []string) bool {
+	for _, stmt := range stmts {
+		if nonTransactional.MatchString(strings.TrimSpace(stmt)) {
+			return false
+		}
+	}
+	return true
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
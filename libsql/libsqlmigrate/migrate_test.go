@@ -0,0 +1,221 @@
+package libsqlmigrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/libsql/libsql-client-go/libsql/hrana"
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+const (
+	existsQuery  = `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`
+	appliedQuery = `SELECT version FROM "schema_migrations" ORDER BY version`
+	createTable  = `CREATE TABLE IF NOT EXISTS "schema_migrations" (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP)`
+	recordQuery  = `INSERT INTO "schema_migrations" (version, name) VALUES (?, ?)`
+	deleteQuery  = `DELETE FROM "schema_migrations" WHERE version = ?`
+)
+
+var migrations = []Migration{
+	{Version: 1, Name: "users", Up: "CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE INDEX users_id ON users (id);", Down: "DROP TABLE users;"},
+	{Version: 2, Name: "rebuild", Up: "PRAGMA foreign_keys = OFF; ALTER TABLE users ADD name TEXT; PRAGMA foreign_keys = ON;"},
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_rebuild.up.sql": {Data: []byte(migrations[1].Up)},
+		"migrations/0001_users.up.sql":   {Data: []byte(migrations[0].Up)},
+		"migrations/0001_users.down.sql": {Data: []byte(migrations[0].Down)},
+		"migrations/README.md":           {Data: []byte("ignored")},
+	}
+	got, err := Load(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, migrations) {
+		t.Errorf("got %#v, want %#v", got, migrations)
+	}
+
+	fsys["migrations/0003_orphan.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE orphans")}
+	if _, err := Load(fsys, "migrations"); err == nil {
+		t.Error("got no error for a migration without up file")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, invalid := range [][]Migration{
+		{{Version: 0, Name: "zero", Up: "SELECT 1"}},
+		{{Version: 1, Name: "a", Up: "SELECT 1"}, {Version: 1, Name: "b", Up: "SELECT 1"}},
+	} {
+		if _, err := New(nil, invalid, nil); err == nil {
+			t.Errorf("got no error for %v", invalid)
+		}
+	}
+}
+
+func TestTransactional(t *testing.T) {
+	if !migrations[0].Transactional() {
+		t.Error("CREATE statements reported as non-transactional")
+	}
+	if migrations[1].Transactional() {
+		t.Error("PRAGMA foreign_keys reported as transactional")
+	}
+	if (Migration{Up: "vacuum"}).Transactional() {
+		t.Error("VACUUM reported as transactional")
+	}
+}
+
+// newDB returns a database recording the statements it receives, answering
+// whether the migrations table exists with *exists and the versions applied
+// with *applied.
+func newDB(t *testing.T, exists *bool, applied *[]int64) (*sql.DB, func() []string) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		switch {
+		case strings.HasPrefix(stmt, existsQuery):
+			return &libsqltest.Result{Cols: []string{"exists"}, Rows: [][]driver.Value{{*exists}}}, nil
+		case strings.HasPrefix(stmt, "SELECT version FROM"):
+			res := &libsqltest.Result{Cols: []string{"version"}}
+			for _, version := range *applied {
+				res.Rows = append(res.Rows, []driver.Value{version})
+			}
+			return res, nil
+		}
+		return nil, nil
+	})
+	db, _ := libsqltest.Open(t, f)
+	return db, executed
+}
+
+func TestUpDown(t *testing.T) {
+	var exists bool
+	var applied []int64
+	db, executed := newDB(t, &exists, &applied)
+	ctx := context.Background()
+	m, err := New(db, migrations, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, migrations) {
+		t.Errorf("got applied %v, want %v", got, migrations)
+	}
+
+	exists, applied = true, []int64{1, 2}
+	if _, err := m.Down(ctx, 2); err == nil {
+		t.Error("got no error reverting a migration without down script")
+	}
+
+	applied = []int64{1}
+	reverted, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reverted, migrations[:1]) {
+		t.Errorf("got reverted %v, want %v", reverted, migrations[:1])
+	}
+
+	want := []string{
+		existsQuery + " schema_migrations",
+		createTable,
+		// The first migration runs in a transaction, the second one
+		// statement by statement.
+		"BEGIN",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY)",
+		"CREATE INDEX users_id ON users (id)",
+		recordQuery + " 1 users",
+		"COMMIT",
+		"PRAGMA foreign_keys = OFF",
+		"ALTER TABLE users ADD name TEXT",
+		"PRAGMA foreign_keys = ON",
+		recordQuery + " 2 rebuild",
+		existsQuery + " schema_migrations",
+		appliedQuery,
+		existsQuery + " schema_migrations",
+		appliedQuery,
+		"BEGIN",
+		"DROP TABLE users",
+		deleteQuery + " 1",
+		"COMMIT",
+	}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	exists, applied := true, []int64{1}
+	db, executed := newDB(t, &exists, &applied)
+	m, err := New(db, migrations, &Options{Table: "versions", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pending, migrations[1:]) {
+		t.Errorf("got %v, want %v", pending, migrations[1:])
+	}
+	want := []string{existsQuery + " versions", `SELECT version FROM "versions" ORDER BY version`}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestUpBatch checks that a migration is sent to sqld in a single atomic
+// batch.
+func TestUpBatch(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		if query == existsQuery {
+			return &libsqltest.Result{Cols: []string{"exists"}, Rows: [][]driver.Value{{int64(0)}}}, nil
+		}
+		return nil, nil
+	})
+	db, err := sql.Open("libsql", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m, err := New(db, migrations[:1], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var batches [][]string
+	for _, r := range server.Requests() {
+		var req hrana.PipelineRequest
+		if json.Unmarshal(r.Body, &req) != nil {
+			continue
+		}
+		for _, sr := range req.Requests {
+			if sr.Batch == nil {
+				continue
+			}
+			var stmts []string
+			for _, step := range sr.Batch.Steps {
+				stmts = append(stmts, *step.Stmt.Sql)
+			}
+			batches = append(batches, stmts)
+		}
+	}
+	if len(batches) != 1 {
+		t.Fatalf("got %d batches, want 1", len(batches))
+	}
+	want := []string{"BEGIN", "CREATE TABLE users (id INTEGER PRIMARY KEY)", "CREATE INDEX users_id ON users (id)", recordQuery, "COMMIT", "ROLLBACK"}
+	if !reflect.DeepEqual(batches[0], want) {
+		t.Errorf("got batch %q, want %q", batches[0], want)
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/libsql/libsql-client-go/libsql/libsqlmock
+
+go 1.21
+
+replace github.com/libsql/libsql-client-go => ../../
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/libsql/libsql-client-go v0.0.0-20261014172903-38f600892ce1
+)
+
+require (
+	github.com/antlr/antlr4/runtime/Go/antlr/v4 v4.0.0-20230512164433-5d1fd1a340c9 // indirect
+	github.com/klauspost/compress v1.15.15 // indirect
+	github.com/libsql/sqlite-antlr4-parser v0.0.0-20230802215326-5cb5bb604475 // indirect
+	golang.org/x/exp v0.0.0-20220722155223-a9213eeb770e // indirect
+	nhooyr.io/websocket v1.8.7 // indirect
+)
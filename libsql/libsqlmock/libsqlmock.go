@@ -0,0 +1,75 @@
+// Package libsqlmock configures github.com/DATA-DOG/go-sqlmock to convert
+// arguments like the libsql driver, so test suites written against sqlmock
+// expectations keep working when the code under test moves to libsql. The mock
+// is a sqlmock database, registered under the "sqlmock" driver name, not
+// "libsql".
+//
+// The package is a module of its own, so that go-sqlmock is only a dependency
+// of the programs testing with it.
+package libsqlmock
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/libsql/libsql-client-go/libsql/libsqlclient"
+)
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// normalize collapses whitespace and strips trailing semicolons, so that
+// expectations do not depend on how statements are formatted. The libsql
+// driver only strips the trailing semicolons of single statements, the
+// whitespace it sends is left as is.
+func normalize(sql string) string {
+	sql = whitespace.ReplaceAllString(strings.TrimSpace(sql), " ")
+	return strings.TrimSpace(strings.TrimRight(sql, "; "))
+}
+
+// QueryMatcher matches a query when it is equal to the expectation after
+// normalization. Otherwise the expectation is treated as a regular expression,
+// which is the default matching strategy of sqlmock.
+var QueryMatcher sqlmock.QueryMatcher = sqlmock.QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+	if normalize(expectedSQL) == normalize(actualSQL) {
+		return nil
+	}
+	return sqlmock.QueryMatcherRegexp.Match(expectedSQL, actualSQL)
+})
+
+// ValueConverter converts arguments with libsqlclient.Value, like the libsql
+// driver does with its default options: booleans become integers, times are
+// formatted as RFC 3339 strings and json.RawMessage becomes text. WithArgs
+// expectations are compared against the values that would be sent over the
+// wire.
+var ValueConverter driver.ValueConverter = valueConverter{}
+
+type valueConverter struct{}
+
+func (valueConverter) ConvertValue(v any) (driver.Value, error) {
+	return libsqlclient.Value(v)
+}
+
+// New creates a sqlmock database configured with QueryMatcher and
+// ValueConverter. Tests that need further sqlmock options can pass
+// sqlmock.QueryMatcherOption(QueryMatcher) and
+// sqlmock.ValueConverterOption(ValueConverter) to sqlmock.New themselves.
+func New() (*sql.DB, sqlmock.Sqlmock, error) {
+	return sqlmock.New(
+		sqlmock.QueryMatcherOption(QueryMatcher),
+		sqlmock.ValueConverterOption(ValueConverter),
+	)
+}
+
+// NewWithDSN is like New but registers the mock under the given dsn, so code
+// that opens its own connection with sql.Open("sqlmock", dsn) gets the mock.
+// Code opening sql.Open("libsql", url) does not, pass it the returned
+// database instead.
+func NewWithDSN(dsn string) (*sql.DB, sqlmock.Sqlmock, error) {
+	return sqlmock.NewWithDSN(dsn,
+		sqlmock.QueryMatcherOption(QueryMatcher),
+		sqlmock.ValueConverterOption(ValueConverter),
+	)
+}
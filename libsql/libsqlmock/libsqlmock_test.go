@@ -0,0 +1,67 @@
+package libsqlmock
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestQueryMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		wantErr  bool
+	}{
+		{
+			name:     "Equal",
+			expected: "SELECT * FROM t WHERE a = ?",
+			actual:   "SELECT * FROM t WHERE a = ?",
+		},
+		{
+			name:     "Whitespace",
+			expected: "SELECT *\n\tFROM t",
+			actual:   "SELECT * FROM t",
+		},
+		{
+			name:     "TrailingSemicolon",
+			expected: "INSERT INTO t VALUES (?)",
+			actual:   "INSERT INTO t VALUES (?);",
+		},
+		{
+			name:     "Regexp",
+			expected: "^SELECT .* FROM t$",
+			actual:   "SELECT a, b FROM t",
+		},
+		{
+			name:     "Mismatch",
+			expected: "SELECT a FROM t",
+			actual:   "SELECT b FROM t",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := QueryMatcher.Match(tt.expected, tt.actual)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	db, mock, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO t (a) VALUES (?)").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+	if _, err := db.Exec("INSERT INTO t (a) VALUES (?);", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
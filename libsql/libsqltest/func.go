@@ -0,0 +1,200 @@
+package libsqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// Func answers every statement with the result it returns, for tests that
+// script the answers of the server instead of running a database: a server
+// started by NewFuncServer passes it the statements it receives. Transactions
+// are statements too: BEGIN, COMMIT and ROLLBACK, as the libsql driver sends
+// them. A Func is also a database/sql connector, for code that should be tested
+// with a database of another driver.
+type Func func(ctx context.Context, query string, args []driver.NamedValue) (*Result, error)
+
+// Result is the result of a statement answered by a Func, an empty result if
+// nil.
+type Result struct {
+	Cols []string
+	// DeclTypes are the declared types of Cols, if any.
+	DeclTypes    []string
+	Rows         [][]driver.Value
+	Affected     int64
+	LastInsertId int64
+}
+
+// NewFuncServer starts a server answering statements with f, or with an empty
+// result if f is nil. The server is closed when the test completes.
+func NewFuncServer(t testing.TB, f Func) *Server {
+	db := sql.OpenDB(f)
+	s := NewServer(db)
+	t.Cleanup(func() {
+		s.Close()
+		db.Close()
+	})
+	return s
+}
+
+// Open starts a server answering statements with f, like NewFuncServer, and
+// opens a database on it with the libsql driver, which the test must import.
+// The database is closed when the test completes.
+func Open(t testing.TB, f Func) (*sql.DB, *Server) {
+	t.Helper()
+	s := NewFuncServer(t, f)
+	db, err := sql.Open("libsql", s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, s
+}
+
+// Statement returns query followed by its arguments, as name=value for named
+// ones, to record the statements a Func received.
+func Statement(query string, args []driver.NamedValue) string {
+	for _, arg := range args {
+		if arg.Name != "" {
+			query += fmt.Sprintf(" %s=%v", arg.Name, arg.Value)
+		} else {
+			query += fmt.Sprintf(" %v", arg.Value)
+		}
+	}
+	return query
+}
+
+// Log returns a Func recording the statements it receives, formatted by
+// Statement, and answering them with answer, or an empty result if answer is
+// nil, and a function returning the statements recorded so far.
+func Log(answer func(stmt string) (*Result, error)) (Func, func() []string) {
+	var mu sync.Mutex
+	var stmts []string
+	f := func(ctx context.Context, query string, args []driver.NamedValue) (*Result, error) {
+		stmt := Statement(query, args)
+		mu.Lock()
+		stmts = append(stmts, stmt)
+		mu.Unlock()
+		if answer == nil {
+			return nil, nil
+		}
+		return answer(stmt)
+	}
+	return f, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), stmts...)
+	}
+}
+
+func (f Func) Connect(context.Context) (driver.Conn, error) {
+	return &conn{f}, nil
+}
+
+func (f Func) Driver() driver.Driver {
+	return f
+}
+
+func (f Func) Open(string) (driver.Conn, error) {
+	return &conn{f}, nil
+}
+
+type conn struct {
+	f Func
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.exec(ctx, "BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return tx{c}, nil
+}
+
+type tx struct {
+	c *conn
+}
+
+func (t tx) Commit() error {
+	_, err := t.c.exec(context.Background(), "COMMIT", nil)
+	return err
+}
+
+func (t tx) Rollback() error {
+	_, err := t.c.exec(context.Background(), "ROLLBACK", nil)
+	return err
+}
+
+func (c *conn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *conn) exec(ctx context.Context, query string, args []driver.NamedValue) (*Result, error) {
+	if c.f == nil {
+		return &Result{}, nil
+	}
+	res, err := c.f(ctx, query, args)
+	if res == nil && err == nil {
+		res = &Result{}
+	}
+	return res, err
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.exec(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return execResult{res}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.exec(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{res: res}, nil
+}
+
+type execResult struct {
+	*Result
+}
+
+func (r execResult) LastInsertId() (int64, error) { return r.Result.LastInsertId, nil }
+func (r execResult) RowsAffected() (int64, error) { return r.Affected, nil }
+
+type rows struct {
+	res  *Result
+	next int
+}
+
+func (r *rows) Columns() []string { return r.res.Cols }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) ColumnTypeDatabaseTypeName(idx int) string {
+	if idx < len(r.res.DeclTypes) {
+		return r.res.DeclTypes[idx]
+	}
+	return ""
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next == len(r.res.Rows) {
+		return io.EOF
+	}
+	copy(dest, r.res.Rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,459 @@
+// Package libsqltest provides a fake sqld server speaking Hrana over HTTP, so
+// code using the libsql driver can be unit tested without a live database.
+//
+// The server executes the statements it receives on a database/sql database
+// provided by the test, typically an in-memory SQLite database, and can inject
+// faults to exercise error handling:
+//
+//	db, _ := sql.Open("sqlite", "file::memory:?cache=shared")
+//	server := libsqltest.NewServer(db)
+//	defer server.Close()
+//	client, _ := sql.Open("libsql", server.URL)
+//	server.FailRequests(1, http.StatusServiceUnavailable)
+//	server.FailStatements(1, "INSERT", &libsqltest.Error{Code: "SQLITE_BUSY", Message: "database is locked"})
+//
+// Tests that only need scripted answers can answer statements with a Func
+// instead, see Open and NewFuncServer.
+package libsqltest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+)
+
+// Server is a fake sqld server. Each Hrana stream runs on its own connection
+// of the database, so transactions behave as they would on sqld.
+type Server struct {
+	*httptest.Server
+	db *sql.DB
+
+	mu      sync.Mutex
+	streams map[string]*stream
+	batons  int
+	latency time.Duration
+	// failures is the number of requests still to be answered with failure.
+	failures     int
+	failure      Failure
+	stmtFailures []*stmtFailure
+	// replicationIndex is reported by the results of statements, unless 0.
+	replicationIndex uint64
+	statements       []string
+	requests         []Request
+}
+
+// Failure is the response to the pipeline requests failed by InjectFailures.
+type Failure struct {
+	// Status is the HTTP status of the response.
+	Status int
+	// Header is added to the response, like the Retry-After header of a rate
+	// limited request.
+	Header http.Header
+	// Code and Message are the error of the response body, Message defaults
+	// to "injected failure".
+	Code    string
+	Message string
+}
+
+// Error is a SQLite error with its extended result code, like
+// SQLITE_CONSTRAINT_UNIQUE. Statements failing with an error wrapping an
+// *Error are answered with its code, other errors of the backend database
+// with their message only.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// stmtFailure is a rule of FailStatements.
+type stmtFailure struct {
+	n      int
+	substr string
+	err    *Error
+}
+
+// Request is an HTTP request received by the server.
+type Request struct {
+	Method string
+	// URL is the target of the request, absolute for requests sent through
+	// a proxy.
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+	// Statements is the SQL of the statements the request executed.
+	Statements []string
+}
+
+// stream is the state of a Hrana stream, requests on a stream come one at a
+// time since each one carries the baton returned by the previous one.
+type stream struct {
+	conn *sql.Conn
+	sqls map[int32]string
+	// request is the request executing on the stream.
+	request *Request
+	// inTx is set while a transaction started by a BEGIN statement is open.
+	inTx bool
+}
+
+// NewServer starts a server executing statements on db. Every open stream
+// holds a connection of db, so db must not limit the number of open
+// connections to fewer than the streams of the test, and its connections must
+// share the same database: use a temporary file or a shared-cache in-memory
+// database, like file::memory:?cache=shared, rather than :memory:. The caller
+// keeps ownership of db and closes it after the server.
+func NewServer(db *sql.DB) *Server {
+	s := &Server{db: db, streams: map[string]*stream{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts the server down and releases the connections of open streams.
+func (s *Server) Close() {
+	s.Server.Close()
+	s.ExpireStreams()
+}
+
+// SetLatency delays every pipeline response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailRequests answers the next n pipeline requests with the HTTP status
+// without executing them, like an overloaded or failing server would.
+func (s *Server) FailRequests(n int, status int) {
+	s.InjectFailures(n, Failure{Status: status})
+}
+
+// InjectFailures answers the next n pipeline requests with f without
+// executing them, like a rate limited request or a request sqld rejected.
+func (s *Server) InjectFailures(n int, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures, s.failure = n, f
+}
+
+// FailStatements fails the next n statements whose SQL contains substr with
+// err without executing them, like a busy database or a constraint violated
+// by another client would, or all of them if n is negative. Rules of earlier
+// calls take precedence.
+func (s *Server) FailStatements(n int, substr string, err *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stmtFailures = append(s.stmtFailures, &stmtFailure{n: n, substr: substr, err: err})
+}
+
+// SetReplicationIndex makes the results of the next statements report index
+// as the replication index of the database, like a primary after its writes or
+// a replica behind it. An index of 0 is not reported, like by servers without
+// replication.
+func (s *Server) SetReplicationIndex(index uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replicationIndex = index
+}
+
+// ExpireStreams closes all open streams, as sqld does with idle streams. The
+// next request on one of them fails with the STREAM_EXPIRED error code and
+// open transactions are rolled back.
+func (s *Server) ExpireStreams() {
+	s.mu.Lock()
+	streams := s.streams
+	s.streams = map[string]*stream{}
+	s.mu.Unlock()
+	for _, st := range streams {
+		st.close()
+	}
+}
+
+// Statements returns the SQL of the statements executed so far, in order,
+// including those failed by FailStatements.
+func (s *Server) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// Requests returns the HTTP requests answered so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	u := *r.URL
+	request := &Request{Method: r.Method, URL: &u, Header: r.Header.Clone()}
+	// The context of the request is only canceled on disconnection once its
+	// body was read.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, hrana.Error{Message: fmt.Sprintf("failed to read request: %s", err)})
+		return
+	}
+	request.Body = body
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, *request)
+	}()
+	switch r.URL.Path {
+	case "/v2", "/v3":
+		if r.Method == http.MethodGet {
+			return
+		}
+	case "/v2/pipeline", "/v3/pipeline":
+		if r.Method == http.MethodPost {
+			s.servePipeline(w, r, request)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (s *Server) servePipeline(w http.ResponseWriter, r *http.Request, request *Request) {
+	var req hrana.PipelineRequest
+	decodeErr := json.Unmarshal(request.Body, &req)
+
+	s.mu.Lock()
+	latency := s.latency
+	failed := s.failures > 0
+	failure := s.failure
+	if failed {
+		s.failures--
+	}
+	s.mu.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if failed {
+		for name, values := range failure.Header {
+			w.Header()[name] = values
+		}
+		e := hrana.Error{Message: failure.Message}
+		if e.Message == "" {
+			e.Message = "injected failure"
+		}
+		if failure.Code != "" {
+			e.Code = &failure.Code
+		}
+		writeError(w, failure.Status, e)
+		return
+	}
+
+	if decodeErr != nil {
+		writeError(w, http.StatusBadRequest, hrana.Error{Message: fmt.Sprintf("invalid pipeline request: %s", decodeErr)})
+		return
+	}
+	st, err := s.openStream(r.Context(), req.Baton)
+	if err != nil {
+		code := "STREAM_EXPIRED"
+		writeError(w, http.StatusBadRequest, hrana.Error{Message: err.Error(), Code: &code})
+		return
+	}
+	st.request = request
+
+	resp := hrana.PipelineResponse{Results: make([]hrana.StreamResult, 0, len(req.Requests))}
+	closed := false
+	for _, request := range req.Requests {
+		if request.Type == "close" {
+			closed = true
+			resp.Results = append(resp.Results, okResult("close", nil))
+			continue
+		}
+		resp.Results = append(resp.Results, s.execute(r.Context(), st, request))
+	}
+	st.request = nil
+	if closed || r.Context().Err() != nil {
+		// The client of a canceled request never gets the baton of the
+		// stream, which is closed like sqld does once it expires.
+		st.close()
+	} else {
+		resp.Baton = s.saveStream(st)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// openStream returns the stream of baton, or a new stream if baton is empty.
+func (s *Server) openStream(ctx context.Context, baton string) (*stream, error) {
+	if baton == "" {
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &stream{conn: conn, sqls: map[int32]string{}}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streams[baton]
+	if !ok {
+		return nil, fmt.Errorf("stream of baton %s expired", baton)
+	}
+	delete(s.streams, baton)
+	return st, nil
+}
+
+// saveStream returns a new baton for st.
+func (s *Server) saveStream(st *stream) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batons++
+	baton := fmt.Sprintf("baton-%d", s.batons)
+	s.streams[baton] = st
+	return baton
+}
+
+// close rolls back the transaction left open on the stream, if any, and gives
+// its connection back to the pool, as sqld does when a stream is closed.
+func (st *stream) close() {
+	if st.inTx {
+		st.conn.ExecContext(context.Background(), "ROLLBACK")
+	}
+	st.conn.Close()
+}
+
+func (s *Server) execute(ctx context.Context, st *stream, request hrana.StreamRequest) hrana.StreamResult {
+	switch request.Type {
+	case "execute":
+		if request.Stmt == nil {
+			return errorResult(fmt.Errorf("execute request without statement"))
+		}
+		res, err := s.executeStmt(ctx, st, request.Stmt)
+		if err != nil {
+			return errorResult(err)
+		}
+		return okResult("execute", res)
+	case "batch":
+		if request.Batch == nil {
+			return errorResult(fmt.Errorf("batch request without batch"))
+		}
+		return okResult("batch", s.executeBatch(ctx, st, request.Batch))
+	case "store_sql":
+		if request.Sql == nil || request.SqlId == nil {
+			return errorResult(fmt.Errorf("store_sql request without sql or sql_id"))
+		}
+		st.sqls[*request.SqlId] = *request.Sql
+		return okResult("store_sql", nil)
+	case "close_sql":
+		if request.SqlId == nil {
+			return errorResult(fmt.Errorf("close_sql request without sql_id"))
+		}
+		delete(st.sqls, *request.SqlId)
+		return okResult("close_sql", nil)
+	default:
+		return errorResult(fmt.Errorf("unsupported request type %s", request.Type))
+	}
+}
+
+func (s *Server) executeBatch(ctx context.Context, st *stream, batch *hrana.Batch) *batchResult {
+	res := &batchResult{
+		StepResults: make([]*stmtResult, len(batch.Steps)),
+		StepErrors:  make([]*hrana.Error, len(batch.Steps)),
+	}
+	for idx, step := range batch.Steps {
+		if step.Condition != nil {
+			run, err := evalCondition(step.Condition, res)
+			if err != nil {
+				res.StepErrors[idx] = &hrana.Error{Message: err.Error()}
+				continue
+			}
+			if !run {
+				continue
+			}
+		}
+		stmt := step.Stmt
+		stepRes, err := s.executeStmt(ctx, st, &stmt)
+		if err != nil {
+			res.StepErrors[idx] = hranaError(err)
+			continue
+		}
+		res.StepResults[idx] = stepRes
+	}
+	return res
+}
+
+// evalCondition reports whether a batch step with cond runs after the steps
+// whose outcome res holds.
+func evalCondition(cond *hrana.BatchCondition, res *batchResult) (bool, error) {
+	switch cond.Type {
+	case "ok", "error":
+		if cond.Step == nil || int(*cond.Step) >= len(res.StepResults) {
+			return false, fmt.Errorf("invalid step in %s condition", cond.Type)
+		}
+		if cond.Type == "ok" {
+			return res.StepResults[*cond.Step] != nil, nil
+		}
+		return res.StepErrors[*cond.Step] != nil, nil
+	case "not":
+		if cond.Cond == nil {
+			return false, fmt.Errorf("not condition without condition")
+		}
+		run, err := evalCondition(cond.Cond, res)
+		return !run, err
+	case "and", "or":
+		for idx := range cond.Conds {
+			run, err := evalCondition(&cond.Conds[idx], res)
+			if err != nil {
+				return false, err
+			}
+			if run == (cond.Type == "or") {
+				return run, nil
+			}
+		}
+		return cond.Type == "and", nil
+	default:
+		return false, fmt.Errorf("unsupported condition type %s", cond.Type)
+	}
+}
+
+func okResult(responseType string, result any) hrana.StreamResult {
+	response := &hrana.StreamResponse{Type: responseType}
+	if result != nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return errorResult(err)
+		}
+		response.Result = encoded
+	}
+	return hrana.StreamResult{Type: "ok", Response: response}
+}
+
+func errorResult(err error) hrana.StreamResult {
+	return hrana.StreamResult{Type: "error", Error: hranaError(err)}
+}
+
+func hranaError(err error) *hrana.Error {
+	e := &hrana.Error{Message: err.Error()}
+	var sqliteErr *Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code != "" {
+		e.Code = &sqliteErr.Code
+	}
+	return e
+}
+
+func writeError(w http.ResponseWriter, status int, e hrana.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
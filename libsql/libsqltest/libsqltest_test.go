@@ -0,0 +1,287 @@
+package libsqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql"
+)
+
+// fakeDriver is a backend database answering SELECT statements with a row of
+// their arguments, prefixed with the id of the connection and whether it is in
+// a transaction, and failing statements starting with FAIL.
+type fakeDriver struct {
+	conns int32
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{id: atomic.AddInt32(&d.conns, 1)}, nil
+}
+
+type fakeConn struct {
+	id   int32
+	inTx bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c, query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, "FAIL"):
+		return nil, errors.New("statement failed")
+	case s.query == "BEGIN":
+		s.c.inTx = true
+	case s.query == "COMMIT" || s.query == "ROLLBACK":
+		if !s.c.inTx {
+			return nil, errors.New("no transaction is active")
+		}
+		s.c.inTx = false
+	}
+	return driver.RowsAffected(len(args)), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(s.query, "FAIL") {
+		return nil, errors.New("statement failed")
+	}
+	return &fakeRows{row: append([]driver.Value{int64(s.c.id), s.c.inTx}, args...)}, nil
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.Exec(values(args))
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.Query(values(args))
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for idx := range args {
+		values[idx] = args[idx].Value
+	}
+	return values
+}
+
+type fakeRows struct {
+	row  []driver.Value
+	done bool
+}
+
+func (r *fakeRows) Columns() []string {
+	cols := make([]string, len(r.row))
+	for idx := range cols {
+		cols[idx] = "c"
+	}
+	return cols
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.row)
+	return nil
+}
+
+func init() {
+	sql.Register("libsqltest-fake", &fakeDriver{})
+}
+
+func newTestServer(t *testing.T) (*Server, *sql.DB) {
+	backend, err := sql.Open("libsqltest-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(backend)
+	client, err := sql.Open("libsql", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+		backend.Close()
+	})
+	return server, client
+}
+
+func TestServer(t *testing.T) {
+	server, client := newTestServer(t)
+	ctx := context.Background()
+
+	var id int64
+	var inTx bool
+	var text, empty string
+	var blob []byte
+	var float float64
+	var null sql.NullString
+	err := client.QueryRowContext(ctx, "SELECT ?, ?, ?, ?, ?", "text", "", []byte{1, 2}, 0.0, nil).
+		Scan(&id, &inTx, &text, &empty, &blob, &float, &null)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "text" || empty != "" || !reflect.DeepEqual(blob, []byte{1, 2}) || float != 0 || null.Valid {
+		t.Errorf("got %#v, %#v, %#v, %#v, %#v", text, empty, blob, float, null)
+	}
+	if err := client.QueryRowContext(ctx, "SELECT :name", sql.Named("name", "named")).Scan(&id, &inTx, &text); err != nil {
+		t.Fatal(err)
+	}
+	if text != "named" {
+		t.Errorf("got named argument %#v", text)
+	}
+
+	res, err := client.ExecContext(ctx, "INSERT INTO t VALUES (?, ?)", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected != 2 {
+		t.Errorf("got %d rows affected and error %v", affected, err)
+	}
+	if _, err := client.ExecContext(ctx, "FAIL"); err == nil || !strings.Contains(err.Error(), "statement failed") {
+		t.Errorf("got %v, want the error of the backend", err)
+	}
+
+	want := []string{"SELECT ?, ?, ?, ?, ?", "SELECT :name", "INSERT INTO t VALUES (?, ?)", "FAIL"}
+	if got := server.Statements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got statements %#v, want %#v", got, want)
+	}
+}
+
+func TestServerTransaction(t *testing.T) {
+	_, client := newTestServer(t)
+	ctx := context.Background()
+	tx, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txConn, otherConn int64
+	var inTx bool
+	if err := tx.QueryRowContext(ctx, "SELECT 1").Scan(&txConn, &inTx); err != nil {
+		t.Fatal(err)
+	}
+	if !inTx {
+		t.Error("expected the statement to run in the transaction")
+	}
+	if err := client.QueryRowContext(ctx, "SELECT 1").Scan(&otherConn, &inTx); err != nil {
+		t.Fatal(err)
+	}
+	if otherConn == txConn || inTx {
+		t.Error("expected another stream to run outside of the transaction")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	server, client := newTestServer(t)
+	ctx := context.Background()
+	var id int64
+	var inTx bool
+
+	server.FailRequests(1, http.StatusServiceUnavailable)
+	err := client.QueryRowContext(ctx, "SELECT 1").Scan(&id, &inTx)
+	if err == nil || !libsql.IsRetryable(err) {
+		t.Errorf("got %v, want a retryable error", err)
+	}
+	if err := client.QueryRowContext(ctx, "SELECT 1").Scan(&id, &inTx); err != nil {
+		t.Errorf("got %v after the injected failure", err)
+	}
+
+	tx, err := client.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT 1").Scan(&id, &inTx); err != nil {
+		t.Fatal(err)
+	}
+	server.ExpireStreams()
+	if err := tx.QueryRowContext(ctx, "SELECT 1").Scan(&id, &inTx); err == nil {
+		t.Error("expected the transaction to fail once its stream expired")
+	}
+	tx.Rollback()
+
+	server.SetLatency(time.Second)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := client.QueryRowContext(timeoutCtx, "SELECT 1").Scan(&id, &inTx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the deadline to expire", err)
+	}
+}
+
+func TestServerStatementFaults(t *testing.T) {
+	server, client := newTestServer(t)
+	ctx := context.Background()
+
+	server.FailStatements(1, "INSERT", &Error{Code: "SQLITE_BUSY", Message: "database is locked"})
+	server.FailStatements(-1, "INSERT INTO u", &Error{Code: "SQLITE_CONSTRAINT_UNIQUE", Message: "UNIQUE constraint failed: u.a"})
+	if _, err := client.ExecContext(ctx, "INSERT INTO t VALUES (1)"); !libsql.IsBusy(err) {
+		t.Errorf("got %v, want SQLITE_BUSY", err)
+	}
+	if _, err := client.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Errorf("got %v once the failure was used", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.ExecContext(ctx, "INSERT INTO u VALUES (1)"); !libsql.IsUniqueViolation(err) {
+			t.Errorf("got %v, want every statement to violate the constraint", err)
+		}
+	}
+
+	server.InjectFailures(1, Failure{Status: http.StatusTooManyRequests, Message: "slow down"})
+	if _, err := client.ExecContext(ctx, "SELECT 1"); err == nil || !strings.Contains(err.Error(), "slow down") {
+		t.Errorf("got %v, want the injected failure", err)
+	}
+
+	var index uint64
+	server.SetReplicationIndex(42)
+	if _, err := client.ExecContext(libsql.CaptureReplicationIndex(ctx, &index), "INSERT INTO t VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+	if index != 42 {
+		t.Errorf("got replication index %d, want 42", index)
+	}
+
+	var statements [][]string
+	for _, r := range server.Requests() {
+		if r.Method == http.MethodPost {
+			statements = append(statements, r.Statements)
+		}
+	}
+	want := [][]string{
+		{"INSERT INTO t VALUES (1)"}, {"INSERT INTO t VALUES (1)"},
+		{"INSERT INTO u VALUES (1)"}, {"INSERT INTO u VALUES (1)"},
+		nil, {"INSERT INTO t VALUES (2)"},
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Errorf("got requests executing %#v, want %#v", statements, want)
+	}
+}
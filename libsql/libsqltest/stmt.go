@@ -0,0 +1,207 @@
+package libsqltest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+)
+
+// stmtResult and batchResult mirror the results of the hrana package, with
+// values encoded the way sqld does.
+type stmtResult struct {
+	Cols             []hrana.Column `json:"cols"`
+	Rows             [][]value      `json:"rows"`
+	AffectedRowCount int64          `json:"affected_row_count"`
+	LastInsertRowId  *string        `json:"last_insert_rowid"`
+	// RowsRead counts the rows returned and RowsWritten the rows affected,
+	// sqld counts those SQLite visited.
+	RowsRead         int64   `json:"rows_read"`
+	RowsWritten      int64   `json:"rows_written"`
+	QueryDurationMs  float64 `json:"query_duration_ms"`
+	ReplicationIndex *string `json:"replication_index,omitempty"`
+}
+
+type batchResult struct {
+	StepResults []*stmtResult  `json:"step_results"`
+	StepErrors  []*hrana.Error `json:"step_errors"`
+}
+
+// value is a value of a result row. Unlike hrana.Value, it keeps the empty
+// strings and zero floats that sqld sends.
+type value struct {
+	v any
+}
+
+func (v value) MarshalJSON() ([]byte, error) {
+	switch x := v.v.(type) {
+	case nil:
+		return []byte(`{"type":"null"}`), nil
+	case int64:
+		return json.Marshal(map[string]string{"type": "integer", "value": strconv.FormatInt(x, 10)})
+	case float64:
+		return json.Marshal(map[string]any{"type": "float", "value": x})
+	case string:
+		return json.Marshal(map[string]string{"type": "text", "value": x})
+	case []byte:
+		return json.Marshal(map[string]string{"type": "blob", "base64": base64.StdEncoding.WithPadding(base64.NoPadding).EncodeToString(x)})
+	case bool:
+		if x {
+			return value{int64(1)}.MarshalJSON()
+		}
+		return value{int64(0)}.MarshalJSON()
+	case time.Time:
+		return value{x.Format(time.RFC3339Nano)}.MarshalJSON()
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v.v)
+	}
+}
+
+func (s *Server) executeStmt(ctx context.Context, st *stream, stmt *hrana.Stmt) (*stmtResult, error) {
+	var query string
+	switch {
+	case stmt.Sql != nil:
+		query = *stmt.Sql
+	case stmt.SqlId != nil:
+		stored, ok := st.sqls[*stmt.SqlId]
+		if !ok {
+			return nil, fmt.Errorf("sql id %d is not stored", *stmt.SqlId)
+		}
+		query = stored
+	default:
+		return nil, fmt.Errorf("statement without sql or sql_id")
+	}
+	s.mu.Lock()
+	s.statements = append(s.statements, query)
+	if st.request != nil {
+		st.request.Statements = append(st.request.Statements, query)
+	}
+	failure := s.statementFailure(query)
+	var replicationIndex *string
+	if s.replicationIndex != 0 {
+		index := strconv.FormatUint(s.replicationIndex, 10)
+		replicationIndex = &index
+	}
+	s.mu.Unlock()
+	if failure != nil {
+		return nil, failure
+	}
+	start := time.Now()
+
+	args := make([]any, 0, len(stmt.Args)+len(stmt.NamedArgs))
+	for _, arg := range stmt.Args {
+		args = append(args, arg.ToValue())
+	}
+	for _, arg := range stmt.NamedArgs {
+		// database/sql names do not carry the prefix of the placeholder.
+		name := arg.Name
+		if len(name) > 0 && (name[0] == ':' || name[0] == '@' || name[0] == '$') {
+			name = name[1:]
+		}
+		args = append(args, sql.Named(name, arg.Value.ToValue()))
+	}
+
+	if !stmt.WantRows {
+		result, err := st.conn.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		res := &stmtResult{Cols: []hrana.Column{}, Rows: [][]value{}}
+		if affected, err := result.RowsAffected(); err == nil {
+			res.AffectedRowCount = affected
+		}
+		if id, err := result.LastInsertId(); err == nil {
+			lastInsertRowId := strconv.FormatInt(id, 10)
+			res.LastInsertRowId = &lastInsertRowId
+		}
+		res.RowsWritten = res.AffectedRowCount
+		res.ReplicationIndex = replicationIndex
+		res.QueryDurationMs = durationMs(time.Since(start))
+		st.track(query)
+		return res, nil
+	}
+
+	rows, err := st.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	res := &stmtResult{Cols: make([]hrana.Column, len(types)), Rows: [][]value{}}
+	for idx, t := range types {
+		name, declType := t.Name(), t.DatabaseTypeName()
+		res.Cols[idx].Name = &name
+		if declType != "" {
+			res.Cols[idx].Type = &declType
+		}
+	}
+	for rows.Next() {
+		values := make([]any, len(types))
+		dest := make([]any, len(types))
+		for idx := range values {
+			dest[idx] = &values[idx]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]value, len(values))
+		for idx, v := range values {
+			row[idx] = value{v}
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	res.RowsRead = int64(len(res.Rows))
+	res.ReplicationIndex = replicationIndex
+	res.QueryDurationMs = durationMs(time.Since(start))
+	st.track(query)
+	return res, nil
+}
+
+// track follows the transaction started and ended by the statements executed
+// on the stream.
+func (st *stream) track(query string) {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "BEGIN":
+		st.inTx = true
+	case "COMMIT", "END":
+		st.inTx = false
+	case "ROLLBACK":
+		if len(fields) == 1 || fields[1] != "TO" {
+			st.inTx = false
+		}
+	}
+}
+
+// statementFailure returns the error of the first rule of FailStatements
+// matching query, if any. s.mu must be held.
+func (s *Server) statementFailure(query string) *Error {
+	for _, f := range s.stmtFailures {
+		if f.n != 0 && strings.Contains(query, f.substr) {
+			if f.n > 0 {
+				f.n--
+			}
+			return f.err
+		}
+	}
+	return nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
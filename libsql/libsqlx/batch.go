@@ -0,0 +1,139 @@
+package libsqlx
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqlclient"
+)
+
+// Kind is the type shared by the values of a column of a Batch.
+type Kind int
+
+const (
+	// KindNull is a column holding only NULL values.
+	KindNull Kind = iota
+	KindInteger
+	KindReal
+	KindText
+	KindBlob
+	// KindMixed is a column holding values of several types, which SQLite
+	// allows in columns without a strict type.
+	KindMixed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindInteger:
+		return "integer"
+	case KindReal:
+		return "real"
+	case KindText:
+		return "text"
+	case KindBlob:
+		return "blob"
+	case KindMixed:
+		return "mixed"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+func kindOf(v any) Kind {
+	switch v.(type) {
+	case int64:
+		return KindInteger
+	case float64:
+		return KindReal
+	case string:
+		return KindText
+	case []byte:
+		return KindBlob
+	}
+	return KindNull
+}
+
+// BatchColumn holds the values of a column of a Batch.
+type BatchColumn struct {
+	libsqlclient.Column
+	// Kind is the type of the non-NULL values.
+	Kind Kind
+	// Values are int64, float64, string, []byte or nil for NULL.
+	Values []any
+}
+
+// Batch is a slice of a result set laid out by column like an Apache Arrow
+// record batch, so that each column can be appended to an Arrow array builder
+// chosen from its Kind.
+type Batch struct {
+	Columns []BatchColumn
+	// Len is the number of rows of the batch.
+	Len int
+}
+
+func (b *Batch) add(row []any) {
+	for idx, v := range row {
+		col := &b.Columns[idx]
+		switch kind := kindOf(v); {
+		case kind == KindNull:
+		case col.Kind == KindNull:
+			col.Kind = kind
+		case col.Kind != kind:
+			col.Kind = KindMixed
+		}
+		col.Values = append(col.Values, v)
+	}
+	b.Len++
+}
+
+// ReadBatches runs s and calls fn with batches of up to size rows, decoded as
+// the rows are received. A batch is only valid until fn returns. It returns the
+// number of rows read.
+func ReadBatches(ctx context.Context, client *libsqlclient.Client, s libsqlclient.Statement, size int, fn func(*Batch) error) (int64, error) {
+	if size <= 0 {
+		return 0, fmt.Errorf("batch size must be positive")
+	}
+	rows, err := client.Query(ctx, s)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	batch := &Batch{Columns: make([]BatchColumn, len(rows.Columns()))}
+	reset := func() {
+		for idx, col := range rows.Columns() {
+			values := batch.Columns[idx].Values
+			if values == nil {
+				values = make([]any, 0, size)
+			}
+			batch.Columns[idx] = BatchColumn{Column: col, Values: values[:0]}
+		}
+		batch.Len = 0
+	}
+	reset()
+	var n int64
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		batch.add(row)
+		n++
+		if batch.Len == size {
+			if err := fn(batch); err != nil {
+				return n, err
+			}
+			reset()
+		}
+	}
+	if batch.Len > 0 {
+		if err := fn(batch); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
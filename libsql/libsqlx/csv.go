@@ -0,0 +1,89 @@
+// Package libsqlx exports query results for analytics and ETL jobs. Rows are
+// streamed from the server by the libsqlclient package and written out as they
+// are decoded, without going through database/sql scanning.
+//
+// It also scans the rows of database/sql queries into structs, for users of the
+// driver who do not need a full mapping library.
+package libsqlx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqlclient"
+)
+
+type CSVOptions struct {
+	// Comma is the field delimiter, ',' if zero.
+	Comma rune
+	// NoHeader omits the row of column names.
+	NoHeader bool
+	// Null is written for NULL values, an empty field by default.
+	Null string
+}
+
+// WriteCSV runs s and writes its rows to w as CSV, starting with a header of
+// column names. Blobs are base64 encoded. It returns the number of rows
+// written.
+func WriteCSV(ctx context.Context, client *libsqlclient.Client, w io.Writer, s libsqlclient.Statement, opts *CSVOptions) (int64, error) {
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	rows, err := client.Query(ctx, s)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	record := make([]string, len(rows.Columns()))
+	if !opts.NoHeader {
+		for idx, col := range rows.Columns() {
+			record[idx] = col.Name
+		}
+		if err := cw.Write(record); err != nil {
+			return 0, err
+		}
+	}
+	var n int64
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		for idx, v := range row {
+			record[idx] = csvField(v, opts.Null)
+		}
+		if err := cw.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	cw.Flush()
+	return n, cw.Error()
+}
+
+func csvField(v any, null string) string {
+	switch v := v.(type) {
+	case nil:
+		return null
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	}
+	return fmt.Sprint(v)
+}
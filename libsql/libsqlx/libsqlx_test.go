@@ -0,0 +1,95 @@
+package libsqlx
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqlclient"
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// answer returns a Func answering every statement with res.
+func answer(res *libsqltest.Result) libsqltest.Func {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		return res, nil
+	}
+}
+
+// newClient returns a client of a server answering every query with res.
+func newClient(t *testing.T, res *libsqltest.Result) *libsqlclient.Client {
+	client, err := libsqlclient.New(libsqltest.NewFuncServer(t, answer(res)).URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+var testResult = &libsqltest.Result{
+	Cols:      []string{"id", "name", "data"},
+	DeclTypes: []string{"INTEGER", "TEXT", "BLOB"},
+	Rows: [][]driver.Value{
+		{int64(1), "a,b", []byte{1, 2}},
+		{int64(2), nil, nil},
+		{2.5, "c", nil},
+	},
+}
+
+func TestWriteCSV(t *testing.T) {
+	client := newClient(t, testResult)
+	var buf bytes.Buffer
+	n, err := WriteCSV(context.Background(), client, &buf, libsqlclient.Statement{SQL: "SELECT id, name, data FROM t"}, &CSVOptions{Null: `\N`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("got %d rows, want 3", n)
+	}
+	want := "id,name,data\n1,\"a,b\",AQI=\n2,\\N,\\N\n2.5,c,\\N\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadBatches(t *testing.T) {
+	client := newClient(t, testResult)
+	var batches []Batch
+	n, err := ReadBatches(context.Background(), client, libsqlclient.Statement{SQL: "SELECT id, name, data FROM t"}, 2, func(b *Batch) error {
+		copied := Batch{Len: b.Len}
+		for _, col := range b.Columns {
+			col.Values = append([]any(nil), col.Values...)
+			copied.Columns = append(copied.Columns, col)
+		}
+		batches = append(batches, copied)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(batches) != 2 || batches[0].Len != 2 || batches[1].Len != 1 {
+		t.Fatalf("got %d rows in %d batches, want 3 rows in batches of 2 and 1", n, len(batches))
+	}
+	first := batches[0].Columns
+	if first[0].Name != "id" || first[0].DeclType != "INTEGER" || first[0].Kind != KindInteger {
+		t.Errorf("got first column %#v", first[0])
+	}
+	if !reflect.DeepEqual(first[2].Values, []any{[]byte{1, 2}, nil}) || first[2].Kind != KindBlob {
+		t.Errorf("got blob column %#v", first[2])
+	}
+	if kind := batches[1].Columns[0].Kind; kind != KindReal {
+		t.Errorf("got kind %s for the second batch, want real", kind)
+	}
+	if kind := batches[1].Columns[2].Kind; kind != KindNull {
+		t.Errorf("got kind %s for a NULL column, want null", kind)
+	}
+
+	var mixed Batch
+	mixed.Columns = make([]BatchColumn, 1)
+	mixed.add([]any{int64(1)})
+	mixed.add([]any{"one"})
+	if mixed.Columns[0].Kind != KindMixed {
+		t.Errorf("got kind %s, want mixed", mixed.Columns[0].Kind)
+	}
+}
@@ -0,0 +1,183 @@
+package libsqlx
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldsCache maps struct types to their fields by column name.
+var fieldsCache sync.Map
+
+// structFields returns the index paths of the fields of t by column name. A
+// field is named by its db tag, or by its name in lower case without one; a
+// field tagged db:"-" and unexported fields are skipped. The fields of embedded
+// structs are promoted like in Go, shallower fields winning.
+func structFields(t reflect.Type) map[string][]int {
+	if fields, ok := fieldsCache.Load(t); ok {
+		return fields.(map[string][]int)
+	}
+	fields := map[string][]int{}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		// Embedded structs are walked after the fields of t so that they do not
+		// hide them.
+		var embedded []reflect.StructField
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag, hasTag := field.Tag.Lookup("db")
+			if tag == "-" {
+				continue
+			}
+			structType := field.Type
+			if structType.Kind() == reflect.Pointer {
+				structType = structType.Elem()
+			}
+			// Nil pointers to unexported embedded structs cannot be allocated.
+			unexportedPointer := !field.IsExported() && field.Type.Kind() == reflect.Pointer
+			if field.Anonymous && !hasTag && structType.Kind() == reflect.Struct && !unexportedPointer {
+				embedded = append(embedded, field)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			name := tag
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			if _, ok := fields[name]; !ok {
+				fields[name] = append(append([]int(nil), index...), i)
+			}
+		}
+		for _, field := range embedded {
+			structType := field.Type
+			if structType.Kind() == reflect.Pointer {
+				structType = structType.Elem()
+			}
+			walk(structType, append(append([]int(nil), index...), field.Index...))
+		}
+	}
+	walk(t, nil)
+	fieldsCache.Store(t, fields)
+	return fields
+}
+
+// fieldByIndex returns the field of v at index, allocating the nil pointers to
+// embedded structs on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// scanDest returns the destinations of the columns of rows in the struct v.
+func scanDest(v reflect.Value, columns []string) ([]any, error) {
+	fields := structFields(v.Type())
+	dest := make([]any, len(columns))
+	for idx, column := range columns {
+		index, ok := fields[column]
+		if !ok {
+			index, ok = fields[strings.ToLower(column)]
+		}
+		if !ok {
+			return nil, fmt.Errorf("column %s has no field in %s, tag one with db:%q", column, v.Type(), column)
+		}
+		dest[idx] = fieldByIndex(v, index).Addr().Interface()
+	}
+	return dest, nil
+}
+
+// ScanStruct scans the current row of rows into the struct pointed to by dst,
+// like rows.Scan with a field for each column. Columns are matched to fields by
+// their db tag, or by their name in lower case for fields without one, and
+// every column needs a field:
+//
+//	type User struct {
+//		ID        int64     `db:"id"`
+//		Name      string    `db:"name"`
+//		CreatedAt time.Time `db:"created_at"`
+//	}
+//
+//	for rows.Next() {
+//		var u User
+//		if err := libsqlx.ScanStruct(rows, &u); err != nil {
+//			return err
+//		}
+//	}
+func ScanStruct(rows *sql.Rows, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ScanStruct needs a pointer to a struct, got %T", dst)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	dest, err := scanDest(v.Elem(), columns)
+	if err != nil {
+		return err
+	}
+	return rows.Scan(dest...)
+}
+
+// ScanSlice appends an element scanned from every row of rows to the slice
+// pointed to by dst, which holds structs or pointers to structs, and closes
+// rows. Rows are scanned into the structs the way ScanStruct does:
+//
+//	var users []User
+//	rows, err := db.QueryContext(ctx, "SELECT id, name, created_at FROM users")
+//	if err != nil {
+//		return err
+//	}
+//	if err := libsqlx.ScanSlice(rows, &users); err != nil {
+//		return err
+//	}
+func ScanSlice(rows *sql.Rows, dst any) error {
+	defer rows.Close()
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("ScanSlice needs a pointer to a slice, got %T", dst)
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	isPointer := elemType.Kind() == reflect.Pointer
+	structType := elemType
+	if isPointer {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("ScanSlice needs a slice of structs, got %T", dst)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		elem := reflect.New(structType)
+		dest, err := scanDest(elem.Elem(), columns)
+		if err != nil {
+			return err
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if !isPointer {
+			elem = elem.Elem()
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
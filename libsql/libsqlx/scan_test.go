@@ -0,0 +1,102 @@
+package libsqlx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/libsql/libsql-client-go/libsql"
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newDB returns a database whose server answers every statement with the rows
+// of the columns id, Name, email and nickname.
+func newDB(t *testing.T) *sql.DB {
+	db, _ := libsqltest.Open(t, answer(&libsqltest.Result{
+		Cols: []string{"id", "Name", "email", "nickname"},
+		Rows: [][]driver.Value{
+			{int64(1), "ada", "ada@example.com", nil},
+			{int64(2), "bob", "bob@example.com", "b"},
+		},
+	}))
+	return db
+}
+
+type Contact struct {
+	Email string
+}
+
+type user struct {
+	ID       int64 `db:"id"`
+	Name     string
+	Nickname sql.NullString `db:"nickname"`
+	Ignored  string         `db:"-"`
+	*Contact
+}
+
+func TestScanStruct(t *testing.T) {
+	db := newDB(t)
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	var u user
+	if err := ScanStruct(rows, &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 1 || u.Name != "ada" || u.Nickname.Valid || u.Contact == nil || u.Email != "ada@example.com" {
+		t.Errorf("got %+v", u)
+	}
+	var n int64
+	if err := ScanStruct(rows, &n); err == nil {
+		t.Error("expected scanning into a non-struct to fail")
+	}
+	var partial struct {
+		ID int64 `db:"id"`
+	}
+	if err := ScanStruct(rows, &partial); err == nil || !strings.Contains(err.Error(), "column Name has no field") {
+		t.Errorf("got %v, want the column without a field to be named", err)
+	}
+}
+
+func TestScanSlice(t *testing.T) {
+	db := newDB(t)
+	want := []string{"ada@example.com", "bob@example.com"}
+	var users []user
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ScanSlice(rows, &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[1].Nickname.String != "b" {
+		t.Fatalf("got %+v", users)
+	}
+	var got []string
+	for _, u := range users {
+		got = append(got, u.Email)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got emails %q, want %q", got, want)
+	}
+
+	var pointers []*user
+	rows, err = db.QueryContext(context.Background(), "SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ScanSlice(rows, &pointers); err != nil {
+		t.Fatal(err)
+	}
+	if len(pointers) != 2 || pointers[0].ID != 1 || pointers[1].ID != 2 {
+		t.Errorf("got %+v", pointers)
+	}
+}
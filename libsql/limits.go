@@ -0,0 +1,53 @@
+package libsql
+
+import (
+	"fmt"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/limits"
+)
+
+// ResponseTooLargeError is returned by queries whose result exceeds the limits
+// set with WithMaxRows or WithMaxResponseBytes. Only the exceeded limit is set.
+type ResponseTooLargeError = limits.Error
+
+// WithMaxRows makes queries returning more than n rows fail with a
+// *ResponseTooLargeError, like the maxRows query parameter.
+func WithMaxRows(n int) Option {
+	return option(func(c *Connector) error {
+		if n <= 0 {
+			return fmt.Errorf("maximum number of rows must be positive")
+		}
+		c.cfg.MaxRows = n
+		return nil
+	})
+}
+
+// WithMaxResponseBytes makes requests whose response is larger than n bytes
+// fail with a *ResponseTooLargeError, like the maxResponseBytes query
+// parameter. The response is not read past the limit, so a huge result cannot
+// exhaust the memory of the client. Over websockets, a message over the limit
+// closes the websocket and fails the requests in flight on it.
+func WithMaxResponseBytes(n int64) Option {
+	return option(func(c *Connector) error {
+		if n <= 0 {
+			return fmt.Errorf("maximum response size must be positive")
+		}
+		c.cfg.MaxResponseBytes = n
+		return nil
+	})
+}
+
+// WithMaxRequestBytes splits batches whose request would be larger than n
+// bytes into several requests sent one after the other, like the
+// maxRequestBytes query parameter. It defaults to 4 MiB, below the payload
+// limit of sqld. The statements keep their order and, with a context from
+// WithAtomicBatch or in a buffered transaction, run in a single transaction.
+func WithMaxRequestBytes(n int) Option {
+	return option(func(c *Connector) error {
+		if n <= 0 {
+			return fmt.Errorf("maximum request size must be positive")
+		}
+		c.cfg.MaxRequestBytes = n
+		return nil
+	})
+}
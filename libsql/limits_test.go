@@ -0,0 +1,92 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newRowsServer returns a server answering every statement with n rows.
+func newRowsServer(t *testing.T, n int) *libsqltest.Server {
+	res := &libsqltest.Result{Cols: []string{"v"}}
+	for i := 0; i < n; i++ {
+		res.Rows = append(res.Rows, []driver.Value{"row"})
+	}
+	return libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		return res, nil
+	})
+}
+
+func TestResponseLimits(t *testing.T) {
+	server := newRowsServer(t, 10)
+	tests := []struct {
+		name    string
+		dbUrl   string
+		opts    []Option
+		wantErr *ResponseTooLargeError
+	}{
+		{name: "no limits", dbUrl: server.URL},
+		{name: "rows within limit", dbUrl: server.URL, opts: []Option{WithMaxRows(10)}},
+		{name: "too many rows", dbUrl: server.URL, opts: []Option{WithMaxRows(9)}, wantErr: &ResponseTooLargeError{MaxRows: 9}},
+		{name: "maxRows parameter", dbUrl: server.URL + "?maxRows=5", wantErr: &ResponseTooLargeError{MaxRows: 5}},
+		{name: "response within limit", dbUrl: server.URL, opts: []Option{WithMaxResponseBytes(1 << 20)}},
+		{name: "response too large", dbUrl: server.URL, opts: []Option{WithMaxResponseBytes(100)}, wantErr: &ResponseTooLargeError{MaxBytes: 100}},
+		{name: "maxResponseBytes parameter", dbUrl: server.URL + "?maxResponseBytes=100", wantErr: &ResponseTooLargeError{MaxBytes: 100}},
+		{name: "streamed rows", dbUrl: server.URL + "?streamRows=1&maxRows=9", wantErr: &ResponseTooLargeError{MaxRows: 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector, err := NewConnector(tt.dbUrl, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			count, err := countRows(db)
+			if tt.wantErr == nil {
+				if err != nil || count != 10 {
+					t.Errorf("got %d rows and error %v, want 10 rows", count, err)
+				}
+				return
+			}
+			var tooLarge *ResponseTooLargeError
+			if !errors.As(err, &tooLarge) || *tooLarge != *tt.wantErr {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func countRows(db *sql.DB) (int, error) {
+	rows, err := db.QueryContext(context.Background(), "SELECT v FROM t")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	return count, rows.Err()
+}
+
+func TestResponseLimitsInvalid(t *testing.T) {
+	for _, dbUrl := range []string{"https://db?maxRows=0", "https://db?maxRows=x", "https://db?maxResponseBytes=-1", "https://db?maxRequestBytes=0"} {
+		if _, err := NewConnector(dbUrl); err == nil {
+			t.Errorf("expected %s to be rejected", dbUrl)
+		}
+	}
+	if _, err := NewConnector("https://db", WithMaxRows(0)); err == nil {
+		t.Error("expected a zero row limit to be rejected")
+	}
+	if _, err := NewConnector("https://db", WithMaxResponseBytes(0)); err == nil {
+		t.Error("expected a zero size limit to be rejected")
+	}
+	if _, err := NewConnector("https://db", WithMaxRequestBytes(-1)); err == nil {
+		t.Error("expected a negative request size limit to be rejected")
+	}
+}
@@ -0,0 +1,19 @@
+package libsql
+
+import (
+	"log/slog"
+)
+
+// WithLogger sends the debug events of the driver to logger: the requests and
+// responses of the transports at the debug level, retries, reconnections and
+// auth token refreshes at the info level, and the failures the driver
+// recovers from, like an unreachable read replica or a rollback that failed,
+// at the warning level. Auth tokens, headers and the credentials of URLs are
+// never logged. Without a logger, the events are written to the standard error
+// when the LIBSQL_DEBUG environment variable is set.
+func WithLogger(logger *slog.Logger) Option {
+	return option(func(c *Connector) error {
+		c.cfg.Logger = logger
+		return nil
+	})
+}
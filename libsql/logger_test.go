@@ -0,0 +1,43 @@
+package libsql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithLogger(t *testing.T) {
+	server := newRateLimitedServer(t, 1, "0")
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	provider := func(ctx context.Context) (string, error) { return "secret-token", nil }
+	connector, err := NewConnector(server.URL, WithLogger(logger), WithRateLimitRetries(1), WithAuthTokenProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := buf.String()
+	for _, want := range []string{
+		`level=DEBUG msg="refreshing auth token"`,
+		`level=DEBUG msg="sending pipeline request"`,
+		`level=DEBUG msg="received pipeline response"`,
+		"status=429",
+		`level=INFO msg="retrying rate limited request"`,
+		"status=200",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs do not contain %q:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "secret-token") {
+		t.Errorf("logs contain the auth token:\n%s", logs)
+	}
+}
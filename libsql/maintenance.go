@@ -0,0 +1,89 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// CheckpointMode is the mode of a WAL checkpoint, as described in
+// https://www.sqlite.org/pragma.html#pragma_wal_checkpoint.
+type CheckpointMode string
+
+const (
+	CheckpointPassive  CheckpointMode = "PASSIVE"
+	CheckpointFull     CheckpointMode = "FULL"
+	CheckpointRestart  CheckpointMode = "RESTART"
+	CheckpointTruncate CheckpointMode = "TRUNCATE"
+)
+
+// CheckpointResult is the row returned by PRAGMA wal_checkpoint.
+type CheckpointResult struct {
+	// Busy is set when the checkpoint could not complete because other
+	// connections were reading or writing.
+	Busy bool
+	// LogFrames is the number of frames in the WAL, -1 if the database is not
+	// in WAL mode.
+	LogFrames int64
+	// CheckpointedFrames is the number of frames of the WAL moved into the
+	// database, -1 if the database is not in WAL mode.
+	CheckpointedFrames int64
+}
+
+// Checkpoint runs PRAGMA wal_checkpoint with mode, CheckpointPassive if empty,
+// and returns its result.
+func Checkpoint(ctx context.Context, db *sql.DB, mode CheckpointMode) (CheckpointResult, error) {
+	switch mode {
+	case "":
+		mode = CheckpointPassive
+	case CheckpointPassive, CheckpointFull, CheckpointRestart, CheckpointTruncate:
+	default:
+		return CheckpointResult{}, fmt.Errorf("invalid checkpoint mode %q", mode)
+	}
+	var res CheckpointResult
+	// PRAGMA statements do not accept bound parameters.
+	err := db.QueryRowContext(ctx, fmt.Sprintf("PRAGMA wal_checkpoint(%s)", mode)).Scan(&res.Busy, &res.LogFrames, &res.CheckpointedFrames)
+	if err != nil {
+		return CheckpointResult{}, fmt.Errorf("failed to checkpoint: %w", err)
+	}
+	return res, nil
+}
+
+// maintenanceStatement returns the name of the maintenance statement query
+// starts with, VACUUM or PRAGMA wal_checkpoint, and an empty string for other
+// queries. SQLite cannot run them inside a transaction.
+func maintenanceStatement(query string) string {
+	query = trimLeadingComments(query)
+	keyword := leadingKeyword(query)
+	switch strings.ToUpper(keyword) {
+	case "VACUUM":
+		return "VACUUM"
+	case "PRAGMA":
+		rest := trimLeadingComments(query[len(keyword):])
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.')
+		})
+		if end >= 0 {
+			rest = rest[:end]
+		}
+		// The pragma may be qualified with a schema, as in main.wal_checkpoint.
+		name := rest[strings.LastIndexByte(rest, '.')+1:]
+		if strings.EqualFold(name, "wal_checkpoint") {
+			return "PRAGMA wal_checkpoint"
+		}
+	}
+	return ""
+}
+
+// checkMaintenance fails maintenance statements that would run inside a
+// transaction, before they are sent or buffered.
+func (c *Conn) checkMaintenance(query string) error {
+	if c.buffered == nil && !c.inTx {
+		return nil
+	}
+	if name := maintenanceStatement(query); name != "" {
+		return fmt.Errorf("%s cannot run inside a transaction", name)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestMaintenanceStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "VACUUM", want: "VACUUM"},
+		{query: "  vacuum INTO '/tmp/copy.db'", want: "VACUUM"},
+		{query: "-- nightly\nVACUUM main", want: "VACUUM"},
+		{query: "PRAGMA wal_checkpoint", want: "PRAGMA wal_checkpoint"},
+		{query: "pragma /* mode */ main.WAL_CHECKPOINT(TRUNCATE)", want: "PRAGMA wal_checkpoint"},
+		{query: "PRAGMA wal_autocheckpoint = 100"},
+		{query: "PRAGMA user_version"},
+		{query: "SELECT 'VACUUM'"},
+		{query: ""},
+	}
+	for _, tt := range tests {
+		if got := maintenanceStatement(tt.query); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+// newMaintenanceServer returns a server answering wal_checkpoint with a row
+// and any other statement with an empty result, and a function listing the
+// statements of every request that executed some.
+func newMaintenanceServer(t *testing.T) (string, func() [][]string) {
+	server := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		if strings.HasPrefix(query, "PRAGMA wal_checkpoint") {
+			return &libsqltest.Result{Cols: []string{"busy", "log", "checkpointed"}, Rows: [][]driver.Value{{int64(0), int64(12), int64(12)}}}, nil
+		}
+		return nil, nil
+	})
+	return server.URL, func() [][]string {
+		var received [][]string
+		for _, r := range server.Requests() {
+			if len(r.Statements) > 0 {
+				received = append(received, r.Statements)
+			}
+		}
+		return received
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	url, received := newMaintenanceServer(t)
+	db, err := sql.Open("libsql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	res, err := Checkpoint(ctx, db, CheckpointTruncate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CheckpointResult{LogFrames: 12, CheckpointedFrames: 12}); res != want {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Checkpoint(ctx, db, "NOW"); err == nil {
+		t.Error("expected an invalid mode to be rejected")
+	}
+	want := [][]string{{"PRAGMA wal_checkpoint(TRUNCATE)"}, {"VACUUM"}}
+	if got := received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want every statement sent on its own", got)
+	}
+}
+
+func TestMaintenanceInTransaction(t *testing.T) {
+	url, received := newMaintenanceServer(t)
+	db, err := sql.Open("libsql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "VACUUM"); err == nil || !strings.Contains(err.Error(), "VACUUM cannot run inside a transaction") {
+		t.Errorf("got %v, want VACUUM to be rejected", err)
+	}
+	if _, err := tx.QueryContext(ctx, "PRAGMA wal_checkpoint"); err == nil {
+		t.Error("expected wal_checkpoint to be rejected")
+	}
+	if got := received(); len(got) != 0 {
+		t.Errorf("got %q, want nothing to be sent before the first statement of the transaction", got)
+	}
+
+	buffered, err := db.BeginTx(WithBufferedTransaction(ctx), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buffered.Rollback()
+	if _, err := buffered.ExecContext(ctx, "VACUUM"); err == nil {
+		t.Error("expected VACUUM to be rejected in a buffered transaction")
+	}
+}
@@ -0,0 +1,198 @@
+package libsql
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMetricsLimit = 1000
+	// latencySamples is the number of most recent latencies kept per statement
+	// to estimate percentiles.
+	latencySamples = 1024
+	// OtherStatements is the fingerprint under which statements are counted
+	// once the registry holds as many fingerprints as its limit.
+	OtherStatements = "(other)"
+)
+
+// StatementStats are the metrics collected for one statement fingerprint, as
+// returned by Fingerprint.
+type StatementStats struct {
+	Fingerprint string        `json:"fingerprint"`
+	Count       int64         `json:"count"`
+	Errors      int64         `json:"errors"`
+	Rows        int64         `json:"rows"`
+	P50         time.Duration `json:"p50"`
+	P99         time.Duration `json:"p99"`
+}
+
+type statementMetrics struct {
+	count, errors, rows int64
+	latencies           []time.Duration
+	next                int
+}
+
+func (s *statementMetrics) observe(d time.Duration) {
+	if len(s.latencies) < latencySamples {
+		s.latencies = append(s.latencies, d)
+		return
+	}
+	s.latencies[s.next] = d
+	s.next = (s.next + 1) % latencySamples
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+type metricsRegistry struct {
+	sync.Mutex
+	limit      int
+	statements map[string]*statementMetrics
+	// fingerprints caches the fingerprints of queries, which are executed
+	// over and over, up to limit queries.
+	fingerprints map[string]string
+}
+
+var registry = &metricsRegistry{limit: defaultMetricsLimit, statements: map[string]*statementMetrics{}, fingerprints: map[string]string{}}
+
+// fingerprint returns the fingerprint of query, the caller must hold the lock.
+func (r *metricsRegistry) fingerprint(query string) string {
+	if fingerprint, ok := r.fingerprints[query]; ok {
+		return fingerprint
+	}
+	fingerprint := Fingerprint(query)
+	if len(r.fingerprints) < r.limit {
+		r.fingerprints[query] = fingerprint
+	}
+	return fingerprint
+}
+
+// get returns the metrics of fingerprint, the caller must hold the lock.
+func (r *metricsRegistry) get(fingerprint string) *statementMetrics {
+	s, ok := r.statements[fingerprint]
+	if !ok {
+		if len(r.statements) >= r.limit {
+			fingerprint = OtherStatements
+			if s, ok = r.statements[fingerprint]; ok {
+				return s
+			}
+		}
+		s = &statementMetrics{}
+		r.statements[fingerprint] = s
+	}
+	return s
+}
+
+func (r *metricsRegistry) record(query string, d time.Duration, rows int64, err error) {
+	r.Lock()
+	defer r.Unlock()
+	s := r.get(r.fingerprint(query))
+	s.count++
+	if err != nil {
+		s.errors++
+	}
+	s.rows += rows
+	s.observe(d)
+}
+
+func (r *metricsRegistry) addRows(query string, rows int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.get(r.fingerprint(query)).rows += rows
+}
+
+// WithMetrics records statement metrics of the connections of the connector
+// in the registry returned by StatementMetrics.
+func WithMetrics() Option {
+	return option(func(c *Connector) error {
+		c.metrics = true
+		return nil
+	})
+}
+
+// StatementMetrics returns the metrics recorded so far, sorted by fingerprint.
+func StatementMetrics() []StatementStats {
+	registry.Lock()
+	defer registry.Unlock()
+	res := make([]StatementStats, 0, len(registry.statements))
+	for fingerprint, s := range registry.statements {
+		sorted := make([]time.Duration, len(s.latencies))
+		copy(sorted, s.latencies)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		res = append(res, StatementStats{
+			Fingerprint: fingerprint,
+			Count:       s.count,
+			Errors:      s.errors,
+			Rows:        s.rows,
+			P50:         percentile(sorted, 0.5),
+			P99:         percentile(sorted, 0.99),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Fingerprint < res[j].Fingerprint })
+	return res
+}
+
+// ResetStatementMetrics discards all recorded metrics.
+func ResetStatementMetrics() {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.statements = map[string]*statementMetrics{}
+	registry.fingerprints = map[string]string{}
+}
+
+// SetStatementMetricsLimit sets the maximum number of fingerprints tracked
+// individually. Further statements are counted under OtherStatements.
+func SetStatementMetricsLimit(limit int) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.limit = limit
+}
+
+// WriteStatementMetrics writes the recorded metrics to w in the Prometheus
+// text exposition format.
+func WriteStatementMetrics(w io.Writer) error {
+	metrics := StatementMetrics()
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	write := func(name, help, kind string, value func(s StatementStats) string) error {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind); err != nil {
+			return err
+		}
+		for _, s := range metrics {
+			if _, err := fmt.Fprintf(w, "%s{statement=\"%s\"} %s\n", name, escape.Replace(s.Fingerprint), value(s)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := write("libsql_statements_total", "Number of executions of the statement.", "counter", func(s StatementStats) string {
+		return fmt.Sprint(s.Count)
+	}); err != nil {
+		return err
+	}
+	if err := write("libsql_statement_errors_total", "Number of failed executions of the statement.", "counter", func(s StatementStats) string {
+		return fmt.Sprint(s.Errors)
+	}); err != nil {
+		return err
+	}
+	if err := write("libsql_statement_rows_total", "Rows returned or affected by the statement.", "counter", func(s StatementStats) string {
+		return fmt.Sprint(s.Rows)
+	}); err != nil {
+		return err
+	}
+	if err := write("libsql_statement_latency_p50_seconds", "Median latency of the statement.", "gauge", func(s StatementStats) string {
+		return fmt.Sprint(s.P50.Seconds())
+	}); err != nil {
+		return err
+	}
+	return write("libsql_statement_latency_p99_seconds", "99th percentile latency of the statement.", "gauge", func(s StatementStats) string {
+		return fmt.Sprint(s.P99.Seconds())
+	})
+}
@@ -0,0 +1,83 @@
+package libsql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatementMetrics(t *testing.T) {
+	ResetStatementMetrics()
+	defer ResetStatementMetrics()
+
+	for i := 1; i <= 100; i++ {
+		registry.record("SELECT *\n  FROM t", time.Duration(i)*time.Millisecond, 2, nil)
+	}
+	registry.record("SELECT * FROM t", time.Millisecond, 0, errors.New("boom"))
+	registry.addRows("SELECT * FROM t", 5)
+
+	metrics := StatementMetrics()
+	if len(metrics) != 1 {
+		t.Fatalf("got %d fingerprints, want 1", len(metrics))
+	}
+	s := metrics[0]
+	if s.Fingerprint != "SELECT * FROM t" || s.Count != 101 || s.Errors != 1 || s.Rows != 205 {
+		t.Errorf("got %+v", s)
+	}
+	if s.P50 != 50*time.Millisecond || s.P99 != 99*time.Millisecond {
+		t.Errorf("got p50 %s and p99 %s", s.P50, s.P99)
+	}
+}
+
+func TestStatementMetricsGroupLiterals(t *testing.T) {
+	ResetStatementMetrics()
+	defer ResetStatementMetrics()
+
+	registry.record("SELECT * FROM t WHERE id = 1", time.Millisecond, 1, nil)
+	registry.record("select * from t where id = 2", time.Millisecond, 1, nil)
+	metrics := StatementMetrics()
+	if len(metrics) != 1 || metrics[0].Fingerprint != "SELECT * FROM t WHERE id = ?" || metrics[0].Count != 2 {
+		t.Errorf("got %+v, want both queries under one fingerprint", metrics)
+	}
+}
+
+func TestStatementMetricsLimit(t *testing.T) {
+	ResetStatementMetrics()
+	SetStatementMetricsLimit(2)
+	defer func() {
+		ResetStatementMetrics()
+		SetStatementMetricsLimit(defaultMetricsLimit)
+	}()
+
+	for _, query := range []string{"SELECT a", "SELECT b", "SELECT c", "SELECT d"} {
+		registry.record(query, time.Millisecond, 0, nil)
+	}
+	metrics := StatementMetrics()
+	if len(metrics) != 3 {
+		t.Fatalf("got %d fingerprints, want 3", len(metrics))
+	}
+	if metrics[0].Fingerprint != OtherStatements || metrics[0].Count != 2 {
+		t.Errorf("got %+v for the overflow bucket", metrics[0])
+	}
+}
+
+func TestWriteStatementMetrics(t *testing.T) {
+	ResetStatementMetrics()
+	defer ResetStatementMetrics()
+
+	registry.record(`SELECT "a" FROM t`, time.Second, 1, nil)
+	var out strings.Builder
+	if err := WriteStatementMetrics(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE libsql_statements_total counter",
+		`libsql_statements_total{statement="SELECT \"a\" FROM t"} 1`,
+		`libsql_statement_latency_p99_seconds{statement="SELECT \"a\" FROM t"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
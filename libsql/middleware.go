@@ -0,0 +1,30 @@
+package libsql
+
+import "net/http"
+
+// WithRequestMiddleware calls middleware with every HTTP request of the
+// connector and every websocket handshake right before it is sent, once the
+// driver set all its headers, including the Authorization header of the auth
+// token if there is one. The middleware can change the request to
+// authenticate it to signing proxies, with AWS SigV4 or an HMAC of the body
+// for instance. The body of requests can be read again from req.GetBody. An
+// error fails the request without sending it, and is wrapped by the error of
+// the query. Middlewares of several options run in the order of the options.
+func WithRequestMiddleware(middleware func(req *http.Request) error) Option {
+	return option(func(c *Connector) error {
+		if middleware == nil {
+			return nil
+		}
+		if previous := c.cfg.RequestMiddleware; previous != nil {
+			c.cfg.RequestMiddleware = func(req *http.Request) error {
+				if err := previous(req); err != nil {
+					return err
+				}
+				return middleware(req)
+			}
+			return nil
+		}
+		c.cfg.RequestMiddleware = middleware
+		return nil
+	})
+}
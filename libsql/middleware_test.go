@@ -0,0 +1,80 @@
+package libsql
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestRequestMiddleware(t *testing.T) {
+	sign := func(body []byte) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	server := libsqltest.NewFuncServer(t, nil)
+
+	errRefused := errors.New("refused")
+	var order []string
+	connector, err := NewConnector(server.URL+"?authToken=token",
+		WithRequestMiddleware(func(req *http.Request) error {
+			order = append(order, "first")
+			if req.Header.Get("Authorization") != "Bearer token" {
+				t.Errorf("got Authorization %q, want the auth token to be set before the middleware", req.Header.Get("Authorization"))
+			}
+			return nil
+		}),
+		WithRequestMiddleware(func(req *http.Request) error {
+			order = append(order, "second")
+			if req.Header.Get("X-Refuse") != "" {
+				return errRefused
+			}
+			var body []byte
+			if req.GetBody != nil {
+				r, err := req.GetBody()
+				if err != nil {
+					return err
+				}
+				defer r.Close()
+				if body, err = io.ReadAll(r); err != nil {
+					return err
+				}
+			}
+			req.Header.Set("X-Signature", sign(body))
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(WithRequestHeaders(ctx, http.Header{"X-Refuse": {"1"}}), "SELECT 1"); !errors.Is(err, errRefused) {
+		t.Errorf("got %v, want the middleware error", err)
+	}
+	var received []string
+	for _, r := range server.Requests() {
+		received = append(received, fmt.Sprintf("%s %s signed=%v", r.Method, r.URL.Path, r.Header.Get("X-Signature") == sign(r.Body)))
+	}
+	want := "GET /v3 signed=true,POST /v3/pipeline signed=true"
+	if got := strings.Join(received, ","); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(order) < 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("got middlewares called in order %q", order)
+	}
+}
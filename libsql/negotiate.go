@@ -0,0 +1,84 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/http"
+	"github.com/libsql/libsql-client-go/libsql/internal/ws"
+)
+
+type transport int
+
+const (
+	transportWebsocket transport = iota
+	transportHttp
+)
+
+// negotiatedTransportTTL is how long connections to a host skip websockets
+// after it refused the upgrade, so a server that starts serving them is
+// eventually used with websockets again.
+var negotiatedTransportTTL = 10 * time.Minute
+
+type negotiatedTransport struct {
+	transport transport
+	expires   time.Time
+}
+
+// negotiatedTransports remembers, per host, whether websockets had to be
+// abandoned in favour of HTTP so later connections skip the failed upgrade.
+var negotiatedTransports = struct {
+	sync.Mutex
+	byHost map[string]negotiatedTransport
+}{byHost: map[string]negotiatedTransport{}}
+
+func cachedTransport(host string) transport {
+	negotiatedTransports.Lock()
+	defer negotiatedTransports.Unlock()
+	cached, ok := negotiatedTransports.byHost[host]
+	if !ok {
+		return transportWebsocket
+	}
+	if time.Now().After(cached.expires) {
+		delete(negotiatedTransports.byHost, host)
+		return transportWebsocket
+	}
+	return cached.transport
+}
+
+func cacheTransport(host string, t transport) {
+	negotiatedTransports.Lock()
+	defer negotiatedTransports.Unlock()
+	negotiatedTransports.byHost[host] = negotiatedTransport{transport: t, expires: time.Now().Add(negotiatedTransportTTL)}
+}
+
+// connectNegotiated opens a libsql:// URL. Websockets are tried first and
+// Hrana over HTTP is used when the server refuses the websocket upgrade, see
+// ws.ErrUpgradeFailed. Other errors are returned as is.
+func connectNegotiated(ctx context.Context, pools *ws.Pools, u *url.URL, tls bool, token *auth.Token, cfg *config.Config) (driver.Conn, error) {
+	wsUrl, httpUrl := *u, *u
+	if tls {
+		wsUrl.Scheme, httpUrl.Scheme = "wss", "https"
+	} else {
+		wsUrl.Scheme, httpUrl.Scheme = "ws", "http"
+	}
+
+	if cachedTransport(u.Host) == transportWebsocket {
+		c, err := pools.Connect(ctx, wsUrl.String(), token, cfg)
+		if err == nil {
+			return c, nil
+		}
+		if !errors.Is(err, ws.ErrUpgradeFailed) {
+			return nil, err
+		}
+		cfg.Log().InfoContext(ctx, "falling back to HTTP", "host", u.Host, "error", err)
+		cacheTransport(u.Host, transportHttp)
+	}
+	return http.Connect(ctx, httpUrl.String(), token, cfg)
+}
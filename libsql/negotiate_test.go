@@ -0,0 +1,94 @@
+package libsql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/config"
+	"github.com/libsql/libsql-client-go/libsql/internal/ws"
+)
+
+func TestConnectNegotiatedFallsBackToHttp(t *testing.T) {
+	var upgrades int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "websocket" {
+			upgrades++
+			// Pretend the server does not serve websockets.
+			w.WriteHeader(http.StatusUpgradeRequired)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		c, err := connectNegotiated(context.Background(), &ws.Pools{}, u, false, nil, &config.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	if got := cachedTransport(u.Host); got != transportHttp {
+		t.Errorf("got cached transport %v, want HTTP", got)
+	}
+	if upgrades != 1 {
+		t.Errorf("got %d websocket upgrade attempts, want 1", upgrades)
+	}
+
+	// Websockets are tried again once the fallback expired.
+	defer func(ttl time.Duration) { negotiatedTransportTTL = ttl }(negotiatedTransportTTL)
+	negotiatedTransportTTL = -time.Second
+	cacheTransport(u.Host, transportHttp)
+	if got := cachedTransport(u.Host); got != transportWebsocket {
+		t.Errorf("got cached transport %v, want websockets after the fallback expired", got)
+	}
+}
+
+func TestConnectNegotiatedReturnsOtherErrors(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connectNegotiated(context.Background(), &ws.Pools{}, u, false, nil, &config.Config{}); err == nil || errors.Is(err, ws.ErrUpgradeFailed) {
+		t.Fatalf("got %v, want the upgrade error", err)
+	}
+	if got := cachedTransport(u.Host); got != transportWebsocket {
+		t.Errorf("got cached transport %v, want websockets", got)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want no fallback to HTTP", requests)
+	}
+}
+
+func TestConnectCanceledDoesNotFallBackToLegacyApi(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	connector, err := NewConnector(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := connector.Connect(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,168 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// nullColumns are the columns answered by newNullServer, with the declared
+// types and values SQLite returns for
+//
+//	SELECT NULL, '', x'', CAST(NULL AS BLOB), x'6261', 0, p.created_at, u.created_at
+//	FROM users u LEFT JOIN posts p ON p.user_id = u.id
+//
+// for a user without posts.
+var nullColumns = []struct {
+	decltype string
+	value    driver.Value
+}{
+	{value: nil},
+	{value: ""},
+	{value: []byte{}},
+	{value: nil},
+	{value: []byte("ba")},
+	{value: int64(0)},
+	{decltype: "DATETIME", value: nil},
+	{decltype: "DATETIME", value: "2024-01-02 03:04:05"},
+}
+
+// newNullServer returns a server answering every statement with a row of
+// nullColumns, and a function listing the arguments of the statements it
+// received.
+func newNullServer(t *testing.T) (string, func() [][]driver.Value) {
+	var mu sync.Mutex
+	var received [][]driver.Value
+	res := &libsqltest.Result{Rows: [][]driver.Value{make([]driver.Value, len(nullColumns))}}
+	for idx, col := range nullColumns {
+		res.Cols = append(res.Cols, fmt.Sprintf("c%d", idx))
+		res.DeclTypes = append(res.DeclTypes, col.decltype)
+		res.Rows[0][idx] = col.value
+	}
+	server := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		values := make([]driver.Value, len(args))
+		for idx, arg := range args {
+			values[idx] = arg.Value
+		}
+		mu.Lock()
+		received = append(received, values)
+		mu.Unlock()
+		return res, nil
+	})
+	return server.URL, func() [][]driver.Value {
+		mu.Lock()
+		defer mu.Unlock()
+		return received
+	}
+}
+
+// TestNullConformance scans the values of nullColumns into the destinations
+// commonly used with database/sql, expecting what mattn/go-sqlite3 returns for
+// the same query on a local database.
+func TestNullConformance(t *testing.T) {
+	stamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	text := func(s string) *string { return &s }
+	tests := []struct {
+		name string
+		col  int
+		dest any
+		want any
+	}{
+		{name: "nullAny", col: 0, dest: new(any), want: nil},
+		{name: "nullString", col: 0, dest: new(sql.NullString), want: sql.NullString{}},
+		{name: "nullStringPointer", col: 0, dest: new(*string), want: (*string)(nil)},
+		{name: "nullBytes", col: 0, dest: new([]byte), want: []byte(nil)},
+		{name: "nullInt64", col: 0, dest: new(sql.NullInt64), want: sql.NullInt64{}},
+		{name: "nullFloat64", col: 0, dest: new(sql.NullFloat64), want: sql.NullFloat64{}},
+		{name: "nullBool", col: 0, dest: new(sql.NullBool), want: sql.NullBool{}},
+		{name: "emptyAny", col: 1, dest: new(any), want: ""},
+		{name: "emptyString", col: 1, dest: new(sql.NullString), want: sql.NullString{Valid: true}},
+		{name: "emptyStringPointer", col: 1, dest: new(*string), want: text("")},
+		{name: "emptyTextBytes", col: 1, dest: new([]byte), want: []byte{}},
+		{name: "emptyBlobAny", col: 2, dest: new(any), want: []byte{}},
+		{name: "emptyBlob", col: 2, dest: new([]byte), want: []byte{}},
+		{name: "emptyBlobString", col: 2, dest: new(sql.NullString), want: sql.NullString{Valid: true}},
+		{name: "nullBlob", col: 3, dest: new([]byte), want: []byte(nil)},
+		{name: "nullBlobString", col: 3, dest: new(sql.NullString), want: sql.NullString{}},
+		{name: "blob", col: 4, dest: new([]byte), want: []byte("ba")},
+		{name: "zeroBool", col: 5, dest: new(sql.NullBool), want: sql.NullBool{Valid: true}},
+		{name: "zeroInt64", col: 5, dest: new(sql.NullInt64), want: sql.NullInt64{Valid: true}},
+		{name: "outerJoinTime", col: 6, dest: new(sql.NullTime), want: sql.NullTime{}},
+		{name: "outerJoinTimePointer", col: 6, dest: new(*time.Time), want: (*time.Time)(nil)},
+		{name: "time", col: 7, dest: new(sql.NullTime), want: sql.NullTime{Time: stamp, Valid: true}},
+	}
+	url, _ := newNullServer(t)
+	db, err := sql.Open("libsql", url+"?parseTime=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := make([]any, len(nullColumns))
+			for idx := range dest {
+				dest[idx] = new(any)
+			}
+			dest[tt.col] = tt.dest
+			if err := db.QueryRow("SELECT").Scan(dest...); err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(tt.dest).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	// Like mattn/go-sqlite3, NULL cannot be scanned into a destination that
+	// has no representation for it.
+	for _, dest := range []any{new(string), new(int64), new(time.Time)} {
+		row := make([]any, len(nullColumns))
+		for idx := range row {
+			row[idx] = new(any)
+		}
+		row[6] = dest
+		if err := db.QueryRow("SELECT").Scan(row...); err == nil {
+			t.Errorf("expected scanning NULL into %T to fail", dest)
+		}
+	}
+}
+
+// TestNullParameters checks that the arguments mattn/go-sqlite3 binds as NULL
+// are sent as NULL, and only those.
+func TestNullParameters(t *testing.T) {
+	url, received := newNullServer(t)
+	db, err := sql.Open("libsql", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	args := []any{
+		nil,
+		[]byte(nil),
+		[]byte{},
+		(*string)(nil),
+		sql.NullString{},
+		sql.NullString{Valid: true},
+		sql.NullInt64{Int64: 5},
+		sql.NullFloat64{},
+		sql.NullBool{Valid: true},
+		sql.NullTime{Time: time.Now()},
+	}
+	if _, err := db.ExecContext(context.Background(), "INSERT INTO t VALUES (?"+strings.Repeat(", ?", len(args)-1)+")", args...); err != nil {
+		t.Fatal(err)
+	}
+	want := []driver.Value{nil, nil, []byte{}, nil, nil, "", nil, nil, int64(0), nil}
+	got := received()
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
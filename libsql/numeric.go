@@ -0,0 +1,12 @@
+package libsql
+
+// WithBigIntText binds *big.Int and big.Int arguments as their decimal text,
+// since SQLite integers are signed 64-bit. Without it such arguments are
+// rejected. Text compares as text, not numerically: scan the column into a
+// string and parse it with big.Int.SetString to read the values back.
+func WithBigIntText() Option {
+	return option(func(c *Connector) error {
+		c.checker.BigIntText = true
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package libsql
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestWithBigIntText(t *testing.T) {
+	value, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	connector, err := NewConnector("http://localhost:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connector.checker.ConvertValue(value); err == nil {
+		t.Error("expected big integers to be rejected without WithBigIntText")
+	}
+
+	connector, err = NewConnector("http://localhost:8080?timeFormat=unix", WithBigIntText())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := connector.checker.ConvertValue(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "123456789012345678901234567890" {
+		t.Errorf("got %#v, want the decimal text", got)
+	}
+}
@@ -0,0 +1,179 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestHTTPProxy(t *testing.T) {
+	// The proxy answers the requests forwarded to it itself.
+	proxy := libsqltest.NewFuncServer(t, nil)
+
+	connector, err := NewConnector("http://db.invalid?insecure=1", WithProxyURL("http://user:pass@"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	var received []string
+	for _, r := range proxy.Requests() {
+		received = append(received, fmt.Sprintf("%s %s %s", r.Method, r.URL, r.Header.Get("Proxy-Authorization")))
+	}
+	auth := "Basic dXNlcjpwYXNz"
+	want := []string{"GET http://db.invalid/v3 " + auth, "POST http://db.invalid/v3/pipeline " + auth}
+	if strings.Join(received, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got requests %q, want %q", received, want)
+	}
+}
+
+// newSOCKS5Proxy returns the address of a SOCKS5 proxy accepting the user
+// name and password authentication and reporting the credentials and
+// destinations of its connections to connected.
+func newSOCKS5Proxy(t *testing.T, connected func(user, password, dest string)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(c, connected)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveSOCKS5(c net.Conn, connected func(user, password, dest string)) {
+	defer c.Close()
+	read := func(n int) []byte {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c, buf); err != nil {
+			return nil
+		}
+		return buf
+	}
+	// Greeting: version, methods. Only user name and password is accepted.
+	greeting := read(2)
+	if greeting == nil || read(int(greeting[1])) == nil {
+		return
+	}
+	if _, err := c.Write([]byte{5, 2}); err != nil {
+		return
+	}
+	// RFC 1929 authentication.
+	header := read(2)
+	if header == nil {
+		return
+	}
+	user := read(int(header[1]))
+	passwordLen := read(1)
+	if user == nil || passwordLen == nil {
+		return
+	}
+	password := read(int(passwordLen[0]))
+	if _, err := c.Write([]byte{1, 0}); err != nil {
+		return
+	}
+	// Request: version, CONNECT, reserved, address type.
+	request := read(4)
+	if request == nil {
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		host = net.IP(read(4)).String()
+	case 3:
+		hostLen := read(1)
+		if hostLen == nil {
+			return
+		}
+		host = string(read(int(hostLen[0])))
+	default:
+		return
+	}
+	port := read(2)
+	if port == nil {
+		return
+	}
+	dest := net.JoinHostPort(host, fmt.Sprint(binary.BigEndian.Uint16(port)))
+	connected(string(user), string(password), dest)
+	upstream, err := net.Dial("tcp", dest)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	if _, err := c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	go io.Copy(upstream, c)
+	io.Copy(c, upstream)
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+	var mu sync.Mutex
+	var connections []string
+	proxy := newSOCKS5Proxy(t, func(user, password, dest string) {
+		mu.Lock()
+		defer mu.Unlock()
+		connections = append(connections, fmt.Sprintf("%s:%s@%s", user, password, dest))
+	})
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	connector, err := NewConnector(server.URL, WithProxyURL("socks5://user:pass@"+proxy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server does not speak websockets, but the handshake goes through
+	// the proxy too.
+	wsConnector, err := NewConnector("ws://"+host, WithProxyURL("socks5://user:pass@"+proxy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wsConnector.Close()
+	if _, err := wsConnector.Connect(context.Background()); err == nil {
+		t.Fatal("expected the websocket upgrade to fail")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(connections) < 2 {
+		t.Fatalf("got connections %q, want the HTTP requests and the websocket through the proxy", connections)
+	}
+	for _, c := range connections {
+		if c != "user:pass@"+host {
+			t.Errorf("got connection %q, want user:pass@%s", c, host)
+		}
+	}
+}
+
+func TestWithProxyURLInvalid(t *testing.T) {
+	for _, proxyUrl := range []string{"ftp://proxy:21", "http://", "://proxy"} {
+		if _, err := NewConnector("http://localhost:8080", WithProxyURL(proxyUrl)); err == nil {
+			t.Errorf("%q: expected the proxy URL to be rejected", proxyUrl)
+		}
+	}
+}
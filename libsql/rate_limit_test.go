@@ -0,0 +1,77 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newRateLimitedServer answers the first limited pipeline requests with HTTP
+// 429 and retryAfter, and the others with an empty result.
+func newRateLimitedServer(t *testing.T, limited int, retryAfter string) *libsqltest.Server {
+	server := libsqltest.NewFuncServer(t, nil)
+	failure := libsqltest.Failure{Status: http.StatusTooManyRequests, Message: "too many requests"}
+	if retryAfter != "" {
+		failure.Header = http.Header{"Retry-After": {retryAfter}}
+	}
+	server.InjectFailures(limited, failure)
+	return server
+}
+
+func TestRateLimitError(t *testing.T) {
+	server := newRateLimitedServer(t, 1, "30")
+	db, err := sql.Open("libsql", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	var rateLimit *RateLimitError
+	if !errors.As(err, &rateLimit) || rateLimit.RetryAfter != 30*time.Second {
+		t.Fatalf("got %v, want a rate limit error asking to wait 30s", err)
+	}
+	if !IsRetryable(err) {
+		t.Error("expected rate limit errors to be retryable")
+	}
+}
+
+func TestWithRateLimitRetries(t *testing.T) {
+	server := newRateLimitedServer(t, 2, "")
+	connector, err := NewConnector(server.URL, WithRateLimitRetries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if requests := countRequests(server, "/v3/pipeline"); requests != 3 {
+		t.Errorf("got %d pipeline requests, want the rate limited ones to be sent again", requests)
+	}
+}
+
+func TestWithRateLimitRetriesDeadline(t *testing.T) {
+	server := newRateLimitedServer(t, 1, "30")
+	connector, err := NewConnector(server.URL, WithRateLimitRetries(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	var rateLimit *RateLimitError
+	if _, err := db.ExecContext(ctx, "SELECT 1"); !errors.As(err, &rateLimit) {
+		t.Fatalf("got %v, want a rate limit error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("gave up after %s, want at once since the wait outlasts the deadline", elapsed)
+	}
+}
@@ -0,0 +1,80 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// errBatchInTx is returned by ExecBatch while a transaction is open, as the
+// batch would run its own.
+var errBatchInTx = errors.New("batches cannot be executed in a transaction")
+
+// ReplicationIndex returns the highest replication index reported for the
+// writes executed on the connection, zero if the server reported none. Unlike
+// Connector.ReplicationIndex, it ignores the writes of other connections.
+func (c *Conn) ReplicationIndex() uint64 {
+	return c.replicationIndex
+}
+
+// Stats returns the sum of the execution statistics of the statements executed
+// on the connection since it was opened, counted like WithStats does.
+func (c *Conn) Stats() Stats {
+	return c.stats
+}
+
+// ServerInfo describes the server of the connection, see
+// Connector.ServerInfo.
+func (c *Conn) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	return c.connector.ServerInfo(ctx)
+}
+
+// ExecBatch executes queries atomically: all of them are applied, or none if
+// one fails. Over Hrana the batch is sent in a single request, otherwise in an
+// interactive transaction. args holds the arguments of each query, positional
+// or sql.NamedArg, and may be shorter than queries for queries without
+// arguments. The transaction mode is read from ctx as set by WithTxMode.
+func (c *Conn) ExecBatch(ctx context.Context, queries []string, args [][]any) error {
+	if c.inTx || c.buffered != nil {
+		return errBatchInTx
+	}
+	if len(args) > len(queries) {
+		return fmt.Errorf("got arguments for %d queries, want at most %d", len(args), len(queries))
+	}
+	if err := c.route(ctx); err != nil {
+		return err
+	}
+	t := &bufferedTx{conn: c, queries: append([]string(nil), queries...), args: make([][]driver.NamedValue, len(queries))}
+	for idx := range args {
+		values, err := c.namedValues(args[idx])
+		if err == nil {
+			t.queries[idx], values, err = c.expandSliceArgs(queries[idx], values)
+		}
+		if err != nil {
+			return fmt.Errorf("query %d: %w", idx+1, err)
+		}
+		t.args[idx] = values
+	}
+	for _, query := range queries {
+		c.connector.diagnostics.checkQuery(query)
+	}
+	return t.exec(ctx)
+}
+
+// namedValues converts the arguments of a query like database/sql does.
+func (c *Conn) namedValues(args []any) ([]driver.NamedValue, error) {
+	values := make([]driver.NamedValue, len(args))
+	for idx, arg := range args {
+		nv := driver.NamedValue{Ordinal: idx + 1, Value: arg}
+		if named, ok := arg.(sql.NamedArg); ok {
+			nv.Name, nv.Value = named.Name, named.Value
+		}
+		if err := c.CheckNamedValue(&nv); err != nil {
+			return nil, fmt.Errorf("argument %d: %w", idx+1, err)
+		}
+		values[idx] = nv
+	}
+	return values, nil
+}
@@ -0,0 +1,74 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnRaw(t *testing.T) {
+	db, err := sql.Open("libsql", newStatsServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := conn.QueryContext(ctx, "SELECT a FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*Conn)
+		if !ok {
+			t.Fatalf("got driver connection %T, want *Conn", driverConn)
+		}
+		checkStats(t, c.Stats(), Stats{RowsRead: 2, RowsWritten: 1, QueryDuration: 2 * time.Millisecond})
+		if got := c.ReplicationIndex(); got != 0 {
+			t.Errorf("got replication index %d, want 0", got)
+		}
+		return c.ExecBatch(ctx, []string{"INSERT INTO t VALUES (?)", "INSERT INTO t VALUES (:v)", "DELETE FROM t"},
+			[][]any{{1}, {sql.Named("v", "two")}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).ExecBatch(ctx, []string{"INSERT INTO t VALUES (?)"}, [][]any{{struct{}{}}})
+	})
+	if err == nil {
+		t.Error("got no error for an unsupported argument")
+	}
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).ExecBatch(ctx, []string{"DELETE FROM t"}, [][]any{nil, nil})
+	})
+	if err == nil {
+		t.Error("got no error for more arguments than queries")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	err = conn.Raw(func(driverConn any) error {
+		return driverConn.(*Conn).ExecBatch(ctx, []string{"DELETE FROM t"}, nil)
+	})
+	if !errors.Is(err, errBatchInTx) {
+		t.Errorf("got error %v, want %v", err, errBatchInTx)
+	}
+}
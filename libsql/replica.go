@@ -0,0 +1,196 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
+
+// replica is a read replica that queries marked read-only are sent to.
+type replica struct {
+	url *url.URL
+	tls bool
+	// token is nil when the replica URL has no auth token, the token of the
+	// primary is used then.
+	token *auth.Token
+}
+
+// WithReadReplica sends the queries made with a context from WithReadOnly to
+// the database at dbUrl, typically a replica in the region of the client,
+// along with read-only transactions, while writes and other transactions keep
+// going to the primary URL. The replica URL
+// is parsed like the primary one and uses its auth token unless it has its
+// own.
+func WithReadReplica(dbUrl string) Option {
+	return option(func(c *Connector) error {
+		u, err := url.Parse(dbUrl)
+		if err != nil {
+			return fmt.Errorf("invalid read replica URL: %w", err)
+		}
+		query := u.Query()
+		ownToken := query.Has("authToken") || query.Has("jwt")
+		rc, err := parseUrl(dbUrl)
+		if err != nil {
+			return fmt.Errorf("invalid read replica URL: %w", err)
+		}
+		if rc.url == nil {
+			return fmt.Errorf("read replica %s is not a remote database", dbUrl)
+		}
+		r := &replica{url: rc.url, tls: rc.tls}
+		if ownToken {
+			r.token = rc.token
+		}
+		c.replica = r
+		return nil
+	})
+}
+
+type readOnlyKey struct{}
+
+// WithReadOnly returns a context marking queries as read-only, so they are
+// sent to the read replica configured with WithReadReplica. Replicas may lag
+// behind the primary; see WithReadYourWrites for how queries are kept from
+// missing the writes of the connector. Queries inside transactions, on connections with attached
+// databases and of prepared statements go where their transaction or
+// connection runs; start a transaction with sql.TxOptions{ReadOnly: true} to
+// run it on the replica.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+func readOnly(ctx context.Context) bool {
+	v, _ := ctx.Value(readOnlyKey{}).(bool)
+	return v
+}
+
+type readYourWritesKey struct{}
+
+// WithReadYourWrites returns a context setting whether queries sent to the
+// read replica must see the writes of the connector, which they do by
+// default. The rows of the replica are then only returned if the replica
+// reported a replication index reaching that of the last write, and the query
+// is sent again to the primary otherwise. Pass false to accept rows that may
+// miss recent writes and save that second request.
+func WithReadYourWrites(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, readYourWritesKey{}, enabled)
+}
+
+func readYourWrites(ctx context.Context) bool {
+	v, ok := ctx.Value(readYourWritesKey{}).(bool)
+	return !ok || v
+}
+
+// caughtUp reports whether rows read on the replica reflect the writes of the
+// connector, as far as read-your-writes requires it.
+func (c *Conn) caughtUp(ctx context.Context, rows driver.Rows) bool {
+	want := c.connector.ReplicationIndex()
+	if want == 0 || !readYourWrites(ctx) {
+		return true
+	}
+	r, ok := rows.(replicatedResult)
+	return ok && r.ReplicationIndex() >= want
+}
+
+// openReplica returns the connection to the read replica, opening it on first
+// use, or nil if there is no replica or it cannot be reached. Reads then fall
+// back to the primary, which can answer every one of them.
+func (c *Conn) openReplica(ctx context.Context) driver.Conn {
+	r := c.connector.replica
+	if r == nil {
+		return nil
+	}
+	if c.replica == nil {
+		token := r.token
+		if token == nil {
+			token = c.connector.token
+		}
+		rc, err := c.connector.connectTransport(ctx, *r.url, r.tls, token, c.database)
+		if err != nil {
+			c.connector.cfg.Log().WarnContext(ctx, "failed to connect to read replica, using the primary", "host", r.url.Host, "error", err)
+			return nil
+		}
+		c.replica = rc
+	}
+	return c.replica
+}
+
+// replicaConn returns the connection to the read replica the query should be
+// sent to, or nil to send it to the primary. Queries reading the session state
+// of the connection, like last_insert_rowid(), stay on the primary, where the
+// statements they depend on ran.
+func (c *Conn) replicaConn(ctx context.Context, query string) driver.QueryerContext {
+	if !readOnly(ctx) || c.inTx || c.buffered != nil || c.attached > 0 || shared.ReadsSessionState(query) {
+		return nil
+	}
+	q, ok := c.openReplica(ctx).(driver.QueryerContext)
+	if !ok {
+		return nil
+	}
+	return q
+}
+
+// beginReplicaTx starts a read-only transaction on the read replica, or
+// returns nil if it should run on the primary.
+func (c *Conn) beginReplicaTx(ctx context.Context, opts driver.TxOptions) driver.Tx {
+	if !opts.ReadOnly || c.attached > 0 {
+		return nil
+	}
+	b, ok := c.openReplica(ctx).(driver.ConnBeginTx)
+	if !ok {
+		return nil
+	}
+	t, err := b.BeginTx(ctx, opts)
+	if err != nil {
+		c.connector.cfg.Log().WarnContext(ctx, "failed to begin a transaction on the read replica, using the primary", "error", err)
+		if errors.Is(err, driver.ErrBadConn) {
+			c.closeReplica()
+		}
+		return nil
+	}
+	c.onReplica = true
+	return t
+}
+
+// target returns the connection statements run on: the read replica while a
+// read-only transaction is open on it, the primary otherwise.
+func (c *Conn) target() driver.Conn {
+	if c.onReplica {
+		return c.replica
+	}
+	return c.transport
+}
+
+// query runs a query on the read replica if it should go there, and on the
+// primary otherwise. A replica connection that went bad is closed, to be
+// reopened by the next read-only query, and its query is sent to the primary
+// instead of discarding the connection to the primary along with it.
+func (c *Conn) query(ctx context.Context, primary driver.QueryerContext, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q := c.replicaConn(ctx, query); q != nil {
+		rows, err := q.QueryContext(ctx, query, args)
+		switch {
+		case errors.Is(err, driver.ErrBadConn):
+			c.connector.cfg.Log().WarnContext(ctx, "read replica connection failed, querying the primary", "error", err)
+			c.closeReplica()
+		case err != nil || c.caughtUp(ctx, rows):
+			return rows, err
+		default:
+			c.connector.cfg.Log().DebugContext(ctx, "read replica is behind the writes of the connector, querying the primary")
+			rows.Close()
+		}
+	}
+	return primary.QueryContext(ctx, query, args)
+}
+
+func (c *Conn) closeReplica() {
+	if c.replica != nil {
+		if err := c.replica.Close(); err != nil {
+			c.connector.cfg.Log().Debug("failed to close read replica connection", "error", err)
+		}
+		c.replica = nil
+	}
+}
@@ -0,0 +1,271 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newRecordingServer returns a fake sqld server answering every statement
+// with a row holding name, and recording the SQL it receives.
+func newRecordingServer(t *testing.T, name string, mu *sync.Mutex, received *[]string) *libsqltest.Server {
+	return libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		mu.Lock()
+		*received = append(*received, name+": "+query)
+		mu.Unlock()
+		return &libsqltest.Result{Cols: []string{"v"}, Rows: [][]driver.Value{{name}}}, nil
+	})
+}
+
+func TestReadReplica(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	primary := newRecordingServer(t, "primary", &mu, &received)
+	replica := newRecordingServer(t, "replica", &mu, &received)
+
+	connector, err := NewConnector(primary.URL, WithReadReplica(replica.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	readOnlyCtx := WithReadOnly(ctx)
+	var v string
+	if err := db.QueryRowContext(readOnlyCtx, "SELECT 1").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != "replica" {
+		t.Errorf("got read-only query answered by %s, want the replica", v)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT 2").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(readOnlyCtx, "INSERT INTO t VALUES (3)"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.QueryRowContext(readOnlyCtx, "SELECT 4").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRowContext(readOnlyCtx, "SELECT 5").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRowContext(readOnlyCtx, "SELECT last_insert_rowid()").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"replica: SELECT 1",
+		"primary: SELECT 2",
+		"primary: INSERT INTO t VALUES (3)",
+		"primary: BEGIN",
+		"primary: SELECT 4",
+		"primary: COMMIT",
+		"replica: SELECT 5",
+		"primary: SELECT last_insert_rowid()",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("got %#v, want %#v", received, want)
+	}
+}
+
+func TestReadReplicaUnreachable(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	primary := newRecordingServer(t, "primary", &mu, &received)
+	replica := "ws" + strings.TrimPrefix(closedServerURL(), "http")
+
+	connector, err := NewConnector(primary.URL, WithReadReplica(replica))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	var v string
+	if err := db.QueryRowContext(WithReadOnly(context.Background()), "SELECT 1").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != "primary" {
+		t.Errorf("got query answered by %s, want the primary when the replica is down", v)
+	}
+}
+
+func TestWithReadReplicaInvalid(t *testing.T) {
+	for _, dbUrl := range []string{"file:replica.db", "ftp://replica", "https://replica?foo=bar"} {
+		if _, err := NewConnector("https://primary", WithReadReplica(dbUrl)); err == nil {
+			t.Errorf("expected %s to be rejected", dbUrl)
+		}
+	}
+}
+
+func TestTransactionOptions(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	primary := newRecordingServer(t, "primary", &mu, &received)
+	replica := newRecordingServer(t, "replica", &mu, &received)
+
+	connector, err := NewConnector(primary.URL, WithReadReplica(replica.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for _, opts := range []*sql.TxOptions{
+		{ReadOnly: true},
+		{Isolation: sql.LevelSerializable},
+		{Isolation: sql.LevelLinearizable},
+	} {
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, "SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelWriteCommitted}); err == nil {
+		t.Error("expected an unsupported isolation level to be rejected")
+	}
+
+	want := []string{
+		"replica: BEGIN", "replica: SELECT 1", "replica: COMMIT",
+		"primary: BEGIN IMMEDIATE", "primary: SELECT 1", "primary: COMMIT",
+		"primary: BEGIN EXCLUSIVE", "primary: SELECT 1", "primary: COMMIT",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("got %#v, want %#v", received, want)
+	}
+}
+
+func TestTxMode(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	primary := newRecordingServer(t, "primary", &mu, &received)
+	db, err := sql.Open("libsql", primary.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for _, tt := range []struct {
+		mode TxMode
+		opts *sql.TxOptions
+	}{
+		{mode: TxImmediate},
+		{mode: TxExclusive},
+		{mode: TxDeferred, opts: &sql.TxOptions{Isolation: sql.LevelSerializable}},
+		{mode: TxDeferred, opts: &sql.TxOptions{ReadOnly: true}},
+	} {
+		tx, err := db.BeginTx(WithTxMode(ctx, tt.mode), tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, "SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.BeginTx(WithTxMode(ctx, TxImmediate), &sql.TxOptions{ReadOnly: true}); err == nil {
+		t.Error("expected a read-only immediate transaction to be rejected")
+	}
+	if _, err := db.BeginTx(WithTxMode(ctx, "EAGER"), nil); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+
+	want := []string{
+		"primary: BEGIN IMMEDIATE", "primary: SELECT 1", "primary: COMMIT",
+		"primary: BEGIN EXCLUSIVE", "primary: SELECT 1", "primary: COMMIT",
+		"primary: BEGIN DEFERRED", "primary: SELECT 1", "primary: COMMIT",
+		"primary: BEGIN DEFERRED", "primary: SELECT 1", "primary: COMMIT",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("got %#v, want %#v", received, want)
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	primary := newRecordingServer(t, "primary", &mu, &received)
+	primary.SetReplicationIndex(10)
+	replica := newRecordingServer(t, "replica", &mu, &received)
+	replica.SetReplicationIndex(5)
+
+	connector, err := NewConnector(primary.URL, WithReadReplica(replica.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := WithReadOnly(context.Background())
+	read := func(ctx context.Context) string {
+		var v string
+		if err := db.QueryRowContext(ctx, "SELECT v FROM t").Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if got := read(ctx); got != "replica" {
+		t.Errorf("got %s before any write, want the replica", got)
+	}
+	if _, err := db.ExecContext(context.Background(), "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(ctx); got != "primary" {
+		t.Errorf("got %s with the replica behind, want the primary", got)
+	}
+	if got := read(WithReadYourWrites(ctx, false)); got != "replica" {
+		t.Errorf("got %s without read-your-writes, want the replica", got)
+	}
+	replica.SetReplicationIndex(10)
+	if got := read(ctx); got != "replica" {
+		t.Errorf("got %s with the replica caught up, want the replica", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"replica: SELECT v FROM t",
+		"primary: INSERT INTO t VALUES (1)",
+		"replica: SELECT v FROM t",
+		"primary: SELECT v FROM t",
+		"replica: SELECT v FROM t",
+		"replica: SELECT v FROM t",
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("got %#v, want %#v", received, want)
+	}
+}
@@ -0,0 +1,65 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync/atomic"
+)
+
+// replicatedResult is implemented by the results of transports that report the
+// replication index of writes.
+type replicatedResult interface {
+	ReplicationIndex() uint64
+}
+
+type replicationIndexKey struct{}
+
+// CaptureReplicationIndex returns a context recording in *index the replication
+// index, or frame number, of the writes executed with it, on servers that
+// report it. It keeps the highest index of the writes, so a context shared by
+// several writes records the index the database must reach to reflect all of
+// them. The index is the way to know when a replica caught up with a write,
+// for read-your-writes consistency or cache invalidation.
+//
+// The sql.Result returned by database/sql cannot carry the index, which is why
+// it is recorded through the context. *index must only be read with
+// sync/atomic while writes using the context may be running.
+func CaptureReplicationIndex(ctx context.Context, index *uint64) context.Context {
+	return context.WithValue(ctx, replicationIndexKey{}, index)
+}
+
+// ReplicationIndex returns the highest replication index reported for the
+// writes executed by the connections of c, zero if the server reported none.
+func (c *Connector) ReplicationIndex() uint64 {
+	return c.replicationIndex.Load()
+}
+
+// recordReplicationIndex stores the replication index of res in the connector
+// and the index captured by ctx.
+func (c *Conn) recordReplicationIndex(ctx context.Context, res driver.Result) {
+	r, ok := res.(replicatedResult)
+	if !ok {
+		return
+	}
+	index := r.ReplicationIndex()
+	if index == 0 {
+		return
+	}
+	if index > c.replicationIndex {
+		c.replicationIndex = index
+	}
+	for {
+		seen := c.connector.replicationIndex.Load()
+		if seen >= index || c.connector.replicationIndex.CompareAndSwap(seen, index) {
+			break
+		}
+	}
+	if captured, ok := ctx.Value(replicationIndexKey{}).(*uint64); ok {
+		for {
+			seen := atomic.LoadUint64(captured)
+			if seen >= index || atomic.CompareAndSwapUint64(captured, seen, index) {
+				break
+			}
+		}
+	}
+}
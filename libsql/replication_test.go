@@ -0,0 +1,68 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestReplicationIndex(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+	connector, err := NewConnector(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	// The writes report the index set before them, an index of 0 is not
+	// reported.
+	exec := func(ctx context.Context, index uint64) {
+		t.Helper()
+		server.SetReplicationIndex(index)
+		if _, err := db.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	var first, second uint64
+	exec(CaptureReplicationIndex(ctx, &first), 7)
+	captureCtx := CaptureReplicationIndex(ctx, &second)
+	for _, index := range []uint64{12, 9, 0} {
+		exec(captureCtx, index)
+	}
+	if first != 7 || second != 12 {
+		t.Errorf("got captured indexes %d and %d, want 7 and 12", first, second)
+	}
+	if got := connector.ReplicationIndex(); got != 12 {
+		t.Errorf("got connector index %d, want 12", got)
+	}
+
+	// The index of a connection ignores the writes of the others.
+	busy, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	server.SetReplicationIndex(3)
+	if _, err := conn.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Raw(func(driverConn any) error {
+		if got := driverConn.(*Conn).ReplicationIndex(); got != 3 {
+			t.Errorf("got connection index %d, want 3", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,68 @@
+package libsql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/retry"
+)
+
+// IsRetryable reports whether an operation that failed with err may succeed if
+// it is tried again: the server could not be reached, the connection dropped,
+// the stream expired, or the server answered that it is overloaded or failing
+// (HTTP 429 and 5xx). SQL errors, rejected requests (other 4xx statuses) and
+// canceled contexts are not retryable.
+//
+// A retryable error does not mean the operation did not run. Only errors
+// wrapping driver.ErrBadConn, which database/sql already retries on another
+// connection, prove the request never reached the server; retry other
+// statements only if running them twice is harmless.
+func IsRetryable(err error) bool {
+	return retry.Is(err)
+}
+
+// ErrUnreachable is wrapped by the errors of requests that failed because no
+// connection to the server could be opened, and of the requests failed at once
+// by WithCircuitBreaker while the server is deemed unreachable. Applications
+// can check it with errors.Is to switch to an offline mode.
+var ErrUnreachable = retry.ErrUnreachable
+
+// RateLimitError is returned for requests the server answered with HTTP 429
+// Too Many Requests, once the retries of WithRateLimitRetries, if any, are
+// exhausted. The request did not run. RetryAfter is the delay the server asked
+// for, for callers throttling their own requests.
+type RateLimitError = retry.RateLimitError
+
+// WithRateLimitRetries sends requests answered with HTTP 429 again up to n
+// times, after the delay of their Retry-After header, or after a wait growing
+// from 100ms when the server does not give one. Requests whose wait would
+// outlast the deadline of their context or the request timeout fail with the
+// *RateLimitError at once. Over websockets, this applies to the handshake.
+func WithRateLimitRetries(n int) Option {
+	return option(func(c *Connector) error {
+		if n < 0 {
+			return fmt.Errorf("rate limit retries must not be negative")
+		}
+		c.cfg.RateLimitRetries = n
+		return nil
+	})
+}
+
+// WithCircuitBreaker fails requests at once with an error wrapping
+// ErrUnreachable for cooldown after failures consecutive attempts to connect to
+// the server failed, instead of letting each of them wait for its own dial to
+// fail. The first request after the cool-down is sent again, and closes the
+// breaker if it connects. The breaker is shared by the connections of the
+// connector.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return option(func(c *Connector) error {
+		if failures <= 0 {
+			return fmt.Errorf("circuit breaker failures must be positive")
+		}
+		if cooldown <= 0 {
+			return fmt.Errorf("circuit breaker cooldown must be positive")
+		}
+		c.cfg.Breaker = retry.NewBreaker(failures, cooldown)
+		return nil
+	})
+}
@@ -0,0 +1,216 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/params"
+)
+
+// timestampFormats are the layouts accepted when parsing TEXT values into
+// time.Time. They match the formats understood by mattn/go-sqlite3.
+var timestampFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func isTimeDeclType(declType string) bool {
+	switch declType {
+	case "DATE", "DATETIME", "TIMESTAMP":
+		return true
+	}
+	return false
+}
+
+// parseTime converts a TEXT or INTEGER value into time.Time. Integers are
+// seconds or milliseconds since the epoch as set by format, or guessed from
+// their number of digits for text formats. Values that cannot be parsed are
+// returned unchanged.
+func parseTime(v driver.Value, format params.TimeFormat) driver.Value {
+	switch v := v.(type) {
+	case string:
+		s := strings.TrimSuffix(v, "Z")
+		for _, format := range timestampFormats {
+			if t, err := time.ParseInLocation(format, s, time.UTC); err == nil {
+				return t
+			}
+		}
+	case int64:
+		switch format {
+		case params.TimeFormatUnix:
+			return time.Unix(v, 0).UTC()
+		case params.TimeFormatUnixMilli:
+			return time.UnixMilli(v).UTC()
+		}
+		// Values with 13 digits are interpreted as milliseconds like mattn/go-sqlite3 does.
+		if len(strconv.FormatInt(v, 10)) == 13 {
+			return time.UnixMilli(v).UTC()
+		}
+		return time.Unix(v, 0).UTC()
+	}
+	return v
+}
+
+// rows post-processes the rows returned by a transport.
+type rows struct {
+	driver.Rows
+	parseTime   bool
+	timeFormat  params.TimeFormat
+	strictTypes bool
+	// timeColumns caches which columns of the current result set hold dates.
+	timeColumns []bool
+	// affinities caches the affinities of the columns of the current result
+	// set when strictTypes is set.
+	affinities []affinity
+	closed     bool
+	// metricsQuery is the query the rows are counted for in the metrics
+	// registry, empty when metrics are disabled.
+	metricsQuery string
+	count        int64
+	// stats collects the statistics of the query when the rows are closed,
+	// nil unless the query context comes from WithStats.
+	stats *statsCollector
+	// conn is the connection the rows were queried on, which adds their
+	// statistics to its own.
+	conn *Conn
+	// connector and query describe the columns of the first result set,
+	// query is cleared once rows move to the next one.
+	connector *Connector
+	query     string
+	// nullability caches the nullability of the columns, nil until it was
+	// looked up or when it is unknown.
+	nullability       []nullability
+	nullabilityLoaded bool
+}
+
+func wrapRows(ctx context.Context, r driver.Rows, c *Conn, query string) driver.Rows {
+	parseTime, strictTypes := c.connector.parseTime, c.connector.strictTypes
+	res := &rows{Rows: r, parseTime: parseTime, timeFormat: c.connector.checker.TimeFormat, strictTypes: strictTypes, connector: c.connector, query: query, stats: statsFrom(ctx), conn: c}
+	if c.connector.metrics {
+		res.metricsQuery = query
+	}
+	c.connector.diagnostics.watchRows(res)
+	return res
+}
+
+func (r *rows) Close() error {
+	if !r.closed && r.metricsQuery != "" {
+		registry.addRows(r.metricsQuery, r.count)
+	}
+	if s, ok := r.Rows.(statsResult); ok && !r.closed {
+		stats := s.Stats()
+		r.conn.stats.Add(stats)
+		if r.stats != nil {
+			r.stats.add(stats)
+		}
+	}
+	r.closed = true
+	return r.Rows.Close()
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	r.count++
+	if r.strictTypes {
+		if r.affinities == nil {
+			r.affinities = make([]affinity, len(dest))
+			for idx := range dest {
+				r.affinities[idx] = columnAffinity(r.ColumnTypeDatabaseTypeName(idx))
+			}
+		}
+		for idx := range dest {
+			v, err := coerce(r.affinities[idx], dest[idx])
+			if err != nil {
+				return fmt.Errorf("column %d: %w", idx, err)
+			}
+			dest[idx] = v
+		}
+	}
+	if r.parseTime {
+		if r.timeColumns == nil {
+			r.timeColumns = make([]bool, len(dest))
+			for idx := range dest {
+				r.timeColumns[idx] = isTimeDeclType(r.ColumnTypeDatabaseTypeName(idx))
+			}
+		}
+		for idx := range dest {
+			if r.timeColumns[idx] {
+				dest[idx] = parseTime(dest[idx], r.timeFormat)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if t, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return t.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *rows) ColumnTypeLength(index int) (int64, bool) {
+	return columnTypeLength(r.ColumnTypeDatabaseTypeName(index))
+}
+
+func (r *rows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	return columnTypePrecisionScale(r.ColumnTypeDatabaseTypeName(index))
+}
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	return columnTypeScanType(r.ColumnTypeDatabaseTypeName(index), r.parseTime)
+}
+
+// ColumnTypeNullable reports whether a column selected from a table may be
+// NULL, from the NOT NULL constraints of the table. The nullability of
+// expressions, and of every column of queries the driver cannot map to tables,
+// is unknown.
+func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if !r.nullabilityLoaded {
+		r.nullabilityLoaded = true
+		if r.query != "" {
+			r.nullability = r.connector.columnNullability(r.query, len(r.Columns()))
+		}
+	}
+	if index >= len(r.nullability) {
+		return false, false
+	}
+	switch r.nullability[index] {
+	case nullabilityNullable:
+		return true, true
+	case nullabilityNotNull:
+		return false, true
+	}
+	return false, false
+}
+
+func (r *rows) HasNextResultSet() bool {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *rows) NextResultSet() error {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		r.timeColumns, r.affinities = nil, nil
+		r.query, r.nullability, r.nullabilityLoaded = "", nil, false
+		return n.NextResultSet()
+	}
+	return io.EOF
+}
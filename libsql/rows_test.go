@@ -0,0 +1,259 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/params"
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  driver.Value
+		format params.TimeFormat
+		want   driver.Value
+	}{
+		{name: "RFC3339", value: "2023-08-01T12:30:00Z", want: time.Date(2023, 8, 1, 12, 30, 0, 0, time.UTC)},
+		{name: "RFC3339Nano", value: "2023-08-01T12:30:00.0000005Z", want: time.Date(2023, 8, 1, 12, 30, 0, 500, time.UTC)},
+		{name: "Offset", value: "2023-08-01 12:30:00+02:00", want: time.Date(2023, 8, 1, 10, 30, 0, 0, time.UTC)},
+		{name: "SQLiteDatetime", value: "2023-08-01 12:30:00", want: time.Date(2023, 8, 1, 12, 30, 0, 0, time.UTC)},
+		{name: "Date", value: "2023-08-01", want: time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "UnixSeconds", value: int64(1690893000), want: time.Date(2023, 8, 1, 12, 30, 0, 0, time.UTC)},
+		{name: "UnixMillis", value: int64(1690893000123), want: time.Date(2023, 8, 1, 12, 30, 0, 123000000, time.UTC)},
+		{name: "Invalid", value: "yesterday", want: "yesterday"},
+		{name: "Null", value: nil, want: nil},
+		{name: "Float", value: 2460158.0, want: 2460158.0},
+		{name: "SQLiteFraction", value: "2023-08-01 12:30:00.25", want: time.Date(2023, 8, 1, 12, 30, 0, 250000000, time.UTC)},
+		{name: "UnixFormat", value: int64(1690893000123), format: params.TimeFormatUnix, want: time.Unix(1690893000123, 0).UTC()},
+		{name: "UnixMilliFormat", value: int64(1690893000), format: params.TimeFormatUnixMilli, want: time.UnixMilli(1690893000).UTC()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTime(tt.value, tt.format)
+			gotTime, gotIsTime := got.(time.Time)
+			wantTime, wantIsTime := tt.want.(time.Time)
+			if gotIsTime && wantIsTime {
+				if !gotTime.Equal(wantTime) {
+					t.Errorf("got %v, want %v", gotTime, wantTime)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeRows struct {
+	columns   []string
+	declTypes []string
+	values    [][]driver.Value
+	idx       int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) ColumnTypeDatabaseTypeName(index int) string { return r.declTypes[index] }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.idx == len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.idx])
+	r.idx++
+	return nil
+}
+
+func TestRowsParseTime(t *testing.T) {
+	fake := &fakeRows{
+		columns:   []string{"a", "b"},
+		declTypes: []string{"TEXT", "DATETIME"},
+		values:    [][]driver.Value{{"2023-08-01", "2023-08-01"}},
+	}
+	r := wrapRows(context.Background(), fake, &Conn{connector: &Connector{parseTime: true}}, "SELECT a, b FROM t")
+	dest := make([]driver.Value, 2)
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if dest[0] != "2023-08-01" {
+		t.Errorf("got %#v for TEXT column, want it unchanged", dest[0])
+	}
+	if _, ok := dest[1].(time.Time); !ok {
+		t.Errorf("got %#v for DATETIME column, want time.Time", dest[1])
+	}
+	if err := r.Next(dest); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+}
+
+func TestMultipleResultSets(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		if query == "SELECT a FROM t" {
+			return &libsqltest.Result{Cols: []string{"a"}, DeclTypes: []string{"INTEGER"}, Rows: [][]driver.Value{{int64(1)}, {int64(2)}}}, nil
+		}
+		return &libsqltest.Result{Cols: []string{"b", "c"}, Rows: [][]driver.Value{{"x", "y"}}}, nil
+	})
+
+	db, err := sql.Open("libsql", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(context.Background(), "SELECT a FROM t; SELECT b, c FROM u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var sets [][]string
+	for {
+		columns, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		set := []string{strings.Join(columns, ",")}
+		for rows.Next() {
+			values := make([]string, len(columns))
+			dest := make([]any, len(columns))
+			for idx := range values {
+				dest[idx] = &values[idx]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatal(err)
+			}
+			set = append(set, strings.Join(values, ","))
+		}
+		sets = append(sets, set)
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"a", "1", "2"}, {"b,c", "x,y"}}; !reflect.DeepEqual(sets, want) {
+		t.Errorf("got %q, want %q", sets, want)
+	}
+}
+
+func TestScanRawBytes(t *testing.T) {
+	blobs := []string{"first blob", "2nd", "", "the last and longest blob"}
+	var rows [][]driver.Value
+	for _, blob := range blobs {
+		rows = append(rows, []driver.Value{[]byte(blob), blob})
+	}
+	server := libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		return &libsqltest.Result{Cols: []string{"b", "t"}, Rows: rows}, nil
+	})
+
+	for _, query := range []string{"", "?streamRows=true"} {
+		t.Run("streamRows="+strconv.FormatBool(query != ""), func(t *testing.T) {
+			db, err := sql.Open("libsql", server.URL+query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			rows, err := db.QueryContext(context.Background(), "SELECT b, t FROM t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var blob, text sql.RawBytes
+			var kept []byte
+			var got []string
+			for rows.Next() {
+				if err := rows.Scan(&blob, &text); err != nil {
+					t.Fatal(err)
+				}
+				if string(blob) != string(text) {
+					t.Errorf("got blob %q, want %q", blob, text)
+				}
+				if blob == nil {
+					t.Error("got a nil blob for an empty one")
+				}
+				got = append(got, string(blob))
+				if len(blob) > 0 && kept == nil {
+					kept = blob
+				}
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, blobs) {
+				t.Errorf("got %q, want %q", got, blobs)
+			}
+			// The second blob was decoded over the first without allocating,
+			// only the last one outgrew the buffer.
+			if want := "2ndst blob"; string(kept) != want {
+				t.Errorf("got first blob %q after the next rows, want %q", kept, want)
+			}
+		})
+	}
+}
+
+// BenchmarkQueryScan measures a query of 100 rows through database/sql, from
+// the request to the values scanned into Go variables.
+func BenchmarkQueryScan(b *testing.B) {
+	row := `[{"type":"integer","value":"12345"},{"type":"text","value":"some text value"},{"type":"float","value":1.5}]`
+	response := []byte(`{"baton":null,"base_url":null,"results":[{"type":"ok","response":{"type":"execute","result":` +
+		`{"cols":[{"name":"a","decltype":"INTEGER"},{"name":"b","decltype":"TEXT"},{"name":"c","decltype":"REAL"}],` +
+		`"rows":[` + strings.Repeat(row+",", 99) + row + `],"affected_row_count":0,"last_insert_rowid":null}}}]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3" {
+			return
+		}
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			b.Error(err)
+		}
+		if _, err := w.Write(response); err != nil {
+			b.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	for _, query := range []string{"", "?strictTypes=true"} {
+		name := "default"
+		if query != "" {
+			name = "strictTypes"
+		}
+		b.Run(name, func(b *testing.B) {
+			db, err := sql.Open("libsql", server.URL+query)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+			var a int64
+			var s string
+			var f float64
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rows, err := db.QueryContext(context.Background(), "SELECT a, b, c FROM t")
+				if err != nil {
+					b.Fatal(err)
+				}
+				for rows.Next() {
+					if err := rows.Scan(&a, &s, &f); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := rows.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package libsql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// SchemaObject is a table, index, view or trigger of a database schema.
+type SchemaObject struct {
+	Type string
+	Name string
+	SQL  string
+}
+
+type SchemaDifferenceKind int
+
+const (
+	// SchemaMissingLocally is an object of the remote database missing from
+	// the local one.
+	SchemaMissingLocally SchemaDifferenceKind = iota
+	// SchemaMissingRemotely is an object of the local database missing from
+	// the remote one.
+	SchemaMissingRemotely
+	// SchemaChanged is an object whose definition differs.
+	SchemaChanged
+)
+
+func (k SchemaDifferenceKind) String() string {
+	switch k {
+	case SchemaMissingLocally:
+		return "missing locally"
+	case SchemaMissingRemotely:
+		return "missing remotely"
+	case SchemaChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("SchemaDifferenceKind(%d)", int(k))
+}
+
+type SchemaDifference struct {
+	Kind SchemaDifferenceKind
+	Type string
+	Name string
+	// LocalSQL and RemoteSQL are the definitions of the object, empty on the
+	// side it is missing from.
+	LocalSQL  string
+	RemoteSQL string
+}
+
+type SchemaReport struct {
+	LocalHash   string
+	RemoteHash  string
+	Differences []SchemaDifference
+}
+
+// Drifted reports whether the local and remote schemas differ.
+func (r *SchemaReport) Drifted() bool {
+	return r.LocalHash != r.RemoteHash
+}
+
+// schemaQuery lists user objects, internal sqlite_ objects are not part of the
+// schema compared.
+const schemaQuery = "SELECT type, name, COALESCE(sql, '') FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' ORDER BY type, name"
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func readSchema(ctx context.Context, db querier) ([]SchemaObject, error) {
+	rows, err := db.QueryContext(ctx, schemaQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+	var objects []SchemaObject
+	for rows.Next() {
+		var o SchemaObject
+		if err := rows.Scan(&o.Type, &o.Name, &o.SQL); err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		objects = append(objects, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	return objects, nil
+}
+
+// schemaHash returns the hex encoded SHA-256 of the objects, independent of
+// their order.
+func schemaHash(objects []SchemaObject) string {
+	sorted := make([]SchemaObject, len(objects))
+	copy(sorted, objects)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Type != sorted[j].Type {
+			return sorted[i].Type < sorted[j].Type
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	hash := sha256.New()
+	for _, o := range sorted {
+		fmt.Fprintf(hash, "%s\x00%s\x00%s\x00", o.Type, o.Name, o.SQL)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func diffSchemas(local, remote []SchemaObject) []SchemaDifference {
+	type key struct{ typ, name string }
+	remoteByKey := map[key]SchemaObject{}
+	for _, o := range remote {
+		remoteByKey[key{o.Type, o.Name}] = o
+	}
+	var diffs []SchemaDifference
+	for _, l := range local {
+		k := key{l.Type, l.Name}
+		r, ok := remoteByKey[k]
+		delete(remoteByKey, k)
+		switch {
+		case !ok:
+			diffs = append(diffs, SchemaDifference{Kind: SchemaMissingRemotely, Type: l.Type, Name: l.Name, LocalSQL: l.SQL})
+		case l.SQL != r.SQL:
+			diffs = append(diffs, SchemaDifference{Kind: SchemaChanged, Type: l.Type, Name: l.Name, LocalSQL: l.SQL, RemoteSQL: r.SQL})
+		}
+	}
+	for _, r := range remoteByKey {
+		diffs = append(diffs, SchemaDifference{Kind: SchemaMissingLocally, Type: r.Type, Name: r.Name, RemoteSQL: r.SQL})
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Type != diffs[j].Type {
+			return diffs[i].Type < diffs[j].Type
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs
+}
+
+// VerifySchema compares the schema of the local database file at path, for
+// example a copy made by Snapshot, with the schema of the remote database. The
+// report lists every table, index, view and trigger that differs, so a copy
+// left behind by failed updates can be detected and recreated. A sqlite driver
+// must be imported, like for file: URLs.
+func (c *Connector) VerifySchema(ctx context.Context, path string) (*SchemaReport, error) {
+	driverName, err := sqliteDriverName()
+	if err != nil {
+		return nil, err
+	}
+	if c.url == nil {
+		return nil, fmt.Errorf("%s is not a remote database", c.dbUrl)
+	}
+	localDb, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, err
+	}
+	defer localDb.Close()
+	local, err := readSchema(ctx, localDb)
+	if err != nil {
+		return nil, err
+	}
+	remoteDb := sql.OpenDB(c)
+	defer remoteDb.Close()
+	remote, err := readSchema(ctx, remoteDb)
+	if err != nil {
+		return nil, err
+	}
+	return &SchemaReport{
+		LocalHash:   schemaHash(local),
+		RemoteHash:  schemaHash(remote),
+		Differences: diffSchemas(local, remote),
+	}, nil
+}
+
+// SchemaVersionInfo identifies a version of a database schema.
+type SchemaVersionInfo struct {
+	// Version is PRAGMA schema_version, incremented by SQLite on every schema
+	// change.
+	Version int64
+	// Hash is a stable hash of the schema objects. Unlike Version it is the
+	// same for databases with identical schemas, for example after the
+	// database was restored from a dump.
+	Hash string
+}
+
+// SchemaVersion reads PRAGMA schema_version and hashes the schema in a single
+// transaction, so both describe the same schema. The result can be used as a
+// cache key to invalidate prepared statements or cached query results when
+// the schema changes.
+func SchemaVersion(ctx context.Context, db *sql.DB) (SchemaVersionInfo, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return SchemaVersionInfo{}, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer tx.Rollback()
+	var info SchemaVersionInfo
+	if err := tx.QueryRowContext(ctx, "PRAGMA schema_version").Scan(&info.Version); err != nil {
+		return SchemaVersionInfo{}, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	objects, err := readSchema(ctx, tx)
+	if err != nil {
+		return SchemaVersionInfo{}, err
+	}
+	info.Hash = schemaHash(objects)
+	if err := tx.Commit(); err != nil {
+		return SchemaVersionInfo{}, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return info, nil
+}
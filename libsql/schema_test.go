@@ -0,0 +1,96 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newSchemaServer returns a database recording the statements it receives,
+// answering PRAGMA schema_version with 7 and the schema query with objects.
+func newSchemaServer(t *testing.T, objects [][]driver.Value) (*sql.DB, func() []string) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		switch stmt {
+		case "PRAGMA schema_version":
+			return &libsqltest.Result{Cols: []string{"schema_version"}, Rows: [][]driver.Value{{int64(7)}}}, nil
+		case schemaQuery:
+			return &libsqltest.Result{Cols: []string{"type", "name", "sql"}, Rows: objects}, nil
+		}
+		return nil, nil
+	})
+	db, _ := libsqltest.Open(t, f)
+	return db, executed
+}
+
+func TestReadSchema(t *testing.T) {
+	db, executed := newSchemaServer(t, [][]driver.Value{
+		{"index", "i", "CREATE INDEX i ON t (a)"},
+		{"table", "t", "CREATE TABLE t (a)"},
+	})
+	objects, err := readSchema(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SchemaObject{{"index", "i", "CREATE INDEX i ON t (a)"}, {"table", "t", "CREATE TABLE t (a)"}}
+	if !reflect.DeepEqual(objects, want) {
+		t.Errorf("got %#v, want %#v", objects, want)
+	}
+	if got := executed(); !reflect.DeepEqual(got, []string{schemaQuery}) {
+		t.Errorf("got %q, want the schema query", got)
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	local := []SchemaObject{
+		{"table", "a", "CREATE TABLE a (x)"},
+		{"table", "b", "CREATE TABLE b (x)"},
+		{"index", "old", "CREATE INDEX old ON a (x)"},
+	}
+	remote := []SchemaObject{
+		{"table", "b", "CREATE TABLE b (x, y)"},
+		{"table", "a", "CREATE TABLE a (x)"},
+		{"view", "v", "CREATE VIEW v AS SELECT 1"},
+	}
+	want := []SchemaDifference{
+		{Kind: SchemaMissingRemotely, Type: "index", Name: "old", LocalSQL: "CREATE INDEX old ON a (x)"},
+		{Kind: SchemaChanged, Type: "table", Name: "b", LocalSQL: "CREATE TABLE b (x)", RemoteSQL: "CREATE TABLE b (x, y)"},
+		{Kind: SchemaMissingLocally, Type: "view", Name: "v", RemoteSQL: "CREATE VIEW v AS SELECT 1"},
+	}
+	if got := diffSchemas(local, remote); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if diffs := diffSchemas(local, local); len(diffs) != 0 {
+		t.Errorf("got %#v for identical schemas", diffs)
+	}
+}
+
+func TestSchemaHash(t *testing.T) {
+	a := []SchemaObject{{"table", "a", "CREATE TABLE a (x)"}, {"table", "b", "CREATE TABLE b (x)"}}
+	b := []SchemaObject{a[1], a[0]}
+	if schemaHash(a) != schemaHash(b) {
+		t.Error("hash should not depend on the order of objects")
+	}
+	c := []SchemaObject{a[0], {"table", "b", "CREATE TABLE b (y)"}}
+	if schemaHash(a) == schemaHash(c) {
+		t.Error("hash should change with a definition")
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	db, executed := newSchemaServer(t, [][]driver.Value{{"table", "t", "CREATE TABLE t (a)"}})
+	info, err := SchemaVersion(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SchemaVersionInfo{Version: 7, Hash: schemaHash([]SchemaObject{{"table", "t", "CREATE TABLE t (a)"}})}
+	if info != want {
+		t.Errorf("got %#v, want %#v", info, want)
+	}
+	if got, want := executed(), []string{"BEGIN", "PRAGMA schema_version", schemaQuery, "COMMIT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
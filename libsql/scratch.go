@@ -0,0 +1,81 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+)
+
+// Scratch is an in-memory database attached to a single connection, for
+// workflows that would use temporary tables with a local database. Every
+// statement run through a Scratch uses its connection, other connections of
+// the pool do not see the scratch database. A Scratch is not safe for
+// concurrent use.
+type Scratch struct {
+	conn *sql.Conn
+	name string
+}
+
+var schemaNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// OpenScratch reserves a connection of db and attaches an empty in-memory
+// database to it as schema name. The connection is pinned, so if the server
+// drops the stream the scratch database lives in, statements fail with
+// driver.ErrBadConn instead of running on a new stream without it. Close must
+// be called to detach the database and give the connection back to db.
+func OpenScratch(ctx context.Context, db *sql.DB, name string) (*Scratch, error) {
+	if !schemaNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid scratch database name %#v", name)
+	}
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.ExecContext(ctx, fmt.Sprintf("ATTACH DATABASE ':memory:' AS %s", name)); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to attach scratch database: %w", err)
+	}
+	return &Scratch{conn: c, name: name}, nil
+}
+
+// Name returns the schema name the scratch database is attached as.
+func (s *Scratch) Name() string {
+	return s.name
+}
+
+// Conn returns the connection the scratch database is attached to.
+func (s *Scratch) Conn() *sql.Conn {
+	return s.conn
+}
+
+func (s *Scratch) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return s.conn.ExecContext(ctx, query, args...)
+}
+
+func (s *Scratch) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.conn.QueryContext(ctx, query, args...)
+}
+
+func (s *Scratch) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.conn.QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx starts a transaction on the connection of the scratch database.
+func (s *Scratch) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return s.conn.BeginTx(ctx, opts)
+}
+
+// Close detaches the scratch database and releases the connection.
+func (s *Scratch) Close() error {
+	_, err := s.conn.ExecContext(context.Background(), fmt.Sprintf("DETACH DATABASE %s", s.name))
+	if err != nil {
+		// The connection still holds the scratch database, it must not be
+		// reused.
+		s.conn.Raw(func(any) error { return driver.ErrBadConn })
+		s.conn.Close()
+		return fmt.Errorf("failed to detach scratch database: %w", err)
+	}
+	return s.conn.Close()
+}
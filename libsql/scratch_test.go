@@ -0,0 +1,83 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+// statelessConn is a transport connection that records the statements it runs.
+type statelessConn struct {
+	driver.Conn
+	queries []string
+}
+
+func (c *statelessConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.queries = append(c.queries, query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *statelessConn) Close() error {
+	return nil
+}
+
+// pinnableConn is a transport connection that can keep its state.
+type pinnableConn struct {
+	statelessConn
+	pins []bool
+}
+
+func (c *pinnableConn) PinState(pinned bool) {
+	c.pins = append(c.pins, pinned)
+}
+
+type transportConnector struct {
+	conn driver.Conn
+}
+
+func (c transportConnector) Connect(context.Context) (driver.Conn, error) {
+	return newConn(c.conn, &Connector{}), nil
+}
+
+func (c transportConnector) Driver() driver.Driver {
+	return libsqlDriver
+}
+
+func TestScratch(t *testing.T) {
+	transport := &pinnableConn{}
+	db := sql.OpenDB(transportConnector{transport})
+	defer db.Close()
+
+	ctx := context.Background()
+	scratch, err := OpenScratch(ctx, db, "scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scratch.ExecContext(ctx, "CREATE TABLE scratch.ids (id)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := scratch.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ATTACH DATABASE ':memory:' AS scratch", "CREATE TABLE scratch.ids (id)", "DETACH DATABASE scratch"}
+	if !reflect.DeepEqual(transport.queries, want) {
+		t.Errorf("got queries %#v, want %#v", transport.queries, want)
+	}
+	if !reflect.DeepEqual(transport.pins, []bool{true, false}) {
+		t.Errorf("got pins %v, want the connection pinned until Close", transport.pins)
+	}
+}
+
+func TestScratchErrors(t *testing.T) {
+	ctx := context.Background()
+	db := sql.OpenDB(transportConnector{&statelessConn{}})
+	defer db.Close()
+	if _, err := OpenScratch(ctx, db, "scratch"); err == nil {
+		t.Error("expected error for a transport that does not keep state")
+	}
+	if _, err := OpenScratch(ctx, db, "main; DROP TABLE t"); err == nil {
+		t.Error("expected error for an invalid name")
+	}
+}
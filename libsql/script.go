@@ -0,0 +1,245 @@
+package libsql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// ScriptProgress is reported by ExecScript after every statement.
+type ScriptProgress struct {
+	// Statements is the number of statements executed so far.
+	Statements int
+	// Bytes is the number of bytes of the script read so far.
+	Bytes int64
+}
+
+type ScriptOptions struct {
+	// Progress, if set, is called after every statement executed.
+	Progress func(ScriptProgress)
+}
+
+// ExecScript reads a SQL script, like a schema dump or a seed file, from r and
+// executes its statements in order on a single connection, so BEGIN and COMMIT
+// statements in the script work as expected. The script is split into
+// statements as it is read, following the rules of sqlite3_complete: string
+// literals, quoted identifiers, comments and the bodies of CREATE TRIGGER
+// statements may contain semicolons. Execution stops at the first failing
+// statement. It returns the number of statements executed.
+func ExecScript(ctx context.Context, db *sql.DB, r io.Reader, opts *ScriptOptions) (int, error) {
+	if opts == nil {
+		opts = &ScriptOptions{}
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	scanner := newScriptScanner(r)
+	executed := 0
+	for {
+		stmt, line, err := scanner.next()
+		if err == io.EOF {
+			return executed, nil
+		}
+		if err != nil {
+			return executed, fmt.Errorf("failed to read script: %w", err)
+		}
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return executed, fmt.Errorf("statement %d at line %d failed: %w", executed+1, line, err)
+		}
+		executed++
+		if opts.Progress != nil {
+			opts.Progress(ScriptProgress{Statements: executed, Bytes: scanner.bytes})
+		}
+	}
+}
+
+// Tokens and states of the statement completeness automaton of SQLite, see
+// sqlite3_complete in complete.c.
+const (
+	tkSemi = iota
+	tkWS
+	tkOther
+	tkExplain
+	tkCreate
+	tkTemp
+	tkTrigger
+	tkEnd
+)
+
+const (
+	completeStart = 1
+)
+
+var completeTransitions = [8][8]int{
+	//             SEMI WS OTHER EXPLAIN CREATE TEMP TRIGGER END
+	/* INVALID */ {1, 0, 2, 3, 4, 2, 2, 2},
+	/* START   */ {1, 1, 2, 3, 4, 2, 2, 2},
+	/* NORMAL  */ {1, 2, 2, 2, 2, 2, 2, 2},
+	/* EXPLAIN */ {1, 3, 3, 2, 4, 2, 2, 2},
+	/* CREATE  */ {1, 4, 2, 2, 2, 4, 5, 2},
+	/* TRIGGER */ {6, 5, 5, 5, 5, 5, 5, 5},
+	/* SEMI    */ {6, 6, 5, 5, 5, 5, 5, 7},
+	/* END     */ {1, 7, 5, 5, 5, 5, 5, 5},
+}
+
+var scriptKeywords = map[string]int{
+	"CREATE":    tkCreate,
+	"TRIGGER":   tkTrigger,
+	"TEMP":      tkTemp,
+	"TEMPORARY": tkTemp,
+	"END":       tkEnd,
+	"EXPLAIN":   tkExplain,
+}
+
+// scriptScanner splits a script into statements while reading it, holding only
+// the current statement in memory.
+type scriptScanner struct {
+	r     *bufio.Reader
+	stmt  strings.Builder
+	line  int
+	bytes int64
+}
+
+func newScriptScanner(r io.Reader) *scriptScanner {
+	return &scriptScanner{r: bufio.NewReader(r), line: 1}
+}
+
+func (s *scriptScanner) read() (rune, error) {
+	c, size, err := s.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	s.bytes += int64(size)
+	if c == '\n' {
+		s.line++
+	}
+	s.stmt.WriteRune(c)
+	return c, nil
+}
+
+// peek reports whether the next rune is c, without consuming it.
+func (s *scriptScanner) peek(c rune) bool {
+	next, _, err := s.r.ReadRune()
+	if err != nil {
+		return false
+	}
+	s.r.UnreadRune()
+	return next == c
+}
+
+// readUntil consumes runes up to and including end, or until EOF.
+func (s *scriptScanner) readUntil(end string) error {
+	matched := 0
+	ends := []rune(end)
+	for matched < len(ends) {
+		c, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == ends[matched]:
+			matched++
+		case c == ends[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+	return nil
+}
+
+func isIdentifierRune(c rune) bool {
+	return c == '_' || c == '$' || c > unicode.MaxASCII || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// token consumes the next token and returns its type.
+func (s *scriptScanner) token() (int, error) {
+	c, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case c == ';':
+		return tkSemi, nil
+	case unicode.IsSpace(c):
+		return tkWS, nil
+	case c == '-' && s.peek('-'):
+		return tkWS, s.readUntil("\n")
+	case c == '/' && s.peek('*'):
+		s.read()
+		return tkWS, s.readUntil("*/")
+	case c == '[':
+		return tkOther, s.readUntil("]")
+	case c == '\'' || c == '"' || c == '`':
+		// A doubled quote closes and reopens the literal, which scans the
+		// same.
+		return tkOther, s.readUntil(string(c))
+	case isIdentifierRune(c):
+		word := []rune{c}
+		for {
+			next, _, err := s.r.ReadRune()
+			if err != nil {
+				break
+			}
+			s.r.UnreadRune()
+			if !isIdentifierRune(next) {
+				break
+			}
+			s.read()
+			word = append(word, next)
+		}
+		if tk, ok := scriptKeywords[strings.ToUpper(string(word))]; ok {
+			return tk, nil
+		}
+		return tkOther, nil
+	}
+	return tkOther, nil
+}
+
+// next returns the next statement of the script and the line it starts at,
+// or io.EOF at the end of the script.
+func (s *scriptScanner) next() (string, int, error) {
+	s.stmt.Reset()
+	state := completeStart
+	// start is the offset in stmt of the first token that is not blank, -1
+	// until there is one. Leading comments are dropped.
+	start := -1
+	line := 0
+	statement := func() string {
+		return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s.stmt.String()[start:]), ";"))
+	}
+	for {
+		offset, tokenLine := s.stmt.Len(), s.line
+		tk, err := s.token()
+		if err == io.EOF {
+			if start >= 0 {
+				return statement(), line, nil
+			}
+			return "", 0, io.EOF
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		if start < 0 && tk != tkWS && tk != tkSemi {
+			start, line = offset, tokenLine
+		}
+		state = completeTransitions[state][tk]
+		if tk == tkSemi && state == completeStart {
+			if start >= 0 {
+				return statement(), line, nil
+			}
+			// Empty statements and comments between statements are dropped.
+			s.stmt.Reset()
+		}
+	}
+}
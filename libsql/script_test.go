@@ -0,0 +1,81 @@
+package libsql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+const testScript = `-- schema
+CREATE TABLE t (a TEXT); ;
+INSERT INTO t VALUES ('a;b'), ("c;d"), ([e;f]); /* a comment; with a semicolon */
+CREATE TEMP TRIGGER tr AFTER INSERT ON t BEGIN
+  DELETE FROM u WHERE x = 'END;';
+  INSERT INTO u VALUES (1);
+END;
+SELECT 1 -- no final semicolon`
+
+func TestScriptScanner(t *testing.T) {
+	// One byte reads check that statements are split the same whatever the
+	// size of the reads.
+	scanner := newScriptScanner(iotest.OneByteReader(strings.NewReader(testScript)))
+	var stmts []string
+	var lines []int
+	for {
+		stmt, line, err := scanner.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmts = append(stmts, stmt)
+		lines = append(lines, line)
+	}
+	want := []string{
+		"CREATE TABLE t (a TEXT)",
+		`INSERT INTO t VALUES ('a;b'), ("c;d"), ([e;f])`,
+		"CREATE TEMP TRIGGER tr AFTER INSERT ON t BEGIN\n  DELETE FROM u WHERE x = 'END;';\n  INSERT INTO u VALUES (1);\nEND",
+		"SELECT 1 -- no final semicolon",
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Errorf("got statements %#v, want %#v", stmts, want)
+	}
+	if !reflect.DeepEqual(lines, []int{2, 3, 4, 8}) {
+		t.Errorf("got lines %v, want [2 3 4 8]", lines)
+	}
+}
+
+func TestExecScript(t *testing.T) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		if strings.HasPrefix(stmt, "INSERT") {
+			return nil, errors.New("no such table")
+		}
+		return nil, nil
+	})
+	db, _ := libsqltest.Open(t, f)
+
+	var progress []ScriptProgress
+	script := "CREATE TABLE t (a);\nINSERT INTO t VALUES (1);\nINSERT INTO t VALUES (2);"
+	n, err := ExecScript(context.Background(), db, strings.NewReader(script), &ScriptOptions{
+		Progress: func(p ScriptProgress) { progress = append(progress, p) },
+	})
+	if err == nil || !strings.Contains(err.Error(), "statement 2 at line 2") {
+		t.Errorf("got %v, want error of the second statement", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d statements executed, want 1", n)
+	}
+	if len(progress) != 1 || progress[0].Statements != 1 || progress[0].Bytes != int64(len("CREATE TABLE t (a);")) {
+		t.Errorf("got progress %#v", progress)
+	}
+	if want := []string{"CREATE TABLE t (a)", "INSERT INTO t VALUES (1)"}; !reflect.DeepEqual(executed(), want) {
+		t.Errorf("got %q, want %q", executed(), want)
+	}
+}
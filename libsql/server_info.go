@@ -0,0 +1,110 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/hranaV2"
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
+
+// ServerInfo describes the server a connector talks to.
+type ServerInfo struct {
+	// Version is the version reported by the /version endpoint of sqld, empty
+	// if the server does not have one.
+	Version string
+	// HranaVersions are the versions of Hrana over HTTP supported by the
+	// server, newest first. It is empty for servers that only support the
+	// legacy HTTP API.
+	HranaVersions []int
+	// SQLiteVersion is the version of the SQLite library of the server.
+	SQLiteVersion string
+	// CompileOptions are the options the SQLite library of the server was
+	// built with, as listed by PRAGMA compile_options, for example
+	// "ENABLE_FTS5" or "MAX_VARIABLE_NUMBER=250000".
+	CompileOptions []string
+}
+
+// HasCompileOption reports whether the SQLite library of the server was built
+// with option, given without its SQLITE_ prefix and without value.
+func (i *ServerInfo) HasCompileOption(option string) bool {
+	option = strings.TrimPrefix(strings.ToUpper(option), "SQLITE_")
+	for _, o := range i.CompileOptions {
+		if name, _, _ := strings.Cut(o, "="); name == option {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerInfo queries the version of the server, the protocols it supports and
+// the build of its SQLite library, so applications can detect features at
+// runtime.
+func (c *Connector) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	u, err := c.httpUrl()
+	if err != nil {
+		return nil, err
+	}
+	baseUrl := strings.TrimSuffix(u.String(), "/")
+	info := &ServerInfo{HranaVersions: hranaV2.SupportedVersions(ctx, baseUrl, c.token, &c.cfg)}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if info.Version, err = c.serverVersion(ctx, baseUrl); err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(c)
+	defer db.Close()
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&info.SQLiteVersion); err != nil {
+		return nil, fmt.Errorf("failed to read SQLite version: %w", err)
+	}
+	rows, err := db.QueryContext(ctx, "PRAGMA compile_options")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compile options: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var option string
+		if err := rows.Scan(&option); err != nil {
+			return nil, fmt.Errorf("failed to read compile options: %w", err)
+		}
+		info.CompileOptions = append(info.CompileOptions, option)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read compile options: %w", err)
+	}
+	return info, nil
+}
+
+// serverVersion returns the body of the /version endpoint, empty if the server
+// does not serve it.
+func (c *Connector) serverVersion(ctx context.Context, baseUrl string) (string, error) {
+	resp, err := c.token.Do(ctx, c.cfg.Client(http.DefaultClient), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", baseUrl+"/version", nil)
+		if err != nil {
+			return nil, err
+		}
+		c.cfg.SetHeaders(req)
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read server version: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read server version: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to read server version: server returned %s: %w", resp.Status, shared.NewHTTPError(resp.StatusCode, resp.Header, body, "", strings.TrimSpace(string(body)), nil))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
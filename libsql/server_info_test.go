@@ -0,0 +1,68 @@
+package libsql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestServerInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			fmt.Fprintln(w, "sqld 0.24.1")
+			return
+		case "/v2":
+			return
+		case "/v2/pipeline":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Requests []struct {
+				Stmt struct {
+					Sql string `json:"sql"`
+				} `json:"stmt"`
+			} `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		rows := map[string]string{
+			"SELECT sqlite_version()": `[[{"type":"text","value":"3.44.0"}]]`,
+			"PRAGMA compile_options":  `[[{"type":"text","value":"ENABLE_FTS5"}],[{"type":"text","value":"MAX_VARIABLE_NUMBER=250000"}]]`,
+		}[req.Requests[0].Stmt.Sql]
+		_, err := fmt.Fprintf(w, `{"baton":"b","base_url":null,"results":[{"type":"ok","response":{"type":"execute","result":`+
+			`{"cols":[{"name":"v"}],"rows":%s,"affected_row_count":0,"last_insert_rowid":null}}}]}`, rows)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	connector, err := NewConnector(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := connector.ServerInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ServerInfo{
+		Version:        "sqld 0.24.1",
+		HranaVersions:  []int{2},
+		SQLiteVersion:  "3.44.0",
+		CompileOptions: []string{"ENABLE_FTS5", "MAX_VARIABLE_NUMBER=250000"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("got %#v, want %#v", info, want)
+	}
+	if !info.HasCompileOption("SQLITE_MAX_VARIABLE_NUMBER") || info.HasCompileOption("ENABLE_JSON1") {
+		t.Error("HasCompileOption does not match the compile options")
+	}
+}
@@ -0,0 +1,30 @@
+package libsql
+
+import (
+	"strings"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// countStatements returns the number of statements received by server that
+// contain substr.
+func countStatements(server *libsqltest.Server, substr string) int {
+	n := 0
+	for _, stmt := range server.Statements() {
+		if strings.Contains(stmt, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+// countRequests returns the number of requests received by server for path.
+func countRequests(server *libsqltest.Server, path string) int {
+	n := 0
+	for _, r := range server.Requests() {
+		if r.URL.Path == path {
+			n++
+		}
+	}
+	return n
+}
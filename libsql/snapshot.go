@@ -0,0 +1,177 @@
+package libsql
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
+
+type SnapshotResult struct {
+	// Bytes is the size of the SQL dump the snapshot was created from.
+	Bytes int64
+	// SHA256 is the hex encoded checksum of the SQL dump.
+	SHA256 string
+	// Verified reports whether the server sent a sha-256 Content-Digest
+	// header the checksum matched. sqld does not send one itself, a proxy in
+	// front of it may.
+	Verified bool
+}
+
+type SnapshotOptions struct {
+	// Progress, if set, is called with the number of bytes downloaded so far.
+	Progress func(downloaded int64)
+}
+
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	progress func(int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if n > 0 && p.progress != nil {
+		p.progress(p.read)
+	}
+	return n, err
+}
+
+// Snapshot downloads a consistent SQL dump of the remote database and restores
+// it into a new SQLite database file at path. The dump is streamed to a
+// temporary file and executed statement by statement, like ExecScript, so it
+// is never held in memory. When the server sends a sha-256 Content-Digest
+// header, the dump is checked against it before being restored. The database
+// is written next to path and only renamed into place once it passed PRAGMA
+// integrity_check, so an existing file is never left half written. A sqlite
+// driver must be imported, like for file: URLs.
+func (c *Connector) Snapshot(ctx context.Context, path string, opts *SnapshotOptions) (*SnapshotResult, error) {
+	if opts == nil {
+		opts = &SnapshotOptions{}
+	}
+	driverName, err := sqliteDriverName()
+	if err != nil {
+		return nil, err
+	}
+
+	dump, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.sql")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(dump.Name())
+	defer dump.Close()
+	result, err := c.downloadDump(ctx, dump, opts.Progress)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dump.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	tmpPath := path + ".snapshot"
+	if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := restoreDump(ctx, driverName, tmpPath, dump); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return result, nil
+}
+
+// downloadDump writes the SQL dump served by sqld to w.
+func (c *Connector) downloadDump(ctx context.Context, w io.Writer, progress func(int64)) (*SnapshotResult, error) {
+	u, err := c.httpUrl()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.token.Do(ctx, c.cfg.Client(http.DefaultClient), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(u.String(), "/")+"/dump", nil)
+		if err != nil {
+			return nil, err
+		}
+		c.cfg.SetHeaders(req)
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download dump: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to download dump: server returned %s: %w", resp.Status, shared.NewHTTPError(resp.StatusCode, resp.Header, body, "", strings.TrimSpace(string(body)), nil))
+	}
+
+	hash := sha256.New()
+	body := &progressReader{r: resp.Body, progress: progress}
+	if _, err := io.Copy(io.MultiWriter(w, hash), body); err != nil {
+		return nil, fmt.Errorf("failed to download dump: %w", err)
+	}
+	if resp.ContentLength >= 0 && body.read != resp.ContentLength {
+		return nil, fmt.Errorf("failed to download dump: got %d bytes, expected %d", body.read, resp.ContentLength)
+	}
+	sum := hash.Sum(nil)
+	result := &SnapshotResult{Bytes: body.read, SHA256: hex.EncodeToString(sum)}
+	if want, ok := contentDigest(resp); ok {
+		if !bytes.Equal(sum, want) {
+			return nil, fmt.Errorf("failed to download dump: checksum %s does not match the Content-Digest %s", result.SHA256, hex.EncodeToString(want))
+		}
+		result.Verified = true
+	}
+	return result, nil
+}
+
+// contentDigest returns the sha-256 digest of the Content-Digest header of
+// resp, see RFC 9530, unless the body was transparently decompressed.
+func contentDigest(resp *http.Response) ([]byte, bool) {
+	if resp.Uncompressed {
+		return nil, false
+	}
+	for _, field := range strings.Split(resp.Header.Get("Content-Digest"), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || !strings.EqualFold(name, "sha-256") {
+			continue
+		}
+		value = strings.TrimSuffix(strings.TrimPrefix(value, ":"), ":")
+		digest, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, false
+		}
+		return digest, true
+	}
+	return nil, false
+}
+
+func restoreDump(ctx context.Context, driverName, path string, dump io.Reader) error {
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := ExecScript(ctx, db, dump, nil); err != nil {
+		return fmt.Errorf("failed to restore dump: %w", err)
+	}
+	var integrity string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return fmt.Errorf("failed to check snapshot integrity: %w", err)
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("snapshot failed integrity check: %s", integrity)
+	}
+	return nil
+}
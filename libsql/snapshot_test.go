@@ -0,0 +1,79 @@
+package libsql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testDump = "PRAGMA foreign_keys=OFF;\nBEGIN TRANSACTION;\nCREATE TABLE t (a);\nINSERT INTO t VALUES(1);\nCOMMIT;\n"
+
+func TestDownloadDump(t *testing.T) {
+	var digest string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dump" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if digest != "" {
+			w.Header().Set("Content-Digest", "sha-512=:AAAA:, sha-256=:"+digest+":")
+		}
+		if _, err := w.Write([]byte(testDump)); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	connector, err := NewConnector(server.URL + "?authToken=token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump strings.Builder
+	var progress int64
+	result, err := connector.downloadDump(context.Background(), &dump, func(n int64) { progress = n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.String() != testDump {
+		t.Errorf("got dump %q", dump.String())
+	}
+	sum := sha256.Sum256([]byte(testDump))
+	if result.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("got checksum %s", result.SHA256)
+	}
+	if result.Bytes != int64(len(testDump)) || progress != result.Bytes {
+		t.Errorf("got %d bytes and progress %d, want %d", result.Bytes, progress, len(testDump))
+	}
+	if result.Verified {
+		t.Error("got the dump verified without a Content-Digest")
+	}
+
+	digest = base64.StdEncoding.EncodeToString(sum[:])
+	if result, err := connector.downloadDump(context.Background(), &strings.Builder{}, nil); err != nil || !result.Verified {
+		t.Errorf("got %+v, %v, want the dump verified", result, err)
+	}
+	other := sha256.Sum256([]byte("other"))
+	digest = base64.StdEncoding.EncodeToString(other[:])
+	if _, err := connector.downloadDump(context.Background(), &strings.Builder{}, nil); err == nil || !strings.Contains(err.Error(), "does not match the Content-Digest") {
+		t.Errorf("got %v, want a checksum mismatch", err)
+	}
+}
+
+func TestSnapshotOfFileUrl(t *testing.T) {
+	connector, err := NewConnector("file:///tmp/test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connector.downloadDump(context.Background(), &strings.Builder{}, nil); err == nil {
+		t.Fatal("expected error for a local database")
+	}
+}
@@ -1,14 +1,14 @@
 package libsql
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
-	"fmt"
-	"net/url"
-	"strings"
 
-	"github.com/libsql/libsql-client-go/libsql/internal/http"
-	"github.com/libsql/libsql-client-go/libsql/internal/ws"
+	"github.com/libsql/libsql-client-go/libsql/internal/auth"
+	"github.com/libsql/libsql-client-go/libsql/internal/clock"
+	"github.com/libsql/libsql-client-go/libsql/internal/dburl"
+	"github.com/libsql/libsql-client-go/libsql/internal/params"
 )
 
 func contains(s []string, item string) bool {
@@ -23,123 +23,47 @@ func contains(s []string, item string) bool {
 type LibsqlDriver struct {
 }
 
-// ExtractJwt extracts the JWT from the URL and removes it from the url.
-func extractJwt(query *url.Values) (string, error) {
-	authTokenSnake := query.Get("auth_token")
-	authTokenCamel := query.Get("authToken")
-	jwt := query.Get("jwt")
-	query.Del("auth_token")
-	query.Del("authToken")
-	query.Del("jwt")
-
-	countNonEmpty := func(slice ...string) int {
-		count := 0
-		for _, s := range slice {
-			if s != "" {
-				count++
-			}
-		}
-		return count
-	}
-
-	if countNonEmpty(authTokenSnake, authTokenCamel, jwt) > 1 {
-		return "", fmt.Errorf("please use at most one of the following query parameters: 'auth_token', 'authToken', 'jwt'")
-	}
-
-	if authTokenSnake != "" {
-		return authTokenSnake, nil
-	} else if authTokenCamel != "" {
-		return authTokenCamel, nil
-	} else {
-		return jwt, nil
-	}
-}
-
-func extractTls(query *url.Values, scheme string) (bool, error) {
-	tls := query.Get("tls")
-	query.Del("tls")
-	if tls == "" {
-		if scheme == "http" || scheme == "ws" {
-			return false, nil
-		} else {
-			return true, nil
-		}
-	} else if tls == "0" {
-		return false, nil
-	} else if tls == "1" {
-		return true, nil
-	} else {
-		return true, fmt.Errorf("unknown value of tls query parameter. Valid values are 0 and 1")
-	}
-}
-
 func (d *LibsqlDriver) Open(dbUrl string) (driver.Conn, error) {
-	u, err := url.Parse(dbUrl)
+	connector, err := d.OpenConnector(dbUrl)
 	if err != nil {
 		return nil, err
 	}
-	if u.Scheme == "file" {
-		if strings.HasPrefix(dbUrl, "file://") && !strings.HasPrefix(dbUrl, "file:///") {
-			return nil, fmt.Errorf("invalid database URL: %s. File URLs should not have double leading slashes. ", dbUrl)
-		}
-		expectedDrivers := []string{"sqlite", "sqlite3"}
-		presentDrivers := sql.Drivers()
-		for _, expectedDriver := range expectedDrivers {
-			if contains(presentDrivers, expectedDriver) {
-				db, err := sql.Open(expectedDriver, dbUrl)
-				if err != nil {
-					return nil, err
-				}
-				return db.Driver().Open(dbUrl)
-			}
-		}
-		return nil, fmt.Errorf("no sqlite driver present. Please import sqlite or sqlite3 driver.")
-	}
+	return connector.Connect(context.Background())
+}
 
-	query := u.Query()
-	jwt, err := extractJwt(&query)
-	if err != nil {
-		return nil, err
-	}
+func (d *LibsqlDriver) OpenConnector(dbUrl string) (driver.Connector, error) {
+	return NewConnector(dbUrl)
+}
 
-	tls, err := extractTls(&query, u.Scheme)
+// parseUrl validates dbUrl and extracts the settings from its query string.
+func parseUrl(dbUrl string) (*Connector, error) {
+	p, err := dburl.Parse(dbUrl)
 	if err != nil {
 		return nil, err
 	}
-
-	for name := range query {
-		return nil, fmt.Errorf("unknown query parameter %#v", name)
-	}
-	u.RawQuery = ""
-
-	if u.Scheme == "libsql" {
-		if tls {
-			u.Scheme = "https"
-		} else {
-			if u.Port() == "" {
-				return nil, fmt.Errorf("libsql:// URL with ?tls=0 must specify an explicit port")
-			}
-			u.Scheme = "http"
-		}
-	}
-
-	if (u.Scheme == "wss" || u.Scheme == "https") && !tls {
-		return nil, fmt.Errorf("%s:// URL cannot opt out of TLS using ?tls=0", u.Scheme)
-	}
-	if (u.Scheme == "ws" || u.Scheme == "http") && tls {
-		return nil, fmt.Errorf("%s:// URL cannot opt in to TLS using ?tls=1", u.Scheme)
-	}
-
-	if u.Scheme == "wss" || u.Scheme == "ws" {
-		return ws.Connect(u.String(), jwt)
-	}
-	if u.Scheme == "https" || u.Scheme == "http" {
-		return http.Connect(u.String(), jwt), nil
+	c := &Connector{dbUrl: dbUrl, deprecations: p.Deprecations}
+	if p.URL == nil {
+		return c, nil
 	}
-
-	return nil, fmt.Errorf("unsupported URL scheme: %s\nThis driver supports only URLs that start with libsql://, file://, https://, http://, wss:// and ws://", u.Scheme)
+	c.url = p.URL
+	c.token = auth.Static(p.Token)
+	c.tls = p.TLS
+	c.cfg.Clock = &clock.Estimator{}
+	c.checker = params.Checker{TimeFormat: p.TimeFormat}
+	c.parseTime = p.ParseTime
+	c.cfg.StreamRows = p.StreamRows
+	c.strictTypes = p.StrictTypes
+	c.expandSlices = p.ExpandSlices
+	c.busyTimeout = p.BusyTimeout
+	c.cfg.MaxRows = p.MaxRows
+	c.cfg.MaxResponseBytes = p.MaxResponseBytes
+	c.cfg.MaxRequestBytes = p.MaxRequestBytes
+	c.cfg.Database = p.Database
+	return c, nil
 }
 
+var libsqlDriver = &LibsqlDriver{}
+
 func init() {
-	sql.Register("libsql", &LibsqlDriver{})
+	sql.Register("libsql", libsqlDriver)
 }
@@ -0,0 +1,47 @@
+package libsql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseUrl(t *testing.T) {
+	tests := []struct {
+		dbUrl   string
+		wantErr string
+	}{
+		{dbUrl: "libsql://db.example.com"},
+		{dbUrl: "https://db.example.com"},
+		{dbUrl: "wss://db.example.com"},
+		{dbUrl: "libsql://db.example.com:8080?tls=0&insecure=1"},
+		{dbUrl: "libsql://127.0.0.1:8080?tls=0"},
+		{dbUrl: "libsql://db.example.com:8080?tls=0", wantErr: "add ?insecure=1"},
+		{dbUrl: "libsql://db.example.com?insecure=1", wantErr: "only applies to http://, ws:// and libsql:// URLs with ?tls=0"},
+		{dbUrl: "http://127.0.0.1:8080"},
+		{dbUrl: "ws://localhost:8080"},
+		{dbUrl: "http://[::1]:8080"},
+		{dbUrl: "http://db.example.com?insecure=1"},
+		{dbUrl: "ws://db.example.com?insecure=1"},
+		{dbUrl: "http://db.example.com", wantErr: "add ?insecure=1"},
+		{dbUrl: "ws://10.0.0.1:8080", wantErr: "add ?insecure=1"},
+		{dbUrl: "https://db.example.com?insecure=1", wantErr: "only applies to http://, ws:// and libsql:// URLs with ?tls=0"},
+		{dbUrl: "http://db.example.com?insecure=yes", wantErr: "insecure query parameter"},
+		{dbUrl: "db.example.com", wantErr: "missing scheme"},
+		{dbUrl: "libsql:///db", wantErr: "missing host"},
+		{dbUrl: "libsql:db.example.com", wantErr: "missing host"},
+		{dbUrl: "libsql://db.example.com:port", wantErr: "invalid database URL"},
+		{dbUrl: "ftp://db.example.com", wantErr: "unsupported URL scheme"},
+	}
+	for _, tt := range tests {
+		_, err := parseUrl(tt.dbUrl)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.dbUrl, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want %#v", tt.dbUrl, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package libsql
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
+
+// SQLiteError is the error of a statement the server failed to execute, over
+// HTTP as well as websockets. Code is the SQLite result code the server
+// reported, an extended code like CodeConstraintUnique when it sent one, and
+// Name the code as sent, like SQLITE_CONSTRAINT_UNIQUE. Retrieve it with
+// errors.As:
+//
+//	var sqliteErr *libsql.SQLiteError
+//	if errors.As(err, &sqliteErr) && sqliteErr.Code.Primary() == libsql.CodeConstraint {
+//		return fmt.Errorf("invalid user: %w", err)
+//	}
+//
+// Servers that do not report codes leave Code zero. The legacy HTTP API of sqld
+// does not report them.
+type SQLiteError = shared.SQLiteError
+
+// ErrorCode is a SQLite result code, see https://www.sqlite.org/rescode.html.
+// Extended codes carry their primary code in their lowest byte, which Primary
+// returns.
+type ErrorCode = shared.ErrorCode
+
+// The primary result codes of SQLite.
+const (
+	CodeError      = shared.CodeError
+	CodeInternal   = shared.CodeInternal
+	CodePerm       = shared.CodePerm
+	CodeAbort      = shared.CodeAbort
+	CodeBusy       = shared.CodeBusy
+	CodeLocked     = shared.CodeLocked
+	CodeNoMem      = shared.CodeNoMem
+	CodeReadOnly   = shared.CodeReadOnly
+	CodeInterrupt  = shared.CodeInterrupt
+	CodeIOErr      = shared.CodeIOErr
+	CodeCorrupt    = shared.CodeCorrupt
+	CodeNotFound   = shared.CodeNotFound
+	CodeFull       = shared.CodeFull
+	CodeCantOpen   = shared.CodeCantOpen
+	CodeProtocol   = shared.CodeProtocol
+	CodeEmpty      = shared.CodeEmpty
+	CodeSchema     = shared.CodeSchema
+	CodeTooBig     = shared.CodeTooBig
+	CodeConstraint = shared.CodeConstraint
+	CodeMismatch   = shared.CodeMismatch
+	CodeMisuse     = shared.CodeMisuse
+	CodeNoLFS      = shared.CodeNoLFS
+	CodeAuth       = shared.CodeAuth
+	CodeFormat     = shared.CodeFormat
+	CodeRange      = shared.CodeRange
+	CodeNotADB     = shared.CodeNotADB
+)
+
+// The extended result codes of SQLite the driver knows by name. Codes of other
+// extended names sent by the server are reduced to their primary code.
+const (
+	CodeBusyRecovery         = shared.CodeBusyRecovery
+	CodeBusySnapshot         = shared.CodeBusySnapshot
+	CodeBusyTimeout          = shared.CodeBusyTimeout
+	CodeLockedSharedCache    = shared.CodeLockedSharedCache
+	CodeLockedVTab           = shared.CodeLockedVTab
+	CodeReadOnlyRecovery     = shared.CodeReadOnlyRecovery
+	CodeReadOnlyCantLock     = shared.CodeReadOnlyCantLock
+	CodeReadOnlyRollback     = shared.CodeReadOnlyRollback
+	CodeReadOnlyDBMoved      = shared.CodeReadOnlyDBMoved
+	CodeAbortRollback        = shared.CodeAbortRollback
+	CodeConstraintCheck      = shared.CodeConstraintCheck
+	CodeConstraintCommitHook = shared.CodeConstraintCommitHook
+	CodeConstraintForeignKey = shared.CodeConstraintForeignKey
+	CodeConstraintFunction   = shared.CodeConstraintFunction
+	CodeConstraintNotNull    = shared.CodeConstraintNotNull
+	CodeConstraintPrimaryKey = shared.CodeConstraintPrimaryKey
+	CodeConstraintTrigger    = shared.CodeConstraintTrigger
+	CodeConstraintUnique     = shared.CodeConstraintUnique
+	CodeConstraintVTab       = shared.CodeConstraintVTab
+	CodeConstraintRowID      = shared.CodeConstraintRowID
+	CodeConstraintPinned     = shared.CodeConstraintPinned
+	CodeConstraintDataType   = shared.CodeConstraintDataType
+)
+
+// errorCode returns the SQLite code and the message of err, which is zero for
+// errors without one.
+func errorCode(err error) (ErrorCode, string) {
+	var sqliteErr *SQLiteError
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code, sqliteErr.Message
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return shared.ParseErrorCode(httpErr.Code), httpErr.Message
+	}
+	if err != nil {
+		return 0, err.Error()
+	}
+	return 0, ""
+}
+
+// IsUniqueViolation reports whether err comes from a statement that violated a
+// UNIQUE or PRIMARY KEY constraint. Servers that only report the primary code
+// SQLITE_CONSTRAINT, or no code, are matched by the message of SQLite.
+func IsUniqueViolation(err error) bool {
+	code, message := errorCode(err)
+	switch code {
+	case CodeConstraintUnique, CodeConstraintPrimaryKey:
+		return true
+	case 0, CodeConstraint:
+		return strings.Contains(message, "UNIQUE constraint failed")
+	}
+	return false
+}
+
+// IsConstraint reports whether err comes from a statement that violated a
+// constraint of the schema: UNIQUE, PRIMARY KEY, NOT NULL, CHECK, FOREIGN KEY
+// or one raised by a trigger. Running the statement again fails the same way
+// until the data changes. Servers that do not report codes are matched by the
+// message of SQLite.
+func IsConstraint(err error) bool {
+	code, message := errorCode(err)
+	if code == 0 {
+		return strings.Contains(message, "constraint failed")
+	}
+	return code.Primary() == CodeConstraint
+}
+
+// IsBusy reports whether err comes from a statement that failed with
+// SQLITE_BUSY because another connection held a conflicting lock on the
+// database. The statement did not run, retrying it after a delay may succeed.
+func IsBusy(err error) bool {
+	code, message := errorCode(err)
+	if code == 0 {
+		return strings.Contains(message, "database is locked")
+	}
+	return code.Primary() == CodeBusy
+}
+
+// IsLocked reports whether err comes from a statement that failed with
+// SQLITE_LOCKED because of a conflict within the same connection, like a table
+// dropped while it is being read.
+func IsLocked(err error) bool {
+	code, message := errorCode(err)
+	if code == 0 {
+		return strings.Contains(message, "database table is locked")
+	}
+	return code.Primary() == CodeLocked
+}
@@ -0,0 +1,74 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newErrorServer returns a fake sqld server failing every statement with
+// message and the SQLite code named code.
+func newErrorServer(t *testing.T, code, message string) string {
+	server := libsqltest.NewFuncServer(t, nil)
+	server.FailStatements(-1, "", &libsqltest.Error{Code: code, Message: message})
+	return server.URL
+}
+
+func TestSQLiteError(t *testing.T) {
+	tests := []struct {
+		code, message        string
+		want                 ErrorCode
+		unique, busy, locked bool
+	}{
+		{code: "SQLITE_CONSTRAINT_UNIQUE", message: "UNIQUE constraint failed: users.email", want: CodeConstraintUnique, unique: true},
+		{code: "SQLITE_CONSTRAINT_PRIMARYKEY", message: "UNIQUE constraint failed: users.id", want: CodeConstraintPrimaryKey, unique: true},
+		{code: "SQLITE_CONSTRAINT", message: "UNIQUE constraint failed: users.email", want: CodeConstraint, unique: true},
+		{code: "SQLITE_CONSTRAINT_NOTNULL", message: "NOT NULL constraint failed: users.name", want: CodeConstraintNotNull},
+		{code: "SQLITE_BUSY", message: "database is locked", want: CodeBusy, busy: true},
+		{code: "SQLITE_LOCKED_SHAREDCACHE", message: "database table is locked", want: CodeLockedSharedCache, locked: true},
+		{code: "", message: "database is locked", busy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			db, err := sql.Open("libsql", newErrorServer(t, tt.code, tt.message))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			_, err = db.ExecContext(context.Background(), "INSERT INTO users (email) VALUES (?)", "a@b.c")
+			var sqliteErr *SQLiteError
+			if !errors.As(err, &sqliteErr) || sqliteErr.Code != tt.want || sqliteErr.Name != tt.code || sqliteErr.Message != tt.message {
+				t.Fatalf("got %#v, want a SQLite error with code %v", err, tt.want)
+			}
+			if IsUniqueViolation(err) != tt.unique || IsBusy(err) != tt.busy || IsLocked(err) != tt.locked {
+				t.Errorf("got unique %t, busy %t, locked %t, want %t, %t, %t",
+					IsUniqueViolation(err), IsBusy(err), IsLocked(err), tt.unique, tt.busy, tt.locked)
+			}
+		})
+	}
+	if IsUniqueViolation(nil) || IsBusy(nil) || IsLocked(nil) {
+		t.Error("expected nil not to match")
+	}
+}
+
+func TestIsConstraint(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{err: &SQLiteError{Code: CodeConstraintNotNull, Message: "NOT NULL constraint failed: users.name"}, want: true},
+		{err: &SQLiteError{Code: CodeConstraint, Message: "FOREIGN KEY constraint failed"}, want: true},
+		{err: fmt.Errorf("failed to execute SQL: %w", &SQLiteError{Message: "CHECK constraint failed: age"}), want: true},
+		{err: &SQLiteError{Code: CodeBusy, Message: "database is locked"}},
+		{err: &SQLiteError{Code: CodeError, Message: "no such table: users"}},
+		{err: nil},
+	} {
+		if got := IsConstraint(tt.err); got != tt.want {
+			t.Errorf("IsConstraint(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package libsql
+
+import "fmt"
+
+// WithStatementCache caches the parsed form of the last size distinct queries
+// of every connection, so repeated queries are not parsed again. Over Hrana
+// over HTTP, single statements executed more than once are also stored on the
+// server and later executions only send their id instead of the SQL text.
+func WithStatementCache(size int) Option {
+	return option(func(c *Connector) error {
+		if size <= 0 {
+			return fmt.Errorf("statement cache size must be positive")
+		}
+		c.cfg.StatementCacheSize = size
+		return nil
+	})
+}
@@ -0,0 +1,73 @@
+package libsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/ctxopt"
+)
+
+// StatementTimeoutError is returned by a statement of a transaction that ran
+// for longer than the timeout of WithTxStatementTimeout. The stream of the
+// transaction was closed, which makes the server roll it back, so Commit fails
+// and Rollback succeeds without a request. It wraps context.DeadlineExceeded.
+type StatementTimeoutError struct {
+	Query   string
+	Timeout time.Duration
+}
+
+func (e *StatementTimeoutError) Error() string {
+	return fmt.Sprintf("statement ran for longer than %s, the transaction was rolled back: %s", e.Timeout, e.Query)
+}
+
+func (e *StatementTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// WithTxStatementTimeout bounds every statement run inside a transaction by d.
+// A statement exceeding it fails with a *StatementTimeoutError and its stream
+// is closed, so the server rolls the transaction back instead of holding the
+// write lock of the primary while the client waits. Statements outside
+// transactions are bounded by WithRequestTimeout only.
+func WithTxStatementTimeout(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		if d <= 0 {
+			return fmt.Errorf("transaction statement timeout must be positive")
+		}
+		c.txStatementTimeout = d
+		return nil
+	})
+}
+
+// statementContext returns the context of a statement, bounded by the
+// transaction statement timeout inside transactions. It reports whether the
+// timeout applies, which it does not when the request timeout or the timeout
+// hint of ctx is shorter.
+func (c *Conn) statementContext(ctx context.Context) (context.Context, bool) {
+	d := c.connector.txStatementTimeout
+	if d <= 0 || !c.inTx {
+		return ctx, false
+	}
+	limit := c.connector.cfg.RequestTimeout
+	if hint := ctxopt.TimeoutHint(ctx); hint > 0 {
+		limit = hint
+	}
+	if limit > 0 && limit <= d {
+		return ctx, false
+	}
+	return ctxopt.WithTimeoutHint(ctx, d), true
+}
+
+// statementError returns the *StatementTimeoutError of a statement run with a
+// context from statementContext that exceeded the timeout, and err otherwise.
+// The transaction is then remembered as rolled back.
+func (c *Conn) statementError(ctx context.Context, guarded bool, query string, err error) error {
+	if err == nil || !guarded || ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	timeoutErr := &StatementTimeoutError{Query: query, Timeout: c.connector.txStatementTimeout}
+	c.txTimedOut = timeoutErr
+	return timeoutErr
+}
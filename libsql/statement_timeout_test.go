@@ -0,0 +1,78 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newSlowServer returns a fake sqld server that answers statements at once,
+// except SELECT slow which never completes, and reports the transactions
+// rolled back by closing their stream on closed.
+func newSlowServer(t *testing.T, closed chan<- struct{}) *libsqltest.Server {
+	return libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		switch query {
+		case "SELECT slow":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "ROLLBACK":
+			closed <- struct{}{}
+		}
+		return nil, nil
+	})
+}
+
+func TestTxStatementTimeout(t *testing.T) {
+	closed := make(chan struct{}, 4)
+	server := newSlowServer(t, closed)
+
+	connector, err := NewConnector(server.URL, WithTxStatementTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, commit := range []bool{true, false} {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		_, err = tx.ExecContext(ctx, "SELECT slow")
+		var timeoutErr *StatementTimeoutError
+		if !errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got %v, want a *StatementTimeoutError", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("statement took %s", elapsed)
+		}
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("the stream of the transaction was not closed")
+		}
+		if commit {
+			if err := tx.Commit(); !errors.As(err, &timeoutErr) {
+				t.Errorf("got %v from Commit, want a *StatementTimeoutError", err)
+			}
+		} else if err := tx.Rollback(); err != nil {
+			t.Errorf("got %v from Rollback, want the rolled back transaction to end", err)
+		}
+	}
+}
+
+func TestTxStatementTimeoutInvalid(t *testing.T) {
+	if _, err := NewConnector("https://db", WithTxStatementTimeout(0)); err == nil {
+		t.Error("expected a zero timeout to be rejected")
+	}
+}
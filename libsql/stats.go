@@ -0,0 +1,82 @@
+package libsql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/http/shared"
+)
+
+// Stats are the execution statistics sqld reports with the results of
+// statements: the rows they read and wrote and the time the server spent
+// executing them. They are the quantities usage-based plans are billed on.
+// Servers that do not report them leave them zero.
+type Stats = shared.Stats
+
+// statsResult is implemented by the results and rows of transports that
+// report execution statistics.
+type statsResult interface {
+	Stats() shared.Stats
+}
+
+type statsKey struct{}
+
+// statsCollector sums the statistics of the statements executed with a
+// context returned by WithStats.
+type statsCollector struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+func (s *statsCollector) add(stats Stats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Add(stats)
+}
+
+// WithStats returns a context collecting the execution statistics of the
+// statements executed with it, read with StatsFromContext:
+//
+//	ctx = libsql.WithStats(ctx)
+//	rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name = ?", name)
+//	// ... read and close rows
+//	log.Print(libsql.StatsFromContext(ctx).RowsRead)
+//
+// The statistics of a query are collected when its rows are closed, and those
+// of rows streamed with streamRows only if all the rows were read. Statements
+// of buffered transactions are not accounted for.
+func WithStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, statsKey{}, &statsCollector{})
+}
+
+// StatsFromContext returns the sum of the statistics of the statements
+// executed so far with ctx, zero if ctx does not come from WithStats. It may be
+// called while statements using ctx are running.
+func StatsFromContext(ctx context.Context) Stats {
+	s, ok := ctx.Value(statsKey{}).(*statsCollector)
+	if !ok {
+		return Stats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func statsFrom(ctx context.Context) *statsCollector {
+	s, _ := ctx.Value(statsKey{}).(*statsCollector)
+	return s
+}
+
+// recordStats adds the statistics of res, a driver.Result or driver.Rows, to
+// those of c and to the collector of ctx.
+func (c *Conn) recordStats(ctx context.Context, res any) {
+	r, ok := res.(statsResult)
+	if !ok {
+		return
+	}
+	stats := r.Stats()
+	c.stats.Add(stats)
+	if s := statsFrom(ctx); s != nil {
+		s.add(stats)
+	}
+}
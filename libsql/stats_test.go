@@ -0,0 +1,102 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newStatsServer returns a fake sqld server answering every statement with
+// two rows, one affected, after at least a millisecond.
+func newStatsServer(t *testing.T) string {
+	return libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		time.Sleep(time.Millisecond)
+		return &libsqltest.Result{Cols: []string{"a"}, Rows: [][]driver.Value{{int64(1)}, {int64(2)}}, Affected: 1}, nil
+	}).URL
+}
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		exec  bool
+		want  Stats
+	}{
+		{name: "exec", query: "INSERT INTO t VALUES (1)", exec: true, want: Stats{RowsWritten: 1, QueryDuration: time.Millisecond}},
+		{name: "execBatch", query: "INSERT INTO t VALUES (1); INSERT INTO t VALUES (2)", exec: true, want: Stats{RowsWritten: 2, QueryDuration: 2 * time.Millisecond}},
+		{name: "query", query: "SELECT a FROM t", want: Stats{RowsRead: 2, QueryDuration: time.Millisecond}},
+		{name: "queryBatch", query: "SELECT a FROM t; SELECT a FROM t", want: Stats{RowsRead: 4, QueryDuration: 2 * time.Millisecond}},
+	}
+	for _, streamRows := range []bool{false, true} {
+		url := newStatsServer(t)
+		if streamRows {
+			url += "?streamRows=true"
+		}
+		db, err := sql.Open("libsql", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/streamRows=%v", tt.name, streamRows), func(t *testing.T) {
+				ctx := WithStats(context.Background())
+				if tt.exec {
+					if _, err := db.ExecContext(ctx, tt.query); err != nil {
+						t.Fatal(err)
+					}
+				} else {
+					rows, err := db.QueryContext(ctx, tt.query)
+					if err != nil {
+						t.Fatal(err)
+					}
+					for rows.Next() {
+					}
+					if rows.NextResultSet() {
+						for rows.Next() {
+						}
+					}
+					if err := rows.Close(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				checkStats(t, StatsFromContext(ctx), tt.want)
+			})
+		}
+	}
+}
+
+func TestStatsAccumulate(t *testing.T) {
+	db, err := sql.Open("libsql", newStatsServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := WithStats(context.Background())
+	for i := 0; i < 3; i++ {
+		var a int64
+		if err := db.QueryRowContext(ctx, "SELECT a FROM t").Scan(&a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.ExecContext(context.Background(), "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, StatsFromContext(ctx), Stats{RowsRead: 6, QueryDuration: 3 * time.Millisecond})
+	if got := StatsFromContext(context.Background()); got != (Stats{}) {
+		t.Errorf("got %+v without WithStats, want zero", got)
+	}
+}
+
+// checkStats checks got has the rows of want and took at least its duration.
+func checkStats(t *testing.T, got, want Stats) {
+	t.Helper()
+	if got.RowsRead != want.RowsRead || got.RowsWritten != want.RowsWritten || got.QueryDuration < want.QueryDuration {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,13 @@
+package libsql
+
+// WithStreamRows decodes the rows of queries over Hrana over HTTP as they are
+// read instead of buffering the whole response, like the streamRows query
+// parameter. QueryContext returns as soon as the columns arrive, which lowers
+// the time to the first row and the memory used by large results. Queries of
+// several statements are still buffered.
+func WithStreamRows() Option {
+	return option(func(c *Connector) error {
+		c.cfg.StreamRows = true
+		return nil
+	})
+}
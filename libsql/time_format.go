@@ -0,0 +1,42 @@
+package libsql
+
+import (
+	"fmt"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/params"
+)
+
+// TimeFormat is the encoding of time.Time arguments, set with WithTimeFormat.
+type TimeFormat = params.TimeFormat
+
+const (
+	// TimeFormatRFC3339 sends time.Time as RFC3339 text with nanoseconds, the
+	// default.
+	TimeFormatRFC3339 = params.TimeFormatRFC3339
+	// TimeFormatSQLite sends time.Time as UTC text in the YYYY-MM-DD HH:MM:SS
+	// format of the date and time functions of SQLite, followed by the
+	// fraction of the second if there is one.
+	TimeFormatSQLite = params.TimeFormatSQLite
+	// TimeFormatUnix sends time.Time as integer seconds since the epoch.
+	TimeFormatUnix = params.TimeFormatUnix
+	// TimeFormatUnixMilli sends time.Time as integer milliseconds since the
+	// epoch.
+	TimeFormatUnixMilli = params.TimeFormatUnixMilli
+)
+
+// WithTimeFormat sets how time.Time arguments are encoded, like the timeFormat
+// query parameter. With parseTime, integers read from DATE, DATETIME and
+// TIMESTAMP columns are then parsed as seconds for TimeFormatUnix and as
+// milliseconds for TimeFormatUnixMilli, instead of being told apart by their
+// number of digits. Text in any of the supported formats is always parsed.
+func WithTimeFormat(format TimeFormat) Option {
+	return option(func(c *Connector) error {
+		switch format {
+		case TimeFormatRFC3339, TimeFormatSQLite, TimeFormatUnix, TimeFormatUnixMilli:
+		default:
+			return fmt.Errorf("unknown time format %d", format)
+		}
+		c.checker.TimeFormat = format
+		return nil
+	})
+}
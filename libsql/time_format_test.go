@@ -0,0 +1,41 @@
+package libsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestWithTimeFormat(t *testing.T) {
+	ts := time.Date(2023, 8, 1, 12, 30, 0, 0, time.UTC)
+	connector, err := NewConnector("http://localhost:8080?parseTime=true", WithTimeFormat(TimeFormatUnixMilli))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := connector.checker.ConvertValue(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != ts.UnixMilli() {
+		t.Errorf("got %#v, want unix milliseconds", value)
+	}
+
+	fake := &fakeRows{
+		columns:   []string{"at"},
+		declTypes: []string{"TIMESTAMP"},
+		values:    [][]driver.Value{{int64(1690893)}},
+	}
+	r := wrapRows(context.Background(), fake, &Conn{connector: connector}, "SELECT at FROM t")
+	dest := make([]driver.Value, 1)
+	if err := r.Next(dest); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := dest[0].(time.Time); !ok || !got.Equal(time.UnixMilli(1690893)) {
+		t.Errorf("got %#v, want the integer parsed as milliseconds", dest[0])
+	}
+
+	if _, err := NewConnector("http://localhost:8080", WithTimeFormat(TimeFormat(42))); err == nil {
+		t.Error("expected an unknown time format to be rejected")
+	}
+}
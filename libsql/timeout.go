@@ -0,0 +1,46 @@
+package libsql
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithConnectTimeout bounds the time spent opening a connection, including
+// the websocket handshake and the detection of the Hrana version, even when
+// the context has no deadline.
+func WithConnectTimeout(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		if d < 0 {
+			return fmt.Errorf("connect timeout must not be negative")
+		}
+		c.cfg.ConnectTimeout = d
+		return nil
+	})
+}
+
+// WithRequestTimeout bounds every request sent to the server, so a caller
+// passing context.Background() cannot hang on a stuck request. It replaces the
+// default limit of 60 seconds of Hrana over HTTP. Streamed rows must be read
+// before the timeout too.
+func WithRequestTimeout(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		if d < 0 {
+			return fmt.Errorf("request timeout must not be negative")
+		}
+		c.cfg.RequestTimeout = d
+		return nil
+	})
+}
+
+// WithStreamIdleTimeout discards connections that did not use their stream for
+// longer than d when they are taken from the database/sql pool, instead of
+// sending a request on a stream the server may have expired.
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return option(func(c *Connector) error {
+		if d < 0 {
+			return fmt.Errorf("stream idle timeout must not be negative")
+		}
+		c.cfg.StreamIdleTimeout = d
+		return nil
+	})
+}
@@ -0,0 +1,114 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+	server.SetLatency(time.Hour)
+
+	connector, err := NewConnector(server.URL, WithRequestTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	start := time.Now()
+	if _, err := db.ExecContext(context.Background(), "SELECT 1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request took %s", elapsed)
+	}
+}
+
+func TestTimeoutHint(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+	server.SetLatency(100 * time.Millisecond)
+
+	connector, err := NewConnector(server.URL, WithRequestTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+	if _, err := db.ExecContext(WithTimeoutHint(ctx, 5*time.Second), "VACUUM"); err != nil {
+		t.Fatalf("got %v, want the hint to extend the request timeout", err)
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded without the hint", err)
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	// The server accepts the websocket but never answers the hello.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"hrana1"}})
+		if err != nil {
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	connector, err := NewConnector("ws"+strings.TrimPrefix(server.URL, "http"), WithConnectTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := connector.Connect(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Connect took %s", elapsed)
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	server := libsqltest.NewFuncServer(t, nil)
+
+	connector, err := NewConnector(server.URL, WithStreamIdleTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, wait := range []time.Duration{0, 0, 50 * time.Millisecond} {
+		time.Sleep(wait)
+		if _, err := db.Exec("SELECT 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var batons []bool
+	for _, r := range server.Requests() {
+		if r.URL.Path != "/v3/pipeline" {
+			continue
+		}
+		var req hrana.PipelineRequest
+		if err := json.Unmarshal(r.Body, &req); err != nil {
+			t.Fatal(err)
+		}
+		batons = append(batons, req.Baton != "")
+	}
+	if want := []bool{false, true, false}; !reflect.DeepEqual(batons, want) {
+		t.Errorf("got requests with batons %v, want the idle connection to be replaced", batons)
+	}
+}
@@ -0,0 +1,90 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MigrationStep moves the schema from one user_version to the next. The step
+// at index i of the slice passed to MigrateTo migrates from version i to i+1.
+type MigrationStep func(ctx context.Context, tx *sql.Tx) error
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getUserVersion(ctx context.Context, q queryRower) (int, error) {
+	var version int
+	if err := q.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read user_version: %w", err)
+	}
+	return version, nil
+}
+
+// GetUserVersion returns the value of PRAGMA user_version.
+func GetUserVersion(ctx context.Context, db *sql.DB) (int, error) {
+	return getUserVersion(ctx, db)
+}
+
+// SetUserVersion sets PRAGMA user_version to version.
+func SetUserVersion(ctx context.Context, db *sql.DB, version int) error {
+	if version < 0 {
+		return fmt.Errorf("invalid user_version %d", version)
+	}
+	// PRAGMA statements do not accept bound parameters.
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return fmt.Errorf("failed to set user_version: %w", err)
+	}
+	return nil
+}
+
+// MigrateTo applies steps until PRAGMA user_version reaches version. Every step
+// runs in its own transaction together with the user_version update, so a
+// failed step leaves the database at the last successfully applied version.
+// Downgrades are not supported.
+func MigrateTo(ctx context.Context, db *sql.DB, version int, steps []MigrationStep) error {
+	if version > len(steps) {
+		return fmt.Errorf("cannot migrate to version %d with %d steps", version, len(steps))
+	}
+	current, err := GetUserVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if current > version {
+		return fmt.Errorf("database is at version %d which is newer than the target version %d", current, version)
+	}
+	for v := current; v < version; v++ {
+		if err := applyStep(ctx, db, v, steps[v]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyStep(ctx context.Context, db *sql.DB, from int, step MigrationStep) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration to version %d: %w", from+1, err)
+	}
+	defer tx.Rollback()
+
+	// Another client may have migrated the database in the meantime.
+	current, err := getUserVersion(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if current != from {
+		return fmt.Errorf("database version changed concurrently: expected %d, got %d", from, current)
+	}
+	if err := step(ctx, tx); err != nil {
+		return fmt.Errorf("migration to version %d failed: %w", from+1, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", from+1)); err != nil {
+		return fmt.Errorf("failed to set user_version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration to version %d: %w", from+1, err)
+	}
+	return nil
+}
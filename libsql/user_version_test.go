@@ -0,0 +1,66 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newUserVersionServer returns a database recording the statements it
+// receives, answering PRAGMA user_version with version and failing CREATE
+// TABLE t2.
+func newUserVersionServer(t *testing.T, version int64) (*sql.DB, func() []string) {
+	f, executed := libsqltest.Log(func(stmt string) (*libsqltest.Result, error) {
+		switch stmt {
+		case "PRAGMA user_version":
+			return &libsqltest.Result{Cols: []string{"user_version"}, Rows: [][]driver.Value{{version}}}, nil
+		case "CREATE TABLE t2 (a)":
+			return nil, errors.New("boom")
+		}
+		fmt.Sscanf(stmt, "PRAGMA user_version = %d", &version)
+		return nil, nil
+	})
+	db, _ := libsqltest.Open(t, f)
+	return db, executed
+}
+
+func TestMigrateTo(t *testing.T) {
+	db, executed := newUserVersionServer(t, 1)
+	applied := []int{}
+	step := func(v int) MigrationStep {
+		return func(ctx context.Context, tx *sql.Tx) error {
+			applied = append(applied, v)
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE t%d (a)", v))
+			return err
+		}
+	}
+
+	err := MigrateTo(context.Background(), db, 3, []MigrationStep{step(0), step(1), step(2)})
+	if err == nil {
+		t.Fatal("expected error from failing step")
+	}
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 2 {
+		t.Errorf("got applied steps %v, want [1 2]", applied)
+	}
+	want := []string{
+		"PRAGMA user_version",
+		"BEGIN", "PRAGMA user_version", "CREATE TABLE t1 (a)", "PRAGMA user_version = 2", "COMMIT",
+		"BEGIN", "PRAGMA user_version", "CREATE TABLE t2 (a)", "ROLLBACK",
+	}
+	if got := executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMigrateToRejectsDowngrade(t *testing.T) {
+	db, _ := newUserVersionServer(t, 3)
+	if err := MigrateTo(context.Background(), db, 1, []MigrationStep{nil, nil}); err == nil {
+		t.Fatal("expected downgrade to fail")
+	}
+}
@@ -0,0 +1,45 @@
+package libsql
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithWebsocketMaxStreams lets up to n connections share one websocket, each
+// using its own stream. By default every connection opens its own websocket.
+func WithWebsocketMaxStreams(n int) Option {
+	return option(func(c *Connector) error {
+		if n <= 0 {
+			return fmt.Errorf("websocket max streams must be positive")
+		}
+		c.cfg.WebsocketMaxStreams = n
+		return nil
+	})
+}
+
+// WithWebsocketWarmup opens n websockets when the first connection is made and
+// keeps that many open while they are idle.
+func WithWebsocketWarmup(n int) Option {
+	return option(func(c *Connector) error {
+		if n < 0 {
+			return fmt.Errorf("websocket warmup must not be negative")
+		}
+		c.cfg.WebsocketWarmup = n
+		return nil
+	})
+}
+
+// WithWebsocketRecycle retires a websocket after maxRequests requests or once it
+// is older than maxAge, whichever comes first. Connections using a retired
+// websocket are replaced when they are returned to the database/sql pool, which
+// spreads long-lived applications across edge nodes. Zero disables a limit.
+func WithWebsocketRecycle(maxRequests int, maxAge time.Duration) Option {
+	return option(func(c *Connector) error {
+		if maxRequests < 0 || maxAge < 0 {
+			return fmt.Errorf("websocket recycle limits must not be negative")
+		}
+		c.cfg.WebsocketMaxRequests = maxRequests
+		c.cfg.WebsocketMaxAge = maxAge
+		return nil
+	})
+}
@@ -0,0 +1,205 @@
+package libsql
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr/v4"
+	"github.com/libsql/sqlite-antlr4-parser/sqliteparser"
+)
+
+// WithWriteHook calls hook after every successful statement that writes to
+// tables, with the names of the tables it writes to, so that applications
+// keeping rows in an in-process cache can invalidate them without wrapping
+// every Exec call. INSERT, REPLACE, UPDATE and DELETE statements report their
+// target table, DROP TABLE and DROP VIEW the dropped one, and ALTER TABLE the
+// altered table and its new name. Tables written by triggers and foreign key
+// actions are not reported.
+//
+// The statements of a transaction are reported together once it commits, and
+// not at all if it rolls back. Table names are reported as written in the
+// statement, unquoted and prefixed with their schema if one is given. Writes
+// whose tables cannot be determined, like statements the driver fails to
+// parse, are reported with nil tables: invalidate everything then.
+//
+// hook runs on the goroutine of the statement, before Exec or Commit returns,
+// and may be called concurrently by different connections.
+func WithWriteHook(hook func(tables []string)) Option {
+	return option(func(c *Connector) error {
+		c.writes.hook = hook
+		return nil
+	})
+}
+
+// writeTargets are the tables a query writes to.
+type writeTargets struct {
+	tables []string
+	// unknown is set for queries that write to tables that cannot be
+	// determined.
+	unknown bool
+}
+
+// writeTracker caches the tables written by queries, like columnMetadata caches
+// their sources.
+type writeTracker struct {
+	hook    func(tables []string)
+	mu      sync.Mutex
+	queries map[string]*writeTargets
+}
+
+// targets returns the tables query writes to, nil if it does not write.
+func (w *writeTracker) targets(query string) *writeTargets {
+	w.mu.Lock()
+	targets, ok := w.queries[query]
+	w.mu.Unlock()
+	if ok {
+		return targets
+	}
+	targets = parseWriteTargets(query)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.queries == nil || len(w.queries) >= maxCachedQueries {
+		w.queries = map[string]*writeTargets{}
+	}
+	w.queries[query] = targets
+	return targets
+}
+
+// writeKeywords are the leading keywords of statements that may write, used to
+// tell writes apart when a query cannot be parsed.
+var writeKeywords = map[string]bool{"INSERT": true, "REPLACE": true, "UPDATE": true, "DELETE": true, "WITH": true, "DROP": true, "ALTER": true}
+
+// parseWriteTargets returns the tables the statements of query write to, nil
+// if none of them writes.
+func parseWriteTargets(query string) *writeTargets {
+	errs := &syntaxErrors{DefaultErrorListener: antlr.NewDefaultErrorListener()}
+	lexer := sqliteparser.NewSQLiteLexer(antlr.NewInputStream(query))
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(errs)
+	parser := sqliteparser.NewSQLiteParser(antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel))
+	parser.RemoveErrorListeners()
+	parser.AddErrorListener(errs)
+	tree := parser.Parse()
+	if errs.count > 0 {
+		if writeKeywords[strings.ToUpper(leadingKeyword(query))] {
+			return &writeTargets{unknown: true}
+		}
+		return nil
+	}
+
+	var tables []string
+	add := func(schema sqliteparser.ISchema_nameContext, name antlr.ParserRuleContext) {
+		if name == nil {
+			return
+		}
+		table := unquoteIdentifier(name.GetText())
+		if schema != nil {
+			table = unquoteIdentifier(schema.GetText()) + "." + table
+		}
+		tables = appendTable(tables, table)
+	}
+	addQualified := func(q sqliteparser.IQualified_table_nameContext) {
+		if q != nil && q.Table_name() != nil {
+			add(q.Schema_name(), q.Table_name())
+		}
+	}
+	writes := false
+	for _, list := range tree.AllSql_stmt_list() {
+		for _, stmt := range list.AllSql_stmt() {
+			if stmt.EXPLAIN_() != nil {
+				continue
+			}
+			switch {
+			case stmt.Insert_stmt() != nil:
+				if s := stmt.Insert_stmt(); s.Table_name() != nil {
+					add(s.Schema_name(), s.Table_name())
+				}
+			case stmt.Update_stmt() != nil:
+				addQualified(stmt.Update_stmt().Qualified_table_name())
+			case stmt.Update_stmt_limited() != nil:
+				addQualified(stmt.Update_stmt_limited().Qualified_table_name())
+			case stmt.Delete_stmt() != nil:
+				addQualified(stmt.Delete_stmt().Qualified_table_name())
+			case stmt.Delete_stmt_limited() != nil:
+				addQualified(stmt.Delete_stmt_limited().Qualified_table_name())
+			case stmt.Drop_stmt() != nil:
+				s := stmt.Drop_stmt()
+				if s.TABLE_() == nil && s.VIEW_() == nil {
+					continue
+				}
+				if s.Any_name() != nil {
+					add(s.Schema_name(), s.Any_name())
+				}
+			case stmt.Alter_table_stmt() != nil:
+				s := stmt.Alter_table_stmt()
+				for _, name := range s.AllTable_name() {
+					add(s.Schema_name(), name)
+				}
+			default:
+				continue
+			}
+			writes = true
+		}
+	}
+	if !writes {
+		return nil
+	}
+	return &writeTargets{tables: tables, unknown: len(tables) == 0}
+}
+
+// recordWrites reports the tables written by queries to the write hook, or
+// keeps them until the open transaction ends.
+func (c *Conn) recordWrites(queries ...string) {
+	if c.connector.writes.hook == nil {
+		return
+	}
+	var writes []*writeTargets
+	for _, query := range queries {
+		if targets := c.connector.writes.targets(query); targets != nil {
+			writes = append(writes, targets)
+		}
+	}
+	if c.inTx {
+		c.txWrites = append(c.txWrites, writes...)
+		return
+	}
+	c.reportWrites(writes)
+}
+
+// endTxWrites reports the writes of the transaction that ended if it
+// committed, and forgets them otherwise.
+func (c *Conn) endTxWrites(committed bool) {
+	writes := c.txWrites
+	c.txWrites = nil
+	if committed {
+		c.reportWrites(writes)
+	}
+}
+
+// reportWrites calls the write hook once with the tables of all writes.
+func (c *Conn) reportWrites(writes []*writeTargets) {
+	if len(writes) == 0 {
+		return
+	}
+	var tables []string
+	for _, w := range writes {
+		if w.unknown {
+			c.connector.writes.hook(nil)
+			return
+		}
+		for _, table := range w.tables {
+			tables = appendTable(tables, table)
+		}
+	}
+	c.connector.writes.hook(tables)
+}
+
+// appendTable appends table to tables unless it is already there.
+func appendTable(tables []string, table string) []string {
+	for _, t := range tables {
+		if t == table {
+			return tables
+		}
+	}
+	return append(tables, table)
+}
@@ -0,0 +1,100 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseWriteTargets(t *testing.T) {
+	tests := []struct {
+		query string
+		want  *writeTargets
+	}{
+		{query: "SELECT * FROM users"},
+		{query: "PRAGMA table_info(users)"},
+		{query: "EXPLAIN DELETE FROM users"},
+		{query: "DROP INDEX users_name"},
+		{query: "INSERT INTO users (name) VALUES (?)", want: &writeTargets{tables: []string{"users"}}},
+		{query: `REPLACE INTO main."User Posts" VALUES (1)`, want: &writeTargets{tables: []string{"main.User Posts"}}},
+		{query: "UPDATE users SET name = ? WHERE id = ? RETURNING id", want: &writeTargets{tables: []string{"users"}}},
+		{query: "WITH old AS (SELECT id FROM users) DELETE FROM posts WHERE user_id IN old", want: &writeTargets{tables: []string{"posts"}}},
+		{query: "DELETE FROM posts; INSERT INTO users VALUES (1); DELETE FROM posts", want: &writeTargets{tables: []string{"posts", "users"}}},
+		{query: "DROP TABLE IF EXISTS aux.posts", want: &writeTargets{tables: []string{"aux.posts"}}},
+		{query: "ALTER TABLE users RENAME TO members", want: &writeTargets{tables: []string{"users", "members"}}},
+		{query: "INSERT INTO users VALUES (", want: &writeTargets{unknown: true}},
+		{query: "SELECT FROM", want: nil},
+	}
+	for _, tt := range tests {
+		if got := parseWriteTargets(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseWriteTargets(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestWithWriteHook(t *testing.T) {
+	var mu sync.Mutex
+	var calls [][]string
+	connector, err := NewConnector(newStatsServer(t), WithWriteHook(func(tables []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, tables)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO users (name) VALUES (?)", "ana"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{"DELETE FROM posts", "UPDATE users SET name = 'bob'", "DELETE FROM posts"} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.BeginTx(WithBufferedTransaction(ctx), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO tags VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"users"}, {"posts", "users"}, {"tags"}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got hook calls %q, want %q", calls, want)
+	}
+}
@@ -0,0 +1,363 @@
+package libsql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/internal/hrana"
+)
+
+// ErrQueued is wrapped by the errors of the writes WriteQueue.Exec journaled
+// instead of running them, because the database was unreachable or earlier
+// writes are still queued. They run when the queue is replayed.
+var ErrQueued = errors.New("write queued until the database is reachable")
+
+// QueuedWrite is a write of a WriteQueue.
+type QueuedWrite struct {
+	Query string
+	// Args are the arguments of the write, with sql.NamedArg for named ones.
+	Args     []any
+	QueuedAt time.Time
+}
+
+type WriteQueueOptions struct {
+	// OnConflict, if set, is called with the queued writes that violated a
+	// constraint when replayed, like a unique index conflicting with a row
+	// written while the client was offline, see IsConstraint. They are removed
+	// from the queue.
+	OnConflict func(w QueuedWrite, err error)
+	// OnFailure, if set, is called with the queued writes that failed when
+	// replayed with an error that would fail them again on every replay, like
+	// a missing table or a syntax error. They are removed from the queue.
+	OnFailure func(w QueuedWrite, err error)
+}
+
+// ReplayResult is the outcome of WriteQueue.Replay.
+type ReplayResult struct {
+	// Applied is the number of queued writes that ran.
+	Applied int
+	// Conflicts is the number of queued writes that violated a constraint
+	// and were dropped.
+	Conflicts int
+	// Failed is the number of queued writes that failed with other errors
+	// that are not transient and were dropped.
+	Failed int
+	// Pending is the number of writes left in the queue.
+	Pending int
+}
+
+// WriteQueue keeps writes that cannot reach the database in a journal file
+// and runs them in order once it can be reached again, for clients that must
+// accept writes while offline, like devices at the edge. Writes are replayed
+// at least once: a write whose answer was lost, because the connection dropped
+// or the process stopped during a replay, runs again on the next one, so queue
+// writes that can safely run twice. A WriteQueue is safe for concurrent use,
+// but a journal file must only be opened by one WriteQueue at a time.
+type WriteQueue struct {
+	path string
+	opts WriteQueueOptions
+
+	mu      sync.Mutex
+	pending []QueuedWrite
+	journal *os.File
+}
+
+// journalEntry is a line of the journal file of a WriteQueue.
+type journalEntry struct {
+	Query    string         `json:"sql"`
+	Args     []journalValue `json:"args,omitempty"`
+	QueuedAt time.Time      `json:"queued_at"`
+}
+
+// journalValue is an argument of a journaled write, encoded like Hrana values
+// with the booleans and times the driver converts when the write runs.
+type journalValue struct {
+	Name string `json:"name,omitempty"`
+	hrana.Value
+}
+
+// OpenWriteQueue opens the queue journaled at path, creating the file if it
+// does not exist. The writes queued by a previous process are kept, except a
+// last write that was not fully written.
+func OpenWriteQueue(path string, opts *WriteQueueOptions) (*WriteQueue, error) {
+	if opts == nil {
+		opts = &WriteQueueOptions{}
+	}
+	q := &WriteQueue{path: path, opts: *opts}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for idx, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		w, err := decodeJournalEntry(line)
+		if err != nil {
+			if idx == len(lines)-1 {
+				// The process stopped while appending it.
+				break
+			}
+			return nil, fmt.Errorf("corrupt write queue %s, line %d: %w", path, idx+1, err)
+		}
+		q.pending = append(q.pending, w)
+	}
+	if err := q.rewrite(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Len returns the number of queued writes.
+func (q *WriteQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Exec runs the write on db like db.ExecContext, after replaying the queued
+// writes. If the database is unreachable, or queued writes remain, the write
+// is appended to the queue instead and Exec returns an error wrapping
+// ErrQueued. Other errors are returned as is and the write is not queued.
+func (q *WriteQueue) Exec(ctx context.Context, db *sql.DB, query string, args ...any) (sql.Result, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) > 0 {
+		if _, err := q.replay(ctx, db); err != nil {
+			if err := q.append(query, args); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %d earlier writes are pending: %w", ErrQueued, len(q.pending)-1, err)
+		}
+	}
+	res, err := db.ExecContext(ctx, query, args...)
+	if errors.Is(err, ErrUnreachable) {
+		if err := q.append(query, args); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrQueued, err)
+	}
+	return res, err
+}
+
+// Replay runs the queued writes on db in order, removing them from the queue.
+// Writes violating a constraint are passed to OnConflict and dropped, writes
+// failing with other errors that are not transient are passed to OnFailure and
+// dropped. The replay stops at the first transient error, like an unreachable,
+// busy or locked database or a canceled context, and returns it with the write
+// that failed and those after it still queued.
+func (q *WriteQueue) Replay(ctx context.Context, db *sql.DB) (ReplayResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.replay(ctx, db)
+}
+
+func (q *WriteQueue) replay(ctx context.Context, db *sql.DB) (ReplayResult, error) {
+	var result ReplayResult
+	var err error
+	done := 0
+	for _, w := range q.pending {
+		if _, err = db.ExecContext(ctx, w.Query, w.Args...); err != nil {
+			// Writes failing with transient errors stay queued, others would
+			// fail again on every replay and block the writes behind them.
+			if ctx.Err() != nil || isTransient(err) {
+				break
+			}
+			if IsConstraint(err) {
+				result.Conflicts++
+				if q.opts.OnConflict != nil {
+					q.opts.OnConflict(w, err)
+				}
+			} else {
+				result.Failed++
+				if q.opts.OnFailure != nil {
+					q.opts.OnFailure(w, err)
+				}
+			}
+			err = nil
+		} else {
+			result.Applied++
+		}
+		done++
+	}
+	if done > 0 {
+		q.pending = q.pending[done:]
+		if rewriteErr := q.rewrite(); rewriteErr != nil && err == nil {
+			err = rewriteErr
+		}
+	}
+	result.Pending = len(q.pending)
+	return result, err
+}
+
+// isTransient reports whether a write that failed with err may succeed when
+// replayed later.
+func isTransient(err error) bool {
+	return errors.Is(err, ErrUnreachable) || IsRetryable(err) || IsBusy(err) || IsLocked(err) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Close closes the journal file. The queued writes stay in it.
+func (q *WriteQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.journal == nil {
+		return nil
+	}
+	err := q.journal.Close()
+	q.journal = nil
+	return err
+}
+
+// append journals a write and queues it.
+func (q *WriteQueue) append(query string, args []any) error {
+	line, err := encodeJournalEntry(QueuedWrite{Query: query, Args: args, QueuedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	// Keep the arguments as they will be replayed, not values the caller may
+	// still change.
+	w, err := decodeJournalEntry(line)
+	if err != nil {
+		return err
+	}
+	if q.journal == nil {
+		return fmt.Errorf("write queue %s is closed", q.path)
+	}
+	if _, err := q.journal.Write(line); err != nil {
+		return err
+	}
+	if err := q.journal.Sync(); err != nil {
+		return err
+	}
+	q.pending = append(q.pending, w)
+	return nil
+}
+
+// rewrite replaces the journal file with the pending writes, through a
+// temporary file renamed into place so that a crash leaves either journal
+// whole, and reopens it for appending.
+func (q *WriteQueue) rewrite() error {
+	if q.journal != nil {
+		q.journal.Close()
+		q.journal = nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	buf := bufio.NewWriter(tmp)
+	for _, w := range q.pending {
+		line, err := encodeJournalEntry(w)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		buf.Write(line)
+	}
+	if err := buf.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		return err
+	}
+	q.journal, err = os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0)
+	return err
+}
+
+func encodeJournalEntry(w QueuedWrite) ([]byte, error) {
+	entry := journalEntry{Query: w.Query, QueuedAt: w.QueuedAt}
+	for _, arg := range w.Args {
+		var jv journalValue
+		if named, ok := arg.(sql.NamedArg); ok {
+			jv.Name, arg = named.Name, named.Value
+		}
+		value, err := encodeJournalValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot queue argument %d of %q: %w", len(entry.Args)+1, w.Query, err)
+		}
+		jv.Value = value
+		entry.Args = append(entry.Args, jv)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func encodeJournalValue(v any) (hrana.Value, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return hrana.Value{}, err
+		}
+		v = value
+	}
+	switch v := v.(type) {
+	case bool:
+		return hrana.Value{Type: "bool", Value: v}, nil
+	case time.Time:
+		return hrana.Value{Type: "time", Value: v.Format(time.RFC3339Nano)}, nil
+	case nil, int64, float64, string, []byte:
+		return hrana.ToValue(v)
+	}
+	value, err := driver.DefaultParameterConverter.ConvertValue(v)
+	if err != nil {
+		return hrana.Value{}, err
+	}
+	switch value.(type) {
+	case nil, int64, float64, string, []byte, bool, time.Time:
+		return encodeJournalValue(value)
+	}
+	return hrana.Value{}, fmt.Errorf("unsupported type %T", v)
+}
+
+func decodeJournalEntry(line []byte) (QueuedWrite, error) {
+	var entry journalEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return QueuedWrite{}, err
+	}
+	w := QueuedWrite{Query: entry.Query, QueuedAt: entry.QueuedAt}
+	for _, jv := range entry.Args {
+		var arg any
+		switch jv.Type {
+		case "bool":
+			arg, _ = jv.Value.Value.(bool)
+		case "time":
+			text, _ := jv.Value.Value.(string)
+			t, err := time.Parse(time.RFC3339Nano, text)
+			if err != nil {
+				return QueuedWrite{}, err
+			}
+			arg = t
+		default:
+			arg = jv.Value.ToValue()
+		}
+		if jv.Name != "" {
+			arg = sql.Named(jv.Name, arg)
+		}
+		w.Args = append(w.Args, arg)
+	}
+	return w, nil
+}
@@ -0,0 +1,206 @@
+package libsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libsql/libsql-client-go/libsql/libsqltest"
+)
+
+// newQueueServer returns a fake sqld server recording the statements it
+// executes with their arguments, failing those containing "conflicts" with
+// SQLITE_CONSTRAINT_UNIQUE, those containing "busy" with SQLITE_BUSY and those
+// containing "missing" with a missing table.
+func newQueueServer(t *testing.T, mu *sync.Mutex, executed *[]string) string {
+	return libsqltest.NewFuncServer(t, func(ctx context.Context, query string, args []driver.NamedValue) (*libsqltest.Result, error) {
+		mu.Lock()
+		*executed = append(*executed, libsqltest.Statement(query, args))
+		mu.Unlock()
+		switch {
+		case strings.Contains(query, "conflicts"):
+			return nil, &libsqltest.Error{Code: "SQLITE_CONSTRAINT_UNIQUE", Message: "UNIQUE constraint failed: t.a"}
+		case strings.Contains(query, "busy"):
+			return nil, &libsqltest.Error{Code: "SQLITE_BUSY", Message: "database is locked"}
+		case strings.Contains(query, "missing"):
+			return nil, &libsqltest.Error{Code: "SQLITE_ERROR", Message: "no such table: missing"}
+		}
+		return &libsqltest.Result{Affected: 1}, nil
+	}).URL
+}
+
+func TestWriteQueue(t *testing.T) {
+	ctx := context.Background()
+	offline, err := sql.Open("libsql", closedServerURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer offline.Close()
+	var mu sync.Mutex
+	var executed []string
+	online, err := sql.Open("libsql", newQueueServer(t, &mu, &executed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer online.Close()
+
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	var conflicts []QueuedWrite
+	opts := &WriteQueueOptions{OnConflict: func(w QueuedWrite, err error) {
+		if !IsUniqueViolation(err) {
+			t.Errorf("got %v, want a unique violation", err)
+		}
+		conflicts = append(conflicts, w)
+	}}
+	q, err := OpenWriteQueue(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	writes := [][]any{
+		{"INSERT INTO t VALUES (?, ?, ?)", 1, true, []byte("blob")},
+		{"INSERT INTO conflicts VALUES (:a)", sql.Named("a", "x")},
+		{"UPDATE t SET at = ?", at},
+	}
+	for _, w := range writes {
+		_, err := q.Exec(ctx, offline, w[0].(string), w[1:]...)
+		if !errors.Is(err, ErrQueued) || !errors.Is(err, ErrUnreachable) {
+			t.Fatalf("got %v, want the write queued", err)
+		}
+	}
+	if _, err := q.Exec(ctx, offline, "INSERT INTO t VALUES (?)", struct{}{}); errors.Is(err, ErrQueued) || err == nil {
+		t.Fatalf("got %v, want an unsupported argument error", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A write the process stopped appending is dropped when reopening.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"sql":"INSERT`)
+	f.Close()
+	q, err = OpenWriteQueue(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if q.Len() != 3 {
+		t.Fatalf("got %d queued writes, want 3", q.Len())
+	}
+
+	if res, err := q.Replay(ctx, offline); !errors.Is(err, ErrUnreachable) || res != (ReplayResult{Pending: 3}) {
+		t.Fatalf("got %+v, %v, want the replay to stop while unreachable", res, err)
+	}
+	if _, err := q.Exec(ctx, online, "DELETE FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"INSERT INTO t VALUES (?, ?, ?) 1 1 [98 108 111 98]",
+		"INSERT INTO conflicts VALUES (:a) a=x",
+		"UPDATE t SET at = ? 2024-01-02T03:04:05Z",
+		"DELETE FROM t",
+	}
+	mu.Lock()
+	if !reflect.DeepEqual(executed, want) {
+		t.Errorf("got %q, want %q", executed, want)
+	}
+	mu.Unlock()
+	if len(conflicts) != 1 || conflicts[0].Query != "INSERT INTO conflicts VALUES (:a)" ||
+		!reflect.DeepEqual(conflicts[0].Args, []any{sql.Named("a", "x")}) {
+		t.Errorf("got conflicts %+v", conflicts)
+	}
+	if q.Len() != 0 {
+		t.Errorf("got %d queued writes, want none", q.Len())
+	}
+	if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+		t.Errorf("got journal %q, %v, want it empty", data, err)
+	}
+
+	// A busy database stops the replay without dropping the write.
+	if _, err := q.Exec(ctx, offline, "UPDATE busy SET a = 1"); !errors.Is(err, ErrQueued) {
+		t.Fatalf("got %v, want the write queued", err)
+	}
+	res, err := q.Replay(ctx, online)
+	if !IsBusy(err) || res != (ReplayResult{Pending: 1}) {
+		t.Fatalf("got %+v, %v, want the replay to stop on the busy write", res, err)
+	}
+	if len(conflicts) != 1 || q.Len() != 1 {
+		t.Errorf("got %d conflicts and %d queued writes, want the busy write kept", len(conflicts), q.Len())
+	}
+}
+
+func TestWriteQueueFailure(t *testing.T) {
+	ctx := context.Background()
+	offline, err := sql.Open("libsql", closedServerURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer offline.Close()
+	var mu sync.Mutex
+	var executed []string
+	online, err := sql.Open("libsql", newQueueServer(t, &mu, &executed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer online.Close()
+
+	var failures []QueuedWrite
+	q, err := OpenWriteQueue(filepath.Join(t.TempDir(), "queue.jsonl"), &WriteQueueOptions{
+		OnConflict: func(w QueuedWrite, err error) { t.Errorf("got conflict %v", err) },
+		OnFailure: func(w QueuedWrite, err error) {
+			if !strings.Contains(err.Error(), "no such table") {
+				t.Errorf("got %v, want a missing table", err)
+			}
+			failures = append(failures, w)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	for _, query := range []string{"INSERT INTO missing VALUES (1)", "INSERT INTO t VALUES (1)"} {
+		if _, err := q.Exec(ctx, offline, query); !errors.Is(err, ErrQueued) {
+			t.Fatalf("got %v, want the write queued", err)
+		}
+	}
+	res, err := q.Replay(ctx, online)
+	if err != nil || res != (ReplayResult{Applied: 1, Failed: 1}) {
+		t.Fatalf("got %+v, %v, want the failing write dropped", res, err)
+	}
+	if len(failures) != 1 || failures[0].Query != "INSERT INTO missing VALUES (1)" {
+		t.Errorf("got failures %+v", failures)
+	}
+
+	// A failing write does not keep later ones queued.
+	if _, err := q.Exec(ctx, offline, "INSERT INTO missing VALUES (2)"); !errors.Is(err, ErrQueued) {
+		t.Fatalf("got %v, want the write queued", err)
+	}
+	if _, err := q.Exec(ctx, online, "INSERT INTO t VALUES (2)"); err != nil {
+		t.Fatalf("got %v, want the write to go through", err)
+	}
+	if len(failures) != 2 || q.Len() != 0 {
+		t.Errorf("got %d failures and %d queued writes, want the failing write dropped", len(failures), q.Len())
+	}
+	want := []string{
+		"INSERT INTO missing VALUES (1)",
+		"INSERT INTO t VALUES (1)",
+		"INSERT INTO missing VALUES (2)",
+		"INSERT INTO t VALUES (2)",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(executed, want) {
+		t.Errorf("got %q, want %q", executed, want)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build integration
+
+package http
+
+import (
+	"os"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/tests/sqldtest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(sqldtest.Main(m, "LIBSQL_TEST_HTTP_DB_URL", "http"))
+}
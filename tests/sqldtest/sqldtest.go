@@ -0,0 +1,131 @@
+// Package sqldtest runs the driver tests of a package against a sqld server
+// started in a Docker container, so they run without a hosted database.
+//
+// It drives the docker command line instead of a Docker client library, to
+// keep the dependencies of the module unchanged.
+package sqldtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultImage is the sqld image started by Start, replaced by the
+// LIBSQL_TEST_SQLD_IMAGE environment variable if set.
+const DefaultImage = "ghcr.io/libsql/sqld:latest"
+
+// Server is a sqld container.
+type Server struct {
+	id   string
+	host string
+}
+
+// Start starts a sqld container listening on a random port of the host and
+// waits until it answers requests.
+func Start(ctx context.Context) (*Server, error) {
+	image := os.Getenv("LIBSQL_TEST_SQLD_IMAGE")
+	if image == "" {
+		image = DefaultImage
+	}
+	out, err := docker(ctx, "run", "--detach", "--rm", "--publish", "127.0.0.1::8080", image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sqld: %w", err)
+	}
+	s := &Server{id: out}
+	out, err = docker(ctx, "port", s.id, "8080/tcp")
+	if err != nil {
+		s.Stop()
+		return nil, fmt.Errorf("failed to get the port of sqld: %w", err)
+	}
+	// Docker prints one address per line, of IPv4 and IPv6 listeners.
+	s.host = strings.SplitN(out, "\n", 2)[0]
+	if _, _, err := net.SplitHostPort(s.host); err != nil {
+		s.Stop()
+		return nil, fmt.Errorf("unexpected address of sqld %q: %w", s.host, err)
+	}
+	if err := s.wait(ctx); err != nil {
+		s.Stop()
+		return nil, err
+	}
+	return s, nil
+}
+
+// wait polls the health endpoint of the server until it answers.
+func (s *Server) wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", s.URL("http")+"/health", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("sqld did not start: %w", ctx.Err())
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// URL returns the URL of the server for scheme, http or ws.
+func (s *Server) URL(scheme string) string {
+	return scheme + "://" + s.host
+}
+
+// Stop removes the container.
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err := docker(ctx, "rm", "--force", s.id)
+	return err
+}
+
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, msg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Main runs the tests of m, from TestMain, with the environment variable env set
+// to the URL with scheme of a sqld container started for them. The tests use
+// the server of env instead when it is already set. Main returns the exit code
+// to pass to os.Exit.
+func Main(m *testing.M, env, scheme string) int {
+	if os.Getenv(env) != "" {
+		return m.Run()
+	}
+	s, err := Start(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer s.Stop()
+	if err := os.Setenv(env, s.URL(scheme)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return m.Run()
+}
@@ -0,0 +1,14 @@
+//go:build integration
+
+package ws
+
+import (
+	"os"
+	"testing"
+
+	"github.com/libsql/libsql-client-go/tests/sqldtest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(sqldtest.Main(m, "LIBSQL_TEST_WS_DB_URL", "ws"))
+}